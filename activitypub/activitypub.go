@@ -0,0 +1,206 @@
+// Package activitypub implements the parts of ActivityPub, WebFinger and
+// HTTP Signatures needed to publish quotes to the fediverse: actor
+// documents, notes and their activities, and signed server-to-server
+// requests.
+package activitypub
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"mime"
+	"strings"
+	"time"
+
+	"quote-vault/models"
+)
+
+// ContentType is the media type of ActivityPub documents
+const ContentType = "application/activity+json"
+
+// Contexts and addresses with a fixed meaning in ActivityStreams
+const (
+	ActivityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	SecurityContext        = "https://w3id.org/security/v1"
+	// Public addresses an activity to everyone
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+// Activity types the service sends or handles
+const (
+	TypeCreate = "Create"
+	TypeUpdate = "Update"
+	TypeDelete = "Delete"
+	TypeFollow = "Follow"
+	TypeAccept = "Accept"
+	TypeUndo   = "Undo"
+)
+
+// AcceptsActivity reports whether an Accept header asks for an ActivityPub
+// document rather than a web page
+func AcceptsActivity(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if mediaType == ContentType {
+			return true
+		}
+		if mediaType == "application/ld+json" && params["profile"] == ActivityStreamsContext {
+			return true
+		}
+	}
+	return false
+}
+
+// Actor is the document describing an account
+type Actor struct {
+	Context           []string   `json:"@context"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	PublicKey         *PublicKey `json:"publicKey,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+}
+
+// PublicKey is the key an actor signs its requests with
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Endpoints holds an actor's optional server-wide endpoints
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+// DeliveryInbox returns the inbox to deliver to: the shared one when the
+// actor's server has one, so a server gets each activity once
+func (a *Actor) DeliveryInbox() string {
+	if a.Endpoints != nil && a.Endpoints.SharedInbox != "" {
+		return a.Endpoints.SharedInbox
+	}
+	return a.Inbox
+}
+
+// Note is a short post; each quote is published as one
+type Note struct {
+	Context      string   `json:"@context,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo,omitempty"`
+	Content      string   `json:"content,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	Published    string   `json:"published,omitempty"`
+	Updated      string   `json:"updated,omitempty"`
+	To           []string `json:"to,omitempty"`
+	Cc           []string `json:"cc,omitempty"`
+}
+
+// NewNote returns the note publishing a quote. id is the note's own URL,
+// actor the publishing actor and link the quote's web page.
+func NewNote(quote *models.Quote, id, actor, followers, link string) *Note {
+	lines := strings.Split(html.EscapeString(quote.Text), "\n")
+	content := "<p>“" + strings.Join(lines, "<br>") + "”</p><p>— " + html.EscapeString(quote.Author)
+	if quote.Source != "" {
+		content += ", <em>" + html.EscapeString(quote.Source) + "</em>"
+	}
+	content += "</p>"
+
+	return &Note{
+		ID:           id,
+		Type:         "Note",
+		AttributedTo: actor,
+		Content:      content,
+		URL:          link,
+		Published:    quote.CreatedAt.UTC().Format(time.RFC3339),
+		To:           []string{Public},
+		Cc:           []string{followers},
+	}
+}
+
+// Tombstone stands in for a deleted note
+type Tombstone struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// NewTombstone returns the tombstone of a deleted note
+func NewTombstone(id string) *Tombstone {
+	return &Tombstone{ID: id, Type: "Tombstone"}
+}
+
+// Activity is an activity as sent by the service
+type Activity struct {
+	Context   string      `json:"@context,omitempty"`
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Actor     string      `json:"actor"`
+	Object    interface{} `json:"object"`
+	Published string      `json:"published,omitempty"`
+	To        []string    `json:"to,omitempty"`
+	Cc        []string    `json:"cc,omitempty"`
+}
+
+// IncomingActivity is an activity received in an inbox. Its object may be
+// a bare ID or an embedded object.
+type IncomingActivity struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// ObjectID returns the ID of the activity's object
+func (a *IncomingActivity) ObjectID() string {
+	var id string
+	if json.Unmarshal(a.Object, &id) == nil {
+		return id
+	}
+	var object struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(a.Object, &object)
+	return object.ID
+}
+
+// Embedded decodes the object as an activity, for activities such as Undo
+// that act on another one
+func (a *IncomingActivity) Embedded() (*IncomingActivity, error) {
+	var inner IncomingActivity
+	if err := json.Unmarshal(a.Object, &inner); err != nil || inner.Type == "" {
+		return nil, fmt.Errorf("%s activity has no embedded activity", a.Type)
+	}
+	return &inner, nil
+}
+
+// OrderedCollection is a collection such as an outbox or follower list.
+// Its items are served by pages starting at First.
+type OrderedCollection struct {
+	Context    string `json:"@context"`
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	TotalItems int    `json:"totalItems"`
+	First      string `json:"first,omitempty"`
+	Last       string `json:"last,omitempty"`
+}
+
+// OrderedCollectionPage is one page of an OrderedCollection
+type OrderedCollectionPage struct {
+	Context      string        `json:"@context"`
+	ID           string        `json:"id"`
+	Type         string        `json:"type"`
+	PartOf       string        `json:"partOf"`
+	TotalItems   int           `json:"totalItems"`
+	OrderedItems []interface{} `json:"orderedItems"`
+	Next         string        `json:"next,omitempty"`
+	Prev         string        `json:"prev,omitempty"`
+}
@@ -0,0 +1,183 @@
+package activitypub
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func TestSignAndVerifyRequest(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	pem, err := EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("EncodePublicKey() error = %v", err)
+	}
+	public, err := DecodePublicKey(pem)
+	if err != nil {
+		t.Fatalf("DecodePublicKey() error = %v", err)
+	}
+	decoded, err := DecodePrivateKey(EncodePrivateKey(key))
+	if err != nil || !decoded.Equal(key) {
+		t.Fatalf("DecodePrivateKey() did not round-trip: %v", err)
+	}
+
+	now := time.Now()
+	body := []byte(`{"type":"Follow"}`)
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "https://quotes.example/ap/actors/quotes/inbox", bytes.NewReader(body))
+		if err := SignRequest(req, "https://remote.example/users/ann#main-key", key, body, now); err != nil {
+			t.Fatalf("SignRequest() error = %v", err)
+		}
+		return req
+	}
+
+	sig, err := ParseSignature(newRequest())
+	if err != nil {
+		t.Fatalf("ParseSignature() error = %v", err)
+	}
+	if sig.KeyID != "https://remote.example/users/ann#main-key" {
+		t.Errorf("KeyID = %q", sig.KeyID)
+	}
+	if err := VerifyRequest(newRequest(), sig, body, public, now); err != nil {
+		t.Errorf("VerifyRequest() of a signed request = %v", err)
+	}
+
+	other, _ := GenerateKey()
+	tests := []struct {
+		name   string
+		change func(req *http.Request) ([]byte, time.Time)
+		key    bool
+		want   error
+	}{
+		{"altered body", func(req *http.Request) ([]byte, time.Time) { return []byte(`{"type":"Undo"}`), now }, false, ErrBadDigest},
+		{"altered path", func(req *http.Request) ([]byte, time.Time) {
+			req.URL.Path = "/ap/actors/other/inbox"
+			return body, now
+		}, false, ErrBadSignature},
+		{"altered digest", func(req *http.Request) ([]byte, time.Time) {
+			other := []byte(`{}`)
+			req.Header.Set("Digest", Digest(other))
+			return other, now
+		}, false, ErrBadSignature},
+		{"stale", func(req *http.Request) ([]byte, time.Time) { return body, now.Add(2 * MaxClockSkew) }, false, ErrStaleRequest},
+		{"other key", func(req *http.Request) ([]byte, time.Time) { return body, now }, true, ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest()
+			reqBody, at := tt.change(req)
+			verifyKey := public
+			if tt.key {
+				verifyKey = &other.PublicKey
+			}
+			if err := VerifyRequest(req, sig, reqBody, verifyKey, at); err != tt.want {
+				t.Errorf("VerifyRequest() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("uncovered digest", func(t *testing.T) {
+		req := newRequest()
+		partial := *sig
+		partial.Headers = []string{"(request-target)", "host", "date"}
+		if err := VerifyRequest(req, &partial, body, public, now); err == nil {
+			t.Error("VerifyRequest() accepted a signature that does not cover the digest")
+		}
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "https://quotes.example/ap/actors/quotes/inbox", nil)
+		if _, err := ParseSignature(req); err != ErrMissingSignature {
+			t.Errorf("ParseSignature() = %v, want %v", err, ErrMissingSignature)
+		}
+	})
+}
+
+func TestParseAccount(t *testing.T) {
+	tests := []struct {
+		resource   string
+		user, host string
+		wantErr    bool
+	}{
+		{"acct:quotes@example.com", "quotes", "example.com", false},
+		{"quotes@example.com:8080", "quotes", "example.com:8080", false},
+		{"@philosophy@example.com", "philosophy", "example.com", false},
+		{"acct:quotes", "", "", true},
+		{"acct:@example.com", "", "", true},
+		{"acct:quotes@", "", "", true},
+	}
+	for _, tt := range tests {
+		user, host, err := ParseAccount(tt.resource)
+		if (err != nil) != tt.wantErr || user != tt.user || host != tt.host {
+			t.Errorf("ParseAccount(%q) = %q, %q, %v", tt.resource, user, host, err)
+		}
+	}
+}
+
+func TestAcceptsActivity(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"application/activity+json", true},
+		{`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, true},
+		{"text/html, application/activity+json;q=0.9", true},
+		{"application/ld+json", false},
+		{"application/json", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := AcceptsActivity(tt.accept); got != tt.want {
+			t.Errorf("AcceptsActivity(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestNewNote(t *testing.T) {
+	quote := &models.Quote{
+		ID:        3,
+		Text:      "Less <is> more\nand more",
+		Author:    "Ann & Bob",
+		Source:    "Essays",
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+	note := NewNote(quote, "https://q.example/ap/actors/quotes/notes/3", "https://q.example/ap/actors/quotes",
+		"https://q.example/ap/actors/quotes/followers", "https://q.example/q/3")
+
+	want := "<p>“Less &lt;is&gt; more<br>and more”</p><p>— Ann &amp; Bob, <em>Essays</em></p>"
+	if note.Content != want {
+		t.Errorf("Content = %q, want %q", note.Content, want)
+	}
+	if note.Published != "2024-05-01T12:00:00Z" {
+		t.Errorf("Published = %q", note.Published)
+	}
+	if len(note.To) != 1 || note.To[0] != Public || len(note.Cc) != 1 || !strings.HasSuffix(note.Cc[0], "/followers") {
+		t.Errorf("addressed to %v, cc %v", note.To, note.Cc)
+	}
+}
+
+func TestIncomingActivity(t *testing.T) {
+	bare := &IncomingActivity{Type: TypeFollow, Object: []byte(`"https://q.example/ap/actors/quotes"`)}
+	if got := bare.ObjectID(); got != "https://q.example/ap/actors/quotes" {
+		t.Errorf("ObjectID() of a bare ID = %q", got)
+	}
+
+	undo := &IncomingActivity{Type: TypeUndo, Object: []byte(`{"id":"https://r.example/f/1","type":"Follow","actor":"https://r.example/ann"}`)}
+	if got := undo.ObjectID(); got != "https://r.example/f/1" {
+		t.Errorf("ObjectID() of an embedded object = %q", got)
+	}
+	inner, err := undo.Embedded()
+	if err != nil || inner.Type != TypeFollow || inner.Actor != "https://r.example/ann" {
+		t.Errorf("Embedded() = %+v, %v", inner, err)
+	}
+	if _, err := bare.Embedded(); err == nil {
+		t.Error("Embedded() of a bare ID succeeded")
+	}
+}
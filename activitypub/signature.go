@@ -0,0 +1,221 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxClockSkew is how far the Date of a signed request may be from the
+// current time. Older requests are refused, so a captured request cannot
+// be replayed later.
+const MaxClockSkew = time.Hour
+
+// Reasons VerifyRequest refuses a request
+var (
+	ErrMissingSignature = errors.New("missing or malformed Signature header")
+	ErrStaleRequest     = errors.New("request date is outside the allowed window")
+	ErrBadDigest        = errors.New("Digest header does not match the body")
+	ErrBadSignature     = errors.New("request signature does not match")
+)
+
+// Signature is a parsed Signature header
+type Signature struct {
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+}
+
+// Digest returns the Digest header value of a body
+func Digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// SignRequest signs a request with the key identified by keyID, setting
+// its Date header, and its Digest header when there is a body. The
+// signature covers the method and path, Host, Date and Digest.
+func SignRequest(req *http.Request, keyID string, key *rsa.PrivateKey, body []byte, now time.Time) error {
+	headers := []string{"(request-target)", "host", "date"}
+	req.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+	if body != nil {
+		req.Header.Set("Digest", Digest(body))
+		headers = append(headers, "digest")
+	}
+
+	hash := sha256.Sum256([]byte(signingString(req, headers)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// ParseSignature parses the Signature header of a request
+func ParseSignature(req *http.Request) (*Signature, error) {
+	header := req.Header.Get("Signature")
+	if header == "" {
+		return nil, ErrMissingSignature
+	}
+
+	sig := &Signature{Headers: []string{"date"}}
+	for _, part := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, ErrMissingSignature
+		}
+		value = strings.Trim(value, `"`)
+		switch name {
+		case "keyId":
+			sig.KeyID = value
+		case "algorithm":
+			sig.Algorithm = value
+		case "headers":
+			sig.Headers = strings.Fields(strings.ToLower(value))
+		case "signature":
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, ErrMissingSignature
+			}
+			sig.Signature = decoded
+		}
+	}
+	if sig.KeyID == "" || sig.Signature == nil {
+		return nil, ErrMissingSignature
+	}
+	return sig, nil
+}
+
+// VerifyRequest checks a signed request against the signer's public key.
+// The signature must cover the method and path, Host and Date, and the
+// Digest when there is a body; the Date must be within MaxClockSkew of
+// now and the Digest must match the body.
+func VerifyRequest(req *http.Request, sig *Signature, body []byte, key *rsa.PublicKey, now time.Time) error {
+	if sig.Algorithm != "" && sig.Algorithm != "rsa-sha256" && sig.Algorithm != "hs2019" {
+		return fmt.Errorf("unsupported signature algorithm %q", sig.Algorithm)
+	}
+	required := []string{"(request-target)", "host", "date"}
+	if len(body) > 0 {
+		required = append(required, "digest")
+	}
+	for _, name := range required {
+		if !containsHeader(sig.Headers, name) {
+			return fmt.Errorf("signature does not cover %s", name)
+		}
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return ErrStaleRequest
+	}
+	if skew := now.Sub(date); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return ErrStaleRequest
+	}
+	if len(body) > 0 && req.Header.Get("Digest") != Digest(body) {
+		return ErrBadDigest
+	}
+
+	hash := sha256.Sum256([]byte(signingString(req, sig.Headers)))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig.Signature) != nil {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// signingString builds the string a signature covers from the named
+// headers
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, name := range headers {
+		var value string
+		switch name {
+		case "(request-target)":
+			value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		default:
+			value = strings.Join(req.Header.Values(name), ", ")
+		}
+		lines[i] = name + ": " + value
+	}
+	return strings.Join(lines, "\n")
+}
+
+func containsHeader(headers []string, name string) bool {
+	for _, header := range headers {
+		if header == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateKey returns a new key for signing requests
+func GenerateKey() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+// EncodePrivateKey returns a private key in PEM form
+func EncodePrivateKey(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+// DecodePrivateKey parses a private key in the PEM form EncodePrivateKey
+// writes
+func DecodePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM data in private key")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// EncodePublicKey returns a public key in the PEM form actor documents use
+func EncodePublicKey(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// DecodePublicKey parses the publicKeyPem of an actor document
+func DecodePublicKey(data string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM data in public key")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return key, nil
+}
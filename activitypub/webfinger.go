@@ -0,0 +1,47 @@
+package activitypub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WebFingerContentType is the media type of WebFinger responses
+const WebFingerContentType = "application/jrd+json"
+
+// JRD is a WebFinger response
+type JRD struct {
+	Subject string   `json:"subject"`
+	Aliases []string `json:"aliases,omitempty"`
+	Links   []Link   `json:"links"`
+}
+
+// Link is a link of a WebFinger response
+type Link struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// ParseAccount splits a WebFinger resource such as acct:quotes@example.com
+// into the user name and host. The acct: scheme is optional.
+func ParseAccount(resource string) (user, host string, err error) {
+	account := strings.TrimPrefix(resource, "acct:")
+	account = strings.TrimPrefix(account, "@")
+	i := strings.LastIndex(account, "@")
+	if i <= 0 || i == len(account)-1 {
+		return "", "", fmt.Errorf("resource must be an account such as acct:quotes@example.com")
+	}
+	return account[:i], account[i+1:], nil
+}
+
+// NewJRD describes an actor for WebFinger. profile is the actor's web page.
+func NewJRD(user, host, actorID, profile string) *JRD {
+	return &JRD{
+		Subject: "acct:" + user + "@" + host,
+		Aliases: []string{actorID},
+		Links: []Link{
+			{Rel: "self", Type: ContentType, Href: actorID},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: profile},
+		},
+	}
+}
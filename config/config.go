@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -11,6 +12,56 @@ type Config struct {
 	LogLevel   string
 	PageSize   int
 	CORSOrigin string
+	// CardCacheSize is the number of rendered PNG cards kept in memory
+	CardCacheSize int
+	// BaseURL is the public URL of the service, used for links in feeds.
+	// When empty it is worked out from each request.
+	BaseURL string
+	// CuratorPassword unlocks creating and editing quotes in the web UI,
+	// and authenticates webhook management in the API. When empty the web
+	// UI is read-only and webhooks cannot be managed.
+	CuratorPassword string
+	// SessionSecret signs web UI session cookies. When empty a random
+	// secret is generated at startup, which signs curators out on restart.
+	SessionSecret string
+	// EventRetention is how long events are kept for resuming event
+	// streams
+	EventRetention time.Duration
+	// TombstoneRetention is how long deleted quotes stay in the changes
+	// feed before clients that have not synced since must resync in full
+	TombstoneRetention time.Duration
+	// ReplicateFrom is the base URL of the leader this instance follows.
+	// When empty the instance is not a follower.
+	ReplicateFrom string
+	// ReplicationConflicts is how a follower handles local writes:
+	// "reject" or "last-writer-wins"
+	ReplicationConflicts string
+	// ReplicationInterval is the wait between checks for new changes
+	ReplicationInterval time.Duration
+	// ReplicationMaxLag is how far behind a follower may fall before its
+	// health check reports it as lagging
+	ReplicationMaxLag time.Duration
+	// SlackSigningSecret verifies requests from the Slack app's /quote
+	// command. When empty the Slack endpoint is not served.
+	SlackSigningSecret string
+	// DiscordPublicKey is the hex public key of the Discord application
+	// whose interactions are answered. When empty the Discord endpoint is
+	// not served.
+	DiscordPublicKey string
+	// ActivityPub publishes the vault and its categories as ActivityPub
+	// actors. It requires BaseURL.
+	ActivityPub bool
+	// SMTPHost is the mail server that email digests and saved search
+	// emails are sent through. When empty neither is offered. Both
+	// require BaseURL.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPFrom is the sender address of emails
+	SMTPFrom string
+	// DigestSendHour is the hour, UTC, at which digests are sent
+	DigestSendHour int
 }
 
 func Load() *Config {
@@ -19,12 +70,77 @@ func Load() *Config {
 		pageSize = 10
 	}
 
+	cardCacheSize, err := strconv.Atoi(getEnv("CARD_CACHE_SIZE", "256"))
+	if err != nil {
+		cardCacheSize = 256
+	}
+
+	eventRetention, err := time.ParseDuration(getEnv("EVENT_RETENTION", "168h"))
+	if err != nil {
+		eventRetention = 168 * time.Hour
+	}
+
+	tombstoneRetention, err := time.ParseDuration(getEnv("TOMBSTONE_RETENTION", "720h"))
+	if err != nil {
+		tombstoneRetention = 720 * time.Hour
+	}
+
+	replicationInterval, err := time.ParseDuration(getEnv("REPLICATION_INTERVAL", "5s"))
+	if err != nil {
+		replicationInterval = 5 * time.Second
+	}
+
+	replicationMaxLag, err := time.ParseDuration(getEnv("REPLICATION_MAX_LAG", "1m"))
+	if err != nil {
+		replicationMaxLag = time.Minute
+	}
+
+	activityPub, err := strconv.ParseBool(getEnv("ACTIVITYPUB_ENABLED", "false"))
+	if err != nil {
+		activityPub = false
+	}
+
+	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil {
+		smtpPort = 587
+	}
+
+	digestSendHour, err := strconv.Atoi(getEnv("DIGEST_SEND_HOUR", "8"))
+	if err != nil {
+		digestSendHour = 8
+	}
+
 	return &Config{
-		Port:       getEnv("PORT", "8080"),
-		DBPath:     getEnv("DB_PATH", "./quotes.db"),
-		LogLevel:   getEnv("LOG_LEVEL", "info"),
-		PageSize:   pageSize,
-		CORSOrigin: getEnv("CORS_ORIGIN", "*"),
+		Port:          getEnv("PORT", "8080"),
+		DBPath:        getEnv("DB_PATH", "./quotes.db"),
+		LogLevel:      getEnv("LOG_LEVEL", "info"),
+		PageSize:      pageSize,
+		CORSOrigin:    getEnv("CORS_ORIGIN", "*"),
+		CardCacheSize: cardCacheSize,
+		BaseURL:       getEnv("BASE_URL", ""),
+
+		CuratorPassword: getEnv("CURATOR_PASSWORD", ""),
+		SessionSecret:   getEnv("SESSION_SECRET", ""),
+
+		EventRetention:     eventRetention,
+		TombstoneRetention: tombstoneRetention,
+
+		ReplicateFrom:        getEnv("REPLICATE_FROM", ""),
+		ReplicationConflicts: getEnv("REPLICATION_CONFLICTS", "reject"),
+		ReplicationInterval:  replicationInterval,
+		ReplicationMaxLag:    replicationMaxLag,
+
+		SlackSigningSecret: getEnv("SLACK_SIGNING_SECRET", ""),
+		DiscordPublicKey:   getEnv("DISCORD_PUBLIC_KEY", ""),
+
+		ActivityPub: activityPub,
+
+		SMTPHost:       getEnv("SMTP_HOST", ""),
+		SMTPPort:       smtpPort,
+		SMTPUsername:   getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:   getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:       getEnv("SMTP_FROM", ""),
+		DigestSendHour: digestSendHour,
 	}
 }
 
@@ -33,4 +149,4 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
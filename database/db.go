@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"quote-vault/models"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -11,8 +12,19 @@ type SQLiteDB struct {
 	db *sql.DB
 }
 
+// sqliteOptions make a connection wait up to 5 seconds for a lock held by
+// another one, instead of failing with "database is locked", and take the
+// write lock when a transaction begins rather than at its first write, so
+// two transactions that read before writing cannot deadlock. The API and
+// the background workers each write from their own goroutine.
+const sqliteOptions = "_busy_timeout=5000&_txlock=immediate"
+
 func NewSQLiteDB(dbPath string) (*SQLiteDB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	dsn := dbPath + "?" + sqliteOptions
+	if strings.Contains(dbPath, "?") {
+		dsn = dbPath + "&" + sqliteOptions
+	}
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -21,6 +33,12 @@ func NewSQLiteDB(dbPath string) (*SQLiteDB, error) {
 		return nil, err
 	}
 
+	// Every connection to ":memory:" opens its own empty database, so
+	// requests and background workers must share a single one
+	if dbPath == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
 	// Create tables if they don't exist
 	if err := createTables(db); err != nil {
 		return nil, err
@@ -30,15 +48,234 @@ func NewSQLiteDB(dbPath string) (*SQLiteDB, error) {
 }
 
 func createTables(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS quotes (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		text TEXT NOT NULL,
-		author TEXT NOT NULL,
-		category TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	)`
-	_, err := db.Exec(query)
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS quotes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			text TEXT NOT NULL,
+			author TEXT NOT NULL,
+			category TEXT NOT NULL,
+			source TEXT NOT NULL DEFAULT '',
+			tags TEXT NOT NULL DEFAULT '',
+			likes INTEGER NOT NULL DEFAULT 0,
+			version INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Supports duplicate detection during bulk imports
+		`CREATE INDEX IF NOT EXISTS idx_quotes_author_text ON quotes (author COLLATE NOCASE, text COLLATE NOCASE)`,
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			url TEXT NOT NULL,
+			events TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			previous_secret TEXT NOT NULL DEFAULT '',
+			previous_secret_expires_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// The persistent queue of webhook deliveries, kept after delivery
+		// as a log
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			event_id TEXT NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_status INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME,
+			last_attempt_at DATETIME,
+			delivered_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Every published event in order, so event streams can resume
+		`CREATE TABLE IF NOT EXISTS events (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			category TEXT NOT NULL DEFAULT '',
+			payload TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// The change log read by sync clients: one row per quote holding the
+		// sequence number of its latest change, kept up to date by the
+		// triggers below inside the transaction that makes the change.
+		// Deleted quotes leave a tombstone until it is pruned.
+		`CREATE TABLE IF NOT EXISTS quote_changes (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			quote_id INTEGER NOT NULL UNIQUE,
+			deleted INTEGER NOT NULL DEFAULT 0,
+			version INTEGER NOT NULL DEFAULT 0,
+			changed_at DATETIME
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS quotes_log_insert AFTER INSERT ON quotes BEGIN
+			INSERT OR REPLACE INTO quote_changes (quote_id, deleted, changed_at)
+			VALUES (NEW.id, 0, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS quotes_log_update AFTER UPDATE ON quotes BEGIN
+			INSERT OR REPLACE INTO quote_changes (quote_id, deleted, changed_at)
+			VALUES (NEW.id, 0, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+		END`,
+		// Quotes stored before the change log existed
+		`INSERT INTO quote_changes (quote_id, changed_at)
+			SELECT id, created_at FROM quotes WHERE id NOT IN (SELECT quote_id FROM quote_changes) ORDER BY id`,
+		// Small pieces of state such as how far the change log was pruned
+		`CREATE TABLE IF NOT EXISTS metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)`,
+		// Events recorded in the same transaction as the changes they
+		// describe, kept until every subscriber of the event bus has them
+		`CREATE TABLE IF NOT EXISTS outbox (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			created_at DATETIME
+		)`,
+		// Remote accounts following the vault or one of its categories over
+		// ActivityPub. actor is the name of the local actor followed.
+		`CREATE TABLE IF NOT EXISTS ap_followers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor TEXT NOT NULL,
+			follower TEXT NOT NULL,
+			inbox TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (actor, follower)
+		)`,
+		// Activities waiting to be posted to remote inboxes. An activity is
+		// queued once per inbox and removed once delivered.
+		`CREATE TABLE IF NOT EXISTS ap_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor TEXT NOT NULL,
+			inbox TEXT NOT NULL,
+			activity_id TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (inbox, activity_id)
+		)`,
+		// Email addresses subscribed to a digest of new quotes. Rows stay
+		// unconfirmed until the link in the confirmation email is followed.
+		`CREATE TABLE IF NOT EXISTS digest_subscriptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			email TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			frequency TEXT NOT NULL,
+			categories TEXT NOT NULL DEFAULT '',
+			confirm_token TEXT NOT NULL UNIQUE,
+			unsubscribe_token TEXT NOT NULL UNIQUE,
+			confirmed_at DATETIME,
+			last_sent_at DATETIME,
+			next_digest_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Searches to be notified about as matching quotes are added. Email
+		// searches only match once their address is confirmed.
+		`CREATE TABLE IF NOT EXISTS saved_searches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			category TEXT NOT NULL DEFAULT '',
+			author TEXT NOT NULL DEFAULT '',
+			query TEXT NOT NULL DEFAULT '',
+			webhook_url TEXT NOT NULL DEFAULT '',
+			secret TEXT NOT NULL DEFAULT '',
+			email TEXT NOT NULL DEFAULT '',
+			confirm_token TEXT NOT NULL DEFAULT '',
+			unsubscribe_token TEXT NOT NULL DEFAULT '',
+			confirmed_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// The history of quotes each saved search matched, which is also
+		// the queue of notifications about them
+		`CREATE TABLE IF NOT EXISTS saved_search_matches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			search_id INTEGER NOT NULL,
+			quote_id INTEGER NOT NULL,
+			quote TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME,
+			notified_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (search_id, quote_id)
+		)`,
+		// Lets an event delivered twice be logged once
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_events_event_id ON events (event_id)`,
+		// Supports polling for deliveries that are due
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_ap_deliveries_due ON ap_deliveries (next_attempt_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_digest_subscriptions_due ON digest_subscriptions (next_digest_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_saved_search_matches_due ON saved_search_matches (status, next_attempt_at)`,
+	}
+
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	// Columns added after the initial release, for databases created
+	// before they existed
+	columns := []struct {
+		table, name, definition string
+	}{
+		{"quotes", "source", "TEXT NOT NULL DEFAULT ''"},
+		{"quotes", "tags", "TEXT NOT NULL DEFAULT ''"},
+		{"quotes", "likes", "INTEGER NOT NULL DEFAULT 0"},
+		{"quotes", "version", "INTEGER NOT NULL DEFAULT 1"},
+		{"quote_changes", "version", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, column := range columns {
+		if err := addColumnIfMissing(db, column.table, column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
+	// A tombstone records the version of the deleted quote. The trigger is
+	// recreated so databases whose trigger predates that column get it too.
+	triggers := []string{
+		`DROP TRIGGER IF EXISTS quotes_log_delete`,
+		`CREATE TRIGGER quotes_log_delete AFTER DELETE ON quotes BEGIN
+			INSERT OR REPLACE INTO quote_changes (quote_id, deleted, version, changed_at)
+			VALUES (OLD.id, 1, OLD.version, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+		END`,
+	}
+	for _, query := range triggers {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is
+// already present
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
 	return err
 }
 
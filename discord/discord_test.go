@@ -0,0 +1,150 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func TestVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	key, err := ParsePublicKey(hex.EncodeToString(public))
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	if _, err := ParsePublicKey("not a key"); err == nil {
+		t.Error("ParsePublicKey() of an invalid key succeeded")
+	}
+
+	body := []byte(`{"type":1}`)
+	req, _ := http.NewRequest(http.MethodPost, "/integrations/discord/interactions", nil)
+	SignRequest(req, private, body, time.Now())
+	if err := Verify(key, req.Header, body); err != nil {
+		t.Errorf("Verify() of a signed request = %v", err)
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	tests := []struct {
+		name   string
+		key    ed25519.PublicKey
+		body   []byte
+		header func(http.Header)
+		want   error
+	}{
+		{"other key", other, body, nil, ErrBadSignature},
+		{"altered body", key, []byte(`{"type":2}`), nil, ErrBadSignature},
+		{"altered timestamp", key, body, func(h http.Header) { h.Set(TimestampHeader, "1") }, ErrBadSignature},
+		{"no signature", key, body, func(h http.Header) { h.Del(SignatureHeader) }, ErrMissingSignature},
+		{"no timestamp", key, body, func(h http.Header) { h.Del(TimestampHeader) }, ErrMissingSignature},
+		{"malformed signature", key, body, func(h http.Header) { h.Set(SignatureHeader, "abc") }, ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := req.Header.Clone()
+			if tt.header != nil {
+				tt.header(h)
+			}
+			if err := Verify(tt.key, h, tt.body); err != tt.want {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandData_Option(t *testing.T) {
+	var interaction Interaction
+	payload := `{"type":4,"data":{"name":"quote","options":[{"name":"category","type":3,"value":" wis","focused":true}]}}`
+	if err := json.Unmarshal([]byte(payload), &interaction); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if value, focused := interaction.Data.Option(CategoryOption); value != "wis" || !focused {
+		t.Errorf("Option() = %q, %v, want the focused value", value, focused)
+	}
+	if value, focused := interaction.Data.Option("missing"); value != "" || focused {
+		t.Errorf("Option() of a missing option = %q, %v", value, focused)
+	}
+}
+
+func TestCategoryChoices(t *testing.T) {
+	categories := []string{"life", "Wisdom", "art", "wit", "old wisdom", strings.Repeat("x", 101)}
+
+	response := CategoryChoices(categories, "WI")
+	data := response.Data.(*AutocompleteData)
+	if response.Type != ResponseAutocompleteResult || fmt.Sprint(data.Choices) != "[{Wisdom Wisdom} {wit wit} {old wisdom old wisdom}]" {
+		t.Errorf("CategoryChoices() = %+v", data.Choices)
+	}
+
+	var many []string
+	for i := 0; i < 30; i++ {
+		many = append(many, fmt.Sprintf("c%02d", i))
+	}
+	if data := CategoryChoices(many, "").Data.(*AutocompleteData); len(data.Choices) != 25 {
+		t.Errorf("CategoryChoices() gave %d choices, want 25", len(data.Choices))
+	}
+
+	// No matches still send an empty list, which Discord requires
+	encoded, _ := json.Marshal(CategoryChoices(categories, "zzz"))
+	if string(encoded) != `{"type":8,"data":{"choices":[]}}` {
+		t.Errorf("CategoryChoices() without matches = %s", encoded)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		"wisdom":       "wisdom",
+		"*bold* _it_":  `\*bold\* \_it\_`,
+		"`code` ~~x~~": "\\`code\\` \\~\\~x\\~\\~",
+		"||spoiler||":  `\|\|spoiler\|\|`,
+		"> quote":      `\> quote`,
+		"<@123> @here": "\\<@\u200b123\\> @\u200bhere",
+		`back\slash`:   `back\\slash`,
+		"# [link](x)":  `\# \[link\](x)`,
+	}
+	for text, want := range tests {
+		if got := Escape(text); got != want {
+			t.Errorf("Escape(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestQuoteResponse(t *testing.T) {
+	quote := &models.Quote{ID: 9, Text: "Simplicity is the ultimate sophistication", Author: "Leonardo da Vinci", Category: "design", Source: "Notebooks"}
+	response := QuoteResponse(quote, "https://quotes.example.com/q/9")
+
+	data := response.Data.(*MessageData)
+	if response.Type != ResponseChannelMessageWithSource || data.Flags != 0 || len(data.Embeds) != 1 {
+		t.Fatalf("QuoteResponse() = %+v", data)
+	}
+	embed := data.Embeds[0]
+	if embed.Title != "Quote #9" || embed.URL != "https://quotes.example.com/q/9" || embed.Author.Name != "Leonardo da Vinci" || embed.Footer.Text != "design" {
+		t.Errorf("embed = %+v", embed)
+	}
+	if embed.Description != "“Simplicity is the ultimate sophistication”\n\n*Notebooks*" {
+		t.Errorf("embed description = %q", embed.Description)
+	}
+
+	if data.AllowedMentions == nil || data.AllowedMentions.Parse == nil || len(data.AllowedMentions.Parse) != 0 {
+		t.Errorf("allowed mentions = %+v, want none", data.AllowedMentions)
+	}
+
+	marked := &models.Quote{ID: 2, Text: "Be *bold* @here", Author: "Anon", Category: "misc", Source: "_Notes_"}
+	if description := QuoteResponse(marked, "").Data.(*MessageData).Embeds[0].Description; description != "“Be \\*bold\\* @\u200bhere”\n\n*\\_Notes\\_*" {
+		t.Errorf("embed description with markdown = %q", description)
+	}
+
+	long := &models.Quote{ID: 1, Text: strings.Repeat("word ", 1000), Author: "Verbose", Category: "long"}
+	if description := QuoteResponse(long, "").Data.(*MessageData).Embeds[0].Description; len([]rune(description)) != 4096 || !strings.HasSuffix(description, "…") {
+		t.Errorf("long description has %d runes", len([]rune(description)))
+	}
+}
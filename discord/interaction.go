@@ -0,0 +1,255 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"quote-vault/models"
+)
+
+// Interaction types
+const (
+	InteractionPing               = 1
+	InteractionApplicationCommand = 2
+	InteractionAutocomplete       = 4
+)
+
+// Interaction response types
+const (
+	ResponsePong                     = 1
+	ResponseChannelMessageWithSource = 4
+	ResponseAutocompleteResult       = 8
+)
+
+// FlagEphemeral shows a message only to the user who ran the command
+const FlagEphemeral = 1 << 6
+
+// Option types used by the /quote command
+const optionString = 3
+
+// Limits Discord puts on autocomplete results and embed text
+const (
+	maxChoices          = 25
+	maxChoiceLength     = 100
+	maxEmbedAuthor      = 256
+	maxEmbedDescription = 4096
+)
+
+// embedColor is the accent color of quote embeds
+const embedColor = 0x5865F2
+
+// QuoteCommand is the name of the application command
+const QuoteCommand = "quote"
+
+// CategoryOption is the name of the /quote command's category option
+const CategoryOption = "category"
+
+// Interaction is a request from Discord. Only the fields the service
+// uses are decoded.
+type Interaction struct {
+	ID   string       `json:"id"`
+	Type int          `json:"type"`
+	Data *CommandData `json:"data,omitempty"`
+}
+
+// CommandData describes the command an interaction invokes
+type CommandData struct {
+	Name    string   `json:"name"`
+	Options []Option `json:"options,omitempty"`
+}
+
+// Option is a command option, as received with an interaction or as
+// defined when registering a command
+type Option struct {
+	Name         string          `json:"name"`
+	Type         int             `json:"type"`
+	Description  string          `json:"description,omitempty"`
+	Required     bool            `json:"required,omitempty"`
+	Autocomplete bool            `json:"autocomplete,omitempty"`
+	Value        json.RawMessage `json:"value,omitempty"`
+	Focused      bool            `json:"focused,omitempty"`
+}
+
+// Option returns the string value of the named option, and whether it is
+// the one being typed in an autocomplete interaction
+func (d *CommandData) Option(name string) (value string, focused bool) {
+	for _, option := range d.Options {
+		if option.Name == name {
+			json.Unmarshal(option.Value, &value)
+			return strings.TrimSpace(value), option.Focused
+		}
+	}
+	return "", false
+}
+
+// Command is an application command as registered with Discord
+type Command struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Options     []Option `json:"options,omitempty"`
+}
+
+// QuoteCommandDefinition is the /quote command to register with Discord
+var QuoteCommandDefinition = Command{
+	Name:        QuoteCommand,
+	Description: "Post a random quote",
+	Options: []Option{{
+		Name:         CategoryOption,
+		Type:         optionString,
+		Description:  "Pick the quote from this category",
+		Autocomplete: true,
+	}},
+}
+
+// Response answers an interaction. Data is a *MessageData, an
+// *AutocompleteData, or nil for a PONG.
+type Response struct {
+	Type int         `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// MessageData is the message of a response
+type MessageData struct {
+	Content         string           `json:"content,omitempty"`
+	Embeds          []Embed          `json:"embeds,omitempty"`
+	Flags           int              `json:"flags,omitempty"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+// AllowedMentions lists the kinds of mentions in a message that notify
+// anyone. Parse must be sent even when empty, which allows none.
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+// noMentions keeps a message from notifying anyone, whatever names from
+// quotes or user input it contains
+func noMentions() *AllowedMentions {
+	return &AllowedMentions{Parse: []string{}}
+}
+
+// AutocompleteData holds the suggestions of an autocomplete response
+type AutocompleteData struct {
+	Choices []Choice `json:"choices"`
+}
+
+// Embed is a rich message card
+type Embed struct {
+	Title       string       `json:"title,omitempty"`
+	URL         string       `json:"url,omitempty"`
+	Description string       `json:"description"`
+	Color       int          `json:"color,omitempty"`
+	Author      *EmbedAuthor `json:"author,omitempty"`
+	Footer      *EmbedFooter `json:"footer,omitempty"`
+}
+
+// EmbedAuthor is the name shown at the top of an embed
+type EmbedAuthor struct {
+	Name string `json:"name"`
+}
+
+// EmbedFooter is the small text under an embed
+type EmbedFooter struct {
+	Text string `json:"text"`
+}
+
+// Choice is an autocomplete suggestion
+type Choice struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Pong answers a PING
+func Pong() *Response {
+	return &Response{Type: ResponsePong}
+}
+
+// MessageResponse is a plain message shown only to the user
+func MessageResponse(content string) *Response {
+	return &Response{
+		Type: ResponseChannelMessageWithSource,
+		Data: &MessageData{Content: content, Flags: FlagEphemeral, AllowedMentions: noMentions()},
+	}
+}
+
+// QuoteResponse posts a quote to the channel as an embed titled with a
+// link to its page
+func QuoteResponse(quote *models.Quote, link string) *Response {
+	description := "“" + Escape(quote.Text) + "”"
+	if quote.Source != "" {
+		description += "\n\n*" + Escape(quote.Source) + "*"
+	}
+
+	return &Response{
+		Type: ResponseChannelMessageWithSource,
+		Data: &MessageData{Embeds: []Embed{{
+			Title:       fmt.Sprintf("Quote #%d", quote.ID),
+			URL:         link,
+			Description: truncate(description, maxEmbedDescription),
+			Color:       embedColor,
+			Author:      &EmbedAuthor{Name: truncate(quote.Author, maxEmbedAuthor)},
+			Footer:      &EmbedFooter{Text: quote.Category},
+		}}, AllowedMentions: noMentions()},
+	}
+}
+
+// CategoryChoices suggests the categories starting with what the user has
+// typed so far, ignoring case, then those containing it, up to Discord's
+// limit of 25
+func CategoryChoices(categories []string, typed string) *Response {
+	typed = strings.ToLower(typed)
+	var prefixed, contained []string
+	for _, category := range categories {
+		name := strings.ToLower(category)
+		switch {
+		case strings.HasPrefix(name, typed):
+			prefixed = append(prefixed, category)
+		case strings.Contains(name, typed):
+			contained = append(contained, category)
+		}
+	}
+	sort.Strings(prefixed)
+	sort.Strings(contained)
+
+	choices := []Choice{}
+	for _, category := range append(prefixed, contained...) {
+		if len(choices) == maxChoices {
+			break
+		}
+		if utf8.RuneCountInString(category) > maxChoiceLength {
+			continue
+		}
+		choices = append(choices, Choice{Name: category, Value: category})
+	}
+	return &Response{
+		Type: ResponseAutocompleteResult,
+		Data: &AutocompleteData{Choices: choices},
+	}
+}
+
+// markdownEscaper puts a backslash before each character Discord markdown
+// gives a meaning to, and a zero-width space after @ so @everyone and
+// @here do not read as mentions
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`,
+	">", `\>`, "<", `\<`, "#", `\#`, "-", `\-`, "[", `\[`, "]", `\]`,
+	"@", "@\u200b",
+)
+
+// Escape makes text show as written in Discord markdown
+func Escape(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// truncate shortens s to at most max runes, ending it with an ellipsis
+// when cut
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,60 @@
+// Package discord implements the parts of Discord's interactions protocol
+// the service answers: signed requests, the /quote application command
+// with its autocomplete, and embed replies.
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers carrying the signature of a request from Discord
+const (
+	SignatureHeader = "X-Signature-Ed25519"
+	TimestampHeader = "X-Signature-Timestamp"
+)
+
+// Reasons Verify refuses a request
+var (
+	ErrMissingSignature = errors.New("missing request signature")
+	ErrBadSignature     = errors.New("request signature does not match")
+)
+
+// ParsePublicKey decodes the hex public key shown on an application's
+// page in the Discord developer portal
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Discord public key: want %d hex-encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// SignRequest sets the signature headers of a request as Discord would at
+// now, signing with the private half of the application's key, so
+// requests can be made to the endpoint without Discord
+func SignRequest(req *http.Request, key ed25519.PrivateKey, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, hex.EncodeToString(ed25519.Sign(key, append([]byte(timestamp), body...))))
+}
+
+// Verify checks that body and its timestamp header were signed with the
+// application's key
+func Verify(key ed25519.PublicKey, header http.Header, body []byte) error {
+	timestamp := header.Get(TimestampHeader)
+	signature, err := hex.DecodeString(header.Get(SignatureHeader))
+	if timestamp == "" || err != nil || len(signature) != ed25519.SignatureSize {
+		return ErrMissingSignature
+	}
+
+	if !ed25519.Verify(key, append([]byte(timestamp), body...), signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
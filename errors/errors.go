@@ -25,6 +25,7 @@ const (
 	TypeInternal     = "internal_error"
 	TypeBadRequest   = "bad_request"
 	TypeConflict     = "conflict"
+	TypeUnauthorized = "unauthorized"
 )
 
 // Common errors
@@ -76,6 +77,66 @@ var (
 		Message: "Quote already exists",
 		Type:    TypeConflict,
 	}
+
+	ErrQuoteModified = &AppError{
+		Code:    http.StatusConflict,
+		Message: "Quote was changed since it was read",
+		Type:    TypeConflict,
+	}
+
+	ErrWebhookNotFound = &AppError{
+		Code:    http.StatusNotFound,
+		Message: "Webhook not found",
+		Type:    TypeNotFound,
+	}
+
+	ErrDeliveryNotFound = &AppError{
+		Code:    http.StatusNotFound,
+		Message: "Webhook delivery not found",
+		Type:    TypeNotFound,
+	}
+
+	ErrDeliveryPending = &AppError{
+		Code:    http.StatusConflict,
+		Message: "Webhook delivery is still pending",
+		Type:    TypeConflict,
+	}
+
+	ErrReplicaWrite = &AppError{
+		Code:    http.StatusConflict,
+		Message: "This instance is a replica; make the change on its leader",
+		Type:    TypeConflict,
+	}
+
+	ErrActorNotFound = &AppError{
+		Code:    http.StatusNotFound,
+		Message: "Actor not found",
+		Type:    TypeNotFound,
+	}
+
+	ErrSubscriptionNotFound = &AppError{
+		Code:    http.StatusNotFound,
+		Message: "Subscription not found or link expired",
+		Type:    TypeNotFound,
+	}
+
+	ErrSavedSearchNotFound = &AppError{
+		Code:    http.StatusNotFound,
+		Message: "Saved search not found",
+		Type:    TypeNotFound,
+	}
+
+	ErrMailUnavailable = &AppError{
+		Code:    http.StatusServiceUnavailable,
+		Message: "Email could not be sent, please try again later",
+		Type:    TypeInternal,
+	}
+
+	ErrUnsupportedFormat = &AppError{
+		Code:    http.StatusBadRequest,
+		Message: "Unsupported format",
+		Type:    TypeBadRequest,
+	}
 )
 
 // NewValidationError creates a new validation error with specific details
@@ -88,6 +149,17 @@ func NewValidationError(message, detail string) *AppError {
 	}
 }
 
+// NewUnauthorizedError creates an error for a request whose sender could
+// not be verified
+func NewUnauthorizedError(message, detail string) *AppError {
+	return &AppError{
+		Code:    http.StatusUnauthorized,
+		Message: message,
+		Type:    TypeUnauthorized,
+		Detail:  detail,
+	}
+}
+
 // NewDatabaseError creates a new database error
 func NewDatabaseError(message string) *AppError {
 	return &AppError{
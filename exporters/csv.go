@@ -0,0 +1,57 @@
+package exporters
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"quote-vault/models"
+)
+
+// CSVWriter writes quotes as CSV with a header row. The column names match
+// the import defaults so exports can be re-imported.
+type CSVWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter creates a CSV export writer
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// WriteQuote writes a single quote row
+func (c *CSVWriter) WriteQuote(quote *models.Quote) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(quote.ID),
+		quote.Text,
+		quote.Author,
+		quote.Category,
+		quote.Source,
+		strings.Join(quote.Tags, ","),
+		strconv.Itoa(quote.Likes),
+		quote.CreatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// Close writes the header if no rows were written and flushes the output
+func (c *CSVWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSVWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write([]string{"id", "text", "author", "category", "source", "tags", "likes", "created_at"})
+}
@@ -0,0 +1,342 @@
+package exporters
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"quote-vault/models"
+)
+
+// Book defaults used when no Metadata is set
+const (
+	defaultBookTitle = "Quote Vault"
+	bookLanguage     = "en"
+	bookPublisher    = "Quote Vault"
+)
+
+// epubDir holds the package document and content inside the container
+const epubDir = "OEBPS/"
+
+// epubStyle is the stylesheet shared by every page of the book
+const epubStyle = `body { font-family: serif; line-height: 1.4; }
+h1 { text-align: center; margin: 1em 0 1.5em; }
+section.title { text-align: center; margin-top: 30%; }
+section.title h1 { font-size: 2em; }
+section.title .date { margin-top: 3em; font-size: 0.9em; }
+blockquote { margin: 0 0 1.8em; page-break-inside: avoid; }
+blockquote p { margin: 0 0 0.4em; }
+blockquote p.attribution { text-align: right; font-style: italic; }
+nav ol { list-style: none; padding: 0; }
+nav li { margin: 0.4em 0; }
+`
+
+// EPUBWriter builds an EPUB 3 book with a title page, a table of contents
+// and a chapter per category. Quotes must arrive ordered by category for
+// the chapters to hold. The zip is streamed as quotes arrive; only the
+// chapter list is kept until Close writes the navigation and package
+// documents.
+type EPUBWriter struct {
+	zw       *zip.Writer
+	meta     Metadata
+	started  bool
+	chapter  io.Writer
+	category string
+	chapters []bookChapter
+	// digest covers the title and every quote version, giving the book an
+	// identifier that only changes when its content does
+	digest hash.Hash
+}
+
+// bookChapter is a chapter of an EPUB book
+type bookChapter struct {
+	ID    string
+	Title string
+}
+
+// NewEPUBWriter creates an EPUB export writer
+func NewEPUBWriter(w io.Writer) *EPUBWriter {
+	return &EPUBWriter{
+		zw:     zip.NewWriter(w),
+		meta:   Metadata{Title: defaultBookTitle},
+		digest: sha1.New(),
+	}
+}
+
+// SetMetadata sets the title, description and date of the book
+func (e *EPUBWriter) SetMetadata(meta Metadata) {
+	if meta.Title == "" {
+		meta.Title = defaultBookTitle
+	}
+	e.meta = meta
+}
+
+// WriteQuote adds a quote to the current chapter, starting a new chapter
+// when the category changes
+func (e *EPUBWriter) WriteQuote(quote *models.Quote) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if e.chapter == nil || quote.Category != e.category {
+		if err := e.closeChapter(); err != nil {
+			return err
+		}
+		if err := e.openChapter(quote.Category); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(e.digest, "%d:%d\n", quote.ID, quote.Version)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<blockquote id=\"quote-%d\">\n", quote.ID)
+	for _, line := range bookParagraphs(quote.Text) {
+		fmt.Fprintf(&b, "<p>%s</p>\n", xmlEscape(line))
+	}
+	b.WriteString("<p class=\"attribution\">— " + xmlEscape(quote.Author))
+	if quote.Source != "" {
+		b.WriteString(", <cite>" + xmlEscape(quote.Source) + "</cite>")
+	}
+	b.WriteString("</p>\n</blockquote>\n")
+
+	_, err := io.WriteString(e.chapter, b.String())
+	return err
+}
+
+// Close finishes the last chapter, writes the table of contents and the
+// package document and closes the zip
+func (e *EPUBWriter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if err := e.closeChapter(); err != nil {
+		return err
+	}
+	if err := e.writeNav(); err != nil {
+		return err
+	}
+	if err := e.writePackage(); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
+
+// start writes the fixed parts of the book ahead of the first chapter. The
+// mimetype entry must come first and be stored uncompressed so readers can
+// identify the file.
+func (e *EPUBWriter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	e.digest.Write([]byte(e.meta.Title + "\n"))
+
+	mimetype := []byte("application/epub+zip")
+	w, err := e.zw.CreateRaw(&zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(mimetype),
+		CompressedSize64:   uint64(len(mimetype)),
+		UncompressedSize64: uint64(len(mimetype)),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(mimetype); err != nil {
+		return err
+	}
+
+	if err := e.writeFile("META-INF/container.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="`+epubDir+`content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`); err != nil {
+		return err
+	}
+	if err := e.writeFile(epubDir+"style.css", epubStyle); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(xhtmlHeader(e.meta.Title))
+	b.WriteString("<section class=\"title\" epub:type=\"titlepage\">\n")
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", xmlEscape(e.meta.Title))
+	if e.meta.Description != "" {
+		fmt.Fprintf(&b, "<p>%s</p>\n", xmlEscape(e.meta.Description))
+	}
+	if !e.meta.Date.IsZero() {
+		fmt.Fprintf(&b, "<p class=\"date\">%s</p>\n", e.meta.Date.Format("2 January 2006"))
+	}
+	b.WriteString("</section>\n" + xhtmlFooter)
+	return e.writeFile(epubDir+"title.xhtml", b.String())
+}
+
+// openChapter starts the chapter of a category
+func (e *EPUBWriter) openChapter(category string) error {
+	chapter := bookChapter{
+		ID:    fmt.Sprintf("chapter-%03d", len(e.chapters)+1),
+		Title: chapterTitle(category),
+	}
+	w, err := e.create(epubDir + chapter.ID + ".xhtml")
+	if err != nil {
+		return err
+	}
+	e.chapters = append(e.chapters, chapter)
+	e.chapter = w
+	e.category = category
+
+	_, err = io.WriteString(w, xhtmlHeader(chapter.Title)+
+		fmt.Sprintf("<section epub:type=\"chapter\" id=\"%s\">\n<h1>%s</h1>\n", chapter.ID, xmlEscape(chapter.Title)))
+	return err
+}
+
+// closeChapter ends the open chapter, if any
+func (e *EPUBWriter) closeChapter() error {
+	if e.chapter == nil {
+		return nil
+	}
+	_, err := io.WriteString(e.chapter, "</section>\n"+xhtmlFooter)
+	e.chapter = nil
+	return err
+}
+
+// writeNav writes the table of contents
+func (e *EPUBWriter) writeNav() error {
+	var b strings.Builder
+	b.WriteString(xhtmlHeader("Contents"))
+	b.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<h1>Contents</h1>\n<ol>\n")
+	for _, chapter := range e.chapters {
+		fmt.Fprintf(&b, "<li><a href=\"%s.xhtml\">%s</a></li>\n", chapter.ID, xmlEscape(chapter.Title))
+	}
+	if len(e.chapters) == 0 {
+		b.WriteString("<li><a href=\"title.xhtml\">No quotes</a></li>\n")
+	}
+	b.WriteString("</ol>\n</nav>\n" + xhtmlFooter)
+	return e.writeFile(epubDir+"nav.xhtml", b.String())
+}
+
+// writePackage writes the package document listing the metadata, files
+// and reading order of the book
+func (e *EPUBWriter) writePackage() error {
+	date := e.meta.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	date = date.UTC()
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + bookLanguage + `">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&b, "    <dc:identifier id=\"book-id\">urn:uuid:%s</dc:identifier>\n", contentUUID(e.digest.Sum(nil)))
+	fmt.Fprintf(&b, "    <dc:title>%s</dc:title>\n", xmlEscape(e.meta.Title))
+	fmt.Fprintf(&b, "    <dc:language>%s</dc:language>\n", bookLanguage)
+	fmt.Fprintf(&b, "    <dc:publisher>%s</dc:publisher>\n", bookPublisher)
+	if e.meta.Description != "" {
+		fmt.Fprintf(&b, "    <dc:description>%s</dc:description>\n", xmlEscape(e.meta.Description))
+	}
+	fmt.Fprintf(&b, "    <dc:date>%s</dc:date>\n", date.Format("2006-01-02"))
+	fmt.Fprintf(&b, "    <meta property=\"dcterms:modified\">%s</meta>\n", date.Format("2006-01-02T15:04:05Z"))
+	b.WriteString(`  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    <item id="title" href="title.xhtml" media-type="application/xhtml+xml"/>
+`)
+	for _, chapter := range e.chapters {
+		fmt.Fprintf(&b, "    <item id=\"%s\" href=\"%s.xhtml\" media-type=\"application/xhtml+xml\"/>\n", chapter.ID, chapter.ID)
+	}
+	b.WriteString(`  </manifest>
+  <spine>
+    <itemref idref="title"/>
+    <itemref idref="nav"/>
+`)
+	for _, chapter := range e.chapters {
+		fmt.Fprintf(&b, "    <itemref idref=\"%s\"/>\n", chapter.ID)
+	}
+	b.WriteString("  </spine>\n</package>\n")
+
+	return e.writeFile(epubDir+"content.opf", b.String())
+}
+
+// create starts a compressed zip entry
+func (e *EPUBWriter) create(name string) (io.Writer, error) {
+	return e.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: e.meta.Date,
+	})
+}
+
+// writeFile adds a whole zip entry
+func (e *EPUBWriter) writeFile(name, content string) error {
+	w, err := e.create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, content)
+	return err
+}
+
+// xhtmlFooter closes a document started with xhtmlHeader
+const xhtmlFooter = "</body>\n</html>\n"
+
+// xhtmlHeader opens an XHTML content document
+func xhtmlHeader(title string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="` + bookLanguage + `" xml:lang="` + bookLanguage + `">
+<head>
+<title>` + xmlEscape(title) + `</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+`
+}
+
+// xmlEscape escapes text for XML, replacing characters XML cannot hold
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// contentUUID formats a digest as a name-based (version 5 style) UUID
+func contentUUID(sum []byte) string {
+	u := make([]byte, 16)
+	copy(u, sum)
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// chapterTitle turns a category into a chapter heading
+func chapterTitle(category string) string {
+	category = strings.Join(strings.Fields(category), " ")
+	if category == "" {
+		return "Uncategorized"
+	}
+	r, size := utf8.DecodeRuneInString(category)
+	return string(unicode.ToUpper(r)) + category[size:]
+}
+
+// bookParagraphs splits quote text into its non-blank lines
+func bookParagraphs(text string) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
@@ -0,0 +1,148 @@
+package exporters
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+// exportEPUB builds an EPUB from quotes and opens it as a zip
+func exportEPUB(t *testing.T, meta Metadata, quotes []*models.Quote) *zip.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := NewEPUBWriter(&buf)
+	writer.SetMetadata(meta)
+	for _, quote := range quotes {
+		if err := writer.WriteQuote(quote); err != nil {
+			t.Fatalf("WriteQuote() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	book, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("EPUB is not a valid zip: %v", err)
+	}
+	return book
+}
+
+func readEntry(t *testing.T, book *zip.Reader, name string) string {
+	t.Helper()
+
+	f, err := book.Open(name)
+	if err != nil {
+		t.Fatalf("EPUB has no %s: %v", name, err)
+	}
+	defer f.Close()
+	data, _ := io.ReadAll(f)
+	return string(data)
+}
+
+func TestEPUBWriter(t *testing.T) {
+	quotes := append(testQuotes(), &models.Quote{
+		ID: 5, Text: "Use <b> & \"quotes\" carefully.", Author: "Ada & Co", Category: "wisdom", Source: "Notes <draft>",
+	})
+	meta := Metadata{Title: "Anthology 2024", Description: "Quotes from 2024", Date: time.Date(2024, 12, 31, 9, 0, 0, 0, time.UTC)}
+	book := exportEPUB(t, meta, quotes)
+
+	first := book.File[0]
+	if first.Name != "mimetype" || first.Method != zip.Store || len(first.Extra) != 0 {
+		t.Errorf("first entry = %q (method %d), want an uncompressed mimetype", first.Name, first.Method)
+	}
+	if got := readEntry(t, book, "mimetype"); got != "application/epub+zip" {
+		t.Errorf("mimetype = %q", got)
+	}
+	if !strings.Contains(readEntry(t, book, "META-INF/container.xml"), `full-path="OEBPS/content.opf"`) {
+		t.Error("container.xml does not point at the package document")
+	}
+
+	// Every XML document must be well-formed
+	for _, f := range book.File {
+		if !strings.HasSuffix(f.Name, ".xml") && !strings.HasSuffix(f.Name, ".xhtml") && !strings.HasSuffix(f.Name, ".opf") {
+			continue
+		}
+		decoder := xml.NewDecoder(strings.NewReader(readEntry(t, book, f.Name)))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s is not well-formed: %v", f.Name, err)
+				break
+			}
+		}
+	}
+
+	opf := readEntry(t, book, "OEBPS/content.opf")
+	for _, want := range []string{
+		`<dc:title>Anthology 2024</dc:title>`,
+		`<dc:description>Quotes from 2024</dc:description>`,
+		`<meta property="dcterms:modified">2024-12-31T09:00:00Z</meta>`,
+		`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>`,
+		`<itemref idref="chapter-002"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("content.opf does not contain %q", want)
+		}
+	}
+	if !regexp.MustCompile(`<dc:identifier id="book-id">urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}</dc:identifier>`).MatchString(opf) {
+		t.Errorf("content.opf has no UUID identifier:\n%s", opf)
+	}
+
+	nav := readEntry(t, book, "OEBPS/nav.xhtml")
+	if !strings.Contains(nav, `<a href="chapter-001.xhtml">Motivation</a>`) || !strings.Contains(nav, `<a href="chapter-002.xhtml">Wisdom</a>`) {
+		t.Errorf("nav.xhtml = %s, want a chapter per category", nav)
+	}
+
+	chapter := readEntry(t, book, "OEBPS/chapter-002.xhtml")
+	for _, want := range []string{
+		"<h1>Wisdom</h1>",
+		"<p>Know thyself.</p>\n<p>And nothing in excess.</p>",
+		"Use &lt;b&gt; &amp; &#34;quotes&#34; carefully.",
+		"— Ada &amp; Co, <cite>Notes &lt;draft&gt;</cite>",
+	} {
+		if !strings.Contains(chapter, want) {
+			t.Errorf("chapter-002.xhtml does not contain %q", want)
+		}
+	}
+	if strings.Contains(chapter, "Stay hungry") {
+		t.Error("chapter-002.xhtml holds a quote from another category")
+	}
+}
+
+func TestEPUBWriter_Identifier(t *testing.T) {
+	identifier := regexp.MustCompile(`urn:uuid:[0-9a-f-]+`)
+	id := func(meta Metadata, quotes []*models.Quote) string {
+		return identifier.FindString(readEntry(t, exportEPUB(t, meta, quotes), "OEBPS/content.opf"))
+	}
+
+	meta := Metadata{Title: "Anthology", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	first := id(meta, testQuotes())
+	if again := id(Metadata{Title: "Anthology", Date: time.Now()}, testQuotes()); again != first {
+		t.Errorf("identifier changed with the export date: %s then %s", first, again)
+	}
+
+	edited := testQuotes()
+	edited[0].Version = 2
+	if id(meta, edited) == first {
+		t.Error("identifier did not change when a quote was edited")
+	}
+}
+
+func TestEPUBWriter_Empty(t *testing.T) {
+	book := exportEPUB(t, Metadata{}, nil)
+	if !strings.Contains(readEntry(t, book, "OEBPS/title.xhtml"), "<h1>Quote Vault</h1>") {
+		t.Error("empty EPUB has no title page with the default title")
+	}
+}
@@ -0,0 +1,126 @@
+package exporters
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"quote-vault/models"
+)
+
+// Writer streams quotes to an underlying io.Writer. Close flushes any
+// buffered output and writes trailing content; it does not close the
+// underlying writer.
+type Writer interface {
+	WriteQuote(quote *models.Quote) error
+	Close() error
+}
+
+// Metadata describes an export as a whole, for formats that produce a book
+// with a title page or an archive of several files
+type Metadata struct {
+	Title string
+	// Description says which quotes the book holds, shown under the title
+	Description string
+	Date        time.Time
+	// Name is the base name of the exported file, without an extension,
+	// which archives name the files inside them after
+	Name string
+}
+
+// MetadataWriter is implemented by writers that use Metadata. SetMetadata
+// must be called before the first quote is written.
+type MetadataWriter interface {
+	Writer
+	SetMetadata(meta Metadata)
+}
+
+// Format describes an export format
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	NewWriter   func(w io.Writer) Writer
+}
+
+// Supported export format names
+const (
+	FormatCSV            = "csv"
+	FormatJSONL          = "jsonl"
+	FormatMarkdown       = "md"
+	FormatYAML           = "yaml"
+	FormatFortune        = "fortune"
+	FormatFortuneArchive = "fortune-zip"
+	FormatEPUB           = "epub"
+	FormatPDF            = "pdf"
+)
+
+var formats = map[string]Format{
+	FormatCSV: {
+		Name:        FormatCSV,
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		NewWriter:   func(w io.Writer) Writer { return NewCSVWriter(w) },
+	},
+	FormatJSONL: {
+		Name:        FormatJSONL,
+		ContentType: "application/x-ndjson",
+		Extension:   "jsonl",
+		NewWriter:   func(w io.Writer) Writer { return NewJSONLWriter(w) },
+	},
+	FormatMarkdown: {
+		Name:        FormatMarkdown,
+		ContentType: "text/markdown; charset=utf-8",
+		Extension:   "md",
+		NewWriter:   func(w io.Writer) Writer { return NewMarkdownWriter(w) },
+	},
+	FormatYAML: {
+		Name:        FormatYAML,
+		ContentType: "application/yaml",
+		Extension:   "yaml",
+		NewWriter:   func(w io.Writer) Writer { return NewYAMLWriter(w) },
+	},
+	// Fortune files conventionally have no extension; the matching index
+	// shares the base name with a .dat suffix.
+	FormatFortune: {
+		Name:        FormatFortune,
+		ContentType: "text/plain; charset=utf-8",
+		Extension:   "",
+		NewWriter:   func(w io.Writer) Writer { return NewFortuneWriter(w) },
+	},
+	FormatFortuneArchive: {
+		Name:        FormatFortuneArchive,
+		ContentType: "application/zip",
+		Extension:   "zip",
+		NewWriter:   func(w io.Writer) Writer { return NewFortuneArchiveWriter(w) },
+	},
+	FormatEPUB: {
+		Name:        FormatEPUB,
+		ContentType: "application/epub+zip",
+		Extension:   "epub",
+		NewWriter:   func(w io.Writer) Writer { return NewEPUBWriter(w) },
+	},
+	FormatPDF: {
+		Name:        FormatPDF,
+		ContentType: "application/pdf",
+		Extension:   "pdf",
+		NewWriter:   func(w io.Writer) Writer { return NewPDFWriter(w) },
+	},
+}
+
+// aliases maps alternative format names onto the canonical ones
+var aliases = map[string]string{
+	"markdown": FormatMarkdown,
+	"yml":      FormatYAML,
+	"ndjson":   FormatJSONL,
+}
+
+// Lookup returns the export format with the given name
+func Lookup(name string) (Format, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if canonical, ok := aliases[name]; ok {
+		name = canonical
+	}
+	format, ok := formats[name]
+	return format, ok
+}
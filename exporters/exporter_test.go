@@ -0,0 +1,145 @@
+package exporters
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func testQuotes() []*models.Quote {
+	created := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	return []*models.Quote{
+		{ID: 1, Text: "Stay hungry, stay foolish.", Author: "Steve Jobs", Category: "motivation", CreatedAt: created},
+		{ID: 2, Text: "Done is better than perfect.", Author: "Sheryl Sandberg", Category: "motivation", Source: "Lean In", Tags: models.Tags{"work", "productivity"}, Likes: 42, CreatedAt: created},
+		{ID: 3, Text: "Know thyself.\nAnd nothing in excess.", Author: "Socrates", Category: "wisdom", CreatedAt: created},
+		{ID: 4, Text: "The unexamined life is not worth living.", Author: "Socrates", Category: "wisdom", CreatedAt: created},
+	}
+}
+
+func export(t *testing.T, name string, quotes []*models.Quote) string {
+	t.Helper()
+
+	format, ok := Lookup(name)
+	if !ok {
+		t.Fatalf("Lookup(%q) not found", name)
+	}
+
+	var buf bytes.Buffer
+	writer := format.NewWriter(&buf)
+	for _, quote := range quotes {
+		if err := writer.WriteQuote(quote); err != nil {
+			t.Fatalf("WriteQuote() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.String()
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{name: "csv", want: FormatCSV, ok: true},
+		{name: "markdown", want: FormatMarkdown, ok: true},
+		{name: "YML", want: FormatYAML, ok: true},
+		{name: "docx", ok: false},
+	}
+
+	for _, tt := range tests {
+		format, ok := Lookup(tt.name)
+		if ok != tt.ok || format.Name != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v, want %q, %v", tt.name, format.Name, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	got := export(t, FormatCSV, testQuotes()[:2])
+	want := "id,text,author,category,source,tags,likes,created_at\n" +
+		"1,\"Stay hungry, stay foolish.\",Steve Jobs,motivation,,,0,2024-01-15T10:00:00Z\n" +
+		"2,Done is better than perfect.,Sheryl Sandberg,motivation,Lean In,\"work,productivity\",42,2024-01-15T10:00:00Z\n"
+	if got != want {
+		t.Errorf("CSV export = %q, want %q", got, want)
+	}
+
+	if got := export(t, FormatCSV, nil); got != "id,text,author,category,source,tags,likes,created_at\n" {
+		t.Errorf("empty CSV export = %q, want header only", got)
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	got := export(t, FormatJSONL, testQuotes())
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("JSONL export lines = %v, want 4", len(lines))
+	}
+	if !strings.Contains(lines[0], `"author":"Steve Jobs"`) {
+		t.Errorf("JSONL export first line = %q", lines[0])
+	}
+}
+
+func TestMarkdownWriter(t *testing.T) {
+	got := export(t, FormatMarkdown, testQuotes())
+	want := `# Quote Vault
+
+## motivation
+
+### Steve Jobs
+
+> Stay hungry, stay foolish.
+
+### Sheryl Sandberg
+
+> Done is better than perfect.
+>
+> — *Lean In*
+
+## wisdom
+
+### Socrates
+
+> Know thyself.
+> And nothing in excess.
+
+> The unexamined life is not worth living.
+
+`
+	if got != want {
+		t.Errorf("Markdown export =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestYAMLWriter(t *testing.T) {
+	got := export(t, FormatYAML, testQuotes()[1:3])
+	want := `quotes:
+  - id: 2
+    text: "Done is better than perfect."
+    author: "Sheryl Sandberg"
+    category: "motivation"
+    source: "Lean In"
+    tags:
+      - "work"
+      - "productivity"
+    likes: 42
+    created_at: 2024-01-15T10:00:00Z
+  - id: 3
+    text: "Know thyself.\nAnd nothing in excess."
+    author: "Socrates"
+    category: "wisdom"
+    created_at: 2024-01-15T10:00:00Z
+`
+	if got != want {
+		t.Errorf("YAML export =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := export(t, FormatYAML, nil); got != "quotes: []\n" {
+		t.Errorf("empty YAML export = %q", got)
+	}
+}
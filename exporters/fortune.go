@@ -0,0 +1,200 @@
+package exporters
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"quote-vault/models"
+)
+
+// strfile header values from fortune-mod's strfile.h
+const (
+	strfileVersion   = 2
+	strfileDelimiter = '%'
+)
+
+// fortuneEntry renders a quote as one fortune, including the trailing
+// delimiter line. Lines consisting of a lone % would end the fortune early,
+// so they are indented by a space.
+func fortuneEntry(quote *models.Quote) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(quote.Text), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "%" {
+			line = " %"
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if author := strings.TrimSpace(quote.Author); author != "" {
+		b.WriteString("\t\t-- ")
+		b.WriteString(author)
+		if source := strings.TrimSpace(quote.Source); source != "" {
+			b.WriteString(`, "` + source + `"`)
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("%\n")
+	return b.String()
+}
+
+// FortuneWriter writes quotes in the %-separated fortune(6) text format
+type FortuneWriter struct {
+	w *bufio.Writer
+}
+
+// NewFortuneWriter creates a fortune text export writer
+func NewFortuneWriter(w io.Writer) *FortuneWriter {
+	return &FortuneWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteQuote writes a single fortune
+func (f *FortuneWriter) WriteQuote(quote *models.Quote) error {
+	_, err := f.w.WriteString(fortuneEntry(quote))
+	return err
+}
+
+// Close flushes the output
+func (f *FortuneWriter) Close() error {
+	return f.w.Flush()
+}
+
+// StrfileWriter writes the binary .dat index that strfile(1) would build
+// for the text produced by FortuneWriter over the same quotes. fortune(6)
+// needs both files side by side to pick random entries, and an index only
+// works with the exact text it was built from.
+type StrfileWriter struct {
+	w        io.Writer
+	offsets  []uint32
+	offset   uint32
+	longest  uint32
+	shortest uint32
+}
+
+// NewStrfileWriter creates a strfile index export writer
+func NewStrfileWriter(w io.Writer) *StrfileWriter {
+	return &StrfileWriter{w: w}
+}
+
+// WriteQuote records the offset and length of the quote's fortune entry
+func (s *StrfileWriter) WriteQuote(quote *models.Quote) error {
+	entry := fortuneEntry(quote)
+
+	// strfile measures a string up to, but not including, its "%\n" line
+	length := uint32(len(entry) - 2)
+	if len(s.offsets) == 0 || length > s.longest {
+		s.longest = length
+	}
+	if len(s.offsets) == 0 || length < s.shortest {
+		s.shortest = length
+	}
+
+	s.offsets = append(s.offsets, s.offset)
+	s.offset += uint32(len(entry))
+	return nil
+}
+
+// Close writes the header followed by the offset table. The table ends
+// with the offset of the end of the text file, as strfile's does.
+func (s *StrfileWriter) Close() error {
+	header := struct {
+		Version  uint32
+		NumStr   uint32
+		LongLen  uint32
+		ShortLen uint32
+		Flags    uint32
+		Delim    [4]byte
+	}{
+		Version:  strfileVersion,
+		NumStr:   uint32(len(s.offsets)),
+		LongLen:  s.longest,
+		ShortLen: s.shortest,
+		Delim:    [4]byte{strfileDelimiter},
+	}
+	if err := binary.Write(s.w, binary.BigEndian, header); err != nil {
+		return err
+	}
+	return binary.Write(s.w, binary.BigEndian, append(s.offsets, s.offset))
+}
+
+// FortuneArchiveWriter writes a zip archive holding a fortune text file and
+// its .dat index, both built in one pass over the quotes so the offsets in
+// the index always match the text
+type FortuneArchiveWriter struct {
+	zw    *zip.Writer
+	meta  Metadata
+	text  *FortuneWriter
+	index *StrfileWriter
+}
+
+// NewFortuneArchiveWriter creates a fortune archive export writer
+func NewFortuneArchiveWriter(w io.Writer) *FortuneArchiveWriter {
+	return &FortuneArchiveWriter{zw: zip.NewWriter(w), meta: Metadata{Name: "quotes"}}
+}
+
+// SetMetadata sets the name of the files in the archive, the text file
+// taking the name as is and the index adding .dat, and their date
+func (f *FortuneArchiveWriter) SetMetadata(meta Metadata) {
+	if meta.Name == "" {
+		meta.Name = f.meta.Name
+	}
+	f.meta = meta
+}
+
+// WriteQuote writes a fortune to the text file and records it in the index
+func (f *FortuneArchiveWriter) WriteQuote(quote *models.Quote) error {
+	if f.text == nil {
+		if err := f.start(); err != nil {
+			return err
+		}
+	}
+	if err := f.text.WriteQuote(quote); err != nil {
+		return err
+	}
+	return f.index.WriteQuote(quote)
+}
+
+// start opens the text file in the archive
+func (f *FortuneArchiveWriter) start() error {
+	entry, err := f.create(f.meta.Name)
+	if err != nil {
+		return err
+	}
+	f.text = NewFortuneWriter(entry)
+	f.index = NewStrfileWriter(nil)
+	return nil
+}
+
+func (f *FortuneArchiveWriter) create(name string) (io.Writer, error) {
+	return f.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: f.meta.Date,
+	})
+}
+
+// Close finishes the text file, adds the index after it and closes the
+// archive
+func (f *FortuneArchiveWriter) Close() error {
+	if f.text == nil {
+		if err := f.start(); err != nil {
+			return err
+		}
+	}
+	if err := f.text.Close(); err != nil {
+		return err
+	}
+
+	entry, err := f.create(f.meta.Name + ".dat")
+	if err != nil {
+		return err
+	}
+	f.index.w = entry
+	if err := f.index.Close(); err != nil {
+		return err
+	}
+	return f.zw.Close()
+}
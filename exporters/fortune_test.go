@@ -0,0 +1,143 @@
+package exporters
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func TestFortuneWriter(t *testing.T) {
+	quotes := []*models.Quote{
+		{Text: "Know thyself.", Author: "Socrates"},
+		{Text: "Man is by nature a political animal.", Author: "Aristotle", Source: "Politics"},
+		{Text: "Line one\n%\nline three", Author: ""},
+	}
+
+	got := export(t, FormatFortune, quotes)
+	want := "Know thyself.\n\t\t-- Socrates\n%\n" +
+		"Man is by nature a political animal.\n\t\t-- Aristotle, \"Politics\"\n%\n" +
+		"Line one\n %\nline three\n%\n"
+	if got != want {
+		t.Errorf("fortune export = %q, want %q", got, want)
+	}
+}
+
+func TestStrfileWriter(t *testing.T) {
+	quotes := []*models.Quote{
+		{Text: "Know thyself.", Author: "Socrates"},
+		{Text: "Less is more.", Author: "Mies van der Rohe"},
+	}
+
+	text := export(t, FormatFortune, quotes)
+	var buf bytes.Buffer
+	writer := NewStrfileWriter(&buf)
+	for _, quote := range quotes {
+		if err := writer.WriteQuote(quote); err != nil {
+			t.Fatalf("WriteQuote() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	index := buf.Bytes()
+
+	var header struct {
+		Version  uint32
+		NumStr   uint32
+		LongLen  uint32
+		ShortLen uint32
+		Flags    uint32
+		Delim    [4]byte
+	}
+	reader := bytes.NewReader(index)
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		t.Fatalf("failed to read header: %v", err)
+	}
+
+	if header.Version != 2 || header.NumStr != 2 || header.Delim[0] != '%' {
+		t.Errorf("strfile header = %+v", header)
+	}
+
+	first := len("Know thyself.\n\t\t-- Socrates\n")
+	second := len("Less is more.\n\t\t-- Mies van der Rohe\n")
+	if header.LongLen != uint32(second) || header.ShortLen != uint32(first) {
+		t.Errorf("strfile lengths = %d/%d, want %d/%d", header.LongLen, header.ShortLen, second, first)
+	}
+
+	offsets := make([]uint32, header.NumStr+1)
+	if err := binary.Read(reader, binary.BigEndian, offsets); err != nil {
+		t.Fatalf("failed to read offsets: %v", err)
+	}
+	want := []uint32{0, uint32(first + 2), uint32(len(text))}
+	for i := range want {
+		if offsets[i] != want[i] {
+			t.Errorf("strfile offset %d = %d, want %d", i, offsets[i], want[i])
+		}
+	}
+	if reader.Len() != 0 {
+		t.Errorf("strfile index has %d trailing bytes", reader.Len())
+	}
+}
+
+func TestFortuneArchiveWriter(t *testing.T) {
+	quotes := []*models.Quote{
+		{Text: "Know thyself.", Author: "Socrates"},
+		{Text: "Less is more.", Author: "Mies van der Rohe"},
+	}
+
+	var buf bytes.Buffer
+	writer := NewFortuneArchiveWriter(&buf)
+	writer.SetMetadata(Metadata{Name: "quotes-wisdom-20240115", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)})
+	for _, quote := range quotes {
+		if err := writer.WriteQuote(quote); err != nil {
+			t.Fatalf("WriteQuote() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("export is not a zip archive: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		files[file.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	if len(files) != 2 {
+		t.Fatalf("archive holds %d files, want the text and its index", len(files))
+	}
+
+	text, ok := files["quotes-wisdom-20240115"]
+	if !ok || string(text) != export(t, FormatFortune, quotes) {
+		t.Errorf("fortune text = %q", text)
+	}
+	index, ok := files["quotes-wisdom-20240115.dat"]
+	if !ok {
+		t.Fatal("archive has no .dat index")
+	}
+
+	// Every offset in the index starts a fortune of the text beside it
+	reader := bytes.NewReader(index[24:])
+	offsets := make([]uint32, len(quotes)+1)
+	if err := binary.Read(reader, binary.BigEndian, offsets); err != nil {
+		t.Fatalf("failed to read offsets: %v", err)
+	}
+	if offsets[1] != uint32(len("Know thyself.\n\t\t-- Socrates\n%\n")) || offsets[2] != uint32(len(text)) {
+		t.Errorf("strfile offsets = %v for a text of %d bytes", offsets, len(text))
+	}
+	if !bytes.HasPrefix(text[offsets[1]:], []byte("Less is more.")) {
+		t.Errorf("second offset %d does not start a fortune", offsets[1])
+	}
+}
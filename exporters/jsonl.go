@@ -0,0 +1,30 @@
+package exporters
+
+import (
+	"encoding/json"
+	"io"
+
+	"quote-vault/models"
+)
+
+// JSONLWriter writes one JSON quote object per line
+type JSONLWriter struct {
+	enc *json.Encoder
+}
+
+// NewJSONLWriter creates a JSON Lines export writer
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JSONLWriter{enc: enc}
+}
+
+// WriteQuote writes a single quote line
+func (j *JSONLWriter) WriteQuote(quote *models.Quote) error {
+	return j.enc.Encode(quote)
+}
+
+// Close is a no-op; every line is written as soon as it is encoded
+func (j *JSONLWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,70 @@
+package exporters
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"quote-vault/models"
+)
+
+// MarkdownWriter renders quotes as a reviewable document with a section per
+// category and a subsection per author. Quotes must arrive ordered by
+// category and then author for the grouping to hold.
+type MarkdownWriter struct {
+	w        *bufio.Writer
+	count    int
+	category string
+	author   string
+}
+
+// NewMarkdownWriter creates a Markdown export writer
+func NewMarkdownWriter(w io.Writer) *MarkdownWriter {
+	return &MarkdownWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteQuote writes a quote, opening new category and author headings as
+// they change
+func (m *MarkdownWriter) WriteQuote(quote *models.Quote) error {
+	if m.count == 0 {
+		m.w.WriteString("# Quote Vault\n\n")
+	}
+	if m.count == 0 || quote.Category != m.category {
+		m.category = quote.Category
+		m.author = ""
+		fmt.Fprintf(m.w, "## %s\n\n", markdownHeading(quote.Category))
+	}
+	if m.author == "" || quote.Author != m.author {
+		m.author = quote.Author
+		fmt.Fprintf(m.w, "### %s\n\n", markdownHeading(quote.Author))
+	}
+	m.count++
+
+	for _, line := range strings.Split(strings.TrimSpace(quote.Text), "\n") {
+		m.w.WriteString(strings.TrimRight("> "+strings.TrimSpace(line), " ") + "\n")
+	}
+	if quote.Source != "" {
+		fmt.Fprintf(m.w, ">\n> — *%s*\n", markdownHeading(quote.Source))
+	}
+	m.w.WriteString("\n")
+
+	return nil
+}
+
+// Close flushes the document
+func (m *MarkdownWriter) Close() error {
+	if m.count == 0 {
+		m.w.WriteString("# Quote Vault\n\n_No quotes._\n")
+	}
+	return m.w.Flush()
+}
+
+// markdownHeading keeps a value on a single heading line
+func markdownHeading(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return "Unknown"
+	}
+	return s
+}
@@ -0,0 +1,399 @@
+package exporters
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"quote-vault/models"
+)
+
+// Page geometry of PDF exports in points: an A5 page, the usual size for a
+// small printed anthology
+const (
+	pdfPageWidth    = 420
+	pdfPageHeight   = 595
+	pdfMarginX      = 54
+	pdfMarginTop    = 64
+	pdfMarginBottom = 64
+	pdfFooterY      = 36
+	pdfTextWidth    = pdfPageWidth - 2*pdfMarginX
+)
+
+// Type sizes and spacing in points
+const (
+	pdfTitleSize       = 24
+	pdfTitleLeading    = 30
+	pdfHeadingSize     = 16
+	pdfHeadingSpace    = 40
+	pdfTextSize        = 11
+	pdfTextLeading     = 15
+	pdfBylineSize      = 10
+	pdfBylineLeading   = 13
+	pdfBylineSpace     = 4
+	pdfQuoteSpace      = 16
+	pdfFooterSize      = 9
+	pdfSubtitleSize    = 12
+	pdfSubtitleLeading = 16
+)
+
+// Objects at fixed numbers, written when the document is closed. Fonts
+// follow them, one per entry in pdfFonts.
+const (
+	pdfCatalogObject = 1
+	pdfPagesObject   = 2
+	pdfFirstFont     = 3
+)
+
+// PDFWriter lays quotes out as a printable PDF: a title page, then a
+// chapter per category starting on a new page, with page numbers and a
+// bookmark per chapter. Quotes must arrive ordered by category for the
+// chapters to hold. Pages are written as soon as they fill up, so only the
+// current page is held in memory.
+type PDFWriter struct {
+	w      *bufio.Writer
+	offset int64
+	err    error
+	meta   Metadata
+
+	started bool
+	// offsets holds the file offset of each object, indexed by number
+	offsets []int64
+	pages   []int
+	marks   []pdfBookmark
+
+	// The page being laid out
+	page      *bytes.Buffer
+	pageObj   int
+	y         float64
+	pageEmpty bool
+	category  string
+	count     int
+}
+
+// pdfBookmark is an entry in the document outline
+type pdfBookmark struct {
+	Title string
+	Page  int
+}
+
+// NewPDFWriter creates a PDF export writer
+func NewPDFWriter(w io.Writer) *PDFWriter {
+	return &PDFWriter{
+		w:       bufio.NewWriter(w),
+		meta:    Metadata{Title: defaultBookTitle},
+		offsets: make([]int64, pdfFirstFont+len(pdfFonts)),
+	}
+}
+
+// SetMetadata sets the title, description and date of the document
+func (p *PDFWriter) SetMetadata(meta Metadata) {
+	if meta.Title == "" {
+		meta.Title = defaultBookTitle
+	}
+	p.meta = meta
+}
+
+// WriteQuote lays out a quote, starting a new chapter when the category
+// changes and a new page when the quote does not fit
+func (p *PDFWriter) WriteQuote(quote *models.Quote) error {
+	p.start()
+	if p.count == 0 || quote.Category != p.category {
+		p.category = quote.Category
+		p.startChapter(chapterTitle(quote.Category))
+	}
+	p.count++
+
+	paragraphs := bookParagraphs(quote.Text)
+	if len(paragraphs) > 0 {
+		paragraphs[0] = "“" + paragraphs[0]
+		paragraphs[len(paragraphs)-1] += "”"
+	}
+	var lines [][]byte
+	for _, paragraph := range paragraphs {
+		lines = append(lines, wrapText(fontRoman, pdfTextSize, winAnsi(paragraph), pdfTextWidth)...)
+	}
+	byline := "— " + quote.Author
+	if quote.Source != "" {
+		byline += ", " + quote.Source
+	}
+	bylines := wrapText(fontItalic, pdfBylineSize, winAnsi(byline), pdfTextWidth)
+
+	// Keep a quote on one page unless it is longer than a page
+	height := float64(len(lines)*pdfTextLeading + pdfBylineSpace + len(bylines)*pdfBylineLeading)
+	if !p.pageEmpty && p.y-height < pdfMarginBottom {
+		p.endPage(true)
+		p.newPage()
+	}
+	p.pageEmpty = false
+
+	for _, line := range lines {
+		p.ensureRoom(pdfTextLeading)
+		p.y -= pdfTextLeading
+		p.text(fontRoman, pdfTextSize, pdfMarginX, p.y, line)
+	}
+	p.y -= pdfBylineSpace
+	for _, line := range bylines {
+		p.ensureRoom(pdfBylineLeading)
+		p.y -= pdfBylineLeading
+		p.text(fontItalic, pdfBylineSize, pdfPageWidth-pdfMarginX-fontItalic.width(line, pdfBylineSize), p.y, line)
+	}
+	p.y -= pdfQuoteSpace
+
+	return p.err
+}
+
+// Close finishes the last page and writes the page tree, outline, document
+// information and cross-reference table
+func (p *PDFWriter) Close() error {
+	p.start()
+	if p.page != nil {
+		p.endPage(true)
+	}
+
+	kids := make([]string, len(p.pages))
+	for i, page := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	p.writeObject(pdfPagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+
+	catalog := fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R", pdfPagesObject)
+	if len(p.marks) > 0 {
+		catalog += fmt.Sprintf(" /Outlines %d 0 R /PageMode /UseOutlines", p.writeOutline())
+	}
+	p.writeObject(pdfCatalogObject, catalog+" >>")
+
+	date := p.meta.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	info := p.newObject()
+	p.writeObject(info, fmt.Sprintf("<< /Title %s /Subject %s /Producer %s /CreationDate (D:%s) >>",
+		pdfTextString(p.meta.Title), pdfTextString(p.meta.Description), pdfTextString(bookPublisher), date.UTC().Format("20060102150405Z")))
+
+	xref := p.offset
+	p.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)))
+	for _, offset := range p.offsets[1:] {
+		p.write(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	p.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(p.offsets), pdfCatalogObject, info, xref))
+
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+// start writes the file header and fonts and lays out the title page
+func (p *PDFWriter) start() {
+	if p.started {
+		return
+	}
+	p.started = true
+
+	// The binary comment tells transfer tools the file is not plain text
+	p.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	for i, font := range pdfFonts {
+		p.writeObject(pdfFirstFont+i, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", font.Base))
+	}
+
+	p.newPage()
+	p.y = pdfPageHeight * 0.65
+	for _, line := range wrapText(fontBold, pdfTitleSize, winAnsi(p.meta.Title), pdfTextWidth) {
+		p.y -= pdfTitleLeading
+		p.centered(fontBold, pdfTitleSize, p.y, line)
+	}
+	p.y -= pdfSubtitleLeading
+	for _, line := range wrapText(fontItalic, pdfSubtitleSize, winAnsi(p.meta.Description), pdfTextWidth) {
+		p.y -= pdfSubtitleLeading
+		p.centered(fontItalic, pdfSubtitleSize, p.y, line)
+	}
+	if !p.meta.Date.IsZero() {
+		p.centered(fontRoman, pdfFooterSize+1, pdfMarginBottom*2, winAnsi(p.meta.Date.Format("2 January 2006")))
+	}
+	// The title page carries no page number
+	p.endPage(false)
+}
+
+// startChapter begins a new page headed with a chapter title
+func (p *PDFWriter) startChapter(title string) {
+	if p.page != nil {
+		p.endPage(true)
+	}
+	p.newPage()
+	p.marks = append(p.marks, pdfBookmark{Title: title, Page: p.pageObj})
+
+	p.y -= pdfHeadingSize
+	p.centered(fontBold, pdfHeadingSize, p.y, winAnsi(title))
+	p.y -= pdfHeadingSpace - pdfHeadingSize
+}
+
+// ensureRoom moves to a new page when the current one has less than
+// height points left
+func (p *PDFWriter) ensureRoom(height float64) {
+	if p.y-height < pdfMarginBottom {
+		p.endPage(true)
+		p.newPage()
+	}
+}
+
+// newPage starts laying out a page
+func (p *PDFWriter) newPage() {
+	p.page = &bytes.Buffer{}
+	p.pageObj = p.newObject()
+	p.y = pdfPageHeight - pdfMarginTop
+	p.pageEmpty = true
+}
+
+// endPage writes the current page's content stream and page object,
+// numbering the page at its foot if asked
+func (p *PDFWriter) endPage(numbered bool) {
+	if numbered {
+		p.centered(fontRoman, pdfFooterSize, pdfFooterY, []byte(fmt.Sprint(len(p.pages)+1)))
+	}
+
+	var content bytes.Buffer
+	zw := zlib.NewWriter(&content)
+	zw.Write(p.page.Bytes())
+	zw.Close()
+
+	contents := p.newObject()
+	p.writeObject(contents, fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.Bytes()))
+
+	fonts := make([]string, len(pdfFonts))
+	for i, font := range pdfFonts {
+		fonts[i] = fmt.Sprintf("/%s %d 0 R", font.Name, pdfFirstFont+i)
+	}
+	p.writeObject(p.pageObj, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+		pdfPagesObject, pdfPageWidth, pdfPageHeight, strings.Join(fonts, " "), contents))
+
+	p.pages = append(p.pages, p.pageObj)
+	p.page = nil
+}
+
+// writeOutline writes the bookmarks and returns the outline's object
+// number
+func (p *PDFWriter) writeOutline() int {
+	root := p.newObject()
+	items := make([]int, len(p.marks))
+	for i := range p.marks {
+		items[i] = p.newObject()
+	}
+
+	for i, mark := range p.marks {
+		item := fmt.Sprintf("<< /Title %s /Parent %d 0 R /Dest [%d 0 R /Fit]", pdfTextString(mark.Title), root, mark.Page)
+		if i > 0 {
+			item += fmt.Sprintf(" /Prev %d 0 R", items[i-1])
+		}
+		if i < len(items)-1 {
+			item += fmt.Sprintf(" /Next %d 0 R", items[i+1])
+		}
+		p.writeObject(items[i], item+" >>")
+	}
+	p.writeObject(root, fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>", items[0], items[len(items)-1], len(items)))
+
+	return root
+}
+
+// text draws a line of WinAnsi text with its baseline at (x, y)
+func (p *PDFWriter) text(font *pdfFont, size, x, y float64, line []byte) {
+	fmt.Fprintf(p.page, "BT /%s %g Tf %.2f %.2f Td %s Tj ET\n", font.Name, size, x, y, pdfString(line))
+}
+
+// centered draws a line of text centered on the page
+func (p *PDFWriter) centered(font *pdfFont, size, y float64, line []byte) {
+	p.text(font, size, (pdfPageWidth-font.width(line, size))/2, y, line)
+}
+
+// newObject reserves the next object number
+func (p *PDFWriter) newObject() int {
+	p.offsets = append(p.offsets, 0)
+	return len(p.offsets) - 1
+}
+
+// writeObject writes an object, recording its offset for the
+// cross-reference table
+func (p *PDFWriter) writeObject(number int, body string) {
+	p.offsets[number] = p.offset
+	p.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", number, body))
+}
+
+func (p *PDFWriter) write(s string) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.WriteString(s)
+	p.offset += int64(n)
+	p.err = err
+}
+
+// wrapText breaks text into lines no wider than width, breaking at spaces
+// and splitting words that are wider than a line on their own
+func wrapText(font *pdfFont, size float64, text []byte, width float64) [][]byte {
+	var lines [][]byte
+	var line []byte
+	for _, word := range bytes.Fields(text) {
+		candidate := word
+		if len(line) > 0 {
+			candidate = append(append(append([]byte{}, line...), ' '), word...)
+		}
+		if font.width(candidate, size) <= width {
+			line = candidate
+			continue
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+		for font.width(word, size) > width {
+			n := 1
+			for n < len(word) && font.width(word[:n+1], size) <= width {
+				n++
+			}
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		line = word
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfString writes WinAnsi text as a PDF literal string, escaping the
+// delimiters and using octal escapes for bytes outside ASCII
+func pdfString(text []byte) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range text {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// pdfTextString encodes text for the document outline and information,
+// which PDF readers show in full Unicode, as UTF-16 with a byte order mark
+func pdfTextString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", unit)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
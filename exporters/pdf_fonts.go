@@ -0,0 +1,120 @@
+package exporters
+
+// pdfFont is one of the standard Type 1 fonts that every PDF reader
+// provides, so nothing needs to be embedded. Text is drawn in
+// WinAnsiEncoding, which covers Western European languages.
+type pdfFont struct {
+	// Name is the resource name content streams refer to the font by
+	Name string
+	// Base is the PostScript name of the font
+	Base string
+	// widths holds the advance widths of the printable ASCII characters in
+	// thousandths of the type size, from the Adobe font metrics
+	widths *[95]int
+}
+
+var (
+	fontRoman  = &pdfFont{Name: "F1", Base: "Times-Roman", widths: &timesRomanWidths}
+	fontItalic = &pdfFont{Name: "F2", Base: "Times-Italic", widths: &timesItalicWidths}
+	fontBold   = &pdfFont{Name: "F3", Base: "Times-Bold", widths: &timesBoldWidths}
+)
+
+// pdfFonts lists the fonts every document declares, in object order
+var pdfFonts = []*pdfFont{fontRoman, fontItalic, fontBold}
+
+// width returns the width of WinAnsi-encoded text at the given size, in
+// points
+func (f *pdfFont) width(text []byte, size float64) float64 {
+	total := 0
+	for _, c := range text {
+		total += f.glyphWidth(c)
+	}
+	return float64(total) * size / 1000
+}
+
+// glyphWidth returns the advance width of a WinAnsi character. Characters
+// outside printable ASCII use the widest value among the three faces, so
+// lines are never measured short.
+func (f *pdfFont) glyphWidth(c byte) int {
+	switch {
+	case c >= 32 && c <= 126:
+		return f.widths[c-32]
+	case c == 0x85, c == 0x89, c == 0x97, c == 0x99:
+		// ellipsis, perthousand, emdash, trademark
+		return 1000
+	case c == 0x82, c == 0x8b, c == 0x91, c == 0x92, c == 0x9b:
+		// single quotes and guillemets
+		return 333
+	case c == 0x84, c == 0x93, c == 0x94:
+		// double quotes
+		return 556
+	case c == 0x95:
+		// bullet
+		return 350
+	case c == 0x8c, c == 0xc6:
+		// OE and AE ligatures
+		return 1000
+	case c >= 0xc0 && c <= 0xde:
+		// accented capitals
+		return 778
+	default:
+		return 556
+	}
+}
+
+// winAnsiSpecials maps the characters WinAnsiEncoding places in 0x80-0x9F
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// winAnsi encodes text in WinAnsiEncoding. Characters the encoding lacks
+// become question marks.
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r <= 0x7e, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		case r == '\t':
+			out = append(out, ' ')
+		default:
+			if c, ok := winAnsiSpecials[r]; ok {
+				out = append(out, c)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// Advance widths of the characters from space (32) to tilde (126)
+var (
+	timesRomanWidths = [95]int{
+		250, 333, 408, 500, 500, 833, 778, 180, 333, 333, 500, 564, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 278, 278, 564, 564, 564, 444,
+		921, 722, 667, 667, 722, 611, 556, 722, 722, 333, 389, 722, 611, 889, 722, 722,
+		556, 722, 667, 556, 611, 722, 722, 944, 722, 722, 611, 333, 278, 333, 469, 500,
+		333, 444, 500, 444, 500, 444, 333, 500, 500, 278, 278, 500, 278, 778, 500, 500,
+		500, 500, 333, 389, 278, 500, 500, 722, 500, 500, 444, 480, 200, 480, 541,
+	}
+	timesItalicWidths = [95]int{
+		250, 333, 420, 500, 500, 833, 778, 214, 333, 333, 500, 675, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 675, 675, 675, 500,
+		920, 611, 611, 667, 722, 611, 611, 722, 722, 333, 444, 667, 556, 833, 667, 722,
+		611, 722, 611, 500, 556, 722, 611, 833, 611, 556, 556, 389, 278, 389, 422, 500,
+		333, 500, 500, 444, 500, 444, 278, 500, 500, 278, 278, 444, 278, 722, 500, 500,
+		500, 500, 389, 389, 278, 500, 444, 667, 444, 444, 389, 400, 275, 400, 541,
+	}
+	timesBoldWidths = [95]int{
+		250, 333, 555, 500, 500, 1000, 833, 278, 333, 333, 500, 570, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 570, 570, 570, 500,
+		930, 722, 667, 722, 722, 667, 611, 778, 778, 389, 500, 778, 667, 944, 722, 778,
+		611, 778, 722, 556, 667, 722, 722, 1000, 722, 722, 667, 333, 278, 333, 581, 500,
+		333, 500, 556, 444, 556, 444, 333, 500, 556, 278, 333, 556, 278, 833, 556, 500,
+		556, 556, 444, 389, 333, 556, 500, 722, 500, 500, 444, 394, 220, 394, 520,
+	}
+)
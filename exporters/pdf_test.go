@@ -0,0 +1,145 @@
+package exporters
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func TestPDFWriter(t *testing.T) {
+	quotes := testQuotes()
+	for i := 0; i < 40; i++ {
+		quotes = append(quotes, &models.Quote{ID: 10 + i, Text: strings.Repeat("A long quote that needs several lines. ", 5), Author: "Filler", Category: "wisdom"})
+	}
+
+	var buf bytes.Buffer
+	writer := NewPDFWriter(&buf)
+	writer.SetMetadata(Metadata{Title: "Anthology 2024", Description: "Quotes from 2024", Date: time.Date(2024, 12, 31, 9, 0, 0, 0, time.UTC)})
+	for _, quote := range quotes {
+		if err := writer.WriteQuote(quote); err != nil {
+			t.Fatalf("WriteQuote() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	pdf := buf.Bytes()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("output is not framed as a PDF file")
+	}
+
+	// The cross-reference table must point at every object
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if match == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj\n"; !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q, want %q", i+1, pdf[offset:offset+10], want)
+		}
+	}
+
+	// Title page, one page of motivation and several of wisdom
+	count := regexp.MustCompile(`/Type /Pages /Kids \[[^\]]*\] /Count (\d+)`).FindSubmatch(pdf)
+	if count == nil {
+		t.Fatal("no page tree")
+	}
+	if pages, _ := strconv.Atoi(string(count[1])); pages < 4 {
+		t.Errorf("page count = %d, want the wisdom chapter to run over several pages", pages)
+	}
+	if !bytes.Contains(pdf, []byte("/Type /Outlines")) || !bytes.Contains(pdf, []byte("/Count 2 >>")) {
+		t.Error("no outline with a bookmark per chapter")
+	}
+	if !bytes.Contains(pdf, []byte("/Title "+pdfTextString("Anthology 2024"))) {
+		t.Error("document information has no title")
+	}
+
+	text := pdfContent(t, pdf)
+	for _, want := range []string{
+		"(Anthology 2024) Tj",
+		"(Motivation) Tj",
+		"(\\223Know thyself.) Tj",
+		"(And nothing in excess.\\224) Tj",
+		"(\\227 Sheryl Sandberg, Lean In) Tj",
+		"(2) Tj",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("page content does not contain %q", want)
+		}
+	}
+
+	// Lines stay within the margins
+	for _, m := range regexp.MustCompile(`/F(\d) (\d+) Tf ([\d.]+) [\d.]+ Td \((.*)\) Tj`).FindAllStringSubmatch(text, -1) {
+		x, _ := strconv.ParseFloat(m[3], 64)
+		if x < pdfMarginX-0.01 && m[4] != "2" {
+			t.Errorf("line %q starts at x = %v, inside the left margin", m[4], x)
+		}
+	}
+}
+
+// pdfContent inflates every content stream of a PDF
+func pdfContent(t *testing.T, pdf []byte) string {
+	t.Helper()
+
+	var out strings.Builder
+	for _, m := range regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`).FindAllSubmatchIndex(pdf, -1) {
+		length, _ := strconv.Atoi(string(pdf[m[2]:m[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(pdf[m[1] : m[1]+length]))
+		if err != nil {
+			t.Fatalf("content stream is not zlib: %v", err)
+		}
+		data, _ := io.ReadAll(zr)
+		out.Write(data)
+	}
+	return out.String()
+}
+
+func TestWrapText(t *testing.T) {
+	text := winAnsi("The quick brown fox jumps over the lazy dog, then Pneumonoultramicroscopicsilicovolcanoconiosis.")
+	lines := wrapText(fontRoman, 12, text, 100)
+	if len(lines) < 3 {
+		t.Fatalf("wrapText() = %q, want several lines", lines)
+	}
+	var words []string
+	for _, line := range lines {
+		if w := fontRoman.width(line, 12); w > 100 {
+			t.Errorf("line %q is %v points wide, want at most 100", line, w)
+		}
+		words = append(words, string(line))
+	}
+	if strings.Join(strings.Fields(strings.Join(words, " ")), "") != strings.Join(strings.Fields(string(text)), "") {
+		t.Errorf("wrapText() = %q, want the same characters as the input", words)
+	}
+}
+
+func TestWinAnsi(t *testing.T) {
+	got := winAnsi("“Ça va” — 5 € 😀")
+	want := []byte{0x93, 0xc7, 'a', ' ', 'v', 'a', 0x94, ' ', 0x97, ' ', '5', ' ', 0x80, ' ', '?'}
+	if !bytes.Equal(got, want) {
+		t.Errorf("winAnsi() = % x, want % x", got, want)
+	}
+}
+
+func TestFontWidths(t *testing.T) {
+	for _, font := range pdfFonts {
+		for i, width := range font.widths {
+			if width == 0 {
+				t.Errorf("%s has no width for %q", font.Base, rune(32+i))
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package exporters
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"quote-vault/models"
+)
+
+// YAMLWriter writes quotes as a YAML sequence of mappings. Strings are
+// always double-quoted so no value can be misread as another YAML type.
+type YAMLWriter struct {
+	w       *bufio.Writer
+	written bool
+}
+
+// NewYAMLWriter creates a YAML export writer
+func NewYAMLWriter(w io.Writer) *YAMLWriter {
+	return &YAMLWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteQuote writes a single sequence entry
+func (y *YAMLWriter) WriteQuote(quote *models.Quote) error {
+	if !y.written {
+		y.written = true
+		y.w.WriteString("quotes:\n")
+	}
+	fmt.Fprintf(y.w, "  - id: %d\n", quote.ID)
+	fmt.Fprintf(y.w, "    text: %s\n", strconv.Quote(quote.Text))
+	fmt.Fprintf(y.w, "    author: %s\n", strconv.Quote(quote.Author))
+	fmt.Fprintf(y.w, "    category: %s\n", strconv.Quote(quote.Category))
+	if quote.Source != "" {
+		fmt.Fprintf(y.w, "    source: %s\n", strconv.Quote(quote.Source))
+	}
+	if len(quote.Tags) > 0 {
+		y.w.WriteString("    tags:\n")
+		for _, tag := range quote.Tags {
+			fmt.Fprintf(y.w, "      - %s\n", strconv.Quote(tag))
+		}
+	}
+	if quote.Likes > 0 {
+		fmt.Fprintf(y.w, "    likes: %d\n", quote.Likes)
+	}
+	fmt.Fprintf(y.w, "    created_at: %s\n", quote.CreatedAt.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// Close writes an empty sequence if nothing was written and flushes
+func (y *YAMLWriter) Close() error {
+	if !y.written {
+		y.w.WriteString("quotes: []\n")
+	}
+	return y.w.Flush()
+}
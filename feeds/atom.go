@@ -0,0 +1,90 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Link      atomLink      `xml:"link"`
+	Published string        `xml:"published"`
+	Updated   string        `xml:"updated"`
+	Author    atomAuthor    `xml:"author"`
+	Category  *atomCategory `xml:"category"`
+	Content   atomContent   `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeAtom(w io.Writer, feed *Feed) error {
+	doc := atomFeed{
+		ID:       feed.ID,
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		Updated:  atomTime(feed.Updated),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: feed.Self},
+			{Rel: "alternate", Href: feed.Link},
+		},
+	}
+	for _, entry := range feed.Entries {
+		e := atomEntry{
+			ID:        entry.ID,
+			Title:     entry.Title,
+			Link:      atomLink{Rel: "alternate", Href: entry.Link},
+			Published: atomTime(entry.Published),
+			Updated:   atomTime(entry.Updated),
+			Author:    atomAuthor{Name: entry.Author},
+			Content:   atomContent{Type: "text", Body: entry.Content},
+		}
+		if entry.Category != "" {
+			e.Category = &atomCategory{Term: entry.Category}
+		}
+		doc.Entries = append(doc.Entries, e)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,98 @@
+// Package feeds writes lists of quotes as Atom and RSS syndication feeds.
+package feeds
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"quote-vault/models"
+)
+
+// Supported feed format names
+const (
+	FormatAtom = "atom"
+	FormatRSS  = "rss"
+)
+
+// ContentType returns the media type of a feed format
+func ContentType(format string) string {
+	if format == FormatRSS {
+		return "application/rss+xml; charset=utf-8"
+	}
+	return "application/atom+xml; charset=utf-8"
+}
+
+// Feed is a titled list of entries, newest first
+type Feed struct {
+	ID          string
+	Title       string
+	Description string
+	// Link is the page the feed describes and Self the feed's own URL
+	Link    string
+	Self    string
+	Updated time.Time
+	Entries []Entry
+}
+
+// Entry is a single quote in a feed
+type Entry struct {
+	ID        string
+	Title     string
+	Link      string
+	Content   string
+	Author    string
+	Category  string
+	Published time.Time
+	Updated   time.Time
+}
+
+// maxTitleLength is the number of characters of quote text used in an
+// entry title
+const maxTitleLength = 80
+
+// NewEntry builds a feed entry for a quote. id must stay the same for the
+// life of the quote, and link is the quote's page.
+func NewEntry(quote *models.Quote, id, link string) Entry {
+	text := strings.TrimSpace(quote.Text)
+	title := text
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		title = strings.TrimSpace(string([]rune(title)[:maxTitleLength-1])) + "…"
+	}
+
+	content := "“" + text + "” — " + quote.Author
+	if quote.Source != "" {
+		content += ", " + quote.Source
+	}
+
+	return Entry{
+		ID:        id,
+		Title:     fmt.Sprintf("“%s” — %s", title, quote.Author),
+		Link:      link,
+		Content:   content,
+		Author:    quote.Author,
+		Category:  quote.Category,
+		Published: quote.CreatedAt.UTC(),
+		Updated:   quote.CreatedAt.UTC(),
+	}
+}
+
+// TagURI returns a tag URI (RFC 4151) for use as a stable entry or feed ID.
+// authority is normally the host name the feed is served from and date the
+// day the resource first existed.
+func TagURI(authority string, date time.Time, specific string) string {
+	if i := strings.LastIndex(authority, ":"); i >= 0 && !strings.Contains(authority[i:], "]") {
+		authority = authority[:i]
+	}
+	return fmt.Sprintf("tag:%s,%s:%s", authority, date.UTC().Format("2006-01-02"), specific)
+}
+
+// Write writes the feed in the given format
+func Write(w io.Writer, format string, feed *Feed) error {
+	if format == FormatRSS {
+		return writeRSS(w, feed)
+	}
+	return writeAtom(w, feed)
+}
@@ -0,0 +1,124 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func testFeed() *Feed {
+	quote := &models.Quote{
+		ID:        15,
+		Text:      "Be yourself; everyone else is already taken & <spoken> for.",
+		Author:    "Oscar Wilde",
+		Category:  "wit",
+		CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+	}
+	return &Feed{
+		ID:      "http://example.com/feeds/quotes.atom",
+		Title:   "Newest quotes",
+		Link:    "http://example.com/api/v1/quotes",
+		Self:    "http://example.com/feeds/quotes.atom",
+		Updated: quote.CreatedAt,
+		Entries: []Entry{NewEntry(quote, TagURI("example.com:8080", quote.CreatedAt, "quotes/15"), "http://example.com/api/v1/quotes/15")},
+	}
+}
+
+func TestTagURI(t *testing.T) {
+	date := time.Date(2024, 1, 15, 23, 0, 0, 0, time.UTC)
+	if got, want := TagURI("example.com:8080", date, "quotes/15"), "tag:example.com,2024-01-15:quotes/15"; got != want {
+		t.Errorf("TagURI() = %q, want %q", got, want)
+	}
+}
+
+func TestNewEntry_TruncatesTitle(t *testing.T) {
+	quote := &models.Quote{Text: strings.Repeat("long ", 40), Author: "Someone"}
+	entry := NewEntry(quote, "id", "link")
+	if !strings.Contains(entry.Title, "…” — Someone") {
+		t.Errorf("Title = %q, want a truncated quote", entry.Title)
+	}
+	if !strings.HasPrefix(entry.Content, "“long long") || strings.Contains(entry.Content, "…") {
+		t.Errorf("Content = %q, want the full quote", entry.Content)
+	}
+}
+
+func TestWrite_Atom(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatAtom, testFeed()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID       string `xml:"id"`
+			Updated  string `xml:"updated"`
+			Author   string `xml:"author>name"`
+			Category struct {
+				Term string `xml:"term,attr"`
+			} `xml:"category"`
+			Content string `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid Atom feed: %v\n%s", err, buf.String())
+	}
+
+	if doc.Updated != "2024-01-15T10:30:00Z" || len(doc.Entries) != 1 {
+		t.Fatalf("feed = %+v", doc)
+	}
+	entry := doc.Entries[0]
+	if entry.ID != "tag:example.com,2024-01-15:quotes/15" || entry.Updated != "2024-01-15T10:30:00Z" {
+		t.Errorf("entry id/updated = %q/%q", entry.ID, entry.Updated)
+	}
+	if entry.Author != "Oscar Wilde" || entry.Category.Term != "wit" {
+		t.Errorf("entry author/category = %q/%q", entry.Author, entry.Category.Term)
+	}
+	if !strings.Contains(entry.Content, "& <spoken>") {
+		t.Errorf("entry content = %q", entry.Content)
+	}
+}
+
+func TestWrite_RSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatRSS, testFeed()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				GUID struct {
+					IsPermaLink string `xml:"isPermaLink,attr"`
+					Value       string `xml:",chardata"`
+				} `xml:"guid"`
+				Creator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				PubDate string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid RSS feed: %v\n%s", err, buf.String())
+	}
+
+	if doc.Version != "2.0" || doc.Channel.LastBuildDate != "Mon, 15 Jan 2024 10:30:00 +0000" {
+		t.Errorf("rss version/lastBuildDate = %q/%q", doc.Version, doc.Channel.LastBuildDate)
+	}
+	if len(doc.Channel.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(doc.Channel.Items))
+	}
+	item := doc.Channel.Items[0]
+	if item.GUID.Value != "tag:example.com,2024-01-15:quotes/15" || item.GUID.IsPermaLink != "false" {
+		t.Errorf("guid = %+v", item.GUID)
+	}
+	if item.Creator != "Oscar Wilde" || item.PubDate != "Mon, 15 Jan 2024 10:30:00 +0000" {
+		t.Errorf("creator/pubDate = %q/%q", item.Creator, item.PubDate)
+	}
+}
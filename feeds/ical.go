@@ -0,0 +1,144 @@
+package feeds
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ICalContentType is the media type of iCalendar output
+const ICalContentType = "text/calendar; charset=utf-8"
+
+// Calendar is an iCalendar (RFC 5545) feed of all-day events
+type Calendar struct {
+	ProdID      string
+	Name        string
+	Description string
+	// TimeZone is an IANA zone name hinting to clients how the calendar's
+	// dates were chosen; all-day events themselves are zone-independent
+	TimeZone string
+	// Refresh is how often subscribers should fetch the calendar again
+	Refresh time.Duration
+	Events  []Event
+}
+
+// Event is an all-day calendar event
+type Event struct {
+	UID         string
+	Date        time.Time // only the year, month and day are used
+	Summary     string
+	Description string
+	URL         string
+	Categories  []string
+}
+
+// maxLineOctets is the longest content line RFC 5545 allows, excluding the
+// CRLF line break
+const maxLineOctets = 75
+
+// WriteICal writes the calendar. stamp is the DTSTAMP of every event, the
+// time the calendar's contents were produced.
+func WriteICal(w io.Writer, cal *Calendar, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", cal.ProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if cal.Name != "" {
+		line("X-WR-CALNAME", escapeText(cal.Name))
+	}
+	if cal.Description != "" {
+		line("X-WR-CALDESC", escapeText(cal.Description))
+	}
+	if cal.TimeZone != "" {
+		line("X-WR-TIMEZONE", escapeText(cal.TimeZone))
+	}
+	if cal.Refresh > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION", icalDuration(cal.Refresh))
+		line("X-PUBLISHED-TTL", icalDuration(cal.Refresh))
+	}
+
+	for _, event := range cal.Events {
+		y, m, d := event.Date.Date()
+		start := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", stamp.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE", start.Format("20060102"))
+		line("DTEND;VALUE=DATE", start.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY", escapeText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", escapeText(event.Description))
+		}
+		if event.URL != "" {
+			line("URL", event.URL)
+		}
+		if len(event.Categories) > 0 {
+			categories := make([]string, len(event.Categories))
+			for i, category := range event.Categories {
+				categories[i] = escapeText(category)
+			}
+			line("CATEGORIES", strings.Join(categories, ","))
+		}
+		// A quote should not show the day as busy
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// textEscaper escapes the characters RFC 5545 section 3.3.11 reserves in
+// TEXT values
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escapeText escapes a TEXT property value
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// writeFolded writes a content line followed by CRLF, folding it onto
+// continuation lines that start with a space so that no line exceeds 75
+// octets. Lines are only broken between UTF-8 characters.
+func writeFolded(bw *bufio.Writer, s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		bw.WriteString(s[:cut])
+		bw.WriteString("\r\n ")
+		s = s[cut:]
+		// The leading space counts towards the continuation line's length
+		limit = maxLineOctets - 1
+	}
+	bw.WriteString(s)
+	bw.WriteString("\r\n")
+}
+
+// icalDuration formats a duration of whole hours or minutes as an
+// RFC 5545 DURATION value
+func icalDuration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return "PT" + strconv.Itoa(int(d/time.Hour)) + "H"
+	}
+	return "PT" + strconv.Itoa(int(d/time.Minute)) + "M"
+}
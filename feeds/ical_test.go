@@ -0,0 +1,71 @@
+package feeds
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	got := escapeText("One, two; three\\four\nfive")
+	if want := `One\, two\; three\\four\nfive`; got != want {
+		t.Errorf("escapeText() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteICal(t *testing.T) {
+	cal := &Calendar{
+		ProdID:   "-//Test//Test//EN",
+		Name:     "Quote of the day",
+		TimeZone: "Europe/Berlin",
+		Refresh:  12 * time.Hour,
+		Events: []Event{{
+			UID:         "daily-2024-01-15@example.com",
+			Date:        time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			Summary:     "“Be yourself; everyone else is already taken.” — Oscar Wilde",
+			Description: strings.Repeat("Ünïcödé quote text, long enough to need folding. ", 4) + "\nhttp://example.com/quotes/15",
+			Categories:  []string{"wit"},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteICal(&buf, cal, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("WriteICal() error = %v", err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Errorf("calendar not wrapped in VCALENDAR:\n%s", out)
+	}
+	if strings.Contains(strings.ReplaceAll(out, "\r\n", ""), "\n") {
+		t.Error("found a bare LF line break")
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line is %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line splits a UTF-8 character: %q", line)
+		}
+	}
+
+	// Unfolding restores the original content lines
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	for _, want := range []string{
+		"DTSTART;VALUE=DATE:20240115\r\n",
+		"DTEND;VALUE=DATE:20240116\r\n",
+		"DTSTAMP:20240115T000000Z\r\n",
+		"SUMMARY:“Be yourself\\; everyone else is already taken.” — Oscar Wilde\r\n",
+		"\\nhttp://example.com/quotes/15\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT12H\r\n",
+		"X-WR-TIMEZONE:Europe/Berlin\r\n",
+		"CATEGORIES:wit\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("calendar does not contain %q:\n%s", want, unfolded)
+		}
+	}
+}
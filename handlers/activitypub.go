@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"quote-vault/activitypub"
+	"quote-vault/errors"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+// activityBodyLimit caps the size of an activity posted to an inbox
+const activityBodyLimit = 256 << 10
+
+// ActivityPubHandler serves the vault's ActivityPub actors and WebFinger
+// discovery
+type ActivityPubHandler struct {
+	activityPubService *services.ActivityPubService
+}
+
+func NewActivityPubHandler(activityPubService *services.ActivityPubService) *ActivityPubHandler {
+	return &ActivityPubHandler{
+		activityPubService: activityPubService,
+	}
+}
+
+// WebFinger describes the actor named by the ?resource= account, such as
+// acct:quotes@example.com
+func (h *ActivityPubHandler) WebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "resource parameter is required")
+		return
+	}
+
+	jrd, err := h.activityPubService.WebFinger(resource)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to look up resource")
+		return
+	}
+	writeActivityJSON(w, activitypub.WebFingerContentType, jrd)
+}
+
+// Actor serves an actor document. Browsers asking for HTML are sent to the
+// web page listing the actor's quotes instead.
+func (h *ActivityPubHandler) Actor(w http.ResponseWriter, r *http.Request) {
+	actor, err := h.activityPubService.Actor(mux.Vars(r)["name"])
+	if err != nil {
+		appErrorResponse(w, err, "Failed to get actor")
+		return
+	}
+	if wantsPage(r) {
+		http.Redirect(w, r, actor.URL, http.StatusSeeOther)
+		return
+	}
+	writeActivityJSON(w, activitypub.ContentType, actor)
+}
+
+// Outbox serves the collection of an actor's activities, or one page of
+// it with ?page=
+func (h *ActivityPubHandler) Outbox(w http.ResponseWriter, r *http.Request) {
+	page := 0
+	if value := r.URL.Query().Get("page"); value != "" {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			utils.ErrorResponse(w, errors.ErrInvalidPagination.Code, errors.ErrInvalidPagination.Message)
+			return
+		}
+	}
+
+	outbox, err := h.activityPubService.Outbox(mux.Vars(r)["name"], page)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to get outbox")
+		return
+	}
+	writeActivityJSON(w, activitypub.ContentType, outbox)
+}
+
+func (h *ActivityPubHandler) Followers(w http.ResponseWriter, r *http.Request) {
+	followers, err := h.activityPubService.Followers(mux.Vars(r)["name"])
+	if err != nil {
+		appErrorResponse(w, err, "Failed to get followers")
+		return
+	}
+	writeActivityJSON(w, activitypub.ContentType, followers)
+}
+
+// Note serves the note an actor publishes a quote as, or sends browsers to
+// the quote's page
+func (h *ActivityPubHandler) Note(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	note, err := h.activityPubService.Note(mux.Vars(r)["name"], id)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to get note")
+		return
+	}
+	if wantsPage(r) {
+		http.Redirect(w, r, note.URL, http.StatusSeeOther)
+		return
+	}
+	writeActivityJSON(w, activitypub.ContentType, note)
+}
+
+// Inbox accepts an activity signed by its sender. Accepted activities get
+// a 202; replies such as the Accept of a Follow are sent separately.
+func (h *ActivityPubHandler) Inbox(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, activityBodyLimit+1))
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Failed to read request")
+		return
+	}
+	if len(body) > activityBodyLimit {
+		utils.ErrorResponse(w, http.StatusRequestEntityTooLarge, "Request too large")
+		return
+	}
+
+	if err := h.activityPubService.HandleInbox(r.Context(), mux.Vars(r)["name"], r, body); err != nil {
+		appErrorResponse(w, err, "Failed to handle activity")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// wantsPage reports whether a request for an ActivityPub object comes from
+// a browser following a link rather than from a server
+func wantsPage(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return !activitypub.AcceptsActivity(accept) && strings.Contains(accept, "text/html")
+}
+
+// writeActivityJSON sends an ActivityPub or WebFinger document
+func writeActivityJSON(w http.ResponseWriter, contentType string, v interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	// Note content is HTML, which reads better unescaped
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		log.Printf("Error encoding ActivityPub response: %v", err)
+	}
+}
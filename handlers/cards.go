@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"image/color"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/render"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+// CardHandler serves quotes rendered as images
+type CardHandler struct {
+	quoteService *services.QuoteService
+	cardService  *services.CardService
+}
+
+func NewCardHandler(quoteService *services.QuoteService, cardService *services.CardService) *CardHandler {
+	return &CardHandler{
+		quoteService: quoteService,
+		cardService:  cardService,
+	}
+}
+
+// GetQuoteCard renders a quote as an SVG card
+func (h *CardHandler) GetQuoteCard(w http.ResponseWriter, r *http.Request) {
+	theme, ok := cardTheme(w, r)
+	if !ok {
+		return
+	}
+
+	quote, ok := h.quoteByID(w, r)
+	if !ok {
+		return
+	}
+
+	writeSVGCard(w, quote, theme)
+}
+
+// GetRandomQuoteCard renders a random quote as an SVG card
+func (h *CardHandler) GetRandomQuoteCard(w http.ResponseWriter, r *http.Request) {
+	theme, ok := cardTheme(w, r)
+	if !ok {
+		return
+	}
+
+	quote, ok := h.randomQuote(w, r)
+	if !ok {
+		return
+	}
+
+	noCache(w)
+	writeSVGCard(w, quote, theme)
+}
+
+// GetQuotePNG renders a quote as a PNG card. The ETag follows the quote's
+// version and the rendering options, so clients revalidate cheaply and see
+// edits straight away.
+func (h *CardHandler) GetQuotePNG(w http.ResponseWriter, r *http.Request) {
+	opts, ok := pngOptions(w, r)
+	if !ok {
+		return
+	}
+
+	quote, ok := h.quoteByID(w, r)
+	if !ok {
+		return
+	}
+
+	sum := sha1.Sum([]byte(services.CardKey(quote, opts)))
+	etag := `"card-` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if notModified(w, r, etag, time.Time{}) {
+		return
+	}
+
+	h.writePNGCard(w, quote, opts)
+}
+
+// GetRandomQuotePNG renders a random quote as a PNG card
+func (h *CardHandler) GetRandomQuotePNG(w http.ResponseWriter, r *http.Request) {
+	opts, ok := pngOptions(w, r)
+	if !ok {
+		return
+	}
+
+	quote, ok := h.randomQuote(w, r)
+	if !ok {
+		return
+	}
+
+	noCache(w)
+	h.writePNGCard(w, quote, opts)
+}
+
+func (h *CardHandler) quoteByID(w http.ResponseWriter, r *http.Request) (*models.Quote, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, errors.ErrInvalidID.Code, errors.ErrInvalidID.Message)
+		return nil, false
+	}
+
+	quote, err := h.quoteService.GetQuoteByID(id)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			utils.ErrorResponse(w, appErr.Code, appErr.Message)
+			return nil, false
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get quote")
+		return nil, false
+	}
+	return quote, true
+}
+
+func (h *CardHandler) randomQuote(w http.ResponseWriter, r *http.Request) (*models.Quote, bool) {
+	quote, err := h.quoteService.GetRandomQuote(r.URL.Query().Get("category"))
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			utils.ErrorResponse(w, appErr.Code, appErr.Message)
+			return nil, false
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get random quote")
+		return nil, false
+	}
+	return quote, true
+}
+
+// noCache marks a random card as uncacheable, otherwise image proxies such
+// as GitHub's would keep showing the same quote
+func noCache(w http.ResponseWriter) {
+	header := w.Header()
+	header.Set("Cache-Control", "no-cache, no-store, must-revalidate, max-age=0")
+	header.Set("Pragma", "no-cache")
+	header.Set("Expires", "Thu, 01 Jan 1970 00:00:00 GMT")
+	header.Del("ETag")
+}
+
+// cardTheme reads ?theme=, writing a 400 response and reporting false when
+// the theme is unknown
+func cardTheme(w http.ResponseWriter, r *http.Request) (render.Theme, bool) {
+	theme, ok := render.ParseTheme(r.URL.Query().Get("theme"))
+	if !ok {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Unknown theme, use light, dark or minimal")
+	}
+	return theme, ok
+}
+
+// pngOptions reads the PNG card options from the query string, starting
+// from the colors of ?theme=:
+//
+//	width, height  image size in pixels
+//	padding        margin around the text in pixels
+//	bg             a hex color, two comma-separated colors for a gradient,
+//	               or "transparent"
+//	color          hex color of the quote text
+//
+// It writes a 400 response and reports false when an option is invalid.
+func pngOptions(w http.ResponseWriter, r *http.Request) (render.PNGOptions, bool) {
+	theme, ok := cardTheme(w, r)
+	if !ok {
+		return render.PNGOptions{}, false
+	}
+	opts := render.DefaultPNGOptions(theme)
+	query := r.URL.Query()
+
+	sizes := []struct {
+		name     string
+		value    *int
+		min, max int
+	}{
+		{"width", &opts.Width, render.MinPNGWidth, render.MaxPNGWidth},
+		{"height", &opts.Height, render.MinPNGHeight, render.MaxPNGHeight},
+	}
+	for _, size := range sizes {
+		if raw := query.Get(size.name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < size.min || n > size.max {
+				utils.ErrorResponse(w, http.StatusBadRequest, size.name+" must be between "+strconv.Itoa(size.min)+" and "+strconv.Itoa(size.max))
+				return opts, false
+			}
+			*size.value = n
+		}
+	}
+
+	// Keep room for at least twenty characters of text
+	maxPadding := (opts.Width - 120) / 2
+	if opts.Height/4 < maxPadding {
+		maxPadding = opts.Height / 4
+	}
+	if opts.Padding > maxPadding {
+		opts.Padding = maxPadding
+	}
+	if raw := query.Get("padding"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxPadding {
+			utils.ErrorResponse(w, http.StatusBadRequest, "padding must be between 0 and "+strconv.Itoa(maxPadding))
+			return opts, false
+		}
+		opts.Padding = n
+	}
+
+	if raw := query.Get("bg"); raw != "" {
+		opts.Background = nil
+		if raw != "transparent" {
+			parts := strings.Split(raw, ",")
+			if len(parts) > 2 {
+				utils.ErrorResponse(w, http.StatusBadRequest, "bg takes one color or two for a gradient")
+				return opts, false
+			}
+			for _, part := range parts {
+				c, ok := render.ParseColor(part)
+				if !ok {
+					utils.ErrorResponse(w, http.StatusBadRequest, "Invalid bg color "+part)
+					return opts, false
+				}
+				opts.Background = append(opts.Background, c)
+			}
+		}
+	}
+
+	if raw := query.Get("color"); raw != "" {
+		c, ok := render.ParseColor(raw)
+		if !ok {
+			utils.ErrorResponse(w, http.StatusBadRequest, "Invalid color "+raw)
+			return opts, false
+		}
+		opts.Text = c
+		opts.Author = fade(c)
+	}
+
+	return opts, true
+}
+
+// fade softens a text color for the attribution line
+func fade(c color.RGBA) color.RGBA {
+	c.A = 0xb0
+	c.R = uint8(uint16(c.R) * 0xb0 / 0xff)
+	c.G = uint8(uint16(c.G) * 0xb0 / 0xff)
+	c.B = uint8(uint16(c.B) * 0xb0 / 0xff)
+	return c
+}
+
+func writeSVGCard(w http.ResponseWriter, quote *models.Quote, theme render.Theme) {
+	var buf bytes.Buffer
+	if err := render.SVGCard(&buf, quote, theme); err != nil {
+		log.Printf("Error rendering quote card: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to render quote card")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing quote card: %v", err)
+	}
+}
+
+func (h *CardHandler) writePNGCard(w http.ResponseWriter, quote *models.Quote, opts render.PNGOptions) {
+	data, err := h.cardService.PNG(quote, opts)
+	if err != nil {
+		log.Printf("Error rendering quote card: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to render quote card")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing quote card: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+// Limits on the number of changes returned at once
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+type ChangesHandler struct {
+	changesService *services.ChangesService
+}
+
+func NewChangesHandler(changesService *services.ChangesService) *ChangesHandler {
+	return &ChangesHandler{
+		changesService: changesService,
+	}
+}
+
+// GetChanges handles GET /api/v1/changes?since=<token>&limit=, a page of
+// quote upserts and tombstones in the order they happened. Clients keep
+// the next token and pass it as since, following it while has_more is set.
+func (h *ChangesHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > maxChangesLimit {
+		limit = defaultChangesLimit
+	}
+
+	set, err := h.changesService.GetChanges(r.URL.Query().Get("since"), limit)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to get changes")
+		return
+	}
+
+	noCache(w)
+	utils.SuccessResponse(w, http.StatusOK, set)
+}
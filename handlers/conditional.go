@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// contentETag returns a strong ETag derived from a response body
+func contentETag(body []byte) string {
+	sum := sha1.Sum(body)
+	return `"` + hex.EncodeToString(sum[:10]) + `"`
+}
+
+// notModified sets the ETag and Last-Modified validators and reports
+// whether the request's conditional headers show the client already has
+// this version, in which case it has written a 304 response. If-None-Match
+// takes precedence over If-Modified-Since, as RFC 9110 requires.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				writeNotModified(w)
+				return true
+			}
+		}
+		return false
+	}
+
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.IsZero() {
+		if !modified.Truncate(time.Second).After(since) {
+			writeNotModified(w)
+			return true
+		}
+	}
+	return false
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+// DigestHandler serves digest sign-up over the API and the confirm and
+// unsubscribe pages that digest emails link to
+type DigestHandler struct {
+	digestService *services.DigestService
+	baseURL       string
+	pages         map[string]*template.Template
+}
+
+// emailPageData is the data of the pages that links in emails lead to
+type emailPageData struct {
+	pageData
+	Message string
+	// UnsubscribeURL is set to show the unsubscribe button
+	UnsubscribeURL string
+}
+
+// NewDigestHandler creates a digest handler. baseURL is the public URL of
+// the service, the same one links in digest emails use.
+func NewDigestHandler(digestService *services.DigestService, baseURL string) *DigestHandler {
+	return &DigestHandler{
+		digestService: digestService,
+		baseURL:       baseURL,
+		pages:         parsePages("email_message"),
+	}
+}
+
+// Subscribe signs an address up for a digest and emails it a confirmation
+// link. The response is the same whether or not the address was already
+// subscribed.
+func (h *DigestHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var req models.DigestSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	if err := h.digestService.Subscribe(r.Context(), &req); err != nil {
+		appErrorResponse(w, err, "Failed to subscribe")
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusAccepted, map[string]string{
+		"message": "Check your inbox for a link to confirm the subscription",
+	})
+}
+
+// Confirm follows the link of a confirmation email at
+// /digests/confirm?token=
+func (h *DigestHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.digestService.Confirm(r.URL.Query().Get("token"))
+	if err == errors.ErrSubscriptionNotFound {
+		h.render(w, http.StatusNotFound, "Link expired",
+			"This confirmation link is invalid or has expired. Subscribe again to get a new one.", "")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to confirm digest subscription: %v", err)
+		h.render(w, http.StatusInternalServerError, "Something went wrong", "The subscription could not be confirmed. Try the link again later.", "")
+		return
+	}
+
+	h.render(w, http.StatusOK, "Subscription confirmed",
+		"Your "+sub.Frequency+" digest will arrive at "+sub.Email+" once there are new quotes.", "")
+}
+
+// Unsubscribe asks for confirmation on GET, so link scanners that fetch
+// every URL in an email do not unsubscribe anyone, and unsubscribes on
+// POST. Mail clients offering one-click unsubscribe POST to the same URL.
+func (h *DigestHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	if r.Method != http.MethodPost {
+		sub, err := h.digestService.GetSubscription(token)
+		if err != nil {
+			h.unsubscribeFailed(w, err)
+			return
+		}
+		action := h.baseURL + "/digests/unsubscribe?token=" + url.QueryEscape(token)
+		h.render(w, http.StatusOK, "Unsubscribe", "Stop sending the "+sub.Frequency+" digest to "+sub.Email+"?", action)
+		return
+	}
+
+	sub, err := h.digestService.Unsubscribe(token)
+	if err != nil {
+		h.unsubscribeFailed(w, err)
+		return
+	}
+	h.render(w, http.StatusOK, "Unsubscribed", sub.Email+" will not receive any more digests.", "")
+}
+
+func (h *DigestHandler) unsubscribeFailed(w http.ResponseWriter, err error) {
+	if err == errors.ErrSubscriptionNotFound {
+		h.render(w, http.StatusNotFound, "Not subscribed", "This address is not subscribed, or has already unsubscribed.", "")
+		return
+	}
+	log.Printf("Failed to unsubscribe from digest: %v", err)
+	h.render(w, http.StatusInternalServerError, "Something went wrong", "You could not be unsubscribed. Try the link again later.", "")
+}
+
+func (h *DigestHandler) render(w http.ResponseWriter, status int, title, message, unsubscribeURL string) {
+	renderEmailPage(w, h.pages, h.baseURL, status, title, message, unsubscribeURL)
+}
+
+// renderEmailPage renders the page a link in an email leads to, showing a
+// message and, when unsubscribeURL is set, a button posting to it
+func renderEmailPage(w http.ResponseWriter, pages map[string]*template.Template, base string, status int, title, message, unsubscribeURL string) {
+	data := &emailPageData{
+		pageData:       pageData{Base: base, Title: title},
+		Message:        message,
+		UnsubscribeURL: unsubscribeURL,
+	}
+	buf, ok := executePage(w, pages, "email_message", data)
+	if !ok {
+		return
+	}
+
+	// The pages answer a token only their recipient holds
+	noCache(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing email link page: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"quote-vault/discord"
+	"quote-vault/errors"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+// discordBodyLimit caps the size of an interaction request
+const discordBodyLimit = 64 << 10
+
+// DiscordHandler answers the interactions of a Discord application: its
+// /quote command and the command's category autocomplete
+type DiscordHandler struct {
+	quoteService *services.QuoteService
+	publicKey    ed25519.PublicKey
+	baseURL      string
+}
+
+// NewDiscordHandler creates a handler for interactions signed with the
+// application's key. baseURL is the public URL of the service used in
+// quote links; when empty it is taken from each request.
+func NewDiscordHandler(quoteService *services.QuoteService, publicKey ed25519.PublicKey, baseURL string) *DiscordHandler {
+	return &DiscordHandler{
+		quoteService: quoteService,
+		publicKey:    publicKey,
+		baseURL:      baseURL,
+	}
+}
+
+// Interactions answers an interaction. Discord checks that unsigned
+// requests are refused with 401 before it accepts the endpoint.
+func (h *DiscordHandler) Interactions(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, discordBodyLimit+1))
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Failed to read request")
+		return
+	}
+	if len(body) > discordBodyLimit {
+		utils.ErrorResponse(w, http.StatusRequestEntityTooLarge, "Request too large")
+		return
+	}
+	if err := discord.Verify(h.publicKey, r.Header, body); err != nil {
+		utils.ErrorResponse(w, http.StatusUnauthorized, "Invalid request signature: "+err.Error())
+		return
+	}
+
+	var interaction discord.Interaction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	switch interaction.Type {
+	case discord.InteractionPing:
+		writeDiscordResponse(w, discord.Pong())
+	case discord.InteractionApplicationCommand, discord.InteractionAutocomplete:
+		if interaction.Data == nil || interaction.Data.Name != discord.QuoteCommand {
+			writeDiscordResponse(w, discord.MessageResponse("Unknown command"))
+			return
+		}
+		if interaction.Type == discord.InteractionAutocomplete {
+			writeDiscordResponse(w, h.autocomplete(interaction.Data))
+			return
+		}
+		writeDiscordResponse(w, h.quote(r, interaction.Data))
+	default:
+		utils.ErrorResponse(w, http.StatusBadRequest, "Unsupported interaction type")
+	}
+}
+
+// quote answers /quote with a random quote, from the chosen category if
+// there is one
+func (h *DiscordHandler) quote(r *http.Request, data *discord.CommandData) *discord.Response {
+	category, _ := data.Option(discord.CategoryOption)
+
+	quote, err := h.quoteService.GetRandomQuote(category)
+	if err == errors.ErrQuoteNotFound {
+		if category == "" {
+			return discord.MessageResponse("There are no quotes yet")
+		}
+		return discord.MessageResponse(fmt.Sprintf("There are no quotes in *%s* yet", discord.Escape(category)))
+	}
+	if err != nil {
+		log.Printf("Discord command failed to get a quote: %v", err)
+		return discord.MessageResponse("Could not get a quote right now, please try again later")
+	}
+
+	return discord.QuoteResponse(quote, fmt.Sprintf("%s/q/%d", baseURL(r, h.baseURL), quote.ID))
+}
+
+// autocomplete suggests categories for the option being typed
+func (h *DiscordHandler) autocomplete(data *discord.CommandData) *discord.Response {
+	typed, focused := data.Option(discord.CategoryOption)
+	var categories []string
+	if focused {
+		var err error
+		if categories, err = h.quoteService.GetCategories(); err != nil {
+			log.Printf("Discord autocomplete failed to get categories: %v", err)
+		}
+	}
+	return discord.CategoryChoices(categories, typed)
+}
+
+// writeDiscordResponse sends an interaction response
+func writeDiscordResponse(w http.ResponseWriter, response *discord.Response) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding Discord response: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/render"
+	"quote-vault/services"
+	"quote-vault/utils"
+	"quote-vault/web"
+)
+
+// oEmbed embed sizes, in CSS pixels
+const (
+	oembedWidth  = 500
+	oembedHeight = 200
+	// oembedCacheAge is how long consumers may cache an embed, in seconds
+	oembedCacheAge = 24 * 60 * 60
+)
+
+// permalinkPath matches the paths of quote URLs that can be embedded
+var permalinkPath = regexp.MustCompile(`^(?:/q|/api/v1/quotes)/([0-9]+)/?$`)
+
+// EmbedHandler serves the embeddable widget script and the oEmbed endpoint
+type EmbedHandler struct {
+	quoteService *services.QuoteService
+	baseURL      string
+	widget       []byte
+	widgetETag   string
+}
+
+// NewEmbedHandler creates an embed handler. baseURL is the public URL of
+// the service; when empty it is taken from each request.
+func NewEmbedHandler(quoteService *services.QuoteService, baseURL string) *EmbedHandler {
+	widget, err := web.Static.ReadFile("static/widget.js")
+	if err != nil {
+		// The file is embedded at build time, so this cannot happen in a
+		// binary that compiled
+		panic(err)
+	}
+
+	return &EmbedHandler{
+		quoteService: quoteService,
+		baseURL:      baseURL,
+		widget:       widget,
+		widgetETag:   contentETag(widget),
+	}
+}
+
+// Widget serves the self-contained widget script
+func (h *EmbedHandler) Widget(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if notModified(w, r, h.widgetETag, time.Time{}) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(h.widget)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(h.widget); err != nil {
+		log.Printf("Error writing widget script: %v", err)
+	}
+}
+
+// oembedResponse is a rich oEmbed 1.0 response
+type oembedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CacheAge     int    `json:"cache_age"`
+}
+
+// OEmbed handles GET /oembed?url=, returning embed HTML for a quote URL.
+// The HTML is a plain blockquote that the widget script upgrades, so the
+// quote still reads correctly where scripts are stripped.
+func (h *EmbedHandler) OEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if format := query.Get("format"); format != "" && format != "json" {
+		utils.ErrorResponse(w, http.StatusNotImplemented, "Only the json oEmbed format is supported")
+		return
+	}
+
+	raw := query.Get("url")
+	if raw == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	base := baseURL(r, h.baseURL)
+	var match []string
+	if target, err := url.Parse(raw); err == nil && strings.EqualFold(target.Hostname(), hostOf(base)) {
+		match = permalinkPath.FindStringSubmatch(target.Path)
+	}
+	if match == nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Not a quote URL on this site")
+		return
+	}
+
+	theme := query.Get("theme")
+	if _, ok := render.ParseTheme(theme); !ok {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Unknown theme, use light, dark or minimal")
+		return
+	}
+
+	id, _ := strconv.Atoi(match[1])
+	quote, err := h.quoteService.GetQuoteByID(id)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			utils.ErrorResponse(w, appErr.Code, appErr.Message)
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get quote")
+		return
+	}
+
+	width := boundedSize(query.Get("maxwidth"), oembedWidth)
+	height := boundedSize(query.Get("maxheight"), oembedHeight)
+
+	attribution := html.EscapeString(quote.Author)
+	if quote.Source != "" {
+		attribution += ", <cite>" + html.EscapeString(quote.Source) + "</cite>"
+	}
+	themeAttr := ""
+	if theme != "" {
+		themeAttr = fmt.Sprintf(` data-theme="%s"`, html.EscapeString(theme))
+	}
+	embed := fmt.Sprintf(
+		`<blockquote class="quote-vault" data-id="%d"%s><p>“%s”</p>— <a href="%s">%s</a></blockquote><script async src="%s/embed/widget.js"></script>`,
+		quote.ID, themeAttr, html.EscapeString(quote.Text), html.EscapeString(fmt.Sprintf("%s/q/%d", base, quote.ID)), attribution, base,
+	)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(oembedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: "Quote Vault",
+		ProviderURL:  base + "/",
+		Title:        "“" + quote.Text + "” — " + quote.Author,
+		AuthorName:   quote.Author,
+		HTML:         embed,
+		Width:        width,
+		Height:       height,
+		CacheAge:     oembedCacheAge,
+	})
+	if err != nil {
+		log.Printf("Error encoding oEmbed response: %v", err)
+	}
+}
+
+// boundedSize returns def, or the consumer's maximum when that is smaller
+func boundedSize(max string, def int) int {
+	if n, err := strconv.Atoi(max); err == nil && n > 0 && n < def {
+		return n
+	}
+	return def
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"quote-vault/models"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+const (
+	// defaultHeartbeat is the interval between keep-alive comments on an
+	// idle event stream
+	defaultHeartbeat = 15 * time.Second
+	// eventStreamBatch is the number of logged events read at a time
+	eventStreamBatch = 100
+	// eventStreamRetry is the reconnection delay suggested to clients, in
+	// milliseconds
+	eventStreamRetry = 3000
+)
+
+type EventsHandler struct {
+	eventLog  *services.EventLog
+	heartbeat time.Duration
+}
+
+// NewEventsHandler creates a handler streaming the event log. A comment is
+// sent every heartbeat so proxies do not close idle streams; 0 means every
+// 15 seconds.
+func NewEventsHandler(eventLog *services.EventLog, heartbeat time.Duration) *EventsHandler {
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+	return &EventsHandler{
+		eventLog:  eventLog,
+		heartbeat: heartbeat,
+	}
+}
+
+// Stream handles GET /api/v1/events, a Server-Sent Events stream of vault
+// changes. Each event's ID is its position in the event log, so a client
+// reconnecting with Last-Event-ID (or ?last_event_id=) gets the events it
+// missed. Without one the stream starts with the next event. A reset event
+// tells clients whose position is no longer in the log to reload.
+// ?category= keeps only events about one category.
+func (h *EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	category := r.URL.Query().Get("category")
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var cursor int64
+	if lastID != "" {
+		var err error
+		cursor, err = strconv.ParseInt(lastID, 10, 64)
+		if err != nil || cursor < 0 {
+			utils.ErrorResponse(w, http.StatusBadRequest, "Invalid Last-Event-ID, expected an event sequence number")
+			return
+		}
+	}
+
+	// Subscribe before reading the log, so no event slips in between
+	notify, unsubscribe := h.eventLog.Subscribe()
+	defer unsubscribe()
+
+	oldest, newest, err := h.eventLog.Bounds()
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to read events")
+		return
+	}
+	reset := false
+	switch {
+	case lastID == "":
+		cursor = newest
+	case cursor > newest, oldest > 0 && cursor < oldest-1:
+		// Events the client missed were pruned, or the log was replaced
+		reset = true
+		cursor = newest
+	}
+
+	rc := http.NewResponseController(w)
+	// A stream outlives the server's write timeout
+	rc.SetWriteDeadline(time.Time{})
+
+	noCache(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	// Stops nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry)
+	if reset {
+		fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {\"reason\":\"missed events are no longer available\"}\n\n", cursor)
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		for {
+			events, err := h.eventLog.Since(cursor, eventStreamBatch)
+			if err != nil {
+				log.Printf("Failed to read events for stream: %v", err)
+				return
+			}
+			for _, event := range events {
+				cursor = event.Sequence
+				if category != "" && event.Category != category {
+					continue
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+			}
+			if len(events) < eventStreamBatch {
+				break
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case _, ok := <-notify:
+			if !ok {
+				// The server is shutting down
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes a logged event in Server-Sent Events format
+func writeEvent(w http.ResponseWriter, event *models.LoggedEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Sequence, event.Type, event.Payload)
+	return err
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/exporters"
+	"quote-vault/models"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+type ExportHandler struct {
+	exportService *services.ExportService
+}
+
+func NewExportHandler(exportService *services.ExportService) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+	}
+}
+
+// Export handles GET /api/v1/export?format=csv|jsonl|md|yaml|fortune|fortune-zip|epub|pdf.
+// Quotes can be filtered by category, author, a text query and a date
+// range, and the whole result is streamed as a file download.
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" {
+		formatName = exporters.FormatJSONL
+	}
+
+	format, ok := exporters.Lookup(formatName)
+	if !ok {
+		utils.ErrorResponse(w, errors.ErrUnsupportedFormat.Code, "Unsupported export format, use csv, jsonl, md, yaml, fortune, fortune-zip, epub or pdf")
+		return
+	}
+
+	filter, err := exportFilter(r.URL.Query())
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	category := filter.Category
+	opts := services.ExportOptions{
+		Filter: filter,
+		Title:  strings.TrimSpace(r.URL.Query().Get("title")),
+		Name:   exportFilename(category, ""),
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exportFilename(category, format.Extension)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Del("ETag")
+
+	cw := &countingWriter{w: w}
+	if err := h.exportService.Export(cw, format, opts); err != nil {
+		// Once streaming has started the headers are gone, so the failure
+		// can only be logged and the response cut short.
+		if cw.n > 0 {
+			log.Printf("Error streaming %s export: %v", format.Name, err)
+			return
+		}
+		w.Header().Del("Content-Disposition")
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to export quotes")
+	}
+}
+
+// exportFilter reads the quote selection from the query string: category,
+// author, q, and either a year or a from and to date, both inclusive
+func exportFilter(query url.Values) (models.QuoteFilter, error) {
+	filter := models.QuoteFilter{
+		Category: query.Get("category"),
+		Author:   strings.TrimSpace(query.Get("author")),
+		Query:    strings.TrimSpace(query.Get("q")),
+	}
+
+	if year := query.Get("year"); year != "" {
+		start, err := time.Parse("2006", year)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid year, use YYYY")
+		}
+		filter.From, filter.To = start, start.AddDate(1, 0, 0)
+	}
+	if from := query.Get("from"); from != "" {
+		day, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid from date, use YYYY-MM-DD")
+		}
+		filter.From = day
+	}
+	if to := query.Get("to"); to != "" {
+		day, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid to date, use YYYY-MM-DD")
+		}
+		filter.To = day.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
+
+// countingWriter records how many bytes have been written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// exportFilename builds a download name such as quotes-wisdom-20240115.md
+func exportFilename(category, extension string) string {
+	name := "quotes"
+	if slug := filenameSlug(category); slug != "" {
+		name += "-" + slug
+	}
+	name += "-" + time.Now().UTC().Format("20060102")
+	if extension != "" {
+		name += "." + extension
+	}
+	return name
+}
+
+// filenameSlug reduces a value to lowercase letters, digits and hyphens so
+// it is safe inside a Content-Disposition filename
+func filenameSlug(s string) string {
+	var b strings.Builder
+	lastHyphen := true
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			b.WriteRune(r)
+			lastHyphen = false
+		case !lastHyphen:
+			b.WriteByte('-')
+			lastHyphen = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // ?tz= must work on hosts without a zoneinfo database
+
+	"github.com/gorilla/mux"
+	"quote-vault/errors"
+	"quote-vault/feeds"
+	"quote-vault/models"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+// Feed sizes
+const (
+	defaultFeedLimit = 20
+	maxFeedLimit     = 100
+	// dailyFeedDays is how many days of daily quotes the daily feed lists
+	dailyFeedDays = 7
+	// The daily calendar covers the coming month and keeps the past week,
+	// since calendar apps drop events that vanish from a subscription
+	dailyCalendarDays     = 30
+	dailyCalendarPastDays = 7
+	dailyCalendarRefresh  = 12 * time.Hour
+)
+
+// FeedHandler serves quotes as Atom and RSS feeds
+type FeedHandler struct {
+	quoteService *services.QuoteService
+	baseURL      string
+}
+
+// NewFeedHandler creates a feed handler. baseURL is the public URL of the
+// service used in links and IDs; when empty it is taken from each request.
+func NewFeedHandler(quoteService *services.QuoteService, baseURL string) *FeedHandler {
+	return &FeedHandler{
+		quoteService: quoteService,
+		baseURL:      baseURL,
+	}
+}
+
+// Quotes serves the newest quotes across the whole vault
+func (h *FeedHandler) Quotes(w http.ResponseWriter, r *http.Request) {
+	h.recent(w, r, "Quote Vault: newest quotes", "", "")
+}
+
+// Category serves the newest quotes in one category
+func (h *FeedHandler) Category(w http.ResponseWriter, r *http.Request) {
+	category := mux.Vars(r)["category"]
+	h.recent(w, r, "Quote Vault: newest "+category+" quotes", category, "")
+}
+
+// Author serves the newest quotes by one author
+func (h *FeedHandler) Author(w http.ResponseWriter, r *http.Request) {
+	author := mux.Vars(r)["author"]
+	h.recent(w, r, "Quote Vault: newest quotes by "+author, "", author)
+}
+
+func (h *FeedHandler) recent(w http.ResponseWriter, r *http.Request, title, category, author string) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > maxFeedLimit {
+		limit = defaultFeedLimit
+	}
+
+	quotes, err := h.quoteService.GetRecentQuotes(category, author, limit)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get quotes")
+		return
+	}
+	changed, err := h.quoteService.LastChanged()
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get quotes")
+		return
+	}
+
+	base := baseURL(r, h.baseURL)
+	link := base + "/api/v1/quotes"
+	if category != "" {
+		link += "?category=" + url.QueryEscape(category)
+	}
+
+	feed := h.newFeed(r, base, title, link)
+	for _, quote := range quotes {
+		feed.Entries = append(feed.Entries, h.entry(base, quote))
+	}
+	// Edits and deletions change the feed too, so it is as new as the
+	// latest change to any quote rather than its newest entry
+	if !changed.IsZero() {
+		feed.Updated = changed.UTC()
+	}
+
+	h.write(w, r, feed)
+}
+
+// Daily serves the quote of the day for the last week, newest first
+func (h *FeedHandler) Daily(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r, h.baseURL)
+	today := services.StartOfDay(time.Now())
+
+	category := r.URL.Query().Get("category")
+	title, link := "Quote Vault: quote of the day", base+"/api/v1/quotes/daily"
+	if category != "" {
+		title = "Quote Vault: " + category + " quote of the day"
+		link += "?category=" + url.QueryEscape(category)
+	}
+
+	feed := h.newFeed(r, base, title, link)
+	feed.Updated = today
+	for i := 0; i < dailyFeedDays; i++ {
+		day := today.AddDate(0, 0, -i)
+		quote, err := h.quoteService.GetDailyQuote(day, category)
+		if err == errors.ErrQuoteNotFound {
+			// Days before the vault had any quotes have no entry
+			continue
+		}
+		if err != nil {
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get daily quote")
+			return
+		}
+
+		entry := h.entry(base, quote)
+		entry.ID = feeds.TagURI(hostOf(base), day, dailyID(category, day))
+		entry.Title = day.Format("January 2, 2006") + ": " + entry.Title
+		entry.Published = day
+		entry.Updated = day
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	h.write(w, r, feed)
+}
+
+// DailyCalendar serves the quote of the day as an iCalendar subscription
+// with an all-day event per day. ?tz= names the IANA time zone whose date
+// counts as today, and ?category= restricts the quotes to one category.
+func (h *FeedHandler) DailyCalendar(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	category := query.Get("category")
+
+	tz := query.Get("tz")
+	loc := time.UTC
+	if tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			utils.ErrorResponse(w, http.StatusBadRequest, "Unknown time zone "+tz)
+			return
+		}
+	}
+
+	base := baseURL(r, h.baseURL)
+	y, m, d := time.Now().In(loc).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	name := "Quote of the day"
+	if category != "" {
+		name = "Quote of the day: " + category
+	}
+	cal := &feeds.Calendar{
+		ProdID:   "-//Quote Vault//Quote of the Day//EN",
+		Name:     name,
+		TimeZone: tz,
+		Refresh:  dailyCalendarRefresh,
+	}
+
+	for i := -dailyCalendarPastDays; i < dailyCalendarDays; i++ {
+		day := today.AddDate(0, 0, i)
+		quote, err := h.quoteService.GetDailyQuote(day, category)
+		if err == errors.ErrQuoteNotFound {
+			continue
+		}
+		if err != nil {
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get daily quote")
+			return
+		}
+
+		entry := h.entry(base, quote)
+		cal.Events = append(cal.Events, feeds.Event{
+			UID:         strings.ReplaceAll(dailyID(category, day), "/", "-") + "@" + hostOf(base),
+			Date:        day,
+			Summary:     entry.Title,
+			Description: entry.Content + "\n\n" + entry.Link,
+			URL:         entry.Link,
+			Categories:  []string{quote.Category},
+		})
+	}
+
+	// Stamping events with the start of the day keeps the output, and so
+	// its ETag, unchanged between refreshes on the same day
+	var buf bytes.Buffer
+	if err := feeds.WriteICal(&buf, cal, today); err != nil {
+		log.Printf("Error rendering daily calendar: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to render calendar")
+		return
+	}
+
+	if notModified(w, r, contentETag(buf.Bytes()), time.Time{}) {
+		return
+	}
+
+	w.Header().Set("Content-Type", feeds.ICalContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="quote-of-the-day.ics"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing daily calendar: %v", err)
+	}
+}
+
+func (h *FeedHandler) newFeed(r *http.Request, base, title, link string) *feeds.Feed {
+	self := base + r.URL.Path
+	return &feeds.Feed{
+		ID:    self,
+		Title: title,
+		Link:  link,
+		Self:  self,
+		// An empty feed still needs an updated time; the start of the day
+		// keeps it stable for conditional requests
+		Updated: services.StartOfDay(time.Now()),
+	}
+}
+
+// entry builds a feed entry whose ID depends only on the quote and the
+// host, so it stays the same as the feed changes around it
+func (h *FeedHandler) entry(base string, quote *models.Quote) feeds.Entry {
+	id := strconv.Itoa(quote.ID)
+	return feeds.NewEntry(quote, feeds.TagURI(hostOf(base), quote.CreatedAt, "quotes/"+id), base+"/q/"+id)
+}
+
+func (h *FeedHandler) write(w http.ResponseWriter, r *http.Request, feed *feeds.Feed) {
+	format := mux.Vars(r)["format"]
+
+	var buf bytes.Buffer
+	if err := feeds.Write(&buf, format, feed); err != nil {
+		log.Printf("Error rendering %s feed: %v", format, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to render feed")
+		return
+	}
+
+	if notModified(w, r, contentETag(buf.Bytes()), feed.Updated) {
+		return
+	}
+
+	w.Header().Set("Content-Type", feeds.ContentType(format))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing %s feed: %v", format, err)
+	}
+}
+
+// dailyID names the quote of the day for a date, distinguishing the
+// per-category selections
+func dailyID(category string, day time.Time) string {
+	if category == "" {
+		return "daily/" + day.Format("2006-01-02")
+	}
+	return "daily/" + category + "/" + day.Format("2006-01-02")
+}
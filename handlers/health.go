@@ -3,21 +3,31 @@ package handlers
 import (
 	"net/http"
 	"quote-vault/database"
+	"quote-vault/models"
+	"quote-vault/services"
 	"quote-vault/utils"
 )
 
 type HealthHandler struct {
-	db database.Database
+	db         database.Database
+	replicator *services.Replicator
 }
 
 func NewHealthHandler(db database.Database) *HealthHandler {
 	return &HealthHandler{db: db}
 }
 
+// SetReplicator adds the replication status of a follower to health
+// checks
+func (h *HealthHandler) SetReplicator(replicator *services.Replicator) {
+	h.replicator = replicator
+}
+
 type HealthResponse struct {
-	Status   string `json:"status"`
-	Database string `json:"database"`
-	Version  string `json:"version"`
+	Status      string                    `json:"status"`
+	Database    string                    `json:"database"`
+	Version     string                    `json:"version"`
+	Replication *models.ReplicationStatus `json:"replication,omitempty"`
 }
 
 func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
@@ -35,6 +45,14 @@ func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 		response.Database = "healthy"
 	}
 
+	// A follower that is behind still serves requests, from older data
+	if h.replicator != nil {
+		response.Replication = h.replicator.Status()
+		if response.Replication.State != models.ReplicationCaughtUp && response.Status == "ok" {
+			response.Status = "degraded"
+		}
+	}
+
 	utils.WriteJSONResponse(w, response)
 }
 
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"quote-vault/errors"
+	"quote-vault/importers"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+// maxImportBytes caps the size of an uploaded import file
+const maxImportBytes = 64 << 20
+
+type ImportHandler struct {
+	importService *services.ImportService
+}
+
+func NewImportHandler(importService *services.ImportService) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+	}
+}
+
+// Import handles POST /api/v1/import. The file is either the raw request
+// body or the "file" field of a multipart upload. The format comes from
+// ?format=, the uploaded file name or the Content-Type, in that order;
+// ?source=goodreads|readwise selects a known CSV layout.
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	body, contentType, filename, err := importFile(r)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid upload: "+err.Error())
+		return
+	}
+	if filename == "" {
+		filename = query.Get("filename")
+	}
+
+	format := importers.DetectFormat(query.Get("format"), contentType, filename)
+
+	source := query.Get("source")
+	if source != "" && format == "" {
+		format = importers.FormatCSV
+	}
+
+	var parser importers.Parser
+	switch format {
+	case importers.FormatCSV:
+		mapping := importers.DefaultColumnMapping()
+		if source != "" {
+			var ok bool
+			if mapping, ok = importers.SourceMapping(source); !ok {
+				utils.ErrorResponse(w, http.StatusBadRequest, "Unsupported import source, use goodreads or readwise")
+				return
+			}
+		}
+		parser = importers.NewCSVParser(mapping.Merge(importers.ColumnMapping{
+			Text:     query.Get("text_column"),
+			Author:   query.Get("author_column"),
+			Category: query.Get("category_column"),
+			Source:   query.Get("source_column"),
+			Tags:     query.Get("tags_column"),
+			Likes:    query.Get("likes_column"),
+		}))
+	case importers.FormatJSONL:
+		parser = importers.NewJSONLParser()
+	case importers.FormatFortune:
+		category := query.Get("category")
+		if category == "" {
+			category = importers.FortuneCategory(filename)
+		}
+		parser = importers.NewFortuneParser(category)
+	case importers.FormatKindle:
+		parser = importers.NewKindleParser()
+	default:
+		utils.ErrorResponse(w, errors.ErrUnsupportedFormat.Code, "Unsupported import format, use csv, jsonl, fortune or kindle")
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(query.Get("dry_run"))
+	preview, _ := strconv.ParseBool(query.Get("preview"))
+
+	report, err := h.importService.Import(parser, body, services.ImportOptions{
+		Format:          format,
+		Source:          source,
+		DryRun:          dryRun,
+		Preview:         preview,
+		DefaultCategory: query.Get("category"),
+	})
+	if err != nil {
+		status, message := http.StatusInternalServerError, "Failed to import quotes"
+		if appErr, ok := err.(*errors.AppError); ok {
+			status, message = appErr.Code, appErr.Message
+			if appErr.Detail != "" {
+				message += ": " + appErr.Detail
+			}
+		}
+		// An import that stopped part way reports the rows it wrote
+		if report != nil {
+			utils.ErrorResponseWithData(w, status, message, report)
+			return
+		}
+		utils.ErrorResponse(w, status, message)
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, report)
+}
+
+// importFile returns the uploaded file along with its content type and
+// name. Multipart uploads are read from their "file" field; any other
+// request body is treated as the file itself.
+func importFile(r *http.Request) (io.Reader, string, string, error) {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "multipart/form-data" {
+		return r.Body, contentType, "", nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", "", err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", "", fmt.Errorf("multipart upload has no \"file\" field")
+		}
+		if err != nil {
+			return nil, "", "", err
+		}
+		if part.FormName() == "file" {
+			return part, part.Header.Get("Content-Type"), part.FileName(), nil
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/services"
+	"quote-vault/utils"
+	"quote-vault/web"
+)
+
+// Social preview image size, the 1.91:1 ratio Open Graph consumers expect
+const (
+	ogImageWidth  = 1200
+	ogImageHeight = 630
+)
+
+// Length limits for page titles and descriptions, in characters
+const (
+	maxPageTitle       = 70
+	maxPageDescription = 200
+)
+
+// PageHandler serves the human-facing HTML pages
+type PageHandler struct {
+	quoteService *services.QuoteService
+	baseURL      string
+	pages        map[string]*template.Template
+	static       http.Handler
+}
+
+// pageData is the data every page template receives
+type pageData struct {
+	Base        string
+	Title       string
+	Description string
+	Canonical   string
+
+	// Curator is set on web UI pages viewed by a signed-in curator, with
+	// the CSRF token their forms must post back
+	Curator bool
+	CSRF    string
+
+	// Quote pages only
+	Quote       *models.Quote
+	Paragraphs  []string
+	Image       string
+	ImageWidth  int
+	ImageHeight int
+	OEmbed      string
+}
+
+// NewPageHandler creates a page handler. baseURL is the public URL of the
+// service used for canonical links; when empty it is taken from each
+// request.
+func NewPageHandler(quoteService *services.QuoteService, baseURL string) *PageHandler {
+	static, err := fs.Sub(web.Static, "static")
+	if err != nil {
+		panic(err)
+	}
+
+	return &PageHandler{
+		quoteService: quoteService,
+		baseURL:      baseURL,
+		pages:        parsePages("quote", "not_found"),
+		static:       http.StripPrefix("/static/", http.FileServer(http.FS(static))),
+	}
+}
+
+// Static serves the embedded stylesheet and scripts under /static/
+func (h *PageHandler) Static(w http.ResponseWriter, r *http.Request) {
+	h.static.ServeHTTP(w, r)
+}
+
+// Quote serves the permalink page of a quote at /q/{id}
+func (h *PageHandler) Quote(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r, h.baseURL)
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	quote, err := h.quoteService.GetQuoteByID(id)
+	if err == errors.ErrQuoteNotFound || err == errors.ErrInvalidID {
+		h.render(w, r, "not_found", http.StatusNotFound, &pageData{Base: base, Title: "Quote not found"})
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get quote")
+		return
+	}
+
+	canonical := fmt.Sprintf("%s/q/%d", base, quote.ID)
+	data := &pageData{
+		Base:        base,
+		Title:       shorten("“"+strings.TrimSpace(quote.Text)+"” — "+quote.Author, maxPageTitle),
+		Description: shorten(strings.TrimSpace(quote.Text)+" — "+quote.Author, maxPageDescription),
+		Canonical:   canonical,
+		Quote:       quote,
+		Paragraphs:  paragraphs(quote.Text),
+		Image:       fmt.Sprintf("%s/api/v1/quotes/%d/card.png?width=%d&height=%d", base, quote.ID, ogImageWidth, ogImageHeight),
+		ImageWidth:  ogImageWidth,
+		ImageHeight: ogImageHeight,
+		OEmbed:      base + "/oembed?url=" + url.QueryEscape(canonical),
+	}
+	h.render(w, r, "quote", http.StatusOK, data)
+}
+
+// Random redirects to the page of a random quote, so the address bar and
+// any shared link hold a permalink
+func (h *PageHandler) Random(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r, h.baseURL)
+
+	quote, err := h.quoteService.GetRandomQuote(r.URL.Query().Get("category"))
+	if err == errors.ErrQuoteNotFound {
+		h.render(w, r, "not_found", http.StatusNotFound, &pageData{Base: base, Title: "Quote not found"})
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get random quote")
+		return
+	}
+
+	noCache(w)
+	http.Redirect(w, r, fmt.Sprintf("%s/q/%d", base, quote.ID), http.StatusFound)
+}
+
+func (h *PageHandler) render(w http.ResponseWriter, r *http.Request, page string, status int, data *pageData) {
+	buf, ok := executePage(w, h.pages, page, data)
+	if !ok {
+		return
+	}
+
+	if status == http.StatusOK && notModified(w, r, contentETag(buf.Bytes()), time.Time{}) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing %s page: %v", page, err)
+	}
+}
+
+// parsePages parses the named page templates, each together with the
+// shared layout
+func parsePages(names ...string) map[string]*template.Template {
+	pages := make(map[string]*template.Template)
+	for _, name := range names {
+		// Each page gets its own copy of the layout so the blocks it
+		// defines do not clash with other pages'
+		pages[name] = template.Must(template.ParseFS(web.Templates, "templates/layout.html", "templates/"+name+".html"))
+	}
+	return pages
+}
+
+// executePage renders a page into a buffer, so a template error can still
+// be reported with an error status. On failure the error response has
+// already been written.
+func executePage(w http.ResponseWriter, pages map[string]*template.Template, page string, data interface{}) (*bytes.Buffer, bool) {
+	var buf bytes.Buffer
+	if err := pages[page].ExecuteTemplate(&buf, "layout", data); err != nil {
+		log.Printf("Error rendering %s page: %v", page, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to render page")
+		return nil, false
+	}
+	return &buf, true
+}
+
+// paragraphs splits quote text on line breaks, dropping blank lines
+func paragraphs(text string) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// shorten cuts s to at most n characters, ending it with an ellipsis
+func shorten(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:n-1])) + "…"
+}
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/gorilla/mux"
 	"quote-vault/errors"
 	"quote-vault/models"
+	"quote-vault/render"
 	"quote-vault/services"
 	"quote-vault/utils"
 )
@@ -32,6 +35,8 @@ func (h *QuoteHandler) CreateQuote(w http.ResponseWriter, r *http.Request) {
 		Text:     req.Text,
 		Author:   req.Author,
 		Category: req.Category,
+		Source:   req.Source,
+		Tags:     models.NormalizeTags(req.Tags),
 	}
 
 	result, err := h.quoteService.CreateQuote(quote)
@@ -60,7 +65,14 @@ func (h *QuoteHandler) GetRandomQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.SuccessResponse(w, http.StatusOK, quote)
+	// Command-line clients get a framed quote, so the response depends on
+	// the User-Agent as well as Accept
+	w.Header().Add("Vary", "User-Agent")
+	if render.WantsTerminal(r) {
+		respondTerminal(w, r, http.StatusOK, quote)
+		return
+	}
+	respondQuote(w, r, http.StatusOK, quote)
 }
 
 func (h *QuoteHandler) GetRandomQuoteByCategory(w http.ResponseWriter, r *http.Request) {
@@ -69,6 +81,22 @@ func (h *QuoteHandler) GetRandomQuoteByCategory(w http.ResponseWriter, r *http.R
 	h.GetRandomQuote(w, r)
 }
 
+// GetDailyQuote returns the quote of the day, which is the same for every
+// request on a given UTC date
+func (h *QuoteHandler) GetDailyQuote(w http.ResponseWriter, r *http.Request) {
+	quote, err := h.quoteService.GetDailyQuote(time.Now(), r.URL.Query().Get("category"))
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			utils.ErrorResponse(w, appErr.Code, appErr.Message)
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get daily quote")
+		return
+	}
+
+	respondQuote(w, r, http.StatusOK, quote)
+}
+
 func (h *QuoteHandler) GetQuotes(w http.ResponseWriter, r *http.Request) {
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	if page < 1 {
@@ -89,28 +117,89 @@ func (h *QuoteHandler) GetQuotes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := map[string]interface{}{
-		"quotes": quotes,
-		"total":  total,
-		"page":   page,
-		"limit":  limit,
-	}
-
-	utils.SuccessResponse(w, http.StatusOK, response)
+	respondQuotes(w, r, &render.List{
+		Quotes: quotes,
+		Total:  total,
+		Page:   page,
+		Limit:  limit,
+	})
 }
 
 func (h *QuoteHandler) GetQuote(w http.ResponseWriter, r *http.Request) {
-	// Extract ID from URL - this depends on router setup
-	// For gorilla/mux, we'd use mux.Vars(r)["id"]
-	utils.ErrorResponse(w, http.StatusNotImplemented, "Not implemented")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, errors.ErrInvalidID.Code, errors.ErrInvalidID.Message)
+		return
+	}
+
+	quote, err := h.quoteService.GetQuoteByID(id)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			utils.ErrorResponse(w, appErr.Code, appErr.Message)
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get quote")
+		return
+	}
+
+	respondQuote(w, r, http.StatusOK, quote)
 }
 
+// UpdateQuote replaces the fields of a quote. A version in the payload
+// makes the update fail with 409 Conflict if the quote has changed since.
 func (h *QuoteHandler) UpdateQuote(w http.ResponseWriter, r *http.Request) {
-	utils.ErrorResponse(w, http.StatusNotImplemented, "Not implemented")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, errors.ErrInvalidID.Code, errors.ErrInvalidID.Message)
+		return
+	}
+
+	var req models.QuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	quote := &models.Quote{
+		ID:       id,
+		Text:     req.Text,
+		Author:   req.Author,
+		Category: req.Category,
+		Source:   req.Source,
+		Tags:     models.NormalizeTags(req.Tags),
+		Version:  req.Version,
+	}
+
+	result, err := h.quoteService.UpdateQuote(quote)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			utils.ErrorResponse(w, appErr.Code, appErr.Message)
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update quote")
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, result)
 }
 
 func (h *QuoteHandler) DeleteQuote(w http.ResponseWriter, r *http.Request) {
-	utils.ErrorResponse(w, http.StatusNotImplemented, "Not implemented")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, errors.ErrInvalidID.Code, errors.ErrInvalidID.Message)
+		return
+	}
+
+	if _, err := h.quoteService.DeleteQuote(id); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			utils.ErrorResponse(w, appErr.Code, appErr.Message)
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete quote")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *QuoteHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
@@ -25,6 +25,10 @@ func setupTestHandler(t *testing.T) (*QuoteHandler, *sql.DB) {
 			text TEXT NOT NULL,
 			author TEXT NOT NULL,
 			category TEXT NOT NULL,
+			source TEXT NOT NULL DEFAULT '',
+			tags TEXT NOT NULL DEFAULT '',
+			likes INTEGER NOT NULL DEFAULT 0,
+			version INTEGER NOT NULL DEFAULT 1,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
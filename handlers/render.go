@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/render"
+	"quote-vault/utils"
+)
+
+// negotiateFormat picks the response format for a quote endpoint. It
+// reports false, having already written a 400 response, when ?format=
+// names a format that is not supported.
+func negotiateFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		if _, ok := render.ParseFormat(name); !ok {
+			utils.ErrorResponse(w, errors.ErrUnsupportedFormat.Code, "Unsupported format, use json, text, html, xml or markdown")
+			return "", false
+		}
+	}
+	return render.Negotiate(r), true
+}
+
+// respondQuote writes a single quote in the negotiated format
+func respondQuote(w http.ResponseWriter, r *http.Request, status int, quote *models.Quote) {
+	format, ok := negotiateFormat(w, r)
+	if !ok {
+		return
+	}
+	if format == render.FormatJSON {
+		utils.SuccessResponse(w, status, quote)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := render.Quote(&buf, format, quote, render.Options{Width: render.ParseWidth(r)}); err != nil {
+		log.Printf("Error rendering quote as %s: %v", format, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to render quote")
+		return
+	}
+	writeRendered(w, format, status, buf.Bytes())
+}
+
+// respondQuotes writes a page of quotes in the negotiated format. JSON
+// responses keep the original list shape.
+func respondQuotes(w http.ResponseWriter, r *http.Request, list *render.List) {
+	format, ok := negotiateFormat(w, r)
+	if !ok {
+		return
+	}
+	if format == render.FormatJSON {
+		utils.SuccessResponse(w, http.StatusOK, map[string]interface{}{
+			"quotes": list.Quotes,
+			"total":  list.Total,
+			"page":   list.Page,
+			"limit":  list.Limit,
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := render.Quotes(&buf, format, list, render.Options{Width: render.ParseWidth(r)}); err != nil {
+		log.Printf("Error rendering quotes as %s: %v", format, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to render quotes")
+		return
+	}
+	writeRendered(w, format, http.StatusOK, buf.Bytes())
+}
+
+// respondTerminal writes a quote styled for a terminal: wrapped to
+// ?width=, framed according to ?frame= and colored unless ?color=false
+func respondTerminal(w http.ResponseWriter, r *http.Request, status int, quote *models.Quote) {
+	var buf bytes.Buffer
+	if err := render.Terminal(&buf, quote, render.ParseTermOptions(r)); err != nil {
+		log.Printf("Error rendering quote for terminal: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to render quote")
+		return
+	}
+	writeRendered(w, render.FormatText, status, buf.Bytes())
+}
+
+func writeRendered(w http.ResponseWriter, format string, status int, body []byte) {
+	w.Header().Set("Content-Type", render.ContentType(format))
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing %s response: %v", format, err)
+	}
+}
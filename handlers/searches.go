@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+// Limits on the number of matches listed at once
+const (
+	defaultMatchLimit = 20
+	maxMatchLimit     = 100
+)
+
+// SavedSearchHandler serves saved searches over the API and the confirm
+// and unsubscribe pages that their emails link to
+type SavedSearchHandler struct {
+	searchService *services.SavedSearchService
+	baseURL       string
+	pages         map[string]*template.Template
+}
+
+// NewSavedSearchHandler creates a saved search handler. baseURL is the
+// public URL of the service, the same one links in emails use; when empty
+// links are built from each request.
+func NewSavedSearchHandler(searchService *services.SavedSearchService, baseURL string) *SavedSearchHandler {
+	return &SavedSearchHandler{
+		searchService: searchService,
+		baseURL:       baseURL,
+		pages:         parsePages("email_message"),
+	}
+}
+
+// createdSearch is the response to saving a search. It is the only one
+// that includes the signing secret of a webhook search, and the link that
+// removes it; an email search gets that link by email instead.
+type createdSearch struct {
+	*models.SavedSearch
+	UnsubscribeURL string `json:"unsubscribe_url,omitempty"`
+}
+
+// CreateSearch saves a search
+func (h *SavedSearchHandler) CreateSearch(w http.ResponseWriter, r *http.Request) {
+	var req models.SavedSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	search, err := h.searchService.CreateSearch(r.Context(), &req)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to save search")
+		return
+	}
+
+	created := &createdSearch{SavedSearch: search}
+	if search.WebhookURL != "" {
+		created.UnsubscribeURL = baseURL(r, h.baseURL) + "/searches/unsubscribe?token=" + url.QueryEscape(search.UnsubscribeToken)
+	}
+	utils.SuccessResponse(w, http.StatusCreated, created)
+}
+
+// GetSearches lists the saved searches, without their webhook URLs,
+// secrets or email addresses
+func (h *SavedSearchHandler) GetSearches(w http.ResponseWriter, r *http.Request) {
+	searches, err := h.searchService.GetSearches()
+	if err != nil {
+		appErrorResponse(w, err, "Failed to list saved searches")
+		return
+	}
+
+	public := make([]*models.SavedSearch, len(searches))
+	for i, search := range searches {
+		public[i] = publicSearch(search)
+	}
+	utils.SuccessResponse(w, http.StatusOK, public)
+}
+
+// GetMatches lists the newest quotes that matched a search, capped by
+// ?limit=. ?token= must be the token of the search's unsubscribe link.
+func (h *SavedSearchHandler) GetMatches(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > maxMatchLimit {
+		limit = defaultMatchLimit
+	}
+
+	matches, err := h.searchService.GetMatches(id, r.URL.Query().Get("token"), limit)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to list saved search matches")
+		return
+	}
+	if matches == nil {
+		matches = []*models.SearchMatch{}
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, matches)
+}
+
+// Confirm follows the link of a confirmation email at
+// /searches/confirm?token=
+func (h *SavedSearchHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	search, err := h.searchService.Confirm(r.URL.Query().Get("token"))
+	if err == errors.ErrSubscriptionNotFound {
+		h.render(w, r, http.StatusNotFound, "Link expired",
+			"This confirmation link is invalid or has expired. Save the search again to get a new one.", "")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to confirm saved search: %v", err)
+		h.render(w, r, http.StatusInternalServerError, "Something went wrong", "The search could not be confirmed. Try the link again later.", "")
+		return
+	}
+
+	h.render(w, r, http.StatusOK, "Search confirmed",
+		"New quotes matching “"+search.Name+"” will be emailed to "+search.Email+".", "")
+}
+
+// Unsubscribe asks for confirmation on GET and removes the search on
+// POST, like the unsubscribe links of digests
+func (h *SavedSearchHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	if r.Method != http.MethodPost {
+		search, err := h.searchService.GetByUnsubscribeToken(token)
+		if err != nil {
+			h.unsubscribeFailed(w, r, err)
+			return
+		}
+		action := baseURL(r, h.baseURL) + "/searches/unsubscribe?token=" + url.QueryEscape(token)
+		message := "Stop emailing " + search.Email + " about quotes matching “" + search.Name + "”?"
+		if search.WebhookURL != "" {
+			message = "Stop posting quotes matching “" + search.Name + "” to " + search.WebhookURL + "?"
+		}
+		h.render(w, r, http.StatusOK, "Unsubscribe", message, action)
+		return
+	}
+
+	search, err := h.searchService.Unsubscribe(token)
+	if err != nil {
+		h.unsubscribeFailed(w, r, err)
+		return
+	}
+	h.render(w, r, http.StatusOK, "Unsubscribed", "The search “"+search.Name+"” was removed and no more notifications will be sent about it.", "")
+}
+
+func (h *SavedSearchHandler) unsubscribeFailed(w http.ResponseWriter, r *http.Request, err error) {
+	if err == errors.ErrSubscriptionNotFound {
+		h.render(w, r, http.StatusNotFound, "Search not found", "This search does not exist, or was already removed.", "")
+		return
+	}
+	log.Printf("Failed to unsubscribe from saved search: %v", err)
+	h.render(w, r, http.StatusInternalServerError, "Something went wrong", "You could not be unsubscribed. Try the link again later.", "")
+}
+
+func (h *SavedSearchHandler) render(w http.ResponseWriter, r *http.Request, status int, title, message, unsubscribeURL string) {
+	renderEmailPage(w, h.pages, baseURL(r, h.baseURL), status, title, message, unsubscribeURL)
+}
+
+// publicSearch returns a copy of search that leaves out where it is
+// delivered: its webhook URL, which often embeds a token, its signing
+// secret and its email address
+func publicSearch(search *models.SavedSearch) *models.SavedSearch {
+	public := *search
+	public.WebhookURL, public.Secret, public.Email = "", "", ""
+	return &public
+}
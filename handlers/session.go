@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Curator session cookie settings
+const (
+	sessionCookie = "quote_vault_session"
+	sessionPath   = "/ui"
+	sessionTTL    = 12 * time.Hour
+)
+
+// csrfField is the name of the hidden form field carrying the CSRF token
+const csrfField = "csrf_token"
+
+// sessions issues and checks the cookies that mark a browser as signed in
+// as a curator. A session is an expiry time and a random nonce, signed with
+// HMAC-SHA256; nothing is stored server-side. The signing key mixes in the
+// curator password, so changing the password signs everyone out.
+type sessions struct {
+	enabled  bool
+	password [sha256.Size]byte
+	key      []byte
+}
+
+// newSessions creates a session manager. An empty password disables
+// sign-in; an empty secret is replaced by a random one.
+func newSessions(password, secret string) *sessions {
+	s := &sessions{
+		enabled:  password != "",
+		password: sha256.Sum256([]byte(password)),
+	}
+
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			panic(err)
+		}
+		secret = string(buf)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(s.password[:])
+	s.key = mac.Sum(nil)
+
+	return s
+}
+
+// checkPassword reports whether password is the curator password. The
+// comparison takes the same time however much of it matches.
+func (s *sessions) checkPassword(password string) bool {
+	sum := sha256.Sum256([]byte(password))
+	return s.enabled && subtle.ConstantTimeCompare(sum[:], s.password[:]) == 1
+}
+
+// checkBearer reports whether r carries the curator password as an
+// "Authorization: Bearer" token, which API clients send in place of a
+// session cookie
+func (s *sessions) checkBearer(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.checkPassword(token)
+}
+
+// sign returns the MAC of payload for the given purpose, so a signature
+// made for one purpose is never accepted for another
+func (s *sessions) sign(purpose, payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// start signs the browser in by setting a new session cookie
+func (s *sessions) start(w http.ResponseWriter, r *http.Request) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	expires := time.Now().Add(sessionTTL)
+	payload := strconv.FormatInt(expires.Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString(nonce)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    payload + "." + s.sign("session", payload),
+		Path:     sessionPath,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// end signs the browser out by clearing its session cookie
+func (s *sessions) end(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     sessionPath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// current returns the session of a signed-in request, or "" when the
+// request has no valid, unexpired session cookie
+func (s *sessions) current(r *http.Request) string {
+	if !s.enabled {
+		return ""
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+
+	i := strings.LastIndexByte(cookie.Value, '.')
+	if i < 0 {
+		return ""
+	}
+	payload, sig := cookie.Value[:i], cookie.Value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.sign("session", payload))) {
+		return ""
+	}
+
+	expiry, _, _ := strings.Cut(payload, ".")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() >= unix {
+		return ""
+	}
+	return payload
+}
+
+// csrfToken returns the token forms must post back within session
+func (s *sessions) csrfToken(session string) string {
+	return s.sign("csrf", session)
+}
+
+// checkCSRF reports whether a form posted within session carries its CSRF
+// token
+func (s *sessions) checkCSRF(r *http.Request, session string) bool {
+	token := r.PostFormValue(csrfField)
+	return session != "" && hmac.Equal([]byte(token), []byte(s.csrfToken(session)))
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// signIn returns a request carrying the session cookie set by s.start
+func signIn(t *testing.T, s *sessions) *http.Request {
+	rec := httptest.NewRecorder()
+	s.start(rec, httptest.NewRequest("POST", "/ui/login", nil))
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("start() set %d cookies, want 1", len(cookies))
+	}
+	if !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Errorf("session cookie = %+v, want HttpOnly and SameSite=Lax", cookies[0])
+	}
+
+	req := httptest.NewRequest("GET", "/ui/", nil)
+	req.AddCookie(cookies[0])
+	return req
+}
+
+func TestSessions_Password(t *testing.T) {
+	s := newSessions("correct horse", "secret")
+	if !s.checkPassword("correct horse") {
+		t.Error("checkPassword() rejected the right password")
+	}
+	if s.checkPassword("correct") || s.checkPassword("") {
+		t.Error("checkPassword() accepted a wrong password")
+	}
+
+	if newSessions("", "secret").checkPassword("") {
+		t.Error("checkPassword() accepted an empty password with sign-in disabled")
+	}
+}
+
+func TestSessions_Bearer(t *testing.T) {
+	s := newSessions("correct horse", "secret")
+	for header, want := range map[string]bool{
+		"Bearer correct horse": true,
+		"Bearer correct":       false,
+		"Basic correct horse":  false,
+		"correct horse":        false,
+		"":                     false,
+	} {
+		req := httptest.NewRequest("GET", "/api/v1/webhooks", nil)
+		req.Header.Set("Authorization", header)
+		if got := s.checkBearer(req); got != want {
+			t.Errorf("checkBearer(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestSessions_Current(t *testing.T) {
+	s := newSessions("password", "secret")
+
+	req := signIn(t, s)
+	session := s.current(req)
+	if session == "" {
+		t.Fatal("current() did not accept a fresh session")
+	}
+
+	// The same secret on another instance accepts the session
+	if newSessions("password", "secret").current(req) != session {
+		t.Error("current() rejected a session signed with the same secret")
+	}
+	if newSessions("password", "other secret").current(req) != "" {
+		t.Error("current() accepted a session signed with another secret")
+	}
+	if newSessions("new password", "secret").current(req) != "" {
+		t.Error("current() accepted a session after the password changed")
+	}
+
+	cookie, _ := req.Cookie(sessionCookie)
+	for name, value := range map[string]string{
+		"tampered expiry": "9" + cookie.Value,
+		"no signature":    session,
+		"garbage":         "garbage",
+		"expired":         "1." + strings.SplitN(session, ".", 2)[1] + "." + s.sign("session", "1."+strings.SplitN(session, ".", 2)[1]),
+	} {
+		req := httptest.NewRequest("GET", "/ui/", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: value})
+		if s.current(req) != "" {
+			t.Errorf("current() accepted a %s cookie", name)
+		}
+	}
+}
+
+func TestSessions_CSRF(t *testing.T) {
+	s := newSessions("password", "secret")
+	session := s.current(signIn(t, s))
+	other := s.current(signIn(t, s))
+
+	post := func(token string) *http.Request {
+		form := url.Values{csrfField: {token}}
+		req := httptest.NewRequest("POST", "/ui/quotes", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	if !s.checkCSRF(post(s.csrfToken(session)), session) {
+		t.Error("checkCSRF() rejected the session's token")
+	}
+	if s.checkCSRF(post(s.csrfToken(other)), session) {
+		t.Error("checkCSRF() accepted another session's token")
+	}
+	if s.checkCSRF(post(""), session) {
+		t.Error("checkCSRF() accepted a missing token")
+	}
+	if s.checkCSRF(post(s.csrfToken("")), "") {
+		t.Error("checkCSRF() accepted a post without a session")
+	}
+}
+
+func TestSafeNext(t *testing.T) {
+	tests := map[string]string{
+		"":                         "/ui/",
+		"/ui/quotes/new":           "/ui/quotes/new",
+		"/ui/?author=Oscar+Wilde":  "/ui/?author=Oscar+Wilde",
+		"https://evil.example/ui/": "/ui/",
+		"//evil.example/ui/":       "/ui/",
+		"/ui/\\evil":               "/ui/",
+		"/api/v1/quotes":           "/ui/",
+	}
+	for next, want := range tests {
+		if got := safeNext(next); got != want {
+			t.Errorf("safeNext(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/services"
+	"quote-vault/slack"
+	"quote-vault/utils"
+)
+
+const (
+	// slackBodyLimit caps the size of a slash-command request
+	slackBodyLimit = 64 << 10
+	// slackSearchResults is the number of matches listed by a search
+	slackSearchResults = 5
+)
+
+// SlackHandler answers the /quote slash command of a Slack app
+type SlackHandler struct {
+	quoteService  *services.QuoteService
+	signingSecret string
+	baseURL       string
+	now           func() time.Time
+}
+
+// NewSlackHandler creates a handler for requests signed with the Slack
+// app's signing secret. baseURL is the public URL of the service used in
+// quote links; when empty it is taken from each request.
+func NewSlackHandler(quoteService *services.QuoteService, signingSecret, baseURL string) *SlackHandler {
+	return &SlackHandler{
+		quoteService:  quoteService,
+		signingSecret: signingSecret,
+		baseURL:       baseURL,
+		now:           time.Now,
+	}
+}
+
+// Command answers a slash command. Once the request is verified, the
+// reply is always a 200 with a message, as Slack shows any other status
+// as a bare failure.
+func (h *SlackHandler) Command(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, slackBodyLimit+1))
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Failed to read request")
+		return
+	}
+	if len(body) > slackBodyLimit {
+		utils.ErrorResponse(w, http.StatusRequestEntityTooLarge, "Request too large")
+		return
+	}
+	if err := slack.Verify(h.signingSecret, r.Header, body, h.now()); err != nil {
+		utils.ErrorResponse(w, http.StatusUnauthorized, "Invalid request signature: "+err.Error())
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid form payload")
+		return
+	}
+
+	command, err := slack.ParseCommand(form.Get("text"))
+	if err != nil {
+		writeSlackMessage(w, slack.TextMessage(err.Error()))
+		return
+	}
+	writeSlackMessage(w, h.run(r, form.Get("command"), command))
+}
+
+// run carries out a parsed command and returns the reply
+func (h *SlackHandler) run(r *http.Request, name string, command *slack.Command) *slack.Message {
+	base := baseURL(r, h.baseURL)
+	link := func(quote *models.Quote) string {
+		return fmt.Sprintf("%s/q/%d", base, quote.ID)
+	}
+
+	switch command.Kind {
+	case slack.CommandHelp:
+		if name == "" {
+			name = "/quote"
+		}
+		return slack.HelpMessage(name)
+
+	case slack.CommandSearch:
+		quotes, total, err := h.quoteService.SearchQuotes(models.QuoteFilter{Query: command.Terms}, slackSearchResults, 0)
+		if err != nil {
+			return slackError(err, "search quotes")
+		}
+		return slack.SearchMessage(command.Terms, quotes, total, link)
+
+	case slack.CommandAdd:
+		quote, err := h.quoteService.CreateQuote(&models.Quote{Text: command.Text, Author: command.Author})
+		if err != nil {
+			return slackError(err, "add the quote")
+		}
+		return slack.AddedMessage(quote, link(quote))
+	}
+
+	quote, err := h.quoteService.GetRandomQuote(command.Category)
+	if err == errors.ErrQuoteNotFound {
+		if command.Category == "" {
+			return slack.TextMessage("There are no quotes yet")
+		}
+		return slack.TextMessage(fmt.Sprintf("There are no quotes in _%s_ yet", slack.Escape(command.Category)))
+	}
+	if err != nil {
+		return slackError(err, "get a quote")
+	}
+	return slack.QuoteMessage(quote, link(quote))
+}
+
+// slackError turns a failure into a reply for the user. Messages of
+// application errors are shown as they are; anything else is logged.
+func slackError(err error, action string) *slack.Message {
+	if appErr, ok := err.(*errors.AppError); ok && appErr.Code < http.StatusInternalServerError {
+		return slack.TextMessage(fmt.Sprintf("Could not %s: %s", action, appErr.Message))
+	}
+	log.Printf("Slack command failed to %s: %v", action, err)
+	return slack.TextMessage(fmt.Sprintf("Could not %s right now, please try again later", action))
+}
+
+// writeSlackMessage sends a slash-command reply
+func writeSlackMessage(w http.ResponseWriter, message *slack.Message) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	// Slack's link syntax uses < and >, which read better unescaped
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(message); err != nil {
+		log.Printf("Error encoding Slack reply: %v", err)
+	}
+}
@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/services"
+	"quote-vault/validators"
+)
+
+// defaultUIPageSize is the number of quotes per browse page when none is
+// configured
+const defaultUIPageSize = 20
+
+// maxFormSize bounds the body of web UI form posts
+const maxFormSize = 64 << 10
+
+// UIOptions configures the web UI
+type UIOptions struct {
+	// BaseURL is the public URL of the service; when empty it is taken from
+	// each request
+	BaseURL string
+	// PageSize is the number of quotes per browse page
+	PageSize int
+	// CuratorPassword unlocks creating and editing quotes. When empty the
+	// web UI is read-only.
+	CuratorPassword string
+	// SessionSecret signs session cookies. When empty a random secret is
+	// used, so sessions do not survive a restart.
+	SessionSecret string
+}
+
+// UIHandler serves the web UI under /ui/ for browsing quotes and, for
+// signed-in curators, creating and editing them
+type UIHandler struct {
+	quoteService *services.QuoteService
+	validator    *validators.QuoteValidator
+	baseURL      string
+	pageSize     int
+	sessions     *sessions
+	pages        map[string]*template.Template
+}
+
+// uiData is the data web UI templates receive
+type uiData struct {
+	pageData
+
+	// CanEdit is set when curator sign-in is enabled
+	CanEdit bool
+	Message string
+
+	// Browse page
+	Filter     models.QuoteFilter
+	Categories []string
+	Quotes     []*models.Quote
+	Total      int
+	Page       int
+	Pages      int
+	PrevURL    string
+	NextURL    string
+
+	// Authors page
+	Authors []models.AuthorCount
+
+	// Sign-in and quote forms
+	Form   quoteForm
+	Errors []string
+	Next   string
+}
+
+// quoteForm holds the fields of the quote editor
+type quoteForm struct {
+	ID       int
+	Version  int
+	Text     string
+	Author   string
+	Category string
+	Source   string
+	// Tags is a comma-separated list
+	Tags string
+}
+
+// NewUIHandler creates a web UI handler
+func NewUIHandler(quoteService *services.QuoteService, opts UIOptions) *UIHandler {
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultUIPageSize
+	}
+
+	return &UIHandler{
+		quoteService: quoteService,
+		validator:    validators.NewQuoteValidator(),
+		baseURL:      opts.BaseURL,
+		pageSize:     opts.PageSize,
+		sessions:     newSessions(opts.CuratorPassword, opts.SessionSecret),
+		pages:        parsePages("ui_browse", "ui_authors", "ui_login", "ui_form", "ui_message"),
+	}
+}
+
+// Browse lists quotes newest first, filtered by ?category=, ?author= and a
+// ?q= search, one ?page= at a time
+func (h *UIHandler) Browse(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.QuoteFilter{
+		Category: query.Get("category"),
+		Author:   strings.TrimSpace(query.Get("author")),
+		Query:    strings.TrimSpace(query.Get("q")),
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	quotes, total, err := h.quoteService.SearchQuotes(filter, h.pageSize, (page-1)*h.pageSize)
+	if err != nil {
+		h.fail(w, r, http.StatusInternalServerError, "Failed to search quotes")
+		return
+	}
+	categories, err := h.quoteService.GetCategories()
+	if err != nil {
+		h.fail(w, r, http.StatusInternalServerError, "Failed to get categories")
+		return
+	}
+
+	data := h.data(r, "Browse quotes")
+	data.Filter = filter
+	data.Categories = categories
+	data.Quotes = quotes
+	data.Total = total
+	data.Page = page
+	data.Pages = (total + h.pageSize - 1) / h.pageSize
+	if page > 1 {
+		data.PrevURL = browseURL(data.Base, filter, page-1)
+	}
+	if page < data.Pages {
+		data.NextURL = browseURL(data.Base, filter, page+1)
+	}
+	h.render(w, r, "ui_browse", http.StatusOK, data)
+}
+
+// Authors lists every author with the number of their quotes
+func (h *UIHandler) Authors(w http.ResponseWriter, r *http.Request) {
+	authors, err := h.quoteService.GetAuthors()
+	if err != nil {
+		h.fail(w, r, http.StatusInternalServerError, "Failed to get authors")
+		return
+	}
+
+	data := h.data(r, "Authors")
+	data.Authors = authors
+	h.render(w, r, "ui_authors", http.StatusOK, data)
+}
+
+// Login shows the curator sign-in form
+func (h *UIHandler) Login(w http.ResponseWriter, r *http.Request) {
+	data := h.data(r, "Sign in")
+	data.Next = safeNext(r.URL.Query().Get("next"))
+	h.render(w, r, "ui_login", http.StatusOK, data)
+}
+
+// SignIn checks the curator password and starts a session
+func (h *UIHandler) SignIn(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	next := safeNext(r.PostFormValue("next"))
+
+	if !h.sessions.checkPassword(r.PostFormValue("password")) {
+		data := h.data(r, "Sign in")
+		data.Next = next
+		data.Errors = []string{"That password is not right."}
+		h.render(w, r, "ui_login", http.StatusUnauthorized, data)
+		return
+	}
+
+	h.sessions.start(w, r)
+	http.Redirect(w, r, baseURL(r, h.baseURL)+next, http.StatusSeeOther)
+}
+
+// SignOut ends the curator session
+func (h *UIHandler) SignOut(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.curator(w, r); !ok {
+		return
+	}
+
+	h.sessions.end(w, r)
+	http.Redirect(w, r, baseURL(r, h.baseURL)+"/ui/", http.StatusSeeOther)
+}
+
+// NewQuote shows an empty quote editor
+func (h *UIHandler) NewQuote(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.curator(w, r); !ok {
+		return
+	}
+
+	category := r.URL.Query().Get("category")
+	if category == "" {
+		category = "general"
+	}
+
+	data := h.data(r, "New quote")
+	data.Form = quoteForm{Category: category}
+	h.render(w, r, "ui_form", http.StatusOK, data)
+}
+
+// CreateQuote saves a quote posted from the editor and redirects to its
+// page
+func (h *UIHandler) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.curator(w, r); !ok {
+		return
+	}
+
+	form := readQuoteForm(r)
+	if errs := h.validate(form); len(errs) > 0 {
+		h.editor(w, r, "New quote", http.StatusUnprocessableEntity, form, errs)
+		return
+	}
+
+	quote, err := h.quoteService.CreateQuote(form.quote())
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Code < http.StatusInternalServerError {
+			h.editor(w, r, "New quote", appErr.Code, form, []string{appErr.Message})
+			return
+		}
+		h.fail(w, r, http.StatusInternalServerError, "Failed to create quote")
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("%s/q/%d", baseURL(r, h.baseURL), quote.ID), http.StatusSeeOther)
+}
+
+// EditQuote shows the editor for an existing quote
+func (h *UIHandler) EditQuote(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.curator(w, r); !ok {
+		return
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	quote, err := h.quoteService.GetQuoteByID(id)
+	if err == errors.ErrQuoteNotFound || err == errors.ErrInvalidID {
+		h.fail(w, r, http.StatusNotFound, "There is no quote with that ID.")
+		return
+	}
+	if err != nil {
+		h.fail(w, r, http.StatusInternalServerError, "Failed to get quote")
+		return
+	}
+
+	h.editor(w, r, "Edit quote", http.StatusOK, formFromQuote(quote), nil)
+}
+
+// UpdateQuote saves changes posted from the editor and redirects to the
+// quote's page. If someone else saved the quote since the editor was
+// opened, the editor is shown again with a warning; posting it once more
+// overwrites their changes.
+func (h *UIHandler) UpdateQuote(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.curator(w, r); !ok {
+		return
+	}
+
+	form := readQuoteForm(r)
+	form.ID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	if errs := h.validate(form); len(errs) > 0 {
+		h.editor(w, r, "Edit quote", http.StatusUnprocessableEntity, form, errs)
+		return
+	}
+
+	quote, err := h.quoteService.UpdateQuote(form.quote())
+	switch {
+	case err == errors.ErrQuoteModified:
+		current, err := h.quoteService.GetQuoteByID(form.ID)
+		if err != nil {
+			h.fail(w, r, http.StatusInternalServerError, "Failed to get quote")
+			return
+		}
+		form.Version = current.Version
+		h.editor(w, r, "Edit quote", http.StatusConflict, form, []string{
+			"Someone else saved this quote while you were editing it. Saving again will replace their changes.",
+		})
+		return
+	case err == errors.ErrQuoteNotFound || err == errors.ErrInvalidID:
+		h.fail(w, r, http.StatusNotFound, "There is no quote with that ID.")
+		return
+	case err != nil:
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Code < http.StatusInternalServerError {
+			h.editor(w, r, "Edit quote", appErr.Code, form, []string{appErr.Message})
+			return
+		}
+		h.fail(w, r, http.StatusInternalServerError, "Failed to update quote")
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("%s/q/%d", baseURL(r, h.baseURL), quote.ID), http.StatusSeeOther)
+}
+
+// curator returns the session of a signed-in curator. Browsers that are
+// not signed in are sent to the sign-in page, and form posts without the
+// session's CSRF token are refused; in both cases it reports false.
+func (h *UIHandler) curator(w http.ResponseWriter, r *http.Request) (string, bool) {
+	session := h.sessions.current(r)
+	if session == "" {
+		next := "/ui/"
+		if r.Method == http.MethodGet {
+			next = r.URL.RequestURI()
+		}
+		http.Redirect(w, r, baseURL(r, h.baseURL)+"/ui/login?next="+url.QueryEscape(next), http.StatusSeeOther)
+		return "", false
+	}
+
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+		if !h.sessions.checkCSRF(r, session) {
+			h.fail(w, r, http.StatusForbidden, "This form has expired. Go back, reload the page and try again.")
+			return "", false
+		}
+	}
+
+	return session, true
+}
+
+// validate checks a submitted quote, returning every problem found
+func (h *UIHandler) validate(form quoteForm) []string {
+	var errs []string
+	if err := h.validator.ValidateQuote(form.Text, form.Author, form.Category); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := h.validator.ValidateSource(form.Source); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := h.validator.ValidateTags(form.quote().Tags); err != nil {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
+
+// data starts the template data of a page
+func (h *UIHandler) data(r *http.Request, title string) *uiData {
+	data := &uiData{
+		pageData: pageData{Base: baseURL(r, h.baseURL), Title: title},
+		CanEdit:  h.sessions.enabled,
+	}
+	if session := h.sessions.current(r); session != "" {
+		data.Curator = true
+		data.CSRF = h.sessions.csrfToken(session)
+	}
+	return data
+}
+
+func (h *UIHandler) editor(w http.ResponseWriter, r *http.Request, title string, status int, form quoteForm, errs []string) {
+	data := h.data(r, title)
+	data.Form = form
+	data.Errors = errs
+	h.render(w, r, "ui_form", status, data)
+}
+
+func (h *UIHandler) fail(w http.ResponseWriter, r *http.Request, status int, message string) {
+	data := h.data(r, http.StatusText(status))
+	data.Message = message
+	h.render(w, r, "ui_message", status, data)
+}
+
+func (h *UIHandler) render(w http.ResponseWriter, r *http.Request, page string, status int, data *uiData) {
+	buf, ok := executePage(w, h.pages, page, data)
+	if !ok {
+		return
+	}
+
+	// Pages differ for signed-in curators and carry their CSRF token, so
+	// they must not be cached
+	noCache(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing %s page: %v", page, err)
+	}
+}
+
+// browseURL returns the address of a browse page
+func browseURL(base string, filter models.QuoteFilter, page int) string {
+	query := url.Values{}
+	if filter.Query != "" {
+		query.Set("q", filter.Query)
+	}
+	if filter.Category != "" {
+		query.Set("category", filter.Category)
+	}
+	if filter.Author != "" {
+		query.Set("author", filter.Author)
+	}
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+
+	if len(query) == 0 {
+		return base + "/ui/"
+	}
+	return base + "/ui/?" + query.Encode()
+}
+
+// safeNext keeps the redirect after signing in within the web UI, so the
+// sign-in form cannot be used to send curators to another site
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/ui/") || strings.ContainsAny(next, "\\\r\n") {
+		return "/ui/"
+	}
+	return next
+}
+
+// readQuoteForm reads the quote editor fields from a form post
+func readQuoteForm(r *http.Request) quoteForm {
+	version, _ := strconv.Atoi(r.PostFormValue("version"))
+	return quoteForm{
+		Version: version,
+		// Browsers submit textarea line breaks as CRLF
+		Text:     strings.TrimSpace(strings.ReplaceAll(r.PostFormValue("text"), "\r\n", "\n")),
+		Author:   strings.TrimSpace(r.PostFormValue("author")),
+		Category: strings.TrimSpace(r.PostFormValue("category")),
+		Source:   strings.TrimSpace(r.PostFormValue("source")),
+		Tags:     r.PostFormValue("tags"),
+	}
+}
+
+// formFromQuote fills the quote editor from a stored quote
+func formFromQuote(quote *models.Quote) quoteForm {
+	return quoteForm{
+		ID:       quote.ID,
+		Version:  quote.Version,
+		Text:     quote.Text,
+		Author:   quote.Author,
+		Category: quote.Category,
+		Source:   quote.Source,
+		Tags:     strings.Join(quote.Tags, ", "),
+	}
+}
+
+// quote turns the form into a quote for the service
+func (f quoteForm) quote() *models.Quote {
+	return &models.Quote{
+		ID:       f.ID,
+		Version:  f.Version,
+		Text:     f.Text,
+		Author:   f.Author,
+		Category: f.Category,
+		Source:   f.Source,
+		Tags:     models.NormalizeTags(strings.Split(f.Tags, ",")),
+	}
+}
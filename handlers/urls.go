@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// baseURL returns the scheme and host that links in responses should
+// point at. A configured base URL wins; otherwise it is worked out from the
+// request, honouring X-Forwarded-Proto from a TLS-terminating proxy.
+func baseURL(r *http.Request, configured string) string {
+	if configured != "" {
+		return strings.TrimRight(configured, "/")
+	}
+
+	scheme := "http"
+	if isHTTPS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// isHTTPS reports whether the client reached the service over HTTPS,
+// directly or through a TLS-terminating proxy
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// hostOf returns the host name of a base URL, without any port
+func hostOf(base string) string {
+	u, err := url.Parse(base)
+	if err != nil || u.Hostname() == "" {
+		return "localhost"
+	}
+	return u.Hostname()
+}
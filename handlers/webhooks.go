@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/services"
+	"quote-vault/utils"
+)
+
+// Limits on the number of deliveries listed at once
+const (
+	defaultDeliveryLimit = 20
+	maxDeliveryLimit     = 100
+)
+
+// WebhookHandler manages webhooks. Every request must carry the curator
+// password as a bearer token.
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	curator        *sessions
+}
+
+// NewWebhookHandler creates a webhook handler that accepts requests
+// authenticated with curatorPassword, which must not be empty
+func NewWebhookHandler(webhookService *services.WebhookService, curatorPassword string) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		curator:        newSessions(curatorPassword, ""),
+	}
+}
+
+// CreateWebhook registers a webhook. The response is the only one besides
+// secret rotation that includes the signing secret.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid JSON payload")
+		return
+	}
+
+	hook, err := h.webhookService.CreateWebhook(&req)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to create webhook")
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusCreated, hook)
+}
+
+// GetWebhooks lists the webhooks without their secrets, and with each URL
+// cut down to its origin, since paths and queries often hold credentials.
+// The full URL is returned by GetWebhook.
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	hooks, err := h.webhookService.GetWebhooks()
+	if err != nil {
+		appErrorResponse(w, err, "Failed to list webhooks")
+		return
+	}
+
+	public := make([]*models.Webhook, len(hooks))
+	for i, hook := range hooks {
+		public[i] = withoutSecret(hook)
+		public[i].URL = urlOrigin(hook.URL)
+	}
+	utils.SuccessResponse(w, http.StatusOK, public)
+}
+
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	id, ok := routeID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	hook, err := h.webhookService.GetWebhook(id)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to get webhook")
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, withoutSecret(hook))
+}
+
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	id, ok := routeID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(id); err != nil {
+		appErrorResponse(w, err, "Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateSecret issues a new signing secret and returns it. The old one
+// keeps signing deliveries alongside it for a grace period.
+func (h *WebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	id, ok := routeID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	hook, err := h.webhookService.RotateSecret(id)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to rotate webhook secret")
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, hook)
+}
+
+// GetDeliveries lists the newest deliveries of a webhook, narrowed by
+// ?status=pending|succeeded|failed and capped by ?limit=
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	id, ok := routeID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > maxDeliveryLimit {
+		limit = defaultDeliveryLimit
+	}
+
+	deliveries, err := h.webhookService.GetDeliveries(id, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to list webhook deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, deliveries)
+}
+
+// ReplayDelivery queues a finished delivery again and returns the new one
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	id, ok := routeID(w, r, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := routeID(w, r, "delivery_id")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayDelivery(id, deliveryID)
+	if err != nil {
+		appErrorResponse(w, err, "Failed to replay webhook delivery")
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusAccepted, delivery)
+}
+
+// authorized reports whether r carries the curator password, answering 401
+// if it does not
+func (h *WebhookHandler) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if h.curator.checkBearer(r) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="webhooks"`)
+	utils.ErrorResponse(w, http.StatusUnauthorized, "Webhook management requires the curator password as a bearer token")
+	return false
+}
+
+// urlOrigin returns the scheme and host of a URL
+func urlOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// withoutSecret returns a copy of hook that leaves out its signing secret
+func withoutSecret(hook *models.Webhook) *models.Webhook {
+	public := *hook
+	public.Secret = ""
+	return &public
+}
+
+// routeID reads a numeric route variable, answering 400 if it is invalid
+func routeID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		utils.ErrorResponse(w, errors.ErrInvalidID.Code, errors.ErrInvalidID.Message)
+		return 0, false
+	}
+	return id, true
+}
+
+// appErrorResponse writes err as an error response, including the detail of
+// validation errors, or a generic message for unexpected errors
+func appErrorResponse(w http.ResponseWriter, err error, fallback string) {
+	if appErr, ok := err.(*errors.AppError); ok {
+		message := appErr.Message
+		if appErr.Detail != "" {
+			message += ": " + appErr.Detail
+		}
+		utils.ErrorResponse(w, appErr.Code, message)
+		return
+	}
+	utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+}
@@ -0,0 +1,244 @@
+package importers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"quote-vault/models"
+)
+
+// ColumnMapping names the CSV header columns that hold each quote field.
+// Header names are matched case-insensitively.
+type ColumnMapping struct {
+	Text     string
+	Author   string
+	Category string
+	Source   string
+	Tags     string
+	Likes    string
+
+	// CategoryFromTags files rows without a category column value under
+	// their first tag
+	CategoryFromTags bool
+	// StripQuotes removes quotation marks wrapped around the quote text
+	StripQuotes bool
+	// TrimAuthor removes the trailing commas that some exports leave after
+	// author names, as in "Oscar Wilde,"
+	TrimAuthor bool
+}
+
+// DefaultColumnMapping returns the mapping used when a request does not
+// override any columns
+func DefaultColumnMapping() ColumnMapping {
+	return ColumnMapping{
+		Text:     "text",
+		Author:   "author",
+		Category: "category",
+		Source:   "source",
+		Tags:     "tags",
+		Likes:    "likes",
+	}
+}
+
+// Known CSV layouts exported by other services
+const (
+	SourceGoodreads = "goodreads"
+	SourceReadwise  = "readwise"
+)
+
+// SourceMapping returns the column mapping for a known export layout.
+// Goodreads quote exports have Quote, Author, Book, Tags and Likes columns;
+// Readwise highlight exports have Highlight, Book Title, Book Author and
+// Tags columns. Both have no category, so rows are filed under their first
+// tag.
+func SourceMapping(source string) (ColumnMapping, bool) {
+	switch strings.ToLower(strings.TrimSpace(source)) {
+	case SourceGoodreads:
+		return ColumnMapping{
+			Text:             "quote",
+			Author:           "author",
+			Category:         "category",
+			Source:           "book",
+			Tags:             "tags",
+			Likes:            "likes",
+			CategoryFromTags: true,
+			StripQuotes:      true,
+			TrimAuthor:       true,
+		}, true
+	case SourceReadwise:
+		return ColumnMapping{
+			Text:             "highlight",
+			Author:           "book author",
+			Category:         "category",
+			Source:           "book title",
+			Tags:             "tags",
+			Likes:            "likes",
+			CategoryFromTags: true,
+			TrimAuthor:       true,
+		}, true
+	}
+	return ColumnMapping{}, false
+}
+
+// Merge returns a copy of m with every non-empty column name in override
+// replacing the corresponding one in m
+func (m ColumnMapping) Merge(override ColumnMapping) ColumnMapping {
+	if override.Text != "" {
+		m.Text = override.Text
+	}
+	if override.Author != "" {
+		m.Author = override.Author
+	}
+	if override.Category != "" {
+		m.Category = override.Category
+	}
+	if override.Source != "" {
+		m.Source = override.Source
+	}
+	if override.Tags != "" {
+		m.Tags = override.Tags
+	}
+	if override.Likes != "" {
+		m.Likes = override.Likes
+	}
+	return m
+}
+
+// CSVParser reads quotes from a CSV file with a header row
+type CSVParser struct {
+	mapping ColumnMapping
+	matched map[string]string
+}
+
+// NewCSVParser creates a CSV parser; empty mapping fields fall back to the
+// default column names
+func NewCSVParser(mapping ColumnMapping) *CSVParser {
+	merged := DefaultColumnMapping().Merge(mapping)
+	merged.CategoryFromTags = mapping.CategoryFromTags
+	merged.StripQuotes = mapping.StripQuotes
+	merged.TrimAuthor = mapping.TrimAuthor
+	return &CSVParser{mapping: merged}
+}
+
+// Mapping returns the quote fields that were found in the last parsed
+// header, keyed by field name, with the header column each was read from
+func (p *CSVParser) Mapping() map[string]string {
+	return p.matched
+}
+
+// Parse reads every data row. A missing text or author column fails the
+// whole file; malformed rows are returned as records with Err set.
+func (p *CSVParser) Parse(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, exists := columns[name]; !exists {
+			columns[name] = i
+		}
+	}
+
+	p.matched = make(map[string]string)
+	lookup := func(field, column string) int {
+		i, ok := columns[strings.ToLower(column)]
+		if !ok {
+			return -1
+		}
+		p.matched[field] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+		return i
+	}
+
+	textCol := lookup("text", p.mapping.Text)
+	if textCol < 0 {
+		return nil, fmt.Errorf("csv header has no %q column", p.mapping.Text)
+	}
+	authorCol := lookup("author", p.mapping.Author)
+	if authorCol < 0 {
+		return nil, fmt.Errorf("csv header has no %q column", p.mapping.Author)
+	}
+	categoryCol := lookup("category", p.mapping.Category)
+	sourceCol := lookup("source", p.mapping.Source)
+	tagsCol := lookup("tags", p.mapping.Tags)
+	likesCol := lookup("likes", p.mapping.Likes)
+
+	var records []Record
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			records = append(records, Record{Row: row, Err: err})
+			continue
+		}
+
+		record := Record{
+			Row:      row,
+			Text:     field(fields, textCol),
+			Author:   field(fields, authorCol),
+			Category: field(fields, categoryCol),
+			Source:   field(fields, sourceCol),
+			Tags:     models.ParseTags(field(fields, tagsCol)),
+			Likes:    parseLikes(field(fields, likesCol)),
+		}
+		if p.mapping.StripQuotes {
+			record.Text = stripQuotes(record.Text)
+		}
+		if p.mapping.TrimAuthor {
+			record.Author = strings.TrimRight(record.Author, ", ")
+		}
+		if record.Category == "" && p.mapping.CategoryFromTags && len(record.Tags) > 0 {
+			record.Category = record.Tags[0]
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// field returns the trimmed value at index i, or an empty string when the
+// column is absent or the row is shorter than the header
+func field(fields []string, i int) string {
+	if i < 0 || i >= len(fields) {
+		return ""
+	}
+	return strings.TrimSpace(fields[i])
+}
+
+// parseLikes reads a like count such as "1,024" or "1024 likes", returning
+// zero when it is not a number
+func parseLikes(s string) int {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.ReplaceAll(fields[0], ",", ""))
+	return n
+}
+
+// stripQuotes removes one pair of straight or curly quotation marks that
+// wrap the whole text
+func stripQuotes(s string) string {
+	pairs := [][2]string{{`"`, `"`}, {"“", "”"}, {"‘", "’"}}
+	for _, pair := range pairs {
+		if len(s) > len(pair[0])+len(pair[1]) && strings.HasPrefix(s, pair[0]) && strings.HasSuffix(s, pair[1]) {
+			return strings.TrimSpace(s[len(pair[0]) : len(s)-len(pair[1])])
+		}
+	}
+	return s
+}
@@ -0,0 +1,147 @@
+package importers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCSVParser_Parse(t *testing.T) {
+	input := "Quote,By,Topic\n" +
+		"\"Stay hungry, stay foolish.\",Steve Jobs,motivation\n" +
+		"Simplicity is the soul of efficiency.,Austin Freeman\n"
+
+	parser := NewCSVParser(ColumnMapping{Text: "quote", Author: "by", Category: "topic"})
+	records, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Parse() count = %v, want 2", len(records))
+	}
+	if records[0].Text != "Stay hungry, stay foolish." || records[0].Author != "Steve Jobs" || records[0].Category != "motivation" {
+		t.Errorf("Parse() first record = %+v", records[0])
+	}
+	if records[1].Row != 2 || records[1].Category != "" {
+		t.Errorf("Parse() short row = %+v, want row 2 with empty category", records[1])
+	}
+}
+
+func TestCSVParser_Parse_DefaultMapping(t *testing.T) {
+	input := "text,author\nThe unexamined life is not worth living.,Socrates\n"
+
+	records, err := NewCSVParser(ColumnMapping{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(records) != 1 || records[0].Author != "Socrates" {
+		t.Errorf("Parse() records = %+v", records)
+	}
+}
+
+func TestCSVParser_Parse_KeepsAuthorPunctuation(t *testing.T) {
+	input := "text,author\nThe unexamined life is not worth living.,\"Socrates,\"\n"
+
+	records, err := NewCSVParser(ColumnMapping{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(records) != 1 || records[0].Author != "Socrates," {
+		t.Errorf("Parse() author = %q, want it as given outside Goodreads and Readwise layouts", records[0].Author)
+	}
+}
+
+func TestCSVParser_Parse_MissingColumn(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty file", input: ""},
+		{name: "no text column", input: "quote,author\nsomething,someone\n"},
+		{name: "no author column", input: "text,who\nsomething,someone\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCSVParser(ColumnMapping{}).Parse(strings.NewReader(tt.input)); err == nil {
+				t.Error("Parse() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		format      string
+		contentType string
+		filename    string
+		want        string
+	}{
+		{format: "csv", want: FormatCSV},
+		{format: "JSONL", want: FormatJSONL},
+		{format: "fortune", want: FormatFortune},
+		{format: "xml", contentType: "text/csv", want: ""},
+		{contentType: "text/csv; charset=utf-8", want: FormatCSV},
+		{contentType: "application/x-ndjson", want: FormatJSONL},
+		{contentType: "application/octet-stream", filename: "quotes.CSV", want: FormatCSV},
+		{contentType: "application/json", want: ""},
+		{contentType: "text/plain", filename: "wisdom", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := DetectFormat(tt.format, tt.contentType, tt.filename); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q, %q) = %q, want %q", tt.format, tt.contentType, tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestCSVParser_Parse_Goodreads(t *testing.T) {
+	input := "Quote,Author,Book,Tags,Likes\n" +
+		"\"“Be yourself; everyone else is already taken.”\",\"Oscar Wilde,\",,\"Humor, Be-Yourself\",\"161,021\"\n" +
+		"“So many books, so little time.”,Frank Zappa,,,\n"
+
+	mapping, ok := SourceMapping("Goodreads")
+	if !ok {
+		t.Fatal("SourceMapping(goodreads) not found")
+	}
+	parser := NewCSVParser(mapping)
+	records, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	first := records[0]
+	if first.Text != "Be yourself; everyone else is already taken." {
+		t.Errorf("Parse() text = %q, want quotes stripped", first.Text)
+	}
+	if first.Author != "Oscar Wilde" || first.Likes != 161021 {
+		t.Errorf("Parse() author/likes = %q/%d", first.Author, first.Likes)
+	}
+	if first.Category != "humor" || len(first.Tags) != 2 || first.Tags[1] != "be-yourself" {
+		t.Errorf("Parse() category/tags = %q/%v", first.Category, first.Tags)
+	}
+	if records[1].Category != "" {
+		t.Errorf("Parse() untagged category = %q, want empty", records[1].Category)
+	}
+
+	mapped := parser.Mapping()
+	if mapped["text"] != "Quote" || mapped["source"] != "Book" || mapped["category"] != "" {
+		t.Errorf("Mapping() = %v", mapped)
+	}
+}
+
+func TestCSVParser_Parse_Readwise(t *testing.T) {
+	input := "Highlight,Book Title,Book Author,Amazon Book ID,Note,Color,Tags,Location Type,Location,Highlighted at,Document tags\n" +
+		"The obstacle is the way.,The Obstacle Is the Way,\"Ryan Holiday, \",B00G3L1C2K,,yellow,stoicism,location,1200,2021-03-01 10:00:00+00:00,\n"
+
+	mapping, _ := SourceMapping(SourceReadwise)
+	records, err := NewCSVParser(mapping).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	record := records[0]
+	if record.Author != "Ryan Holiday" || record.Source != "The Obstacle Is the Way" || record.Category != "stoicism" {
+		t.Errorf("Parse() record = %+v", record)
+	}
+}
@@ -0,0 +1,124 @@
+package importers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// fortuneAnonymous is used as the author of fortunes without an attribution
+const fortuneAnonymous = "Anonymous"
+
+// attributionPattern matches a trailing "-- Author" line, allowing the
+// indentation, em dashes and horizontal bars seen in real fortune files
+var attributionPattern = regexp.MustCompile(`^\s*(?:--|—|―)\s*(\S.*?)\s*$`)
+
+// sourcePattern splits an attribution such as `Mark Twain, "Pudd'nhead
+// Wilson"` into the author and the quoted work
+var sourcePattern = regexp.MustCompile(`^(.+?),\s*["“](.+?)["”]$`)
+
+// FortuneParser reads the %-separated text format used by fortune(6).
+// Every fortune in a file is filed under the same category.
+type FortuneParser struct {
+	category string
+}
+
+// NewFortuneParser creates a parser that assigns every fortune to category
+func NewFortuneParser(category string) *FortuneParser {
+	return &FortuneParser{category: category}
+}
+
+// FortuneCategory derives a category from a fortune file name, so that
+// "fortunes/art" and "art.txt" both become "art". Characters the category
+// validator rejects are replaced with hyphens.
+func FortuneCategory(filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if ext := path.Ext(name); ext != "" && ext != name {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == ' ' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return strings.Trim(b.String(), "- ")
+}
+
+// Parse splits the file on lines holding a single % and turns each block
+// into a record. A final line starting with -- becomes the author.
+func (p *FortuneParser) Parse(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLLineBytes)
+
+	var records []Record
+	var lines []string
+	row := 0
+
+	flush := func() {
+		record, ok := fortuneRecord(lines)
+		lines = lines[:0]
+		if !ok {
+			return
+		}
+		row++
+		record.Row = row
+		record.Category = p.category
+		records = append(records, record)
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "%" {
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fortune file: %w", err)
+	}
+	flush()
+
+	return records, nil
+}
+
+// fortuneRecord builds a record from the lines of one fortune. It returns
+// false for blocks that contain nothing but blank lines.
+func fortuneRecord(lines []string) (Record, bool) {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return Record{}, false
+	}
+
+	author, source := fortuneAnonymous, ""
+	if len(lines) > 1 {
+		if match := attributionPattern.FindStringSubmatch(lines[len(lines)-1]); match != nil {
+			author = match[1]
+			if parts := sourcePattern.FindStringSubmatch(author); parts != nil {
+				author, source = strings.TrimSpace(parts[1]), strings.TrimSpace(parts[2])
+			}
+			lines = lines[:len(lines)-1]
+			for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+		}
+	}
+
+	return Record{
+		Text:   strings.Join(lines, "\n"),
+		Author: author,
+		Source: source,
+	}, true
+}
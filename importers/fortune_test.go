@@ -0,0 +1,63 @@
+package importers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFortuneParser_Parse(t *testing.T) {
+	input := "A day without sunshine is like, you know, night.\n" +
+		"\t\t-- Steve Martin\n" +
+		"%\n" +
+		"%\n" +
+		"Q: How many programmers does it take to change a light bulb?\n" +
+		"A: None, that's a hardware problem.\n" +
+		"%\n" +
+		"Whenever you find yourself on the side of the majority,\n" +
+		"it is time to pause and reflect.\n" +
+		"\n" +
+		"    — Mark Twain, \"Notebook\"\n"
+
+	records, err := NewFortuneParser("humor").Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("Parse() count = %v, want 3", len(records))
+	}
+
+	tests := []struct {
+		text   string
+		author string
+		source string
+	}{
+		{text: "A day without sunshine is like, you know, night.", author: "Steve Martin"},
+		{text: "Q: How many programmers does it take to change a light bulb?\nA: None, that's a hardware problem.", author: "Anonymous"},
+		{text: "Whenever you find yourself on the side of the majority,\nit is time to pause and reflect.", author: "Mark Twain", source: "Notebook"},
+	}
+	for i, tt := range tests {
+		if records[i].Text != tt.text || records[i].Author != tt.author || records[i].Source != tt.source {
+			t.Errorf("Parse() record %d = %q by %q (%q), want %q by %q (%q)", i, records[i].Text, records[i].Author, records[i].Source, tt.text, tt.author, tt.source)
+		}
+		if records[i].Row != i+1 || records[i].Category != "humor" {
+			t.Errorf("Parse() record %d row/category = %v/%v", i, records[i].Row, records[i].Category)
+		}
+	}
+}
+
+func TestFortuneCategory(t *testing.T) {
+	tests := map[string]string{
+		"art":                                 "art",
+		"/usr/share/games/fortunes/computers": "computers",
+		"love.txt":                            "love",
+		"star_trek":                           "star-trek",
+		"":                                    "",
+	}
+
+	for filename, want := range tests {
+		if got := FortuneCategory(filename); got != want {
+			t.Errorf("FortuneCategory(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
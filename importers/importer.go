@@ -0,0 +1,83 @@
+package importers
+
+import (
+	"io"
+	"path"
+	"strings"
+)
+
+// Record is a single quote read from an import source. Row is the 1-based
+// position of the record in the source so reports can point back at it.
+// Err is set when the row could not be decoded at all.
+type Record struct {
+	Row      int
+	Text     string
+	Author   string
+	Category string
+	Source   string
+	Tags     []string
+	Likes    int
+	Err      error
+}
+
+// Parser decodes an import payload into records
+type Parser interface {
+	Parse(r io.Reader) ([]Record, error)
+}
+
+// MappingReporter is implemented by parsers that map source columns onto
+// quote fields. Mapping returns the field name to source column pairs used
+// by the last Parse, for display in import previews.
+type MappingReporter interface {
+	Mapping() map[string]string
+}
+
+// Supported import formats
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatFortune = "fortune"
+	FormatKindle  = "kindle"
+)
+
+// DetectFormat resolves the import format from an explicit format name or,
+// failing that, from the uploaded file's extension and then the request
+// content type. It returns an empty string when none of them identifies a
+// supported format. Kindle exports are recognised by their fixed file name;
+// fortune files have no conventional extension or media type, so they must
+// always be named explicitly.
+func DetectFormat(format, contentType, filename string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case FormatCSV:
+		return FormatCSV
+	case FormatJSONL, "ndjson":
+		return FormatJSONL
+	case FormatFortune:
+		return FormatFortune
+	case FormatKindle, "clippings":
+		return FormatKindle
+	case "":
+	default:
+		return ""
+	}
+
+	if strings.EqualFold(path.Base(filename), kindleClippingsFilename) {
+		return FormatKindle
+	}
+
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return FormatCSV
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	}
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return FormatCSV
+	case "application/jsonl", "application/x-jsonlines", "application/x-ndjson", "application/ndjson":
+		return FormatJSONL
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package importers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"quote-vault/models"
+)
+
+// maxJSONLLineBytes bounds a single JSON Lines record
+const maxJSONLLineBytes = 1 << 20
+
+// JSONLParser reads quotes from JSON Lines, one object per line
+type JSONLParser struct{}
+
+// NewJSONLParser creates a JSON Lines parser
+func NewJSONLParser() *JSONLParser {
+	return &JSONLParser{}
+}
+
+// Parse decodes each non-blank line as a quote object. Lines that are not
+// valid JSON are returned as records with Err set.
+func (p *JSONLParser) Parse(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLLineBytes)
+
+	var records []Record
+	row := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row++
+
+		var item struct {
+			Text     string   `json:"text"`
+			Author   string   `json:"author"`
+			Category string   `json:"category"`
+			Source   string   `json:"source"`
+			Tags     []string `json:"tags"`
+			Likes    int      `json:"likes"`
+		}
+		if err := json.Unmarshal(line, &item); err != nil {
+			records = append(records, Record{Row: row, Err: fmt.Errorf("invalid JSON: %w", err)})
+			continue
+		}
+
+		records = append(records, Record{
+			Row:      row,
+			Text:     strings.TrimSpace(item.Text),
+			Author:   strings.TrimSpace(item.Author),
+			Category: strings.TrimSpace(item.Category),
+			Source:   strings.TrimSpace(item.Source),
+			Tags:     models.NormalizeTags(item.Tags),
+			Likes:    item.Likes,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read jsonl: %w", err)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,32 @@
+package importers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONLParser_Parse(t *testing.T) {
+	input := `{"text":"Know thyself.","author":"Socrates","category":"wisdom"}
+
+not json
+{"text":"  Less is more.  ","author":"Mies van der Rohe"}
+`
+
+	records, err := NewJSONLParser().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("Parse() count = %v, want 3", len(records))
+	}
+	if records[0].Author != "Socrates" || records[0].Category != "wisdom" {
+		t.Errorf("Parse() first record = %+v", records[0])
+	}
+	if records[1].Err == nil || records[1].Row != 2 {
+		t.Errorf("Parse() invalid line = %+v, want error on row 2", records[1])
+	}
+	if records[2].Text != "Less is more." {
+		t.Errorf("Parse() text = %q, want trimmed text", records[2].Text)
+	}
+}
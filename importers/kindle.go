@@ -0,0 +1,200 @@
+package importers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// kindleClippingsFilename is the file Kindle devices write highlights to
+const kindleClippingsFilename = "My Clippings.txt"
+
+// kindleSeparator ends every entry in a clippings file
+const kindleSeparator = "=========="
+
+// kindleLocationPattern finds the location range in an entry's metadata
+// line, e.g. "Location 180-182" or the older "Loc. 1805-07"
+var kindleLocationPattern = regexp.MustCompile(`(?i)\bloc(?:ation|\.)?\s+(\d+)(?:-(\d+))?`)
+
+// KindleParser reads the "My Clippings.txt" file that Kindle devices keep.
+// Only highlights are imported; bookmarks and notes are skipped. When a
+// highlight is extended or adjusted Kindle appends a new entry rather than
+// replacing the old one, so overlapping highlights from the same book are
+// collapsed to the most recent.
+type KindleParser struct{}
+
+// NewKindleParser creates a Kindle clippings parser
+func NewKindleParser() *KindleParser {
+	return &KindleParser{}
+}
+
+// kindleHighlight is a parsed highlight along with the location range used
+// to detect overlaps. start is zero when the entry has no location.
+type kindleHighlight struct {
+	record Record
+	book   string
+	start  int
+	end    int
+}
+
+// Parse returns one record per distinct highlight, in file order. Row is
+// the position of the entry among all entries in the file, so skipped
+// bookmarks and notes leave gaps.
+func (p *KindleParser) Parse(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLLineBytes)
+
+	var highlights []*kindleHighlight
+	var lines []string
+	entry := 0
+
+	flush := func() {
+		if len(lines) == 0 {
+			return
+		}
+		entry++
+		highlight, ok := parseKindleEntry(lines)
+		lines = lines[:0]
+		if !ok {
+			return
+		}
+		highlight.record.Row = entry
+		highlights = mergeKindleHighlight(highlights, highlight)
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(strings.TrimPrefix(scanner.Text(), "\ufeff"), " \t\r")
+		if strings.TrimSpace(line) == kindleSeparator {
+			flush()
+			continue
+		}
+		if len(lines) == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read clippings file: %w", err)
+	}
+	flush()
+
+	records := make([]Record, 0, len(highlights))
+	for _, highlight := range highlights {
+		if highlight != nil {
+			records = append(records, highlight.record)
+		}
+	}
+	return records, nil
+}
+
+// parseKindleEntry turns the lines of one entry into a highlight. It
+// returns false for bookmarks, notes and empty highlights.
+func parseKindleEntry(lines []string) (*kindleHighlight, bool) {
+	if len(lines) < 2 {
+		return nil, false
+	}
+
+	meta := strings.ToLower(lines[1])
+	if !strings.Contains(meta, "highlight") {
+		return nil, false
+	}
+
+	body := lines[2:]
+	for len(body) > 0 && strings.TrimSpace(body[0]) == "" {
+		body = body[1:]
+	}
+	text := strings.TrimSpace(strings.Join(body, "\n"))
+	if text == "" {
+		return nil, false
+	}
+
+	title, author := splitKindleTitle(lines[0])
+	highlight := &kindleHighlight{
+		record: Record{
+			Text:   text,
+			Author: author,
+			Source: title,
+		},
+		book: strings.ToLower(lines[0]),
+	}
+
+	if match := kindleLocationPattern.FindStringSubmatch(lines[1]); match != nil {
+		highlight.start, _ = strconv.Atoi(match[1])
+		highlight.end = highlight.start
+		if match[2] != "" {
+			highlight.end = expandKindleLocation(match[1], match[2])
+		}
+	}
+
+	return highlight, true
+}
+
+// splitKindleTitle separates "Title (Author)" into its parts. Kindle writes
+// some authors as "Last, First", which is turned back into "First Last".
+func splitKindleTitle(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if !strings.HasSuffix(line, ")") {
+		return line, ""
+	}
+
+	depth := 0
+	for i := len(line) - 1; i >= 0; i-- {
+		switch line[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				title := strings.TrimSpace(line[:i])
+				author := strings.TrimSpace(line[i+1 : len(line)-1])
+				if parts := strings.Split(author, ","); len(parts) == 2 && !strings.Contains(author, ";") {
+					author = strings.TrimSpace(parts[1]) + " " + strings.TrimSpace(parts[0])
+				}
+				if title == "" {
+					return line, ""
+				}
+				return title, author
+			}
+		}
+	}
+	return line, ""
+}
+
+// expandKindleLocation resolves abbreviated range ends such as 1805-07,
+// where the end only gives the digits that differ from the start
+func expandKindleLocation(start, end string) int {
+	if len(end) < len(start) {
+		end = start[:len(start)-len(end)] + end
+	}
+	n, _ := strconv.Atoi(end)
+	return n
+}
+
+// mergeKindleHighlight adds highlight to the list, replacing an earlier
+// highlight from the same book that it overlaps. Replaced entries are set
+// to nil so the remaining ones keep their file order.
+func mergeKindleHighlight(highlights []*kindleHighlight, highlight *kindleHighlight) []*kindleHighlight {
+	for i, existing := range highlights {
+		if existing == nil || existing.book != highlight.book {
+			continue
+		}
+		if kindleOverlap(existing, highlight) {
+			highlights[i] = nil
+		}
+	}
+	return append(highlights, highlight)
+}
+
+// kindleOverlap reports whether two highlights from the same book cover the
+// same passage: one text contains the other and, when both have a
+// location, their ranges overlap. Neighbouring highlights often share a
+// location, so the range alone does not tell an edit apart.
+func kindleOverlap(a, b *kindleHighlight) bool {
+	if a.start > 0 && b.start > 0 && (a.start > b.end || b.start > a.end) {
+		return false
+	}
+	return strings.Contains(a.record.Text, b.record.Text) || strings.Contains(b.record.Text, a.record.Text)
+}
@@ -0,0 +1,111 @@
+package importers
+
+import (
+	"strings"
+	"testing"
+)
+
+const testClippings = "\ufeffMeditations (Aurelius, Marcus)\r\n" +
+	"- Your Highlight on page 12 | Location 180-182 | Added on Monday, March 4, 2019 10:15:23 AM\r\n" +
+	"\r\n" +
+	"You have power over your mind\r\n" +
+	"==========\r\n" +
+	"Meditations (Aurelius, Marcus)\r\n" +
+	"- Your Bookmark on page 13 | Location 190 | Added on Monday, March 4, 2019 10:16:00 AM\r\n" +
+	"\r\n" +
+	"\r\n" +
+	"==========\r\n" +
+	"Meditations (Aurelius, Marcus)\r\n" +
+	"- Your Highlight on page 12 | Location 180-184 | Added on Monday, March 4, 2019 10:17:00 AM\r\n" +
+	"\r\n" +
+	"You have power over your mind - not outside events. Realize this, and you will find strength.\r\n" +
+	"==========\r\n" +
+	"Meditations (Aurelius, Marcus)\r\n" +
+	"- Your Note on page 12 | Location 184 | Added on Monday, March 4, 2019 10:18:00 AM\r\n" +
+	"\r\n" +
+	"Read this again\r\n" +
+	"==========\r\n" +
+	"The Pragmatic Programmer (David Thomas;Andrew Hunt)\r\n" +
+	"- Highlight Loc. 1805-07 | Added on Tuesday, May 7, 2019, 09:00 PM\r\n" +
+	"\r\n" +
+	"Don't live with broken windows.\r\n" +
+	"==========\r\n"
+
+func TestKindleParser_Parse(t *testing.T) {
+	records, err := NewKindleParser().Parse(strings.NewReader(testClippings))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Parse() count = %v, want 2: %+v", len(records), records)
+	}
+
+	first := records[0]
+	if first.Row != 3 {
+		t.Errorf("Parse() first row = %v, want 3 (the extended highlight)", first.Row)
+	}
+	if !strings.HasSuffix(first.Text, "you will find strength.") {
+		t.Errorf("Parse() first text = %q, want the extended highlight", first.Text)
+	}
+	if first.Author != "Marcus Aurelius" || first.Source != "Meditations" {
+		t.Errorf("Parse() first author/source = %q/%q", first.Author, first.Source)
+	}
+
+	second := records[1]
+	if second.Author != "David Thomas;Andrew Hunt" || second.Source != "The Pragmatic Programmer" {
+		t.Errorf("Parse() second author/source = %q/%q", second.Author, second.Source)
+	}
+}
+
+func TestKindleParser_ParseKeepsNeighbouringHighlights(t *testing.T) {
+	clippings := "Meditations (Aurelius, Marcus)\r\n" +
+		"- Your Highlight on page 20 | Location 120-121 | Added on Monday, March 4, 2019 10:15:23 AM\r\n" +
+		"\r\n" +
+		"Waste no more time arguing about what a good man should be.\r\n" +
+		"==========\r\n" +
+		"Meditations (Aurelius, Marcus)\r\n" +
+		"- Your Highlight on page 20 | Location 121-123 | Added on Monday, March 4, 2019 10:16:00 AM\r\n" +
+		"\r\n" +
+		"Be one.\r\n" +
+		"==========\r\n"
+
+	records, err := NewKindleParser().Parse(strings.NewReader(clippings))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Parse() count = %v, want 2: %+v", len(records), records)
+	}
+	if records[0].Text != "Waste no more time arguing about what a good man should be." || records[1].Text != "Be one." {
+		t.Errorf("Parse() texts = %q, %q", records[0].Text, records[1].Text)
+	}
+}
+
+func TestSplitKindleTitle(t *testing.T) {
+	tests := []struct {
+		line   string
+		title  string
+		author string
+	}{
+		{line: "Dune (Frank Herbert)", title: "Dune", author: "Frank Herbert"},
+		{line: "Foundation (The Foundation Trilogy #1) (Asimov, Isaac)", title: "Foundation (The Foundation Trilogy #1)", author: "Isaac Asimov"},
+		{line: "Untitled Document", title: "Untitled Document", author: ""},
+	}
+
+	for _, tt := range tests {
+		title, author := splitKindleTitle(tt.line)
+		if title != tt.title || author != tt.author {
+			t.Errorf("splitKindleTitle(%q) = %q, %q, want %q, %q", tt.line, title, author, tt.title, tt.author)
+		}
+	}
+}
+
+func TestExpandKindleLocation(t *testing.T) {
+	if got := expandKindleLocation("1805", "07"); got != 1807 {
+		t.Errorf("expandKindleLocation(1805, 07) = %v, want 1807", got)
+	}
+	if got := expandKindleLocation("180", "1182"); got != 1182 {
+		t.Errorf("expandKindleLocation(180, 1182) = %v, want 1182", got)
+	}
+}
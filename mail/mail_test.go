@@ -0,0 +1,93 @@
+package mail
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/mail/mailtest"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"ann@example.com", "ann@example.com", false},
+		{"Quote Vault <quotes@example.com>", "quotes@example.com", false},
+		{"not an address", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAddress(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAddress(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSMTPSender_Send(t *testing.T) {
+	server := mailtest.NewServer()
+	defer server.Close()
+
+	sender := NewSMTPSender(SMTPConfig{Host: server.Host, Port: server.Port, Username: "vault", Password: "secret"})
+	msg := &Message{
+		From:    "Quote Vault <quotes@example.com>",
+		To:      "ann@example.com",
+		Subject: "Déjà vu — 2 new quotes",
+		Text:    "“Stay hungry.”\n— Steve Jobs",
+		HTML:    "<p>“Stay hungry.”</p>",
+		Header:  map[string]string{"list-unsubscribe": "<https://quotes.example.com/unsubscribe>"},
+	}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	received, err := server.Wait(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := received[0]
+	if got.From != "quotes@example.com" || len(got.To) != 1 || got.To[0] != "ann@example.com" {
+		t.Errorf("envelope = %s -> %v", got.From, got.To)
+	}
+	if got.Username != "vault" {
+		t.Errorf("authenticated as %q, want vault", got.Username)
+	}
+
+	parsed, err := got.Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if parsed.Subject != msg.Subject {
+		t.Errorf("Subject = %q, want %q", parsed.Subject, msg.Subject)
+	}
+	if text := strings.ReplaceAll(parsed.Text, "\r\n", "\n"); text != msg.Text {
+		t.Errorf("Text = %q, want %q", text, msg.Text)
+	}
+	if parsed.HTML != msg.HTML {
+		t.Errorf("HTML = %q, want %q", parsed.HTML, msg.HTML)
+	}
+	if h := parsed.Header.Get("List-Unsubscribe"); h != "<https://quotes.example.com/unsubscribe>" {
+		t.Errorf("List-Unsubscribe = %q", h)
+	}
+	if parsed.Header.Get("Message-ID") == "" || parsed.Header.Get("Date") == "" {
+		t.Error("Message-ID and Date headers should be set")
+	}
+}
+
+func TestSMTPSender_Rejected(t *testing.T) {
+	server := mailtest.NewServer()
+	defer server.Close()
+	server.Reject(true)
+
+	sender := NewSMTPSender(SMTPConfig{Host: server.Host, Port: server.Port})
+	err := sender.Send(context.Background(), &Message{From: "quotes@example.com", To: "ann@example.com", Subject: "Hi", Text: "Hello"})
+	if err == nil {
+		t.Fatal("Send() to a rejecting server should fail")
+	}
+	if n := len(server.Messages()); n != 0 {
+		t.Errorf("server kept %d messages, want 0", n)
+	}
+}
@@ -0,0 +1,306 @@
+// Package mailtest provides an in-process SMTP server for testing code
+// that sends email.
+package mailtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an SMTP server on the loopback interface that keeps the
+// messages it receives
+type Server struct {
+	Host string
+	Port int
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []*Received
+	reject   bool
+	received chan struct{}
+}
+
+// Received is a message as handed to the server
+type Received struct {
+	From string
+	To   []string
+	Data []byte
+	// Username is the user the client authenticated as, if it did
+	Username string
+}
+
+// NewServer starts a server, which must be closed when done with
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("mailtest: failed to listen: %v", err))
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+
+	s := &Server{
+		Host:     "127.0.0.1",
+		Port:     addr.Port,
+		listener: listener,
+		received: make(chan struct{}, 1),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close stops the server and waits for open connections to finish
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Reject makes the server refuse messages, as a full or failing server
+// would, until called with false
+func (s *Server) Reject(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reject
+}
+
+// Messages returns the messages received so far
+func (s *Server) Messages() []*Received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Received(nil), s.messages...)
+}
+
+// Wait waits until at least n messages have been received and returns
+// them, or fails once timeout has passed
+func (s *Server) Wait(n int, timeout time.Duration) ([]*Received, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		if messages := s.Messages(); len(messages) >= n {
+			return messages, nil
+		}
+		select {
+		case <-s.received:
+		case <-deadline.C:
+			return nil, fmt.Errorf("mailtest: received %d messages, want %d", len(s.Messages()), n)
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle speaks enough SMTP for a client to authenticate and send
+// messages
+func (s *Server) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	reply("220 mailtest ESMTP ready")
+
+	msg := &Received{}
+	var username string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-mailtest")
+			reply("250-8BITMIME")
+			reply("250 AUTH PLAIN")
+		case "HELO":
+			reply("250 mailtest")
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			if !strings.EqualFold(mechanism, "PLAIN") {
+				reply("504 unsupported mechanism")
+				continue
+			}
+			username = plainUser(initial)
+			reply("235 authenticated")
+		case "MAIL":
+			msg = &Received{From: address(arg), Username: username}
+			reply("250 ok")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			reply("250 ok")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			msg.Data = data
+			s.mu.Lock()
+			reject := s.reject
+			if !reject {
+				s.messages = append(s.messages, msg)
+			}
+			s.mu.Unlock()
+			if reject {
+				reply("554 message rejected")
+				continue
+			}
+			select {
+			case s.received <- struct{}{}:
+			default:
+			}
+			reply("250 queued")
+		case "RSET":
+			msg = &Received{Username: username}
+			reply("250 ok")
+		case "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// address returns the address of a MAIL FROM or RCPT TO argument
+func address(arg string) string {
+	if i := strings.Index(arg, "<"); i >= 0 {
+		if j := strings.Index(arg[i:], ">"); j >= 0 {
+			return arg[i+1 : i+j]
+		}
+	}
+	return arg
+}
+
+// plainUser returns the user of an AUTH PLAIN initial response
+func plainUser(initial string) string {
+	decoded, err := base64.StdEncoding.DecodeString(initial)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(string(decoded), "\x00")
+	if len(parts) != 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// readData reads a message up to the line holding a single dot, undoing
+// dot-stuffing
+func readData(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return buf.Bytes(), nil
+		}
+		buf.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+// Parsed is a received message taken apart
+type Parsed struct {
+	Header  mail.Header
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Parse decodes a received message: its headers, decoded subject and its
+// plain-text and HTML bodies
+func (m *Received) Parse() (*Parsed, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		return nil, err
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return nil, err
+	}
+	parsed := &Parsed{Header: msg.Header, Subject: subject}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, err := io.ReadAll(decodeBody(msg.Body, msg.Header.Get("Content-Transfer-Encoding")))
+		if err != nil {
+			return nil, err
+		}
+		parsed.Text = string(body)
+		return parsed, nil
+	}
+
+	// multipart.Reader undoes quoted-printable encoding of each part
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			return parsed, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "text/plain":
+			parsed.Text = string(body)
+		case "text/html":
+			parsed.HTML = string(body)
+		}
+	}
+}
+
+// decodeBody undoes the transfer encoding of a single-part body
+func decodeBody(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	}
+	return r
+}
+
+// String returns a short description of the message for test failures
+func (m *Received) String() string {
+	return "message from " + m.From + " to " + strings.Join(m.To, ", ") + " (" + strconv.Itoa(len(m.Data)) + " bytes)"
+}
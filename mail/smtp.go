@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// DefaultTimeout bounds a whole SMTP conversation when no other deadline
+// is set
+const DefaultTimeout = 30 * time.Second
+
+// Sender sends email messages
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// SMTPConfig says how to reach an SMTP server
+type SMTPConfig struct {
+	Host string
+	// Port 465 uses implicit TLS; any other port upgrades the connection
+	// with STARTTLS when the server offers it
+	Port int
+	// Username and Password authenticate with AUTH PLAIN when Username is
+	// set. Credentials are only sent over TLS, or to a server on the local
+	// host.
+	Username string
+	Password string
+	// Timeout bounds each message; DefaultTimeout when zero
+	Timeout time.Duration
+}
+
+// SMTPSender sends messages through an SMTP server, one connection per
+// message
+type SMTPSender struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPSender creates a sender for the SMTP server described by cfg
+func NewSMTPSender(cfg SMTPConfig) *SMTPSender {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	return &SMTPSender{cfg: cfg}
+}
+
+// Send delivers a message to the SMTP server
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	from, err := ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("invalid From address: %v", err)
+	}
+	to, err := ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid To address: %v", err)
+	}
+	data, err := msg.Bytes(time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port)))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+	if s.cfg.Port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.cfg.Port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -10,7 +10,9 @@ import (
 
 	"quote-vault/config"
 	"quote-vault/database"
+	"quote-vault/discord"
 	"quote-vault/handlers"
+	"quote-vault/mail"
 	"quote-vault/repository"
 	"quote-vault/router"
 	"quote-vault/services"
@@ -31,11 +33,146 @@ func main() {
 	// Setup repository, service, and handlers
 	quoteRepo := repository.NewQuoteRepository(db.DB())
 	quoteService := services.NewQuoteService(quoteRepo)
+	importService := services.NewImportService(quoteRepo)
+	exportService := services.NewExportService(quoteRepo)
+	webhookService := services.NewWebhookService(repository.NewWebhookRepository(db.DB()), services.WebhookOptions{})
+	eventLog := services.NewEventLog(repository.NewEventRepository(db.DB()), cfg.EventRetention)
+
+	// Side effects of changes are driven by the events recorded with them
+	eventBus := services.NewEventBus(repository.NewOutboxRepository(db.DB()), services.EventBusOptions{})
+	if err := eventBus.Subscribe("event_log", eventLog); err != nil {
+		log.Fatalf("Failed to subscribe event log: %v", err)
+	}
+	if err := eventBus.Subscribe("webhooks", webhookService); err != nil {
+		log.Fatalf("Failed to subscribe webhooks: %v", err)
+	}
+	quoteService.SetEventBus(eventBus)
+	importService.SetEventBus(eventBus)
+
+	// Email goes out through the configured mail server, if any
+	var sender mail.Sender
+	if cfg.SMTPHost != "" {
+		sender = mail.NewSMTPSender(mail.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		})
+	}
+
+	// Saved searches are told about new matching quotes by webhook, or by
+	// email when a mail server is configured
+	savedSearchService, err := services.NewSavedSearchService(repository.NewSavedSearchRepository(db.DB()), sender,
+		services.SavedSearchOptions{BaseURL: cfg.BaseURL, From: cfg.SMTPFrom})
+	if err != nil {
+		log.Fatalf("Failed to configure saved searches: %v", err)
+	}
+	if err := eventBus.Subscribe("saved_searches", savedSearchService); err != nil {
+		log.Fatalf("Failed to subscribe saved searches: %v", err)
+	}
+	// Publish quotes to followers on the fediverse when enabled
+	var activityPubService *services.ActivityPubService
+	var activityPubHandler *handlers.ActivityPubHandler
+	if cfg.ActivityPub {
+		activityPubService, err = services.NewActivityPubService(repository.NewActivityPubRepository(db.DB()), quoteRepo,
+			services.ActivityPubOptions{BaseURL: cfg.BaseURL})
+		if err != nil {
+			log.Fatalf("Failed to configure ActivityPub: %v", err)
+		}
+		if err := eventBus.Subscribe("activitypub", activityPubService); err != nil {
+			log.Fatalf("Failed to subscribe ActivityPub: %v", err)
+		}
+		activityPubHandler = handlers.NewActivityPubHandler(activityPubService)
+	}
 	quoteHandler := handlers.NewQuoteHandler(quoteService)
 	healthHandler := handlers.NewHealthHandler(db)
 
+	// Follow another instance when configured as a replica
+	var replicator *services.Replicator
+	if cfg.ReplicateFrom != "" {
+		replicator, err = services.NewReplicator(repository.NewReplicationRepository(db.DB()), quoteRepo, services.ReplicatorOptions{
+			Leader:    cfg.ReplicateFrom,
+			Conflicts: cfg.ReplicationConflicts,
+			Interval:  cfg.ReplicationInterval,
+			MaxLag:    cfg.ReplicationMaxLag,
+		})
+		if err != nil {
+			log.Fatalf("Failed to configure replication: %v", err)
+		}
+		replicator.SetEventBus(eventBus)
+		quoteService.SetReplica(replicator.Conflicts())
+		importService.SetReplica(replicator.Conflicts())
+		healthHandler.SetReplicator(replicator)
+	}
+	importHandler := handlers.NewImportHandler(importService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	cardHandler := handlers.NewCardHandler(quoteService, services.NewCardService(cfg.CardCacheSize))
+	feedHandler := handlers.NewFeedHandler(quoteService, cfg.BaseURL)
+	embedHandler := handlers.NewEmbedHandler(quoteService, cfg.BaseURL)
+	pageHandler := handlers.NewPageHandler(quoteService, cfg.BaseURL)
+	uiHandler := handlers.NewUIHandler(quoteService, handlers.UIOptions{
+		BaseURL:         cfg.BaseURL,
+		PageSize:        cfg.PageSize,
+		CuratorPassword: cfg.CuratorPassword,
+		SessionSecret:   cfg.SessionSecret,
+	})
+	// Webhooks are managed with the curator password; without one, those
+	// already registered keep receiving events
+	var webhookHandler *handlers.WebhookHandler
+	if cfg.CuratorPassword != "" {
+		webhookHandler = handlers.NewWebhookHandler(webhookService, cfg.CuratorPassword)
+	}
+	eventsHandler := handlers.NewEventsHandler(eventLog, 0)
+	changesService := services.NewChangesService(repository.NewChangeRepository(db.DB()), cfg.TombstoneRetention)
+	changesHandler := handlers.NewChangesHandler(changesService)
+	var slackHandler *handlers.SlackHandler
+	if cfg.SlackSigningSecret != "" {
+		slackHandler = handlers.NewSlackHandler(quoteService, cfg.SlackSigningSecret, cfg.BaseURL)
+	}
+	var discordHandler *handlers.DiscordHandler
+	if cfg.DiscordPublicKey != "" {
+		publicKey, err := discord.ParsePublicKey(cfg.DiscordPublicKey)
+		if err != nil {
+			log.Fatalf("Failed to configure Discord: %v", err)
+		}
+		discordHandler = handlers.NewDiscordHandler(quoteService, publicKey, cfg.BaseURL)
+	}
+
+	// Offer email digests when a mail server is configured
+	var digestService *services.DigestService
+	var digestHandler *handlers.DigestHandler
+	if sender != nil {
+		digestService, err = services.NewDigestService(repository.NewDigestRepository(db.DB()), quoteRepo, sender, services.DigestOptions{
+			BaseURL:  cfg.BaseURL,
+			From:     cfg.SMTPFrom,
+			SendHour: cfg.DigestSendHour,
+		})
+		if err != nil {
+			log.Fatalf("Failed to configure email digests: %v", err)
+		}
+		digestHandler = handlers.NewDigestHandler(digestService, cfg.BaseURL)
+	}
+
 	// Setup router (middleware is configured inside router)
-	r := router.NewRouter(quoteHandler, healthHandler)
+	r := router.NewRouter(router.Handlers{
+		Quote:       quoteHandler,
+		Health:      healthHandler,
+		Import:      importHandler,
+		Export:      exportHandler,
+		Card:        cardHandler,
+		Feed:        feedHandler,
+		Embed:       embedHandler,
+		Page:        pageHandler,
+		UI:          uiHandler,
+		Webhook:     webhookHandler,
+		Events:      eventsHandler,
+		Changes:     changesHandler,
+		Slack:       slackHandler,
+		Discord:     discordHandler,
+		ActivityPub: activityPubHandler,
+		Digest:      digestHandler,
+		SavedSearch: handlers.NewSavedSearchHandler(savedSearchService, cfg.BaseURL),
+	})
 
 	// Configure HTTP server
 	srv := &http.Server{
@@ -47,6 +184,51 @@ func main() {
 		MaxHeaderBytes: httpCfg.MaxHeaderBytes,
 	}
 
+	// Event streams never finish on their own, so end them on shutdown
+	srv.RegisterOnShutdown(eventLog.Close)
+
+	// Dispatch events, deliver webhooks, activities, digests and saved
+	// search matches, and follow the leader of a replica, in the
+	// background until shutdown
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	busDone := make(chan struct{})
+	go func() {
+		eventBus.Run(workerCtx)
+		close(busDone)
+	}()
+	workerDone := make(chan struct{})
+	go func() {
+		webhookService.Run(workerCtx)
+		close(workerDone)
+	}()
+	activityPubDone := make(chan struct{})
+	go func() {
+		if activityPubService != nil {
+			activityPubService.Run(workerCtx)
+		}
+		close(activityPubDone)
+	}()
+	searchDone := make(chan struct{})
+	go func() {
+		savedSearchService.Run(workerCtx)
+		close(searchDone)
+	}()
+	digestDone := make(chan struct{})
+	go func() {
+		if digestService != nil {
+			digestService.Run(workerCtx)
+		}
+		close(digestDone)
+	}()
+	replicatorDone := make(chan struct{})
+	go func() {
+		if replicator != nil {
+			log.Printf("Replicating from %s", cfg.ReplicateFrom)
+			replicator.Run(workerCtx)
+		}
+		close(replicatorDone)
+	}()
+
 	// Start server in goroutine
 	go func() {
 		log.Printf("Server starting on port %s", httpCfg.Port)
@@ -71,5 +253,16 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Stop the background workers once requests can no longer queue
+	// events; interrupted deliveries and undispatched events stay
+	// queued for the next start
+	stopWorker()
+	<-workerDone
+	<-activityPubDone
+	<-digestDone
+	<-searchDone
+	<-replicatorDone
+	<-busDone
+
 	log.Println("Server exited")
 }
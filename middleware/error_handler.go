@@ -35,6 +35,11 @@ type errorResponseWriter struct {
 	written bool
 }
 
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (ew *errorResponseWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
 // WriteError writes an error response
 func (ew *errorResponseWriter) WriteError(err error) {
 	if ew.written {
@@ -29,4 +29,10 @@ type responseWriter struct {
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
-}
\ No newline at end of file
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer,
+// so streaming responses can flush
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Follower is a remote ActivityPub account following one of the vault's
+// actors
+type Follower struct {
+	ID int `json:"id"`
+	// Actor is the name of the local actor followed
+	Actor string `json:"actor"`
+	// Follower is the ID of the remote account
+	Follower string `json:"follower"`
+	// Inbox receives the activities sent to the follower
+	Inbox     string    `json:"inbox"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ActivityDelivery is an activity queued for posting to a remote inbox
+type ActivityDelivery struct {
+	ID int `json:"id"`
+	// Actor is the name of the local actor sending the activity, whose
+	// key signs the request
+	Actor         string          `json:"actor"`
+	Inbox         string          `json:"inbox"`
+	ActivityID    string          `json:"activity_id"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
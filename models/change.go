@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Kinds of change in the changes feed
+const (
+	// ChangeUpsert means the quote was created or updated
+	ChangeUpsert = "upsert"
+	// ChangeDelete is a tombstone for a deleted quote
+	ChangeDelete = "delete"
+)
+
+// Change is the latest change to one quote. Sequence numbers grow with
+// every change, so a quote changed again moves to the end of the feed.
+type Change struct {
+	Sequence int64  `json:"seq"`
+	Type     string `json:"type"`
+	ID       int    `json:"id"`
+	// Quote is the current quote for upserts and nil for tombstones
+	Quote *Quote `json:"quote,omitempty"`
+	// Version is the version of the deleted quote for tombstones, 0 when
+	// it is not known
+	Version   int       `json:"version,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ChangeSet is one page of the changes feed
+type ChangeSet struct {
+	Changes []*Change `json:"changes"`
+	// Next is the token to pass as since in the following request
+	Next string `json:"next"`
+	// HasMore is set when more changes are waiting after this page
+	HasMore bool `json:"has_more"`
+	// FullResync is set when the given token is too old or unknown. The
+	// page then starts from the beginning, and the client should drop its
+	// copy and rebuild it from this and the following pages.
+	FullResync bool `json:"full_resync"`
+}
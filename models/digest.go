@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Digest frequencies
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSubscription is an email address receiving a digest of new quotes.
+// It only gets digests once the address has been confirmed.
+type DigestSubscription struct {
+	ID        int    `json:"id"`
+	Email     string `json:"email"`
+	Frequency string `json:"frequency"`
+	// Categories limits the digest to quotes in these categories; all
+	// categories when empty
+	Categories []string `json:"categories,omitempty"`
+	// ConfirmToken is sent in the confirmation email and UnsubscribeToken
+	// in every digest; they are never part of API responses
+	ConfirmToken     string     `json:"-"`
+	UnsubscribeToken string     `json:"-"`
+	ConfirmedAt      *time.Time `json:"confirmed_at,omitempty"`
+	// LastSentAt is when the latest digest was sent; the next one holds
+	// the quotes added since
+	LastSentAt   *time.Time `json:"last_sent_at,omitempty"`
+	NextDigestAt *time.Time `json:"next_digest_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Confirmed reports whether the address has been confirmed
+func (s *DigestSubscription) Confirmed() bool {
+	return s.ConfirmedAt != nil
+}
+
+// DigestSubscriptionRequest represents the payload for subscribing to a
+// digest
+type DigestSubscriptionRequest struct {
+	Email string `json:"email"`
+	// Frequency is "daily" or "weekly"
+	Frequency  string   `json:"frequency"`
+	Categories []string `json:"categories,omitempty"`
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Event types sent when the vault changes
+const (
+	EventQuoteCreated    = "quote.created"
+	EventQuoteUpdated    = "quote.updated"
+	EventQuoteDeleted    = "quote.deleted"
+	EventCategoryChanged = "category.changed"
+)
+
+// EventTypes lists every event type
+var EventTypes = []string{EventQuoteCreated, EventQuoteUpdated, EventQuoteDeleted, EventCategoryChanged}
+
+// Actions of a category.changed event
+const (
+	// CategoryAdded means a category got its first quote
+	CategoryAdded = "added"
+	// CategoryRemoved means a category lost its last quote
+	CategoryRemoved = "removed"
+)
+
+// Event describes a change to the vault
+type Event struct {
+	// ID is unique per event, so receivers can ignore repeated deliveries
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      EventData `json:"data"`
+}
+
+// EventData is the subject of an event: the quote for quote events, the
+// category and action for category.changed
+type EventData struct {
+	Quote    *Quote `json:"quote,omitempty"`
+	Category string `json:"category,omitempty"`
+	Action   string `json:"action,omitempty"`
+	// PreviousCategory is set on quote.updated when the quote was moved
+	// out of it
+	PreviousCategory string `json:"previous_category,omitempty"`
+}
+
+// NewEvent creates an event of the given type with a random ID
+func NewEvent(eventType string, data EventData) *Event {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return &Event{
+		ID:        "evt_" + hex.EncodeToString(id),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// IsEventType reports whether name is a known event type
+func IsEventType(name string) bool {
+	for _, eventType := range EventTypes {
+		if name == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Category returns the category an event concerns: that of its quote, or
+// the one a category.changed event is about
+func (e *Event) Category() string {
+	if e.Data.Quote != nil {
+		return e.Data.Quote.Category
+	}
+	return e.Data.Category
+}
+
+// LoggedEvent is an event as kept in the event log. Sequence numbers grow
+// in the order events were published.
+type LoggedEvent struct {
+	Sequence int64
+	ID       string
+	Type     string
+	Category string
+	// Payload is the event encoded as JSON
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// OutboxEvent is an event waiting in the outbox. Sequence numbers grow in
+// the order the changes were committed.
+type OutboxEvent struct {
+	Sequence int64
+	Event    *Event
+}
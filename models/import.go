@@ -0,0 +1,40 @@
+package models
+
+// Import row outcomes reported back to the client
+const (
+	ImportStatusCreated          = "created"
+	ImportStatusSkippedDuplicate = "skipped_duplicate"
+	ImportStatusInvalid          = "invalid"
+	// ImportStatusNotImported marks the rows left unwritten when an
+	// import stops part way
+	ImportStatusNotImported = "not_imported"
+)
+
+// ImportRowResult describes what happened to a single imported row
+type ImportRowResult struct {
+	Row     int    `json:"row"`
+	Status  string `json:"status"`
+	QuoteID int    `json:"quote_id,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	// Quote holds the mapped values in previews
+	Quote *Quote `json:"quote,omitempty"`
+}
+
+// ImportReport summarises a bulk import. Previews also carry the column
+// mapping that was applied and list only the first rows, while the counts
+// still cover the whole file.
+type ImportReport struct {
+	Format  string            `json:"format"`
+	Source  string            `json:"source,omitempty"`
+	DryRun  bool              `json:"dry_run"`
+	Preview bool              `json:"preview,omitempty"`
+	Mapping map[string]string `json:"mapping,omitempty"`
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	Invalid int               `json:"invalid"`
+	// NotImported counts the rows left unwritten when the import stopped
+	// part way
+	NotImported int               `json:"not_imported,omitempty"`
+	Rows        []ImportRowResult `json:"rows"`
+}
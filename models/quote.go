@@ -1,23 +1,34 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
 // Quote represents an inspirational quote with metadata
 type Quote struct {
-	ID        int       `json:"id"`
-	Text      string    `json:"text"`
-	Author    string    `json:"author"`
-	Category  string    `json:"category"`
+	ID       int    `json:"id"`
+	Text     string `json:"text"`
+	Author   string `json:"author"`
+	Category string `json:"category"`
+	Source   string `json:"source,omitempty"`
+	Tags     Tags   `json:"tags,omitempty"`
+	Likes    int    `json:"likes,omitempty"`
+	// Version starts at 1 and increases each time the quote changes
+	Version   int       `json:"version"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
-// QuoteRequest represents the payload for creating a new quote
+// QuoteRequest represents the payload for creating or updating a quote
 type QuoteRequest struct {
-	Text     string `json:"text" binding:"required"`
-	Author   string `json:"author" binding:"required"`
-	Category string `json:"category" binding:"required"`
+	Text     string   `json:"text" binding:"required"`
+	Author   string   `json:"author" binding:"required"`
+	Category string   `json:"category" binding:"required"`
+	Source   string   `json:"source,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// Version is only read by updates. When set, the update is rejected if
+	// the quote has changed since that version was read.
+	Version int `json:"version,omitempty"`
 }
 
 // QuoteResponse represents the response structure for quote operations
@@ -32,4 +43,58 @@ type QuotesListResponse struct {
 	Total  int     `json:"total"`
 	Page   int     `json:"page"`
 	Limit  int     `json:"limit"`
-}
\ No newline at end of file
+}
+
+// QuoteFilter narrows a quote search. Empty fields match every quote.
+type QuoteFilter struct {
+	Category string
+	Author   string
+	// Query matches quotes whose text, author or source contains it,
+	// ignoring ASCII case
+	Query string
+	// From and To, when set, keep quotes created at or after From and
+	// before To
+	From time.Time
+	To   time.Time
+}
+
+// Matches reports whether the filter keeps quote, comparing the way the
+// quote repository's searches do
+func (f QuoteFilter) Matches(quote *Quote) bool {
+	if f.Category != "" && quote.Category != f.Category {
+		return false
+	}
+	if f.Author != "" && lowerASCII(quote.Author) != lowerASCII(f.Author) {
+		return false
+	}
+	if query := lowerASCII(strings.TrimSpace(f.Query)); query != "" &&
+		!strings.Contains(lowerASCII(quote.Text), query) &&
+		!strings.Contains(lowerASCII(quote.Author), query) &&
+		!strings.Contains(lowerASCII(quote.Source), query) {
+		return false
+	}
+	if !f.From.IsZero() && quote.CreatedAt.Before(f.From.Truncate(time.Second)) {
+		return false
+	}
+	if !f.To.IsZero() && !quote.CreatedAt.Before(f.To.Truncate(time.Second)) {
+		return false
+	}
+	return true
+}
+
+// lowerASCII lower-cases the ASCII letters of s, the only ones SQLite
+// compares without case
+func lowerASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// AuthorCount is an author together with the number of their quotes
+type AuthorCount struct {
+	Author string `json:"author"`
+	Count  int    `json:"count"`
+}
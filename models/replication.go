@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Ways a follower handles writes made to it directly
+const (
+	// ConflictReject refuses every local write; the leader is the only
+	// source of changes
+	ConflictReject = "reject"
+	// ConflictLastWriterWins allows local edits and deletions. A local
+	// edit is kept until the leader sends a version at least as new, or
+	// deletes a version at least as new.
+	ConflictLastWriterWins = "last-writer-wins"
+)
+
+// States of a follower reported by ReplicationStatus
+const (
+	// ReplicationSyncing means the first sync, or a full resync, has not
+	// finished yet
+	ReplicationSyncing = "syncing"
+	// ReplicationCaughtUp means the follower recently had every change
+	ReplicationCaughtUp = "caught_up"
+	// ReplicationLagging means the follower has not caught up for longer
+	// than allowed
+	ReplicationLagging = "lagging"
+)
+
+// ReplicationState is a follower's saved position in its leader's changes
+// feed
+type ReplicationState struct {
+	Leader   string
+	Position string
+	// Resyncing is set while the follower copies the whole vault, after
+	// which quotes the leader did not send are removed
+	Resyncing bool
+}
+
+// ReplicatedChange is a change a follower applied to its own copy
+type ReplicatedChange struct {
+	// Previous is the quote before the change, nil when it was new
+	Previous *Quote
+	// Quote is the quote after the change, nil when it was deleted
+	Quote *Quote
+}
+
+// ReplicationStatus describes how far a follower is behind its leader
+type ReplicationStatus struct {
+	Leader    string `json:"leader"`
+	Conflicts string `json:"conflicts"`
+	State     string `json:"state"`
+	Position  string `json:"position"`
+	// LagSeconds is the time since the follower last had every change of
+	// the leader, nil before it first caught up
+	LagSeconds *float64   `json:"lag_seconds"`
+	LastSyncAt *time.Time `json:"last_sync_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	// LocalWins counts leader changes skipped because a local edit was
+	// newer, under last-writer-wins
+	LocalWins int64 `json:"local_wins"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// SavedSearch is a quote search kept to be told about new quotes matching
+// it, either by a signed webhook or by email
+type SavedSearch struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Query    string `json:"query,omitempty"`
+	// WebhookURL receives matches as signed POST requests
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// Secret signs webhook notifications. Responses only include it when
+	// the search is created.
+	Secret string `json:"secret,omitempty"`
+	// Email receives a message per match once the address is confirmed
+	Email            string     `json:"email,omitempty"`
+	ConfirmToken     string     `json:"-"`
+	UnsubscribeToken string     `json:"-"`
+	ConfirmedAt      *time.Time `json:"confirmed_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// Filter returns the quote filter the search runs
+func (s *SavedSearch) Filter() QuoteFilter {
+	return QuoteFilter{Category: s.Category, Author: s.Author, Query: s.Query}
+}
+
+// Active reports whether the search is matched against new quotes: webhook
+// searches always are, email ones once their address is confirmed
+func (s *SavedSearch) Active() bool {
+	return s.Email == "" || s.ConfirmedAt != nil
+}
+
+// SavedSearchRequest represents the payload for saving a search. At least
+// one of Category, Author and Query must be set, and exactly one of
+// WebhookURL and Email.
+type SavedSearchRequest struct {
+	Name       string `json:"name"`
+	Category   string `json:"category,omitempty"`
+	Author     string `json:"author,omitempty"`
+	Query      string `json:"query,omitempty"`
+	WebhookURL string `json:"webhook_url,omitempty"`
+	Email      string `json:"email,omitempty"`
+}
+
+// SearchMatch is a quote that matched a saved search when it was added,
+// with the outcome of notifying the search about it. Status is one of the
+// delivery statuses.
+type SearchMatch struct {
+	ID       int `json:"id"`
+	SearchID int `json:"search_id"`
+	// Quote is the quote as it was when it matched
+	Quote         *Quote     `json:"quote"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	NotifiedAt    *time.Time `json:"notified_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
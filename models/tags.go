@@ -0,0 +1,57 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Tags is a set of lowercase labels attached to a quote. It is stored in a
+// single column as a comma-separated list.
+type Tags []string
+
+// ParseTags splits a comma or semicolon separated list into normalised
+// tags, dropping blanks and duplicates while keeping the original order
+func ParseTags(s string) Tags {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';'
+	})
+	return NormalizeTags(fields)
+}
+
+// NormalizeTags lowercases and trims each tag, collapses inner whitespace
+// and removes blanks and duplicates
+func NormalizeTags(tags []string) Tags {
+	var result Tags
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		tag = strings.NewReplacer(",", " ", ";", " ").Replace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// Value implements driver.Valuer
+func (t Tags) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+// Scan implements sql.Scanner
+func (t *Tags) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+	case string:
+		*t = ParseTags(v)
+	case []byte:
+		*t = ParseTags(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into Tags", src)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is a URL that receives events as signed HTTP POST requests
+type Webhook struct {
+	ID     int      `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret signs deliveries. Responses only include it when the webhook
+	// is created or its secret rotated.
+	Secret string `json:"secret,omitempty"`
+	// PreviousSecret keeps signing deliveries next to a rotated secret
+	// until PreviousSecretExpiresAt, so receivers can switch over without
+	// rejecting any
+	PreviousSecret          string     `json:"-"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+	CreatedAt               time.Time  `json:"created_at"`
+}
+
+// Subscribes reports whether the webhook wants events of the given type
+func (h *Webhook) Subscribes(eventType string) bool {
+	for _, event := range h.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookRequest represents the payload for registering a webhook
+type WebhookRequest struct {
+	URL string `json:"url"`
+	// Events to receive; all event types when empty
+	Events []string `json:"events,omitempty"`
+}
+
+// Delivery statuses
+const (
+	// DeliveryPending deliveries are waiting for their next attempt
+	DeliveryPending = "pending"
+	// DeliverySucceeded deliveries got a 2xx response
+	DeliverySucceeded = "succeeded"
+	// DeliveryFailed deliveries ran out of attempts
+	DeliveryFailed = "failed"
+)
+
+// WebhookDelivery is one event queued for one webhook, with the outcome of
+// its latest attempt
+type WebhookDelivery struct {
+	ID        int             `json:"id"`
+	WebhookID int             `json:"webhook_id"`
+	EventID   string          `json:"event_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	// ResponseStatus is the HTTP status of the latest attempt, 0 when the
+	// request failed before a response arrived
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
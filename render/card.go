@@ -0,0 +1,186 @@
+package render
+
+import (
+	"io"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"quote-vault/models"
+)
+
+// Card dimensions, a 2:1 banner that suits READMEs and link previews
+const (
+	CardWidth  = 800
+	CardHeight = 400
+)
+
+// Card layout constants, in pixels
+const (
+	cardPadding      = 56
+	cardAuthorHeight = 56
+	cardAuthorSize   = 22
+	cardMaxFontSize  = 40
+	cardMinFontSize  = 16
+	// cardCharWidth approximates the average glyph advance of a serif
+	// font as a fraction of the font size
+	cardCharWidth  = 0.5
+	cardLineHeight = 1.35
+)
+
+// Theme is the color scheme of a quote card
+type Theme struct {
+	Name       string
+	Background string // empty for a transparent card
+	Border     string // empty for no border
+	Text       string
+	Author     string
+	Accent     string // color of the decorative quote mark, empty for none
+	FontFamily string
+}
+
+// Card themes
+var (
+	ThemeLight = Theme{
+		Name:       "light",
+		Background: "#ffffff",
+		Border:     "#d0d7de",
+		Text:       "#1f2328",
+		Author:     "#57606a",
+		Accent:     "#0969da",
+		FontFamily: "Georgia, 'Times New Roman', serif",
+	}
+	ThemeDark = Theme{
+		Name:       "dark",
+		Background: "#0d1117",
+		Border:     "#30363d",
+		Text:       "#e6edf3",
+		Author:     "#8b949e",
+		Accent:     "#58a6ff",
+		FontFamily: "Georgia, 'Times New Roman', serif",
+	}
+	ThemeMinimal = Theme{
+		Name:       "minimal",
+		Text:       "#24292f",
+		Author:     "#57606a",
+		FontFamily: "-apple-system, 'Segoe UI', Helvetica, Arial, sans-serif",
+	}
+)
+
+var themes = map[string]Theme{
+	ThemeLight.Name:   ThemeLight,
+	ThemeDark.Name:    ThemeDark,
+	ThemeMinimal.Name: ThemeMinimal,
+}
+
+// ParseTheme resolves a theme name, defaulting to light when name is empty.
+// It reports false for unknown names.
+func ParseTheme(name string) (Theme, bool) {
+	if name == "" {
+		return ThemeLight, true
+	}
+	theme, ok := themes[strings.ToLower(name)]
+	return theme, ok
+}
+
+// CardLine is a line of quote text positioned on the card
+type CardLine struct {
+	Text string
+	Y    float64
+}
+
+// CardLayout is a quote laid out on a card, shared by the image renderers
+type CardLayout struct {
+	Width, Height int
+	Padding       int
+	FontSize      float64
+	Lines         []CardLine
+	Author        string
+	AuthorY       float64
+	AuthorSize    float64
+	Label         string
+}
+
+// LayoutCard fits a quote onto a card, picking the largest font size at
+// which the wrapped text fits. Text that does not fit even at the smallest
+// size is cut short with an ellipsis.
+func LayoutCard(quote *models.Quote) *CardLayout {
+	text := "“" + strings.TrimSpace(quote.Text) + "”"
+	textWidth := float64(CardWidth - 2*cardPadding)
+	textHeight := float64(CardHeight - 2*cardPadding - cardAuthorHeight)
+
+	var lines []string
+	size := float64(cardMaxFontSize)
+	for ; size >= cardMinFontSize; size -= 2 {
+		lines = Wrap(text, int(textWidth/(size*cardCharWidth)))
+		if float64(len(lines))*size*cardLineHeight <= textHeight {
+			break
+		}
+	}
+	if size < cardMinFontSize {
+		size = cardMinFontSize
+		maxLines := int(textHeight / (size * cardLineHeight))
+		if len(lines) > maxLines {
+			lines = lines[:maxLines]
+			lines[maxLines-1] = strings.TrimRight(lines[maxLines-1], " .,;:") + "…"
+		}
+	}
+
+	lineHeight := size * cardLineHeight
+	top := cardPadding + (textHeight-float64(len(lines))*lineHeight)/2
+	layout := &CardLayout{
+		Width:      CardWidth,
+		Height:     CardHeight,
+		Padding:    cardPadding,
+		FontSize:   size,
+		Author:     truncate("— "+attribution(quote), int(textWidth/(cardAuthorSize*cardCharWidth))),
+		AuthorY:    CardHeight - cardPadding,
+		AuthorSize: cardAuthorSize,
+		Label:      strings.TrimSpace(quote.Text) + " — " + quote.Author,
+	}
+	for i, line := range lines {
+		// Baselines sit roughly one font size below the top of each line
+		layout.Lines = append(layout.Lines, CardLine{Text: line, Y: top + float64(i)*lineHeight + size})
+	}
+	return layout
+}
+
+// truncate shortens s to at most n runes, ending it with an ellipsis
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+var svgTemplate = template.Must(template.New("card").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Layout.Width}}" height="{{.Layout.Height}}" viewBox="0 0 {{.Layout.Width}} {{.Layout.Height}}" role="img" aria-label="{{html .Layout.Label}}">
+  <title>{{html .Layout.Label}}</title>
+{{- if .Theme.Background}}
+  <rect x="1" y="1" width="{{.InnerWidth}}" height="{{.InnerHeight}}" rx="12" fill="{{.Theme.Background}}"{{if .Theme.Border}} stroke="{{.Theme.Border}}" stroke-width="2"{{end}}/>
+{{- end}}
+{{- if .Theme.Accent}}
+  <text x="{{.MarkX}}" y="{{.MarkY}}" font-family="Georgia, serif" font-size="160" fill="{{.Theme.Accent}}" fill-opacity="0.15">“</text>
+{{- end}}
+  <text font-family="{{.Theme.FontFamily}}" font-size="{{.Layout.FontSize}}" fill="{{.Theme.Text}}">
+{{- range .Layout.Lines}}
+    <tspan x="{{$.Layout.Padding}}" y="{{printf "%.1f" .Y}}">{{html .Text}}</tspan>
+{{- end}}
+  </text>
+  <text x="{{.AuthorX}}" y="{{.Layout.AuthorY}}" text-anchor="end" font-family="{{.Theme.FontFamily}}" font-size="{{.Layout.AuthorSize}}" font-style="italic" fill="{{.Theme.Author}}">{{html .Layout.Author}}</text>
+</svg>
+`))
+
+// SVGCard writes a quote as a standalone SVG image
+func SVGCard(w io.Writer, quote *models.Quote, theme Theme) error {
+	layout := LayoutCard(quote)
+	return svgTemplate.Execute(w, map[string]interface{}{
+		"Layout":      layout,
+		"Theme":       theme,
+		"InnerWidth":  layout.Width - 2,
+		"InnerHeight": layout.Height - 2,
+		"MarkX":       layout.Padding / 2,
+		"MarkY":       layout.Padding + 80,
+		"AuthorX":     layout.Width - layout.Padding,
+	})
+}
@@ -0,0 +1,85 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"quote-vault/models"
+)
+
+func TestParseTheme(t *testing.T) {
+	if theme, ok := ParseTheme(""); !ok || theme.Name != "light" {
+		t.Errorf("ParseTheme(\"\") = %v, %v, want light", theme.Name, ok)
+	}
+	if theme, ok := ParseTheme("Dark"); !ok || theme.Name != "dark" {
+		t.Errorf("ParseTheme(Dark) = %v, %v, want dark", theme.Name, ok)
+	}
+	if _, ok := ParseTheme("neon"); ok {
+		t.Error("ParseTheme(neon) should fail")
+	}
+}
+
+func TestLayoutCard_AutoFit(t *testing.T) {
+	short := LayoutCard(&models.Quote{Text: "Less is more.", Author: "Mies van der Rohe"})
+	if short.FontSize != cardMaxFontSize || len(short.Lines) != 1 {
+		t.Errorf("short quote: font size %v with %d lines, want %d with 1", short.FontSize, len(short.Lines), cardMaxFontSize)
+	}
+
+	long := LayoutCard(&models.Quote{Text: strings.Repeat("All that we are is the result of what we have thought. ", 6), Author: "Buddha"})
+	if long.FontSize >= short.FontSize {
+		t.Errorf("long quote font size %v should be smaller than %v", long.FontSize, short.FontSize)
+	}
+	last := long.Lines[len(long.Lines)-1]
+	if last.Y > long.AuthorY-float64(cardAuthorSize) {
+		t.Errorf("last line at %v overlaps the author line at %v", last.Y, long.AuthorY)
+	}
+}
+
+func TestLayoutCard_Truncates(t *testing.T) {
+	layout := LayoutCard(&models.Quote{Text: strings.Repeat("word ", 400), Author: "Someone"})
+	if layout.FontSize != cardMinFontSize {
+		t.Errorf("font size = %v, want %v", layout.FontSize, cardMinFontSize)
+	}
+	if last := layout.Lines[len(layout.Lines)-1].Text; !strings.HasSuffix(last, "…") {
+		t.Errorf("last line = %q, want an ellipsis", last)
+	}
+}
+
+func TestSVGCard(t *testing.T) {
+	quote := testQuote()
+	quote.Author = "Seneca <the Younger> & co"
+
+	for _, theme := range []Theme{ThemeLight, ThemeDark, ThemeMinimal} {
+		t.Run(theme.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := SVGCard(&buf, quote, theme); err != nil {
+				t.Fatalf("SVGCard() error = %v", err)
+			}
+
+			// The card must be well-formed XML with its text escaped
+			decoder := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+			for {
+				if _, err := decoder.Token(); err != nil {
+					if err != io.EOF {
+						t.Fatalf("invalid SVG: %v\n%s", err, buf.String())
+					}
+					break
+				}
+			}
+
+			got := buf.String()
+			if !strings.Contains(got, "Seneca &lt;the Younger&gt; &amp; co") {
+				t.Errorf("author not escaped:\n%s", got)
+			}
+			if !strings.Contains(got, theme.Text) {
+				t.Errorf("text color %s missing", theme.Text)
+			}
+			if hasRect := strings.Contains(got, "<rect"); hasRect != (theme.Background != "") {
+				t.Errorf("background rect present = %v, want %v", hasRect, theme.Background != "")
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package render
+
+import (
+	"html/template"
+	"io"
+
+	"quote-vault/models"
+)
+
+// htmlTemplates render quotes as self-contained fragments that can be
+// dropped into another page. Class names are stable for styling.
+var htmlTemplates = template.Must(template.New("quote").Parse(`
+{{- define "quote" -}}
+<figure class="quote" data-id="{{.ID}}" data-category="{{.Category}}">
+  <blockquote class="quote-text">{{.Text}}</blockquote>
+  <figcaption class="quote-attribution">— <span class="quote-author">{{.Author}}</span>{{if .Source}}, <cite class="quote-source">{{.Source}}</cite>{{end}}</figcaption>
+</figure>
+{{end -}}
+{{- define "list" -}}
+<section class="quotes" data-total="{{.Total}}" data-page="{{.Page}}" data-limit="{{.Limit}}">
+{{range .Quotes}}{{template "quote" .}}{{end -}}
+</section>
+{{end -}}
+`))
+
+func htmlQuote(w io.Writer, quote *models.Quote) error {
+	return htmlTemplates.ExecuteTemplate(w, "quote", quote)
+}
+
+func htmlQuotes(w io.Writer, list *List) error {
+	return htmlTemplates.ExecuteTemplate(w, "list", list)
+}
@@ -0,0 +1,37 @@
+package render
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"quote-vault/models"
+)
+
+func writeMarkdownQuote(bw *bufio.Writer, quote *models.Quote) {
+	for _, line := range strings.Split(strings.TrimSpace(quote.Text), "\n") {
+		bw.WriteString(strings.TrimRight("> "+strings.TrimSpace(line), " ") + "\n")
+	}
+	bw.WriteString(">\n> — **" + quote.Author + "**")
+	if quote.Source != "" {
+		bw.WriteString(", *" + quote.Source + "*")
+	}
+	bw.WriteString("\n")
+}
+
+func markdownQuote(w io.Writer, quote *models.Quote) error {
+	bw := bufio.NewWriter(w)
+	writeMarkdownQuote(bw, quote)
+	return bw.Flush()
+}
+
+func markdownQuotes(w io.Writer, list *List) error {
+	bw := bufio.NewWriter(w)
+	for i, quote := range list.Quotes {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		writeMarkdownQuote(bw, quote)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,115 @@
+package render
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Output formats for quote responses
+const (
+	FormatJSON     = "json"
+	FormatText     = "text"
+	FormatHTML     = "html"
+	FormatXML      = "xml"
+	FormatMarkdown = "markdown"
+)
+
+// mediaTypes maps each format to the media types that select it, the first
+// being the Content-Type it is served as
+var mediaTypes = map[string][]string{
+	FormatJSON:     {"application/json"},
+	FormatText:     {"text/plain"},
+	FormatHTML:     {"text/html", "application/xhtml+xml"},
+	FormatXML:      {"application/xml", "text/xml"},
+	FormatMarkdown: {"text/markdown", "text/x-markdown"},
+}
+
+// formatNames maps ?format= values onto formats
+var formatNames = map[string]string{
+	"json":     FormatJSON,
+	"text":     FormatText,
+	"txt":      FormatText,
+	"plain":    FormatText,
+	"html":     FormatHTML,
+	"xml":      FormatXML,
+	"md":       FormatMarkdown,
+	"markdown": FormatMarkdown,
+}
+
+// ContentType returns the Content-Type header value for a format
+func ContentType(format string) string {
+	types, ok := mediaTypes[format]
+	if !ok {
+		return "application/json"
+	}
+	if format == FormatJSON {
+		return types[0]
+	}
+	return types[0] + "; charset=utf-8"
+}
+
+// ParseFormat resolves a ?format= value, reporting false for unknown names
+func ParseFormat(name string) (string, bool) {
+	format, ok := formatNames[strings.ToLower(strings.TrimSpace(name))]
+	return format, ok
+}
+
+// Negotiate picks the response format for a request. A known ?format=
+// value wins; otherwise the Accept header is matched by quality, and JSON
+// is used when nothing better matches so existing clients are unaffected.
+func Negotiate(r *http.Request) string {
+	if format, ok := ParseFormat(r.URL.Query().Get("format")); ok {
+		return format
+	}
+	return fromAccept(r.Header.Get("Accept"))
+}
+
+// acceptRange is one media range from an Accept header
+type acceptRange struct {
+	mediaType string
+	quality   float64
+	order     int
+}
+
+// fromAccept returns the supported format with the highest quality in an
+// Accept header. Wildcards match JSON; ties go to the earlier range.
+func fromAccept(header string) string {
+	var ranges []acceptRange
+	for i, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality, order: i})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, rng := range ranges {
+		if rng.mediaType == "*/*" || rng.mediaType == "application/*" {
+			return FormatJSON
+		}
+		for format, types := range mediaTypes {
+			for _, mediaType := range types {
+				if rng.mediaType == mediaType {
+					return format
+				}
+			}
+		}
+	}
+	return FormatJSON
+}
@@ -0,0 +1,38 @@
+package render
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		accept string
+		want   string
+	}{
+		{name: "no accept header", url: "/", want: FormatJSON},
+		{name: "curl default", url: "/", accept: "*/*", want: FormatJSON},
+		{name: "plain text", url: "/", accept: "text/plain", want: FormatText},
+		{name: "browser", url: "/", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: FormatHTML},
+		{name: "quality ordering", url: "/", accept: "text/plain;q=0.5, text/markdown", want: FormatMarkdown},
+		{name: "xml", url: "/", accept: "text/xml", want: FormatXML},
+		{name: "unsupported falls back to json", url: "/", accept: "image/png", want: FormatJSON},
+		{name: "zero quality is ignored", url: "/", accept: "text/html;q=0, text/plain", want: FormatText},
+		{name: "format override", url: "/?format=md", accept: "text/html", want: FormatMarkdown},
+		{name: "unknown override is ignored", url: "/?format=pdf", accept: "text/plain", want: FormatText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if got := Negotiate(req); got != tt.want {
+				t.Errorf("Negotiate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
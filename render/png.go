@@ -0,0 +1,269 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"quote-vault/models"
+)
+
+// Glyph cell size of the embedded bitmap font, in pixels
+const (
+	glyphWidth  = 6
+	glyphHeight = 13
+	// glyphLeading is the gap between lines of text
+	glyphLeading = 4
+)
+
+// PNG size bounds
+const (
+	MinPNGWidth  = 200
+	MaxPNGWidth  = 1600
+	MinPNGHeight = 100
+	MaxPNGHeight = 1200
+	// maxGlyphScale caps how far the bitmap font is enlarged for short
+	// quotes, beyond which it gets too blocky to read comfortably
+	maxGlyphScale = 5
+)
+
+// PNGOptions control PNG card rendering
+type PNGOptions struct {
+	Width, Height int
+	Padding       int
+	// Background holds one color for a solid fill or two for a diagonal
+	// gradient from the top left to the bottom right. It is empty for a
+	// transparent card.
+	Background []color.RGBA
+	Text       color.RGBA
+	Author     color.RGBA
+}
+
+// DefaultPNGOptions returns a card of the standard size in the colors of
+// theme
+func DefaultPNGOptions(theme Theme) PNGOptions {
+	opts := PNGOptions{
+		Width:   CardWidth,
+		Height:  CardHeight,
+		Padding: cardPadding,
+	}
+	if bg, ok := ParseColor(theme.Background); ok {
+		opts.Background = []color.RGBA{bg}
+	}
+	opts.Text, _ = ParseColor(theme.Text)
+	opts.Author, _ = ParseColor(theme.Author)
+	return opts
+}
+
+// Key identifies the options for caching rendered cards
+func (o PNGOptions) Key() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%dx%d:%d:", o.Width, o.Height, o.Padding)
+	for _, c := range o.Background {
+		b.WriteString(hexColor(c))
+	}
+	b.WriteString(":" + hexColor(o.Text) + ":" + hexColor(o.Author))
+	return b.String()
+}
+
+// ParseColor reads a #rrggbb or #rgb hex color; the # is optional
+func ParseColor(s string) (color.RGBA, bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, true
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// asciiReplacer maps typographic punctuation, and Latin letters that are
+// not a base letter with accents, onto the ASCII the bitmap font covers
+var asciiReplacer = strings.NewReplacer(
+	"“", `"`, "”", `"`, "„", `"`, "«", `"`, "»", `"`,
+	"‘", "'", "’", "'", "‚", "'",
+	"—", "--", "–", "-", "…", "...", " ", " ",
+	"ß", "ss", "Æ", "AE", "æ", "ae", "Œ", "OE", "œ", "oe", "Ø", "O", "ø", "o",
+	"Đ", "D", "đ", "d", "Ð", "D", "ð", "d", "Ł", "L", "ł", "l", "Þ", "Th", "þ", "th", "ı", "i",
+)
+
+// accentedLetters and baseLetters pair up rune for rune: every precomposed
+// Latin letter up to U+024F whose canonical decomposition starts with an
+// ASCII letter, and that letter
+const (
+	accentedLetters = "ÀÁÂÃÄÅÇÈÉÊËÌÍÎÏÑÒÓÔÕÖÙÚÛÜÝàáâãäåçèéêëìíîïñòóôõöù" +
+		"úûüýÿĀāĂăĄąĆćĈĉĊċČčĎďĒēĔĕĖėĘęĚěĜĝĞğĠġĢģĤĥĨĩĪīĬĭĮ" +
+		"įİĴĵĶķĹĺĻļĽľŃńŅņŇňŌōŎŏŐőŔŕŖŗŘřŚśŜŝŞşŠšŢţŤťŨũŪūŬŭ" +
+		"ŮůŰűŲųŴŵŶŷŸŹźŻżŽžƠơƯưǍǎǏǐǑǒǓǔǕǖǗǘǙǚǛǜǞǟǠǡǦǧǨǩǪǫǬ" +
+		"ǭǰǴǵǸǹǺǻȀȁȂȃȄȅȆȇȈȉȊȋȌȍȎȏȐȑȒȓȔȕȖȗȘșȚțȞȟȦȧȨȩȪȫȬȭȮȯ" +
+		"ȰȱȲȳ"
+	baseLetters = "AAAAAACEEEEIIIINOOOOOUUUUYaaaaaaceeeeiiiinooooou" +
+		"uuuyyAaAaAaCcCcCcCcDdEeEeEeEeEeGgGgGgGgHhIiIiIiI" +
+		"iIJjKkLlLlLlNnNnNnOoOoOoRrRrRrSsSsSsSsTtTtUuUuUu" +
+		"UuUuUuWwYyYZzZzZzOoUuAaIiOoUuUuUuUuUuAaAaGgKkOoO" +
+		"ojGgNnAaAaAaEeEeIiIiOoOoRrRrUuUuSsTtHhAaEeOoOoOo" +
+		"OoYy"
+)
+
+// baseLetter maps each of accentedLetters to its base letter
+var baseLetter = func() map[rune]rune {
+	base := []rune(baseLetters)
+	m := make(map[rune]rune, len(base))
+	for i, r := range []rune(accentedLetters) {
+		m[r] = base[i]
+	}
+	return m
+}()
+
+// toASCII prepares text for the bitmap font. Accented Latin letters lose
+// their accents, whether precomposed or followed by combining marks; any
+// other character it cannot draw, such as Greek, Cyrillic or CJK text,
+// becomes a question mark.
+func toASCII(s string) string {
+	s = asciiReplacer.Replace(s)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || (r >= 0x20 && r < 0x7f):
+			return r
+		case unicode.Is(unicode.Mn, r):
+			return -1
+		}
+		if base, ok := baseLetter[r]; ok {
+			return base
+		}
+		return '?'
+	}, s)
+}
+
+// PNGCard writes a quote as a PNG image, drawn with the embedded bitmap
+// font at the largest whole-pixel scale at which the text fits
+func PNGCard(w io.Writer, quote *models.Quote, opts PNGOptions) error {
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	fillBackground(img, opts.Background)
+
+	textWidth := opts.Width - 2*opts.Padding
+	authorScale := 1
+	textHeight := opts.Height - 2*opts.Padding
+
+	text := toASCII(`"` + strings.TrimSpace(quote.Text) + `"`)
+	var lines []string
+	scale := maxGlyphScale
+	for ; scale > 1; scale-- {
+		authorScale = (scale*2 + 2) / 3
+		lines = Wrap(text, textWidth/(glyphWidth*scale))
+		height := len(lines)*(glyphHeight+glyphLeading)*scale + 2*(glyphHeight+glyphLeading)*authorScale
+		if height <= textHeight && fitsWidth(lines, textWidth, scale) {
+			break
+		}
+	}
+	if scale == 1 {
+		authorScale = 1
+		lines = Wrap(text, textWidth/glyphWidth)
+		maxLines := (textHeight - 2*(glyphHeight+glyphLeading)) / (glyphHeight + glyphLeading)
+		if maxLines < 1 {
+			maxLines = 1
+		}
+		if len(lines) > maxLines {
+			lines = lines[:maxLines]
+			lines[maxLines-1] = strings.TrimRight(lines[maxLines-1], " .,;:") + "..."
+		}
+	}
+
+	lineHeight := (glyphHeight + glyphLeading) * scale
+	authorHeight := glyphHeight * authorScale
+	blockHeight := len(lines) * lineHeight
+	top := opts.Padding + (textHeight-authorHeight-blockHeight)/2
+	for i, line := range lines {
+		drawString(img, line, opts.Padding, top+i*lineHeight, scale, opts.Text)
+	}
+
+	author := toASCII("-- " + attribution(quote))
+	if max := textWidth / (glyphWidth * authorScale); len(author) > max && max > 3 {
+		author = strings.TrimSpace(author[:max-3]) + "..."
+	}
+	authorX := opts.Width - opts.Padding - len(author)*glyphWidth*authorScale
+	drawString(img, author, authorX, opts.Height-opts.Padding-authorHeight, authorScale, opts.Author)
+
+	return png.Encode(w, img)
+}
+
+// fitsWidth reports whether every line fits across width at scale. Wrap
+// leaves words longer than a line whole, so long words can overflow.
+func fitsWidth(lines []string, width, scale int) bool {
+	for _, line := range lines {
+		if utf8.RuneCountInString(line)*glyphWidth*scale > width {
+			return false
+		}
+	}
+	return true
+}
+
+func fillBackground(img *image.RGBA, colors []color.RGBA) {
+	switch len(colors) {
+	case 0:
+		return
+	case 1:
+		draw.Draw(img, img.Bounds(), image.NewUniform(colors[0]), image.Point{}, draw.Src)
+		return
+	}
+
+	from, to := colors[0], colors[1]
+	bounds := img.Bounds()
+	span := bounds.Dx() + bounds.Dy() - 2
+	if span < 1 {
+		span = 1
+	}
+	mix := func(a, b uint8, t, n int) uint8 {
+		return uint8((int(a)*(n-t) + int(b)*t) / n)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			t := x + y
+			img.SetRGBA(x, y, color.RGBA{
+				R: mix(from.R, to.R, t, span),
+				G: mix(from.G, to.G, t, span),
+				B: mix(from.B, to.B, t, span),
+				A: 0xff,
+			})
+		}
+	}
+}
+
+// drawString draws ASCII text with its top left corner at x, y, enlarging
+// each font pixel to a scale×scale square
+func drawString(img *image.RGBA, s string, x, y, scale int, c color.RGBA) {
+	src := image.NewUniform(c)
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch < 0x20 || ch >= 0x7f {
+			ch = '?'
+		}
+		glyph := glyphs[ch-0x20]
+		left := x + i*glyphWidth*scale
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				px := image.Rect(left+col*scale, y+row*scale, left+(col+1)*scale, y+(row+1)*scale)
+				draw.Draw(img, px, src, image.Point{}, draw.Over)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.RGBA
+		ok   bool
+	}{
+		{"#0d1117", color.RGBA{0x0d, 0x11, 0x17, 0xff}, true},
+		{"ff8800", color.RGBA{0xff, 0x88, 0x00, 0xff}, true},
+		{"#fff", color.RGBA{0xff, 0xff, 0xff, 0xff}, true},
+		{"#12345", color.RGBA{}, false},
+		{"red", color.RGBA{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseColor(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"“Café” — it’s…", `"Cafe" -- it's...`},
+		{"Camus, Albert (é)", "Camus, Albert (e)"},
+		{"Gödel", "Godel"},
+		{"Nietzsche (Übermensch)", "Nietzsche (Ubermensch)"},
+		{"Łukasz, Straße, Ærø", "Lukasz, Strasse, AEro"},
+		// Decomposed accents are dropped the same way
+		{"Go\u0308del", "Godel"},
+		// Scripts the bitmap font cannot draw are not transliterated
+		{"知者不言", "????"},
+		{"Σωκράτης", "????????"},
+	}
+	for _, tt := range tests {
+		if got := toASCII(tt.in); got != tt.want {
+			t.Errorf("toASCII(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func decodePNG(t *testing.T, opts PNGOptions) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := PNGCard(&buf, testQuote(), opts); err != nil {
+		t.Fatalf("PNGCard() error = %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+	return img
+}
+
+func TestPNGCard(t *testing.T) {
+	opts := DefaultPNGOptions(ThemeDark)
+	opts.Width, opts.Height = 600, 300
+	img := decodePNG(t, opts)
+
+	if size := img.Bounds().Size(); size.X != 600 || size.Y != 300 {
+		t.Fatalf("size = %v, want 600x300", size)
+	}
+	if r, g, b, _ := img.At(0, 0).RGBA(); r>>8 != 0x0d || g>>8 != 0x11 || b>>8 != 0x17 {
+		t.Errorf("background = %v, want the dark theme background", img.At(0, 0))
+	}
+
+	// Some pixels inside the padding must have been drawn in the text color
+	text, _ := ParseColor(ThemeDark.Text)
+	found := false
+	for y := opts.Padding; y < opts.Height-opts.Padding && !found; y++ {
+		for x := opts.Padding; x < opts.Width-opts.Padding; x++ {
+			if color.RGBAModel.Convert(img.At(x, y)) == text {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		t.Error("no text drawn on the card")
+	}
+}
+
+func TestPNGCard_Gradient(t *testing.T) {
+	opts := DefaultPNGOptions(ThemeLight)
+	opts.Background = []color.RGBA{{0xff, 0x00, 0x00, 0xff}, {0x00, 0x00, 0xff, 0xff}}
+	img := decodePNG(t, opts)
+
+	bounds := img.Bounds()
+	if got := color.RGBAModel.Convert(img.At(0, 0)); got != opts.Background[0] {
+		t.Errorf("top left = %v, want %v", got, opts.Background[0])
+	}
+	if got := color.RGBAModel.Convert(img.At(bounds.Max.X-1, bounds.Max.Y-1)); got != opts.Background[1] {
+		t.Errorf("bottom right = %v, want %v", got, opts.Background[1])
+	}
+}
+
+func TestPNGCard_Transparent(t *testing.T) {
+	img := decodePNG(t, DefaultPNGOptions(ThemeMinimal))
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("corner alpha = %d, want a transparent background", a)
+	}
+}
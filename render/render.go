@@ -0,0 +1,62 @@
+package render
+
+import (
+	"io"
+
+	"quote-vault/models"
+)
+
+// List is a page of quotes with its pagination details
+type List struct {
+	Quotes []*models.Quote
+	Total  int
+	Page   int
+	Limit  int
+}
+
+// Options tune how quotes are rendered
+type Options struct {
+	// Width is the column to wrap plain text at
+	Width int
+}
+
+// DefaultWidth is the plain text wrap column when none is requested
+const DefaultWidth = 72
+
+// Quote writes a single quote in one of the non-JSON formats
+func Quote(w io.Writer, format string, quote *models.Quote, opts Options) error {
+	switch format {
+	case FormatText:
+		return textQuote(w, quote, opts)
+	case FormatHTML:
+		return htmlQuote(w, quote)
+	case FormatXML:
+		return xmlQuote(w, quote)
+	case FormatMarkdown:
+		return markdownQuote(w, quote)
+	}
+	return nil
+}
+
+// Quotes writes a page of quotes in one of the non-JSON formats
+func Quotes(w io.Writer, format string, list *List, opts Options) error {
+	switch format {
+	case FormatText:
+		return textQuotes(w, list, opts)
+	case FormatHTML:
+		return htmlQuotes(w, list)
+	case FormatXML:
+		return xmlQuotes(w, list)
+	case FormatMarkdown:
+		return markdownQuotes(w, list)
+	}
+	return nil
+}
+
+// attribution joins the author and source into a single line
+func attribution(quote *models.Quote) string {
+	if quote.Source == "" {
+		return quote.Author
+	}
+	return quote.Author + ", " + quote.Source
+}
@@ -0,0 +1,92 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func testQuote() *models.Quote {
+	return &models.Quote{
+		ID:        7,
+		Text:      "It is not that we have a short time to live, but that we waste a lot of it.",
+		Author:    "Seneca",
+		Category:  "wisdom",
+		Source:    "On the Shortness of Life",
+		CreatedAt: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func renderQuote(t *testing.T, format string, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Quote(&buf, format, testQuote(), opts); err != nil {
+		t.Fatalf("Quote(%s) error = %v", format, err)
+	}
+	return buf.String()
+}
+
+func TestWrap(t *testing.T) {
+	got := Wrap("the quick brown fox jumps over the lazy dog again and again", 20)
+	want := []string{"the quick brown fox", "jumps over the lazy", "dog again and again"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Wrap() = %q, want %q", got, want)
+	}
+}
+
+func TestQuote_Text(t *testing.T) {
+	got := renderQuote(t, FormatText, Options{Width: 40})
+	want := "“It is not that we have a short time to\n" +
+		"live, but that we waste a lot of it.”\n" +
+		"    — Seneca, On the Shortness of Life\n"
+	if got != want {
+		t.Errorf("text quote =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestQuote_HTML(t *testing.T) {
+	quote := testQuote()
+	quote.Text = "<script>alert(1)</script>"
+
+	var buf bytes.Buffer
+	if err := Quote(&buf, FormatHTML, quote, Options{}); err != nil {
+		t.Fatalf("Quote(html) error = %v", err)
+	}
+	got := buf.String()
+	if strings.Contains(got, "<script>") {
+		t.Errorf("html quote is not escaped: %s", got)
+	}
+	if !strings.Contains(got, `<cite class="quote-source">On the Shortness of Life</cite>`) {
+		t.Errorf("html quote missing source: %s", got)
+	}
+}
+
+func TestQuote_XML(t *testing.T) {
+	got := renderQuote(t, FormatXML, Options{})
+	if !strings.HasPrefix(got, "<?xml") || !strings.Contains(got, `<quote id="7">`) || !strings.Contains(got, "<author>Seneca</author>") {
+		t.Errorf("xml quote = %s", got)
+	}
+}
+
+func TestQuote_Markdown(t *testing.T) {
+	got := renderQuote(t, FormatMarkdown, Options{})
+	want := "> It is not that we have a short time to live, but that we waste a lot of it.\n" +
+		">\n> — **Seneca**, *On the Shortness of Life*\n"
+	if got != want {
+		t.Errorf("markdown quote = %q, want %q", got, want)
+	}
+}
+
+func TestQuotes_Text(t *testing.T) {
+	var buf bytes.Buffer
+	list := &List{Quotes: []*models.Quote{testQuote(), testQuote()}, Total: 12, Page: 2, Limit: 5}
+	if err := Quotes(&buf, FormatText, list, Options{}); err != nil {
+		t.Fatalf("Quotes(text) error = %v", err)
+	}
+	if !strings.HasSuffix(buf.String(), "[page 2 of 3, 12 quotes]\n") {
+		t.Errorf("text list footer = %q", buf.String())
+	}
+}
@@ -0,0 +1,219 @@
+package render
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"quote-vault/models"
+)
+
+// Terminal frames
+const (
+	FrameNone = "none"
+	FrameBox  = "box"
+	FrameCow  = "cow"
+)
+
+// maxWidth bounds ?width= so a typo cannot produce absurd output
+const maxWidth = 200
+
+// ANSI escape sequences used by terminal output
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiItalic = "\x1b[3m"
+	ansiCyan   = "\x1b[36m"
+	ansiDim    = "\x1b[2m"
+)
+
+// terminalAgents are User-Agent prefixes of command-line HTTP clients
+var terminalAgents = []string{"curl/", "wget/", "httpie/", "xh/"}
+
+// TermOptions control terminal output
+type TermOptions struct {
+	Width int
+	Frame string
+	Color bool
+}
+
+// WantsTerminal reports whether a request should get terminal output. An
+// explicit ?term= always decides. Otherwise command-line clients get it,
+// unless they asked for a specific format through ?format= or Accept.
+func WantsTerminal(r *http.Request) bool {
+	query := r.URL.Query()
+	if term := query.Get("term"); term != "" {
+		enabled, err := strconv.ParseBool(term)
+		return err == nil && enabled
+	}
+	if query.Get("format") != "" {
+		return false
+	}
+	if accept := strings.TrimSpace(r.Header.Get("Accept")); accept != "" && accept != "*/*" {
+		return false
+	}
+
+	agent := strings.ToLower(r.UserAgent())
+	for _, prefix := range terminalAgents {
+		if strings.HasPrefix(agent, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseWidth reads ?width=, clamped to a usable range, falling back to
+// DefaultWidth when it is absent or not a number
+func ParseWidth(r *http.Request) int {
+	width, err := strconv.Atoi(r.URL.Query().Get("width"))
+	if err != nil || width <= 0 {
+		return DefaultWidth
+	}
+	if width < minWidth {
+		return minWidth
+	}
+	if width > maxWidth {
+		return maxWidth
+	}
+	return width
+}
+
+// ParseTermOptions reads the terminal options from the query string:
+// ?width=, ?frame=box|cow|none (default box) and ?color=false
+func ParseTermOptions(r *http.Request) TermOptions {
+	query := r.URL.Query()
+
+	frame := strings.ToLower(query.Get("frame"))
+	switch frame {
+	case FrameNone, FrameBox, FrameCow:
+	case "cowsay":
+		frame = FrameCow
+	default:
+		frame = FrameBox
+	}
+
+	color := true
+	if value := query.Get("color"); value != "" {
+		color, _ = strconv.ParseBool(value)
+	}
+
+	return TermOptions{
+		Width: ParseWidth(r),
+		Frame: frame,
+		Color: color,
+	}
+}
+
+// termLine is a line of terminal output with its display width, which
+// excludes any escape sequences
+type termLine struct {
+	text  string
+	width int
+}
+
+// termBody wraps a quote to width and styles it, returning the lines that
+// go inside the frame
+func termBody(quote *models.Quote, width int, color bool) []termLine {
+	var lines []termLine
+	for _, line := range Wrap("“"+strings.TrimSpace(quote.Text)+"”", width) {
+		styled := line
+		if color {
+			styled = ansiBold + line + ansiReset
+		}
+		lines = append(lines, termLine{text: styled, width: utf8.RuneCountInString(line)})
+	}
+
+	author := Wrap("— "+quote.Author, width-2)
+	for _, line := range author {
+		line = "  " + line
+		styled := line
+		if color {
+			styled = ansiCyan + line + ansiReset
+		}
+		lines = append(lines, termLine{text: styled, width: utf8.RuneCountInString(line)})
+	}
+	if quote.Source != "" {
+		for _, line := range Wrap(quote.Source, width-4) {
+			line = "    " + line
+			styled := line
+			if color {
+				styled = ansiDim + ansiItalic + line + ansiReset
+			}
+			lines = append(lines, termLine{text: styled, width: utf8.RuneCountInString(line)})
+		}
+	}
+	return lines
+}
+
+// Terminal writes a quote for display in a terminal
+func Terminal(w io.Writer, quote *models.Quote, opts TermOptions) error {
+	if opts.Width <= 0 {
+		opts.Width = DefaultWidth
+	}
+
+	bw := bufio.NewWriter(w)
+	switch opts.Frame {
+	case FrameBox:
+		writeBox(bw, termBody(quote, opts.Width-4, opts.Color))
+	case FrameCow:
+		writeCow(bw, termBody(quote, opts.Width-4, opts.Color))
+	default:
+		for _, line := range termBody(quote, opts.Width, opts.Color) {
+			bw.WriteString(line.text + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+func innerWidth(lines []termLine) int {
+	width := 0
+	for _, line := range lines {
+		if line.width > width {
+			width = line.width
+		}
+	}
+	return width
+}
+
+func pad(line termLine, width int) string {
+	return line.text + strings.Repeat(" ", width-line.width)
+}
+
+func writeBox(bw *bufio.Writer, lines []termLine) {
+	width := innerWidth(lines)
+	bw.WriteString("╭" + strings.Repeat("─", width+2) + "╮\n")
+	for _, line := range lines {
+		bw.WriteString("│ " + pad(line, width) + " │\n")
+	}
+	bw.WriteString("╰" + strings.Repeat("─", width+2) + "╯\n")
+}
+
+// cow is the classic cowsay cow, hanging off the bottom of the balloon
+const cow = `        \   ^__^
+         \  (oo)\_______
+            (__)\       )\/\
+                ||----w |
+                ||     ||
+`
+
+func writeCow(bw *bufio.Writer, lines []termLine) {
+	width := innerWidth(lines)
+	bw.WriteString(" " + strings.Repeat("_", width+2) + "\n")
+	for i, line := range lines {
+		left, right := "|", "|"
+		switch {
+		case len(lines) == 1:
+			left, right = "<", ">"
+		case i == 0:
+			left, right = "/", "\\"
+		case i == len(lines)-1:
+			left, right = "\\", "/"
+		}
+		bw.WriteString(left + " " + pad(line, width) + " " + right + "\n")
+	}
+	bw.WriteString(" " + strings.Repeat("-", width+2) + "\n")
+	bw.WriteString(cow)
+}
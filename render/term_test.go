@@ -0,0 +1,137 @@
+package render
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func renderTerminal(t *testing.T, opts TermOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Terminal(&buf, testQuote(), opts); err != nil {
+		t.Fatalf("Terminal() error = %v", err)
+	}
+	return buf.String()
+}
+
+func TestWantsTerminal(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		agent  string
+		accept string
+		want   bool
+	}{
+		{"curl", "/quotes/random", "curl/8.4.0", "*/*", true},
+		{"wget", "/quotes/random", "Wget/1.21.4", "", true},
+		{"httpie", "/quotes/random", "HTTPie/3.2.2", "", true},
+		{"browser", "/quotes/random", "Mozilla/5.0", "text/html", false},
+		{"curl asking for json", "/quotes/random", "curl/8.4.0", "application/json", false},
+		{"curl with format", "/quotes/random?format=json", "curl/8.4.0", "*/*", false},
+		{"term param", "/quotes/random?term=1", "Mozilla/5.0", "text/html", true},
+		{"term disabled", "/quotes/random?term=0", "curl/8.4.0", "*/*", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			r.Header.Set("User-Agent", tt.agent)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if got := WantsTerminal(r); got != tt.want {
+				t.Errorf("WantsTerminal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTermOptions(t *testing.T) {
+	r := httptest.NewRequest("GET", "/quotes/random?width=500&frame=cowsay&color=false", nil)
+	opts := ParseTermOptions(r)
+	if opts.Width != maxWidth || opts.Frame != FrameCow || opts.Color {
+		t.Errorf("ParseTermOptions() = %+v", opts)
+	}
+
+	opts = ParseTermOptions(httptest.NewRequest("GET", "/quotes/random", nil))
+	if opts.Width != DefaultWidth || opts.Frame != FrameBox || !opts.Color {
+		t.Errorf("ParseTermOptions() defaults = %+v", opts)
+	}
+}
+
+func TestTerminal_Box(t *testing.T) {
+	got := renderTerminal(t, TermOptions{Width: 40, Frame: FrameBox})
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	if !strings.HasPrefix(lines[0], "╭") || !strings.HasPrefix(lines[len(lines)-1], "╰") {
+		t.Errorf("box not closed:\n%s", got)
+	}
+	width := utf8.RuneCountInString(lines[0])
+	if width > 40 {
+		t.Errorf("box is %d columns wide, want at most 40", width)
+	}
+	for _, line := range lines {
+		if n := utf8.RuneCountInString(line); n != width {
+			t.Errorf("line %q is %d columns, want %d", line, n, width)
+		}
+	}
+	if !strings.Contains(got, "— Seneca") || !strings.Contains(got, "On the Shortness of Life") {
+		t.Errorf("attribution missing:\n%s", got)
+	}
+	if strings.Contains(got, "\x1b[") {
+		t.Errorf("uncolored output contains escape sequences:\n%s", got)
+	}
+}
+
+func TestTerminal_Color(t *testing.T) {
+	got := renderTerminal(t, TermOptions{Width: 40, Frame: FrameBox, Color: true})
+	if !strings.Contains(got, ansiBold) || !strings.Contains(got, ansiCyan+"  — Seneca") {
+		t.Errorf("expected ANSI styling:\n%q", got)
+	}
+
+	// Escape sequences must not count towards the frame padding
+	plain := renderTerminal(t, TermOptions{Width: 40, Frame: FrameBox})
+	stripped := strings.NewReplacer(ansiReset, "", ansiBold, "", ansiItalic, "", ansiCyan, "", ansiDim, "").Replace(got)
+	if stripped != plain {
+		t.Errorf("colored output differs from plain once escapes are removed:\n%s\n%s", stripped, plain)
+	}
+}
+
+func TestTerminal_StripsControlCharacters(t *testing.T) {
+	quote := testQuote()
+	quote.Text = "Clipboard\x1b]52;c;ZWNobyBoaQ==\x07 and screen\x1b[2J\u009b31m\tcleared"
+	quote.Author = "Sen\x1b[8meca"
+	quote.Source = "Letters\r\x00"
+
+	for _, opts := range []TermOptions{{Width: 60}, {Width: 60, Frame: FrameBox, Color: true}, {Width: 60, Frame: FrameCow}} {
+		var buf bytes.Buffer
+		if err := Terminal(&buf, quote, opts); err != nil {
+			t.Fatalf("Terminal() error = %v", err)
+		}
+		got := strings.NewReplacer(ansiReset, "", ansiBold, "", ansiItalic, "", ansiCyan, "", ansiDim, "").Replace(buf.String())
+		for _, r := range got {
+			if r != '\n' && unicode.IsControl(r) {
+				t.Errorf("Terminal(%+v) output contains control character %U:\n%q", opts, r, got)
+				break
+			}
+		}
+		if !strings.Contains(got, "]52;c;ZWNobyBoaQ== and screen[2J31m cleared") || !strings.Contains(got, "Sen[8meca") {
+			t.Errorf("Terminal(%+v) = %q, want the text with only its control characters removed", opts, got)
+		}
+	}
+}
+
+func TestTerminal_Cow(t *testing.T) {
+	got := renderTerminal(t, TermOptions{Width: 40, Frame: FrameCow})
+	if !strings.Contains(got, "(oo)") {
+		t.Errorf("cow missing:\n%s", got)
+	}
+	lines := strings.Split(got, "\n")
+	if !strings.HasPrefix(lines[1], "/ ") || !strings.HasSuffix(lines[1], " \\") {
+		t.Errorf("balloon first line = %q", lines[1])
+	}
+}
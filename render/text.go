@@ -0,0 +1,110 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"quote-vault/models"
+)
+
+// minWidth keeps wrapping readable when a tiny width is requested
+const minWidth = 20
+
+// Wrap breaks text into lines of at most width runes, splitting on spaces
+// and keeping existing line breaks. Words longer than the width are left
+// whole on their own line. Control characters other than line breaks are
+// removed first, so stored text cannot send escape sequences to a
+// terminal.
+func Wrap(text string, width int) []string {
+	if width < minWidth {
+		width = minWidth
+	}
+	text = stripControl(text)
+
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+
+		line := words[0]
+		for _, word := range words[1:] {
+			if utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+				lines = append(lines, line)
+				line = word
+				continue
+			}
+			line += " " + word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// stripControl removes the C0 and C1 control characters from text, apart
+// from line breaks. Tabs become spaces so the words they separate stay
+// apart.
+func stripControl(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return r
+		case r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// textLines lays out a quote as wrapped text followed by a right-leaning
+// attribution line
+func textLines(quote *models.Quote, width int) []string {
+	lines := Wrap("“"+strings.TrimSpace(quote.Text)+"”", width)
+	for _, line := range Wrap("— "+attribution(quote), width-4) {
+		lines = append(lines, "    "+line)
+	}
+	return lines
+}
+
+func textQuote(w io.Writer, quote *models.Quote, opts Options) error {
+	bw := bufio.NewWriter(w)
+	for _, line := range textLines(quote, widthOrDefault(opts.Width)) {
+		bw.WriteString(line + "\n")
+	}
+	return bw.Flush()
+}
+
+func textQuotes(w io.Writer, list *List, opts Options) error {
+	bw := bufio.NewWriter(w)
+	for i, quote := range list.Quotes {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		for _, line := range textLines(quote, widthOrDefault(opts.Width)) {
+			bw.WriteString(line + "\n")
+		}
+	}
+	if list.Limit > 0 {
+		pages := (list.Total + list.Limit - 1) / list.Limit
+		if pages == 0 {
+			pages = 1
+		}
+		fmt.Fprintf(bw, "\n[page %d of %d, %d quotes]\n", list.Page, pages, list.Total)
+	}
+	return bw.Flush()
+}
+
+func widthOrDefault(width int) int {
+	if width <= 0 {
+		return DefaultWidth
+	}
+	return width
+}
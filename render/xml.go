@@ -0,0 +1,70 @@
+package render
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"quote-vault/models"
+)
+
+type xmlQuoteDoc struct {
+	XMLName   xml.Name `xml:"quote"`
+	ID        int      `xml:"id,attr"`
+	Text      string   `xml:"text"`
+	Author    string   `xml:"author"`
+	Category  string   `xml:"category"`
+	Source    string   `xml:"source,omitempty"`
+	Tags      []string `xml:"tags>tag,omitempty"`
+	CreatedAt string   `xml:"created_at"`
+}
+
+type xmlListDoc struct {
+	XMLName xml.Name      `xml:"quotes"`
+	Total   int           `xml:"total,attr"`
+	Page    int           `xml:"page,attr"`
+	Limit   int           `xml:"limit,attr"`
+	Quotes  []xmlQuoteDoc `xml:"quote"`
+}
+
+func toXMLQuote(quote *models.Quote) xmlQuoteDoc {
+	return xmlQuoteDoc{
+		ID:        quote.ID,
+		Text:      quote.Text,
+		Author:    quote.Author,
+		Category:  quote.Category,
+		Source:    quote.Source,
+		Tags:      quote.Tags,
+		CreatedAt: quote.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func xmlQuote(w io.Writer, quote *models.Quote) error {
+	return writeXML(w, toXMLQuote(quote))
+}
+
+func xmlQuotes(w io.Writer, list *List) error {
+	doc := xmlListDoc{
+		Total:  list.Total,
+		Page:   list.Page,
+		Limit:  list.Limit,
+		Quotes: make([]xmlQuoteDoc, 0, len(list.Quotes)),
+	}
+	for _, quote := range list.Quotes {
+		doc.Quotes = append(doc.Quotes, toXMLQuote(quote))
+	}
+	return writeXML(w, doc)
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+// activityPubKeyName is the metadata key holding the private key that
+// signs requests from the vault's actors
+const activityPubKeyName = "activitypub.private_key"
+
+// followerColumns lists the columns read by scanFollower, in scan order
+const followerColumns = `id, actor, follower, inbox, created_at`
+
+// activityDeliveryColumns lists the columns read by scanActivityDelivery,
+// in scan order
+const activityDeliveryColumns = `id, actor, inbox, activity_id, payload, attempts, last_error, next_attempt_at, created_at`
+
+// scanFollower reads a row selected with followerColumns into follower
+func scanFollower(row rowScanner, follower *models.Follower) error {
+	return row.Scan(
+		&follower.ID,
+		&follower.Actor,
+		&follower.Follower,
+		&follower.Inbox,
+		&follower.CreatedAt,
+	)
+}
+
+// scanActivityDelivery reads a row selected with activityDeliveryColumns
+// into delivery
+func scanActivityDelivery(row rowScanner, delivery *models.ActivityDelivery) error {
+	var payload string
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.Actor,
+		&delivery.Inbox,
+		&delivery.ActivityID,
+		&payload,
+		&delivery.Attempts,
+		&delivery.LastError,
+		&delivery.NextAttemptAt,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	delivery.Payload = []byte(payload)
+	delivery.NextAttemptAt = delivery.NextAttemptAt.UTC()
+	return nil
+}
+
+// ActivityPubRepository handles database operations for ActivityPub
+// followers and the queue of activities sent to them
+type ActivityPubRepository struct {
+	db *sql.DB
+}
+
+// NewActivityPubRepository creates a new ActivityPub repository
+func NewActivityPubRepository(db *sql.DB) *ActivityPubRepository {
+	return &ActivityPubRepository{
+		db: db,
+	}
+}
+
+// PrivateKey returns the stored signing key in PEM form, reporting false
+// when none has been saved yet
+func (r *ActivityPubRepository) PrivateKey() (string, bool, error) {
+	key, ok, err := getMetadata(r.db, activityPubKeyName)
+	if err != nil {
+		return "", false, errors.NewDatabaseError("failed to get ActivityPub key")
+	}
+	return key, ok, nil
+}
+
+// SavePrivateKey stores the signing key in PEM form
+func (r *ActivityPubRepository) SavePrivateKey(key string) error {
+	if err := setMetadata(r.db, activityPubKeyName, key); err != nil {
+		return errors.NewDatabaseError("failed to save ActivityPub key")
+	}
+	return nil
+}
+
+// AddFollower records a follower of an actor. Following again updates the
+// inbox of an existing follower.
+func (r *ActivityPubRepository) AddFollower(follower *models.Follower) error {
+	query := `INSERT INTO ap_followers (actor, follower, inbox, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (actor, follower) DO UPDATE SET inbox = excluded.inbox`
+
+	now := createdNow()
+	_, err := r.db.Exec(query, follower.Actor, follower.Follower, follower.Inbox, now.Format(sqliteTimeFormat))
+	if err != nil {
+		return errors.NewDatabaseError("failed to add follower")
+	}
+	follower.CreatedAt = now
+
+	return nil
+}
+
+// RemoveFollower removes a follower of an actor and reports whether it was
+// following
+func (r *ActivityPubRepository) RemoveFollower(actor, follower string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM ap_followers WHERE actor = ? AND follower = ?`, actor, follower)
+	if err != nil {
+		return false, errors.NewDatabaseError("failed to remove follower")
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.NewDatabaseError("failed to remove follower")
+	}
+
+	return removed > 0, nil
+}
+
+// GetFollowers retrieves the followers of an actor, oldest first
+func (r *ActivityPubRepository) GetFollowers(actor string) ([]*models.Follower, error) {
+	rows, err := r.db.Query(`SELECT `+followerColumns+` FROM ap_followers WHERE actor = ? ORDER BY id`, actor)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get followers")
+	}
+	defer rows.Close()
+
+	var followers []*models.Follower
+	for rows.Next() {
+		follower := &models.Follower{}
+		if err := scanFollower(rows, follower); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan follower")
+		}
+		followers = append(followers, follower)
+	}
+
+	return followers, nil
+}
+
+// CountFollowers returns the number of followers of an actor
+func (r *ActivityPubRepository) CountFollowers(actor string) (int, error) {
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM ap_followers WHERE actor = ?`, actor).Scan(&count); err != nil {
+		return 0, errors.NewDatabaseError("failed to count followers")
+	}
+
+	return count, nil
+}
+
+// QueueDelivery queues an activity for an inbox, due at its NextAttemptAt,
+// and reports whether it was queued. An activity already queued for the
+// inbox is left alone.
+func (r *ActivityPubRepository) QueueDelivery(delivery *models.ActivityDelivery) (bool, error) {
+	query := `INSERT OR IGNORE INTO ap_deliveries (actor, inbox, activity_id, payload, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	now := createdNow()
+	result, err := r.db.Exec(query, delivery.Actor, delivery.Inbox, delivery.ActivityID, string(delivery.Payload),
+		delivery.NextAttemptAt.UTC().Format(queueTimeFormat), now.Format(sqliteTimeFormat))
+	if err != nil {
+		return false, errors.NewDatabaseError("failed to queue activity")
+	}
+	queued, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.NewDatabaseError("failed to queue activity")
+	}
+	if queued == 0 {
+		return false, nil
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, errors.NewDatabaseError("failed to get last insert id")
+	}
+	delivery.ID = int(id)
+	delivery.CreatedAt = now
+
+	return true, nil
+}
+
+// GetDueDeliveries retrieves queued activities whose next attempt is due
+// at now, the longest waiting first
+func (r *ActivityPubRepository) GetDueDeliveries(now time.Time, limit int) ([]*models.ActivityDelivery, error) {
+	query := `SELECT ` + activityDeliveryColumns + ` FROM ap_deliveries
+		WHERE next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`
+
+	rows, err := r.db.Query(query, now.UTC().Format(queueTimeFormat), limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get queued activities")
+	}
+	defer rows.Close()
+
+	var deliveries []*models.ActivityDelivery
+	for rows.Next() {
+		delivery := &models.ActivityDelivery{}
+		if err := scanActivityDelivery(rows, delivery); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan queued activity")
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// NextDueAt returns when the earliest queued activity is due, or nil when
+// the queue is empty
+func (r *ActivityPubRepository) NextDueAt() (*time.Time, error) {
+	var next time.Time
+	err := r.db.QueryRow(`SELECT next_attempt_at FROM ap_deliveries ORDER BY next_attempt_at LIMIT 1`).Scan(&next)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get next queued activity")
+	}
+
+	next = next.UTC()
+	return &next, nil
+}
+
+// RescheduleDelivery saves a failed attempt at a delivery and when to try
+// again
+func (r *ActivityPubRepository) RescheduleDelivery(delivery *models.ActivityDelivery) error {
+	_, err := r.db.Exec(`UPDATE ap_deliveries SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?`,
+		delivery.Attempts, delivery.LastError, delivery.NextAttemptAt.UTC().Format(queueTimeFormat), delivery.ID)
+	if err != nil {
+		return errors.NewDatabaseError("failed to reschedule activity")
+	}
+
+	return nil
+}
+
+// DeleteDelivery removes an activity from the queue once it was delivered
+// or gave up on
+func (r *ActivityPubRepository) DeleteDelivery(id int) error {
+	if _, err := r.db.Exec(`DELETE FROM ap_deliveries WHERE id = ?`, id); err != nil {
+		return errors.NewDatabaseError("failed to delete queued activity")
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func setupActivityPubDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		CREATE TABLE ap_followers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor TEXT NOT NULL,
+			follower TEXT NOT NULL,
+			inbox TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (actor, follower)
+		);
+		CREATE TABLE ap_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor TEXT NOT NULL,
+			inbox TEXT NOT NULL,
+			activity_id TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (inbox, activity_id)
+		)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	return db
+}
+
+func TestActivityPubRepository_Followers(t *testing.T) {
+	db := setupActivityPubDB(t)
+	defer db.Close()
+
+	repo := NewActivityPubRepository(db)
+	if _, ok, err := repo.PrivateKey(); err != nil || ok {
+		t.Fatalf("PrivateKey() before saving = %v, %v", ok, err)
+	}
+	if err := repo.SavePrivateKey("pem"); err != nil {
+		t.Fatalf("SavePrivateKey() error = %v", err)
+	}
+	if key, ok, err := repo.PrivateKey(); err != nil || !ok || key != "pem" {
+		t.Errorf("PrivateKey() = %q, %v, %v", key, ok, err)
+	}
+
+	for _, f := range []*models.Follower{
+		{Actor: "quotes", Follower: "https://a.example/ann", Inbox: "https://a.example/ann/inbox"},
+		{Actor: "quotes", Follower: "https://b.example/bob", Inbox: "https://b.example/inbox"},
+		{Actor: "wisdom", Follower: "https://a.example/ann", Inbox: "https://a.example/ann/inbox"},
+		// Following again moves the follower to its new inbox
+		{Actor: "quotes", Follower: "https://a.example/ann", Inbox: "https://a.example/inbox"},
+	} {
+		if err := repo.AddFollower(f); err != nil {
+			t.Fatalf("AddFollower() error = %v", err)
+		}
+	}
+
+	followers, err := repo.GetFollowers("quotes")
+	if err != nil {
+		t.Fatalf("GetFollowers() error = %v", err)
+	}
+	if len(followers) != 2 || followers[0].Follower != "https://a.example/ann" || followers[0].Inbox != "https://a.example/inbox" {
+		t.Fatalf("GetFollowers() = %+v", followers)
+	}
+	if count, err := repo.CountFollowers("wisdom"); err != nil || count != 1 {
+		t.Errorf("CountFollowers(wisdom) = %d, %v", count, err)
+	}
+
+	if removed, err := repo.RemoveFollower("quotes", "https://a.example/ann"); err != nil || !removed {
+		t.Errorf("RemoveFollower() = %v, %v", removed, err)
+	}
+	if removed, err := repo.RemoveFollower("quotes", "https://a.example/ann"); err != nil || removed {
+		t.Errorf("RemoveFollower() of a removed follower = %v, %v", removed, err)
+	}
+	if count, err := repo.CountFollowers("quotes"); err != nil || count != 1 {
+		t.Errorf("CountFollowers(quotes) after removal = %d, %v", count, err)
+	}
+}
+
+func TestActivityPubRepository_Deliveries(t *testing.T) {
+	db := setupActivityPubDB(t)
+	defer db.Close()
+
+	repo := NewActivityPubRepository(db)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(1500 * time.Millisecond)
+
+	var ids []int
+	for i, due := range []time.Time{later, now, now.Add(time.Hour)} {
+		delivery := &models.ActivityDelivery{
+			Actor:         "quotes",
+			Inbox:         "https://a.example/inbox",
+			ActivityID:    "https://q.example/activities/" + string(rune('a'+i)),
+			Payload:       []byte(`{"type":"Create"}`),
+			NextAttemptAt: due,
+		}
+		queued, err := repo.QueueDelivery(delivery)
+		if err != nil || !queued {
+			t.Fatalf("QueueDelivery() = %v, %v", queued, err)
+		}
+		ids = append(ids, delivery.ID)
+	}
+
+	// The same activity is queued once per inbox
+	queued, err := repo.QueueDelivery(&models.ActivityDelivery{
+		Actor: "quotes", Inbox: "https://a.example/inbox", ActivityID: "https://q.example/activities/a",
+		Payload: []byte(`{}`), NextAttemptAt: now,
+	})
+	if err != nil || queued {
+		t.Errorf("QueueDelivery() of a queued activity = %v, %v", queued, err)
+	}
+
+	next, err := repo.NextDueAt()
+	if err != nil || next == nil || !next.Equal(now) {
+		t.Errorf("NextDueAt() = %v, %v, want %v", next, err, now)
+	}
+
+	due, err := repo.GetDueDeliveries(later, 10)
+	if err != nil {
+		t.Fatalf("GetDueDeliveries() error = %v", err)
+	}
+	if len(due) != 2 || due[0].ID != ids[1] || due[1].ID != ids[0] {
+		t.Fatalf("GetDueDeliveries() = %+v, want deliveries %d and %d", due, ids[1], ids[0])
+	}
+	if string(due[0].Payload) != `{"type":"Create"}` || due[0].Actor != "quotes" {
+		t.Errorf("GetDueDeliveries() returned %+v", due[0])
+	}
+
+	retry := due[0]
+	retry.Attempts = 1
+	retry.LastError = "connection refused"
+	retry.NextAttemptAt = now.Add(time.Minute)
+	if err := repo.RescheduleDelivery(retry); err != nil {
+		t.Fatalf("RescheduleDelivery() error = %v", err)
+	}
+	if err := repo.DeleteDelivery(ids[0]); err != nil {
+		t.Fatalf("DeleteDelivery() error = %v", err)
+	}
+
+	due, err = repo.GetDueDeliveries(now.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("GetDueDeliveries() error = %v", err)
+	}
+	if len(due) != 1 || due[0].ID != ids[1] || due[0].Attempts != 1 || due[0].LastError != "connection refused" {
+		t.Errorf("GetDueDeliveries() after retry = %+v", due)
+	}
+}
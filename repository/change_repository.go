@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+// prunedThroughKey is the metadata key holding the highest sequence number
+// of a pruned tombstone
+const prunedThroughKey = "changes.pruned_through"
+
+// ChangeRepository reads the quote change log maintained by triggers on
+// the quotes table
+type ChangeRepository struct {
+	db *sql.DB
+}
+
+// NewChangeRepository creates a new change repository
+func NewChangeRepository(db *sql.DB) *ChangeRepository {
+	return &ChangeRepository{
+		db: db,
+	}
+}
+
+// Since retrieves up to limit changes following the given sequence number,
+// in order, with the current quote for upserts
+func (r *ChangeRepository) Since(seq int64, limit int) ([]*models.Change, error) {
+	query := `SELECT c.seq, c.quote_id, c.deleted, c.version, c.changed_at,
+			q.id, q.text, q.author, q.category, q.source, q.tags, q.likes, q.version, q.created_at
+		FROM quote_changes c LEFT JOIN quotes q ON q.id = c.quote_id
+		WHERE c.seq > ? ORDER BY c.seq LIMIT ?`
+
+	rows, err := r.db.Query(query, seq, limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get changes")
+	}
+	defer rows.Close()
+
+	var changes []*models.Change
+	for rows.Next() {
+		change := &models.Change{}
+		var deleted bool
+		var deletedVersion int
+		var id, likes, version sql.NullInt64
+		var text, author, category, source sql.NullString
+		var tags models.Tags
+		var createdAt sql.NullTime
+		err := rows.Scan(&change.Sequence, &change.ID, &deleted, &deletedVersion, &change.ChangedAt,
+			&id, &text, &author, &category, &source, &tags, &likes, &version, &createdAt)
+		if err != nil {
+			return nil, errors.NewDatabaseError("failed to scan change")
+		}
+
+		change.Type = models.ChangeUpsert
+		if deleted || !id.Valid {
+			change.Type = models.ChangeDelete
+			change.Version = deletedVersion
+		} else {
+			change.Quote = &models.Quote{
+				ID:        int(id.Int64),
+				Text:      text.String,
+				Author:    author.String,
+				Category:  category.String,
+				Source:    source.String,
+				Tags:      tags,
+				Likes:     int(likes.Int64),
+				Version:   int(version.Int64),
+				CreatedAt: createdAt.Time,
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// Latest returns the sequence number of the newest change, 0 when nothing
+// has changed yet
+func (r *ChangeRepository) Latest() (int64, error) {
+	var seq int64
+	// Deleted rows leave their numbers behind in sqlite_sequence, which
+	// keeps counting for AUTOINCREMENT tables
+	query := `SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'quote_changes'), 0)`
+	if err := r.db.QueryRow(query).Scan(&seq); err != nil {
+		return 0, errors.NewDatabaseError("failed to get latest change")
+	}
+
+	return seq, nil
+}
+
+// PrunedThrough returns the highest sequence number of a pruned tombstone.
+// Clients that last synced before it may have missed a deletion.
+func (r *ChangeRepository) PrunedThrough() (int64, error) {
+	value, ok, err := getMetadata(r.db, prunedThroughKey)
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to get change log state")
+	}
+	if !ok {
+		return 0, nil
+	}
+
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.NewDatabaseError("invalid change log state")
+	}
+	return seq, nil
+}
+
+// PruneTombstones removes the tombstones of quotes deleted before t and
+// raises PrunedThrough to cover them
+func (r *ChangeRepository) PruneTombstones(before time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errors.NewDatabaseError("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	cutoff := before.UTC().Format(queueTimeFormat)
+	var highest sql.NullInt64
+	err = tx.QueryRow(`SELECT MAX(seq) FROM quote_changes WHERE deleted = 1 AND changed_at < ?`, cutoff).Scan(&highest)
+	if err != nil {
+		return errors.NewDatabaseError("failed to find expired tombstones")
+	}
+	if !highest.Valid {
+		return nil
+	}
+
+	if _, err := tx.Exec(`DELETE FROM quote_changes WHERE deleted = 1 AND seq <= ?`, highest.Int64); err != nil {
+		return errors.NewDatabaseError("failed to prune tombstones")
+	}
+	query := `INSERT INTO metadata (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = MAX(CAST(value AS INTEGER), CAST(excluded.value AS INTEGER))`
+	if _, err := tx.Exec(query, prunedThroughKey, strconv.FormatInt(highest.Int64, 10)); err != nil {
+		return errors.NewDatabaseError("failed to save change log state")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.NewDatabaseError("failed to commit transaction")
+	}
+
+	return nil
+}
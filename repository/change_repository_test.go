@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func setupChangeDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE quotes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			text TEXT NOT NULL,
+			author TEXT NOT NULL,
+			category TEXT NOT NULL,
+			source TEXT NOT NULL DEFAULT '',
+			tags TEXT NOT NULL DEFAULT '',
+			likes INTEGER NOT NULL DEFAULT 0,
+			version INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE quote_changes (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			quote_id INTEGER NOT NULL UNIQUE,
+			deleted INTEGER NOT NULL DEFAULT 0,
+			version INTEGER NOT NULL DEFAULT 0,
+			changed_at DATETIME
+		);
+		CREATE TRIGGER quotes_log_insert AFTER INSERT ON quotes BEGIN
+			INSERT OR REPLACE INTO quote_changes (quote_id, deleted, changed_at)
+			VALUES (NEW.id, 0, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+		END;
+		CREATE TRIGGER quotes_log_update AFTER UPDATE ON quotes BEGIN
+			INSERT OR REPLACE INTO quote_changes (quote_id, deleted, changed_at)
+			VALUES (NEW.id, 0, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+		END;
+		CREATE TRIGGER quotes_log_delete AFTER DELETE ON quotes BEGIN
+			INSERT OR REPLACE INTO quote_changes (quote_id, deleted, version, changed_at)
+			VALUES (OLD.id, 1, OLD.version, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+		END;
+		CREATE TABLE metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		CREATE TABLE outbox (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			created_at DATETIME
+		)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	return db
+}
+
+func TestChangeRepository(t *testing.T) {
+	db := setupChangeDB(t)
+	defer db.Close()
+
+	quotes := NewQuoteRepository(db)
+	repo := NewChangeRepository(db)
+
+	if latest, err := repo.Latest(); err != nil || latest != 0 {
+		t.Errorf("Latest() of an empty log = %d, %v, want 0", latest, err)
+	}
+	if changed, err := quotes.LastChanged(); err != nil || !changed.IsZero() {
+		t.Errorf("LastChanged() of an empty log = %v, %v, want the zero time", changed, err)
+	}
+
+	var ids []int
+	for _, text := range []string{"First", "Second", "Third"} {
+		quote, err := quotes.Create(&models.Quote{Text: text, Author: "Author", Category: "test"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		ids = append(ids, quote.ID)
+	}
+
+	// Editing the first quote and deleting the second moves both to the end
+	first, _ := quotes.GetByID(ids[0])
+	first.Text = "First, edited"
+	if _, err := quotes.Update(first); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := quotes.Delete(ids[1]); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	changes, err := repo.Since(0, 10)
+	if err != nil {
+		t.Fatalf("Since() error = %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("Since() returned %d changes, want 3", len(changes))
+	}
+	wantIDs := []int{ids[2], ids[0], ids[1]}
+	wantTypes := []string{models.ChangeUpsert, models.ChangeUpsert, models.ChangeDelete}
+	for i, change := range changes {
+		if change.ID != wantIDs[i] || change.Type != wantTypes[i] {
+			t.Errorf("change %d = %s of %d, want %s of %d", i, change.Type, change.ID, wantTypes[i], wantIDs[i])
+		}
+		if i > 0 && change.Sequence <= changes[i-1].Sequence {
+			t.Errorf("change %d sequence %d is not after %d", i, change.Sequence, changes[i-1].Sequence)
+		}
+		if change.ChangedAt.IsZero() {
+			t.Errorf("change %d has no ChangedAt", i)
+		}
+	}
+	if q := changes[1].Quote; q == nil || q.Text != "First, edited" || q.Version != 2 {
+		t.Errorf("upsert quote = %+v, want the edited quote", q)
+	}
+	if changes[2].Quote != nil || changes[2].Version != 1 {
+		t.Errorf("tombstone = %+v, want no quote and version 1", changes[2])
+	}
+
+	if changed, err := quotes.LastChanged(); err != nil || !changed.Equal(changes[2].ChangedAt) {
+		t.Errorf("LastChanged() = %v, %v, want the time of the deletion, %v", changed, err, changes[2].ChangedAt)
+	}
+
+	latest, err := repo.Latest()
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if latest != changes[2].Sequence {
+		t.Errorf("Latest() = %d, want %d", latest, changes[2].Sequence)
+	}
+	if rest, err := repo.Since(changes[0].Sequence, 1); err != nil || len(rest) != 1 || rest[0].ID != ids[0] {
+		t.Errorf("Since(%d, 1) = %v, %v, want the edited quote", changes[0].Sequence, rest, err)
+	}
+
+	// Pruning before the deletion keeps the tombstone
+	if err := repo.PruneTombstones(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("PruneTombstones() error = %v", err)
+	}
+	if pruned, err := repo.PrunedThrough(); err != nil || pruned != 0 {
+		t.Errorf("PrunedThrough() = %d, %v, want 0", pruned, err)
+	}
+
+	if err := repo.PruneTombstones(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PruneTombstones() error = %v", err)
+	}
+	if pruned, err := repo.PrunedThrough(); err != nil || pruned != latest {
+		t.Errorf("PrunedThrough() = %d, %v, want %d", pruned, err, latest)
+	}
+	changes, err = repo.Since(0, 10)
+	if err != nil {
+		t.Fatalf("Since() error = %v", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("Since() after pruning returned %d changes, want the 2 upserts", len(changes))
+	}
+	if after, err := repo.Latest(); err != nil || after != latest {
+		t.Errorf("Latest() after pruning = %d, %v, want %d", after, err, latest)
+	}
+}
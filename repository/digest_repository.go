@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+// subscriptionColumns lists the columns read by scanSubscription, in scan
+// order
+const subscriptionColumns = `id, email, frequency, categories, confirm_token, unsubscribe_token,
+	confirmed_at, last_sent_at, next_digest_at, created_at`
+
+// scanSubscription reads a row selected with subscriptionColumns into sub
+func scanSubscription(row rowScanner, sub *models.DigestSubscription) error {
+	var categories string
+	var confirmed, lastSent, next sql.NullTime
+	err := row.Scan(
+		&sub.ID,
+		&sub.Email,
+		&sub.Frequency,
+		&categories,
+		&sub.ConfirmToken,
+		&sub.UnsubscribeToken,
+		&confirmed,
+		&lastSent,
+		&next,
+		&sub.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	sub.Categories = nil
+	if categories != "" {
+		sub.Categories = strings.Split(categories, ",")
+	}
+	sub.ConfirmedAt = timePtr(confirmed)
+	sub.LastSentAt = timePtr(lastSent)
+	sub.NextDigestAt = timePtr(next)
+	return nil
+}
+
+// DigestRepository handles database operations for email digest
+// subscriptions
+type DigestRepository struct {
+	db *sql.DB
+}
+
+// NewDigestRepository creates a new digest repository
+func NewDigestRepository(db *sql.DB) *DigestRepository {
+	return &DigestRepository{
+		db: db,
+	}
+}
+
+// Create adds an unconfirmed subscription
+func (r *DigestRepository) Create(sub *models.DigestSubscription) (*models.DigestSubscription, error) {
+	query := `INSERT INTO digest_subscriptions (email, frequency, categories, confirm_token, unsubscribe_token, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+
+	now := createdNow()
+	result, err := r.db.Exec(query, sub.Email, sub.Frequency, strings.Join(sub.Categories, ","),
+		sub.ConfirmToken, sub.UnsubscribeToken, now.Format(sqliteTimeFormat))
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to create subscription")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get last insert id")
+	}
+	sub.ID = int(id)
+	sub.CreatedAt = now
+
+	return sub, nil
+}
+
+// UpdatePending replaces the settings and confirmation token of an
+// unconfirmed subscription, restarting the time it has to be confirmed in.
+// Confirmed subscriptions are left alone.
+func (r *DigestRepository) UpdatePending(sub *models.DigestSubscription) error {
+	query := `UPDATE digest_subscriptions SET frequency = ?, categories = ?, confirm_token = ?, created_at = ?
+		WHERE id = ? AND confirmed_at IS NULL`
+
+	now := createdNow()
+	result, err := r.db.Exec(query, sub.Frequency, strings.Join(sub.Categories, ","), sub.ConfirmToken,
+		now.Format(sqliteTimeFormat), sub.ID)
+	if err != nil {
+		return errors.NewDatabaseError("failed to update subscription")
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return errors.NewDatabaseError("failed to update subscription")
+	}
+	if updated == 0 {
+		return errors.ErrSubscriptionNotFound
+	}
+	sub.CreatedAt = now
+
+	return nil
+}
+
+// GetByEmail retrieves the subscription of an address, ignoring case
+func (r *DigestRepository) GetByEmail(email string) (*models.DigestSubscription, error) {
+	return r.getBy("email", email)
+}
+
+// GetByConfirmToken retrieves the subscription a confirmation link is for
+func (r *DigestRepository) GetByConfirmToken(token string) (*models.DigestSubscription, error) {
+	return r.getBy("confirm_token", token)
+}
+
+// GetByUnsubscribeToken retrieves the subscription an unsubscribe link is
+// for
+func (r *DigestRepository) GetByUnsubscribeToken(token string) (*models.DigestSubscription, error) {
+	return r.getBy("unsubscribe_token", token)
+}
+
+// getBy retrieves the subscription whose column holds value
+func (r *DigestRepository) getBy(column, value string) (*models.DigestSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM digest_subscriptions WHERE ` + column + ` = ?`
+
+	sub := &models.DigestSubscription{}
+	if err := scanSubscription(r.db.QueryRow(query, value), sub); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrSubscriptionNotFound
+		}
+		return nil, errors.NewDatabaseError("failed to get subscription")
+	}
+
+	return sub, nil
+}
+
+// Confirm marks a subscription confirmed at sub.ConfirmedAt, with its first
+// digest due at sub.NextDigestAt
+func (r *DigestRepository) Confirm(sub *models.DigestSubscription) error {
+	_, err := r.db.Exec(`UPDATE digest_subscriptions SET confirmed_at = ?, next_digest_at = ? WHERE id = ?`,
+		queueTime(sub.ConfirmedAt), queueTime(sub.NextDigestAt), sub.ID)
+	if err != nil {
+		return errors.NewDatabaseError("failed to confirm subscription")
+	}
+
+	return nil
+}
+
+// GetDue retrieves confirmed subscriptions whose next digest is due at
+// now, the longest waiting first
+func (r *DigestRepository) GetDue(now time.Time, limit int) ([]*models.DigestSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM digest_subscriptions
+		WHERE confirmed_at IS NOT NULL AND next_digest_at <= ? ORDER BY next_digest_at, id LIMIT ?`
+
+	rows, err := r.db.Query(query, now.UTC().Format(queueTimeFormat), limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get due subscriptions")
+	}
+	defer rows.Close()
+
+	var subs []*models.DigestSubscription
+	for rows.Next() {
+		sub := &models.DigestSubscription{}
+		if err := scanSubscription(rows, sub); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan subscription")
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+// MarkSent records that a digest was sent at sentAt and when the next one
+// is due
+func (r *DigestRepository) MarkSent(id int, sentAt, next time.Time) error {
+	_, err := r.db.Exec(`UPDATE digest_subscriptions SET last_sent_at = ?, next_digest_at = ? WHERE id = ?`,
+		queueTime(&sentAt), queueTime(&next), id)
+	if err != nil {
+		return errors.NewDatabaseError("failed to update subscription")
+	}
+
+	return nil
+}
+
+// Reschedule moves the next digest of a subscription without recording a
+// send, so the next digest still covers everything since the last one
+func (r *DigestRepository) Reschedule(id int, next time.Time) error {
+	if _, err := r.db.Exec(`UPDATE digest_subscriptions SET next_digest_at = ? WHERE id = ?`, queueTime(&next), id); err != nil {
+		return errors.NewDatabaseError("failed to update subscription")
+	}
+
+	return nil
+}
+
+// Delete removes a subscription
+func (r *DigestRepository) Delete(id int) error {
+	if _, err := r.db.Exec(`DELETE FROM digest_subscriptions WHERE id = ?`, id); err != nil {
+		return errors.NewDatabaseError("failed to delete subscription")
+	}
+
+	return nil
+}
+
+// DeleteUnconfirmed removes subscriptions created before the given time
+// that were never confirmed, and returns how many there were
+func (r *DigestRepository) DeleteUnconfirmed(before time.Time) (int, error) {
+	result, err := r.db.Exec(`DELETE FROM digest_subscriptions WHERE confirmed_at IS NULL AND created_at < ?`,
+		before.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to delete unconfirmed subscriptions")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to delete unconfirmed subscriptions")
+	}
+
+	return int(deleted), nil
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+func setupDigestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE digest_subscriptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			email TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			frequency TEXT NOT NULL,
+			categories TEXT NOT NULL DEFAULT '',
+			confirm_token TEXT NOT NULL UNIQUE,
+			unsubscribe_token TEXT NOT NULL UNIQUE,
+			confirmed_at DATETIME,
+			last_sent_at DATETIME,
+			next_digest_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	return db
+}
+
+func TestDigestRepository_Lifecycle(t *testing.T) {
+	db := setupDigestDB(t)
+	defer db.Close()
+
+	repo := NewDigestRepository(db)
+	sub, err := repo.Create(&models.DigestSubscription{
+		Email:            "Ann@Example.com",
+		Frequency:        models.DigestDaily,
+		Categories:       []string{"wisdom", "life"},
+		ConfirmToken:     "confirm",
+		UnsubscribeToken: "unsubscribe",
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := repo.GetByEmail("ann@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail() error = %v", err)
+	}
+	if got.ID != sub.ID || got.Confirmed() || len(got.Categories) != 2 || got.Categories[1] != "life" {
+		t.Errorf("GetByEmail() = %+v", got)
+	}
+	if _, err := repo.Create(&models.DigestSubscription{Email: "ann@example.com", Frequency: models.DigestDaily,
+		ConfirmToken: "other", UnsubscribeToken: "other"}); err == nil {
+		t.Error("Create() with an address differing only in case should fail")
+	}
+
+	// Pending subscriptions can be changed
+	got.Frequency = models.DigestWeekly
+	got.Categories = nil
+	got.ConfirmToken = "confirm-2"
+	if err := repo.UpdatePending(got); err != nil {
+		t.Fatalf("UpdatePending() error = %v", err)
+	}
+	if _, err := repo.GetByConfirmToken("confirm"); err != errors.ErrSubscriptionNotFound {
+		t.Errorf("old confirm token error = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	confirmed := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	next := time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)
+	got.ConfirmedAt = &confirmed
+	got.NextDigestAt = &next
+	if err := repo.Confirm(got); err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	if err := repo.UpdatePending(got); err != errors.ErrSubscriptionNotFound {
+		t.Errorf("UpdatePending() of a confirmed subscription error = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	got, err = repo.GetByConfirmToken("confirm-2")
+	if err != nil {
+		t.Fatalf("GetByConfirmToken() error = %v", err)
+	}
+	if !got.Confirmed() || got.Frequency != models.DigestWeekly || got.Categories != nil || !got.NextDigestAt.Equal(next) {
+		t.Errorf("confirmed subscription = %+v", got)
+	}
+
+	if err := repo.Delete(got.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.GetByUnsubscribeToken("unsubscribe"); err != errors.ErrSubscriptionNotFound {
+		t.Errorf("GetByUnsubscribeToken() after Delete() error = %v, want ErrSubscriptionNotFound", err)
+	}
+}
+
+func TestDigestRepository_Due(t *testing.T) {
+	db := setupDigestDB(t)
+	defer db.Close()
+
+	repo := NewDigestRepository(db)
+	now := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+	create := func(email string, next *time.Time) *models.DigestSubscription {
+		t.Helper()
+		sub, err := repo.Create(&models.DigestSubscription{Email: email, Frequency: models.DigestDaily,
+			ConfirmToken: "c-" + email, UnsubscribeToken: "u-" + email})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if next != nil {
+			confirmed := now.Add(-24 * time.Hour)
+			sub.ConfirmedAt = &confirmed
+			sub.NextDigestAt = next
+			if err := repo.Confirm(sub); err != nil {
+				t.Fatalf("Confirm() error = %v", err)
+			}
+		}
+		return sub
+	}
+	earlier, later := now.Add(-time.Hour), now.Add(time.Hour)
+	due := create("due@example.com", &now)
+	overdue := create("overdue@example.com", &earlier)
+	create("later@example.com", &later)
+	create("pending@example.com", nil)
+
+	subs, err := repo.GetDue(now, 10)
+	if err != nil {
+		t.Fatalf("GetDue() error = %v", err)
+	}
+	if len(subs) != 2 || subs[0].ID != overdue.ID || subs[1].ID != due.ID {
+		t.Fatalf("GetDue() = %d subscriptions, want overdue then due", len(subs))
+	}
+
+	tomorrow := now.Add(24 * time.Hour)
+	if err := repo.MarkSent(due.ID, now, tomorrow); err != nil {
+		t.Fatalf("MarkSent() error = %v", err)
+	}
+	if err := repo.Reschedule(overdue.ID, tomorrow); err != nil {
+		t.Fatalf("Reschedule() error = %v", err)
+	}
+	if subs, _ := repo.GetDue(now, 10); len(subs) != 0 {
+		t.Errorf("GetDue() after sending = %d subscriptions, want 0", len(subs))
+	}
+
+	got, _ := repo.GetByEmail("due@example.com")
+	if got.LastSentAt == nil || !got.LastSentAt.Equal(now) || !got.NextDigestAt.Equal(tomorrow) {
+		t.Errorf("after MarkSent() = %+v", got)
+	}
+	got, _ = repo.GetByEmail("overdue@example.com")
+	if got.LastSentAt != nil {
+		t.Errorf("Reschedule() should not record a send, LastSentAt = %v", got.LastSentAt)
+	}
+
+	deleted, err := repo.DeleteUnconfirmed(time.Now().Add(time.Minute))
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteUnconfirmed() = %d, %v; want 1", deleted, err)
+	}
+}
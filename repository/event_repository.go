@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+// EventRepository handles database operations for the event log
+type EventRepository struct {
+	db *sql.DB
+}
+
+// NewEventRepository creates a new event repository
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{
+		db: db,
+	}
+}
+
+// Append adds an event to the end of the log and sets its sequence number.
+// An event with an ID already in the log is not added again.
+func (r *EventRepository) Append(event *models.LoggedEvent) (*models.LoggedEvent, error) {
+	query := `INSERT INTO events (event_id, type, category, payload, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (event_id) DO NOTHING`
+
+	_, err := r.db.Exec(query, event.ID, event.Type, event.Category, string(event.Payload),
+		event.CreatedAt.UTC().Format(queueTimeFormat))
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to log event")
+	}
+
+	if err := r.db.QueryRow(`SELECT seq FROM events WHERE event_id = ?`, event.ID).Scan(&event.Sequence); err != nil {
+		return nil, errors.NewDatabaseError("failed to get event sequence")
+	}
+
+	return event, nil
+}
+
+// Since retrieves up to limit events following the given sequence number,
+// in order
+func (r *EventRepository) Since(seq int64, limit int) ([]*models.LoggedEvent, error) {
+	query := `SELECT seq, event_id, type, category, payload, created_at FROM events WHERE seq > ? ORDER BY seq LIMIT ?`
+
+	rows, err := r.db.Query(query, seq, limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get events")
+	}
+	defer rows.Close()
+
+	var events []*models.LoggedEvent
+	for rows.Next() {
+		event := &models.LoggedEvent{}
+		var payload string
+		if err := rows.Scan(&event.Sequence, &event.ID, &event.Type, &event.Category, &payload, &event.CreatedAt); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan event")
+		}
+		event.Payload = []byte(payload)
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Bounds returns the sequence numbers of the oldest and newest logged
+// events, both 0 when the log is empty
+func (r *EventRepository) Bounds() (oldest, newest int64, err error) {
+	if err := r.db.QueryRow(`SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM events`).Scan(&oldest, &newest); err != nil {
+		return 0, 0, errors.NewDatabaseError("failed to get event log bounds")
+	}
+
+	return oldest, newest, nil
+}
+
+// DeleteBefore removes the events logged before t and returns how many
+// were removed. The newest event is always kept, so Bounds can still tell
+// how far the log reaches after a quiet period.
+func (r *EventRepository) DeleteBefore(t time.Time) (int64, error) {
+	query := `DELETE FROM events WHERE created_at < ? AND seq < (SELECT MAX(seq) FROM events)`
+
+	result, err := r.db.Exec(query, t.UTC().Format(queueTimeFormat))
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to prune events")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to prune events")
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func setupEventDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE events (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			category TEXT NOT NULL DEFAULT '',
+			payload TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX idx_events_event_id ON events (event_id)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	return db
+}
+
+func TestEventRepository(t *testing.T) {
+	db := setupEventDB(t)
+	defer db.Close()
+
+	repo := NewEventRepository(db)
+
+	if oldest, newest, err := repo.Bounds(); err != nil || oldest != 0 || newest != 0 {
+		t.Errorf("Bounds() of an empty log = %d, %d, %v, want 0, 0", oldest, newest, err)
+	}
+
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		event, err := repo.Append(&models.LoggedEvent{
+			ID:        "evt_" + string(rune('a'+i)),
+			Type:      models.EventQuoteCreated,
+			Category:  "test",
+			Payload:   []byte(`{"type":"quote.created"}`),
+			CreatedAt: start.Add(time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		if event.Sequence != int64(i+1) {
+			t.Errorf("Append() sequence = %d, want %d", event.Sequence, i+1)
+		}
+	}
+
+	// Logging an event again keeps its first place
+	again, err := repo.Append(&models.LoggedEvent{ID: "evt_b", Type: models.EventQuoteCreated, Payload: []byte(`{}`), CreatedAt: start})
+	if err != nil || again.Sequence != 2 {
+		t.Errorf("Append() of a logged event = %+v, %v, want sequence 2", again, err)
+	}
+
+	events, err := repo.Since(1, 10)
+	if err != nil {
+		t.Fatalf("Since() error = %v", err)
+	}
+	if len(events) != 2 || events[0].Sequence != 2 || events[1].ID != "evt_c" || string(events[1].Payload) != `{"type":"quote.created"}` {
+		t.Errorf("Since(1) = %+v, want events 2 and 3", events)
+	}
+	if !events[0].CreatedAt.Equal(start.Add(time.Hour)) || events[0].Category != "test" {
+		t.Errorf("Since(1) first event = %+v", events[0])
+	}
+	if events, _ := repo.Since(0, 1); len(events) != 1 || events[0].Sequence != 1 {
+		t.Errorf("Since(0) with limit 1 = %+v, want event 1", events)
+	}
+
+	// Pruning everything still keeps the newest event
+	deleted, err := repo.DeleteBefore(start.Add(24 * time.Hour))
+	if err != nil || deleted != 2 {
+		t.Errorf("DeleteBefore() = %d, %v, want 2 deleted", deleted, err)
+	}
+	if oldest, newest, err := repo.Bounds(); err != nil || oldest != 3 || newest != 3 {
+		t.Errorf("Bounds() after pruning = %d, %d, %v, want 3, 3", oldest, newest, err)
+	}
+}
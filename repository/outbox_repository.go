@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+// outboxCursorPrefix starts the metadata keys holding how far each
+// subscriber has read the outbox
+const outboxCursorPrefix = "outbox.cursor."
+
+// OutboxRepository records events in the same transaction as the changes
+// they describe, and reads them back for dispatch
+type OutboxRepository struct {
+	db dbtx
+}
+
+// NewOutboxRepository creates a new outbox repository
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db: db,
+	}
+}
+
+// Append adds events to the outbox, in order
+func (r *OutboxRepository) Append(events ...*models.Event) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return errors.NewInternalError("failed to encode event")
+		}
+		_, err = r.db.Exec(`INSERT INTO outbox (event_id, type, payload, created_at) VALUES (?, ?, ?, ?)`,
+			event.ID, event.Type, string(payload), event.CreatedAt.UTC().Format(queueTimeFormat))
+		if err != nil {
+			return errors.NewDatabaseError("failed to record event")
+		}
+	}
+	return nil
+}
+
+// Since retrieves up to limit events following the given sequence number,
+// in order
+func (r *OutboxRepository) Since(seq int64, limit int) ([]*models.OutboxEvent, error) {
+	rows, err := r.db.Query(`SELECT seq, payload FROM outbox WHERE seq > ? ORDER BY seq LIMIT ?`, seq, limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get outbox events")
+	}
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		entry := &models.OutboxEvent{Event: &models.Event{}}
+		var payload string
+		if err := rows.Scan(&entry.Sequence, &payload); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan outbox event")
+		}
+		if err := json.Unmarshal([]byte(payload), entry.Event); err != nil {
+			return nil, errors.NewDatabaseError("invalid outbox event")
+		}
+		events = append(events, entry)
+	}
+
+	return events, nil
+}
+
+// Latest returns the sequence number of the newest event ever recorded,
+// including ones already removed, or 0
+func (r *OutboxRepository) Latest() (int64, error) {
+	var seq int64
+	query := `SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'outbox'), 0)`
+	if err := r.db.QueryRow(query).Scan(&seq); err != nil {
+		return 0, errors.NewDatabaseError("failed to get latest outbox event")
+	}
+
+	return seq, nil
+}
+
+// Cursor returns the sequence number of the last event handled by the
+// named subscriber, reporting false when it has none yet
+func (r *OutboxRepository) Cursor(name string) (int64, bool, error) {
+	value, ok, err := getMetadata(r.db, outboxCursorPrefix+name)
+	if err != nil {
+		return 0, false, errors.NewDatabaseError("failed to get outbox cursor")
+	}
+	if !ok {
+		return 0, false, nil
+	}
+
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, errors.NewDatabaseError("invalid outbox cursor")
+	}
+	return seq, true, nil
+}
+
+// SaveCursor records that the named subscriber has handled the events up
+// to seq
+func (r *OutboxRepository) SaveCursor(name string, seq int64) error {
+	if err := setMetadata(r.db, outboxCursorPrefix+name, strconv.FormatInt(seq, 10)); err != nil {
+		return errors.NewDatabaseError("failed to save outbox cursor")
+	}
+	return nil
+}
+
+// DeleteThrough removes the events up to seq, once every subscriber has
+// handled them, and returns how many were removed
+func (r *OutboxRepository) DeleteThrough(seq int64) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM outbox WHERE seq <= ?`, seq)
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to prune outbox")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to prune outbox")
+	}
+
+	return deleted, nil
+}
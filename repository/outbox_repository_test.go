@@ -0,0 +1,110 @@
+package repository
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"quote-vault/models"
+)
+
+func TestOutboxRepository(t *testing.T) {
+	db := setupChangeDB(t)
+	defer db.Close()
+
+	repo := NewOutboxRepository(db)
+
+	if latest, err := repo.Latest(); err != nil || latest != 0 {
+		t.Errorf("Latest() of an empty outbox = %d, %v, want 0", latest, err)
+	}
+	if _, ok, err := repo.Cursor("webhooks"); err != nil || ok {
+		t.Errorf("Cursor() of a new subscriber = %v, %v, want none", ok, err)
+	}
+
+	first := models.NewEvent(models.EventQuoteCreated, models.EventData{Quote: &models.Quote{ID: 1, Text: "Recorded"}})
+	second := models.NewEvent(models.EventCategoryChanged, models.EventData{Category: "test", Action: models.CategoryAdded})
+	third := models.NewEvent(models.EventQuoteDeleted, models.EventData{Quote: &models.Quote{ID: 1}})
+	if err := repo.Append(first, second, third); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	events, err := repo.Since(1, 10)
+	if err != nil {
+		t.Fatalf("Since() error = %v", err)
+	}
+	if len(events) != 2 || events[0].Sequence != 2 || events[1].Sequence != 3 {
+		t.Fatalf("Since(1) = %+v, want events 2 and 3", events)
+	}
+	if got := events[0].Event; got.ID != second.ID || got.Type != second.Type || got.Data.Category != "test" {
+		t.Errorf("Since(1) first event = %+v, want %+v", got, second)
+	}
+	if events, err := repo.Since(0, 1); err != nil || len(events) != 1 || events[0].Event.Data.Quote.Text != "Recorded" {
+		t.Errorf("Since(0, 1) = %+v, %v, want the first event", events, err)
+	}
+
+	if err := repo.SaveCursor("webhooks", 2); err != nil {
+		t.Fatalf("SaveCursor() error = %v", err)
+	}
+	if cursor, ok, err := repo.Cursor("webhooks"); err != nil || !ok || cursor != 2 {
+		t.Errorf("Cursor() = %d, %v, %v, want 2", cursor, ok, err)
+	}
+
+	// Removing handled events keeps the sequence numbers growing
+	if deleted, err := repo.DeleteThrough(3); err != nil || deleted != 3 {
+		t.Errorf("DeleteThrough() = %d, %v, want 3", deleted, err)
+	}
+	if latest, err := repo.Latest(); err != nil || latest != 3 {
+		t.Errorf("Latest() after pruning = %d, %v, want 3", latest, err)
+	}
+}
+
+func TestQuoteRepository_InTx(t *testing.T) {
+	db := setupChangeDB(t)
+	defer db.Close()
+
+	quotes := NewQuoteRepository(db)
+	outbox := NewOutboxRepository(db)
+
+	// A failure undoes both the change and its events
+	failure := stderrors.New("failed")
+	err := quotes.InTx(func(tx *Tx) error {
+		quote, err := tx.Quotes.Create(&models.Quote{Text: "Never saved", Author: "Author", Category: "test"})
+		if err != nil {
+			return err
+		}
+		if err := tx.Outbox.Append(models.NewEvent(models.EventQuoteCreated, models.EventData{Quote: quote})); err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("InTx() error = %v, want fn's error", err)
+	}
+	if _, total, _ := quotes.GetAll(10, 0); total != 0 {
+		t.Errorf("rolled back transaction left %d quotes", total)
+	}
+	if events, _ := outbox.Since(0, 10); len(events) != 0 {
+		t.Errorf("rolled back transaction left %d events", len(events))
+	}
+
+	// A dry-run batch inside the transaction undoes only its own work
+	err = quotes.InTx(func(tx *Tx) error {
+		quote, err := tx.Quotes.Create(&models.Quote{Text: "Saved", Author: "Author", Category: "test"})
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Quotes.CreateBatch([]*models.Quote{{Text: "Only tried", Author: "Author", Category: "test"}}, true); err != nil {
+			return err
+		}
+		return tx.Outbox.Append(models.NewEvent(models.EventQuoteCreated, models.EventData{Quote: quote}))
+	})
+	if err != nil {
+		t.Fatalf("InTx() error = %v", err)
+	}
+	all, total, _ := quotes.GetAll(10, 0)
+	if total != 1 || all[0].Text != "Saved" {
+		t.Errorf("committed quotes = %v, want the saved one only", all)
+	}
+	if events, _ := outbox.Since(0, 10); len(events) != 1 || events[0].Event.Data.Quote.Text != "Saved" {
+		t.Errorf("committed events = %+v, want the saved quote's", events)
+	}
+}
@@ -2,14 +2,49 @@ package repository
 
 import (
 	"database/sql"
+	"strings"
+	"time"
 
 	"quote-vault/errors"
 	"quote-vault/models"
 )
 
+// quoteColumns lists the columns read by scanQuote, in scan order
+const quoteColumns = `id, text, author, category, source, tags, likes, version, created_at`
+
+// sqliteTimeFormat matches the layout of CURRENT_TIMESTAMP, so times in
+// this format compare correctly with stored created_at values
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// createdNow returns the creation time stored for a new quote, at the
+// one-second precision of sqliteTimeFormat
+func createdNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanQuote reads a row selected with quoteColumns into quote
+func scanQuote(row rowScanner, quote *models.Quote) error {
+	return row.Scan(
+		&quote.ID,
+		&quote.Text,
+		&quote.Author,
+		&quote.Category,
+		&quote.Source,
+		&quote.Tags,
+		&quote.Likes,
+		&quote.Version,
+		&quote.CreatedAt,
+	)
+}
+
 // QuoteRepository handles database operations for quotes
 type QuoteRepository struct {
-	db *sql.DB
+	db dbtx
 }
 
 // NewQuoteRepository creates a new quote repository
@@ -21,9 +56,10 @@ func NewQuoteRepository(db *sql.DB) *QuoteRepository {
 
 // Create adds a new quote to the database
 func (r *QuoteRepository) Create(quote *models.Quote) (*models.Quote, error) {
-	query := `INSERT INTO quotes (text, author, category) VALUES (?, ?, ?)`
+	query := `INSERT INTO quotes (text, author, category, source, tags, likes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
 
-	result, err := r.db.Exec(query, quote.Text, quote.Author, quote.Category)
+	now := createdNow()
+	result, err := r.db.Exec(query, quote.Text, quote.Author, quote.Category, quote.Source, quote.Tags, quote.Likes, now.Format(sqliteTimeFormat))
 	if err != nil {
 		return nil, errors.NewDatabaseError("failed to create quote")
 	}
@@ -33,22 +69,18 @@ func (r *QuoteRepository) Create(quote *models.Quote) (*models.Quote, error) {
 		return nil, errors.NewDatabaseError("failed to get last insert id")
 	}
 	quote.ID = int(id)
+	quote.Version = 1
+	quote.CreatedAt = now
 
 	return quote, nil
 }
 
 // GetByID retrieves a quote by its ID
 func (r *QuoteRepository) GetByID(id int) (*models.Quote, error) {
-	query := `SELECT id, text, author, category, created_at FROM quotes WHERE id = ?`
+	query := `SELECT ` + quoteColumns + ` FROM quotes WHERE id = ?`
 
 	quote := &models.Quote{}
-	err := r.db.QueryRow(query, id).Scan(
-		&quote.ID,
-		&quote.Text,
-		&quote.Author,
-		&quote.Category,
-		&quote.CreatedAt,
-	)
+	err := scanQuote(r.db.QueryRow(query, id), quote)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -62,16 +94,10 @@ func (r *QuoteRepository) GetByID(id int) (*models.Quote, error) {
 
 // GetRandom retrieves a random quote
 func (r *QuoteRepository) GetRandom() (*models.Quote, error) {
-	query := `SELECT id, text, author, category, created_at FROM quotes ORDER BY RANDOM() LIMIT 1`
+	query := `SELECT ` + quoteColumns + ` FROM quotes ORDER BY RANDOM() LIMIT 1`
 
 	quote := &models.Quote{}
-	err := r.db.QueryRow(query).Scan(
-		&quote.ID,
-		&quote.Text,
-		&quote.Author,
-		&quote.Category,
-		&quote.CreatedAt,
-	)
+	err := scanQuote(r.db.QueryRow(query), quote)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -85,16 +111,10 @@ func (r *QuoteRepository) GetRandom() (*models.Quote, error) {
 
 // GetRandomByCategory retrieves a random quote from a specific category
 func (r *QuoteRepository) GetRandomByCategory(category string) (*models.Quote, error) {
-	query := `SELECT id, text, author, category, created_at FROM quotes WHERE category = ? ORDER BY RANDOM() LIMIT 1`
+	query := `SELECT ` + quoteColumns + ` FROM quotes WHERE category = ? ORDER BY RANDOM() LIMIT 1`
 
 	quote := &models.Quote{}
-	err := r.db.QueryRow(query, category).Scan(
-		&quote.ID,
-		&quote.Text,
-		&quote.Author,
-		&quote.Category,
-		&quote.CreatedAt,
-	)
+	err := scanQuote(r.db.QueryRow(query, category), quote)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -108,7 +128,7 @@ func (r *QuoteRepository) GetRandomByCategory(category string) (*models.Quote, e
 
 // GetAll retrieves all quotes with pagination
 func (r *QuoteRepository) GetAll(limit, offset int) ([]*models.Quote, int, error) {
-	query := `SELECT id, text, author, category, created_at FROM quotes ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	query := `SELECT ` + quoteColumns + ` FROM quotes ORDER BY created_at DESC LIMIT ? OFFSET ?`
 
 	rows, err := r.db.Query(query, limit, offset)
 	if err != nil {
@@ -119,13 +139,7 @@ func (r *QuoteRepository) GetAll(limit, offset int) ([]*models.Quote, int, error
 	var quotes []*models.Quote
 	for rows.Next() {
 		quote := &models.Quote{}
-		err := rows.Scan(
-			&quote.ID,
-			&quote.Text,
-			&quote.Author,
-			&quote.Category,
-			&quote.CreatedAt,
-		)
+		err := scanQuote(rows, quote)
 		if err != nil {
 			return nil, 0, errors.NewDatabaseError("failed to scan quote")
 		}
@@ -144,7 +158,7 @@ func (r *QuoteRepository) GetAll(limit, offset int) ([]*models.Quote, int, error
 
 // GetByCategory retrieves quotes by category with pagination
 func (r *QuoteRepository) GetByCategory(category string, limit, offset int) ([]*models.Quote, int, error) {
-	query := `SELECT id, text, author, category, created_at FROM quotes WHERE category = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	query := `SELECT ` + quoteColumns + ` FROM quotes WHERE category = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`
 
 	rows, err := r.db.Query(query, category, limit, offset)
 	if err != nil {
@@ -155,13 +169,7 @@ func (r *QuoteRepository) GetByCategory(category string, limit, offset int) ([]*
 	var quotes []*models.Quote
 	for rows.Next() {
 		quote := &models.Quote{}
-		err := rows.Scan(
-			&quote.ID,
-			&quote.Text,
-			&quote.Author,
-			&quote.Category,
-			&quote.CreatedAt,
-		)
+		err := scanQuote(rows, quote)
 		if err != nil {
 			return nil, 0, errors.NewDatabaseError("failed to scan quote")
 		}
@@ -199,3 +207,313 @@ func (r *QuoteRepository) GetCategories() ([]string, error) {
 
 	return categories, nil
 }
+
+// CreateBatch inserts quotes inside a single transaction, skipping any whose
+// text and author already exist. The returned slice has one entry per input
+// quote and is true where the quote was skipped as a duplicate. When dryRun
+// is set the transaction is rolled back instead of committed, so IDs are
+// assigned but nothing is persisted.
+func (r *QuoteRepository) CreateBatch(quotes []*models.Quote, dryRun bool) ([]bool, error) {
+	var skipped []bool
+	err := withTx(r.db, func(tx dbtx) error {
+		existsStmt, err := tx.Prepare(`SELECT COUNT(*) FROM quotes WHERE author = ? COLLATE NOCASE AND text = ? COLLATE NOCASE`)
+		if err != nil {
+			return errors.NewDatabaseError("failed to prepare duplicate check")
+		}
+		defer existsStmt.Close()
+
+		insertStmt, err := tx.Prepare(`INSERT INTO quotes (text, author, category, source, tags, likes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return errors.NewDatabaseError("failed to prepare insert")
+		}
+		defer insertStmt.Close()
+
+		skipped = make([]bool, len(quotes))
+		for i, quote := range quotes {
+			var count int
+			if err := existsStmt.QueryRow(quote.Author, quote.Text).Scan(&count); err != nil {
+				return errors.NewDatabaseError("failed to check for duplicate quote")
+			}
+			if count > 0 {
+				skipped[i] = true
+				continue
+			}
+
+			now := createdNow()
+			result, err := insertStmt.Exec(quote.Text, quote.Author, quote.Category, quote.Source, quote.Tags, quote.Likes, now.Format(sqliteTimeFormat))
+			if err != nil {
+				return errors.NewDatabaseError("failed to create quote")
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return errors.NewDatabaseError("failed to get last insert id")
+			}
+			quote.ID = int(id)
+			quote.Version = 1
+			quote.CreatedAt = now
+		}
+
+		if dryRun {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && err != errRollback {
+		return nil, err
+	}
+
+	return skipped, nil
+}
+
+// Each streams the quotes matching filter to fn ordered by category,
+// author and ID. Iteration stops at the first error returned by fn, which
+// is passed back unchanged.
+func (r *QuoteRepository) Each(filter models.QuoteFilter, fn func(*models.Quote) error) error {
+	where, args := filterClause(filter)
+	query := `SELECT ` + quoteColumns + ` FROM quotes` + where + ` ORDER BY category, author, id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return errors.NewDatabaseError("failed to get quotes")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		quote := &models.Quote{}
+		err := scanQuote(rows, quote)
+		if err != nil {
+			return errors.NewDatabaseError("failed to scan quote")
+		}
+		if err := fn(quote); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return errors.NewDatabaseError("failed to read quotes")
+	}
+
+	return nil
+}
+
+// LastChanged returns when a quote was last created, edited or deleted,
+// from the change log, or the zero time when nothing has changed yet
+func (r *QuoteRepository) LastChanged() (time.Time, error) {
+	var changed sql.NullTime
+	err := r.db.QueryRow(`SELECT changed_at FROM quote_changes ORDER BY seq DESC LIMIT 1`).Scan(&changed)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, errors.NewDatabaseError("failed to get last change")
+	}
+	return changed.Time, nil
+}
+
+// GetRecent retrieves the newest quotes, optionally restricted to a
+// category and to an author. The author comparison ignores ASCII case.
+func (r *QuoteRepository) GetRecent(category, author string, limit int) ([]*models.Quote, error) {
+	query := `SELECT ` + quoteColumns + ` FROM quotes WHERE 1 = 1`
+	var args []interface{}
+	if category != "" {
+		query += ` AND category = ?`
+		args = append(args, category)
+	}
+	if author != "" {
+		query += ` AND author = ? COLLATE NOCASE`
+		args = append(args, author)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get recent quotes")
+	}
+	defer rows.Close()
+
+	var quotes []*models.Quote
+	for rows.Next() {
+		quote := &models.Quote{}
+		if err := scanQuote(rows, quote); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan quote")
+		}
+		quotes = append(quotes, quote)
+	}
+
+	return quotes, nil
+}
+
+// GetNth retrieves the quote at position n, counting from zero in ID order,
+// among the quotes created before the given time, optionally restricted to
+// a category. n wraps around the number of such quotes, so any seed picks a
+// quote. Quotes added later do not change the pick.
+func (r *QuoteRepository) GetNth(n uint64, before time.Time, category string) (*models.Quote, error) {
+	where := ` WHERE created_at < ?`
+	args := []interface{}{before.UTC().Format(sqliteTimeFormat)}
+	if category != "" {
+		where += ` AND category = ?`
+		args = append(args, category)
+	}
+
+	var count uint64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM quotes`+where, args...).Scan(&count); err != nil {
+		return nil, errors.NewDatabaseError("failed to get quote count")
+	}
+	if count == 0 {
+		return nil, errors.ErrQuoteNotFound
+	}
+
+	query := `SELECT ` + quoteColumns + ` FROM quotes` + where + ` ORDER BY id LIMIT 1 OFFSET ?`
+
+	quote := &models.Quote{}
+	if err := scanQuote(r.db.QueryRow(query, append(args, n%count)...), quote); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrQuoteNotFound
+		}
+		return nil, errors.NewDatabaseError("failed to get quote")
+	}
+
+	return quote, nil
+}
+
+// likeEscaper escapes the LIKE wildcards in user input, for patterns
+// written with ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// filterClause builds the WHERE clause and arguments selecting the quotes
+// that match filter. The author comparison and the text query ignore ASCII
+// case.
+func filterClause(filter models.QuoteFilter) (string, []interface{}) {
+	where := ` WHERE 1 = 1`
+	var args []interface{}
+	if filter.Category != "" {
+		where += ` AND category = ?`
+		args = append(args, filter.Category)
+	}
+	if filter.Author != "" {
+		where += ` AND author = ? COLLATE NOCASE`
+		args = append(args, filter.Author)
+	}
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		pattern := "%" + likeEscaper.Replace(query) + "%"
+		where += ` AND (text LIKE ? ESCAPE '\' OR author LIKE ? ESCAPE '\' OR source LIKE ? ESCAPE '\')`
+		args = append(args, pattern, pattern, pattern)
+	}
+	if !filter.From.IsZero() {
+		where += ` AND created_at >= ?`
+		args = append(args, filter.From.UTC().Format(sqliteTimeFormat))
+	}
+	if !filter.To.IsZero() {
+		where += ` AND created_at < ?`
+		args = append(args, filter.To.UTC().Format(sqliteTimeFormat))
+	}
+	return where, args
+}
+
+// Search retrieves quotes matching filter, newest first, with pagination
+func (r *QuoteRepository) Search(filter models.QuoteFilter, limit, offset int) ([]*models.Quote, int, error) {
+	where, args := filterClause(filter)
+
+	query := `SELECT ` + quoteColumns + ` FROM quotes` + where + ` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, errors.NewDatabaseError("failed to search quotes")
+	}
+	defer rows.Close()
+
+	var quotes []*models.Quote
+	for rows.Next() {
+		quote := &models.Quote{}
+		if err := scanQuote(rows, quote); err != nil {
+			return nil, 0, errors.NewDatabaseError("failed to scan quote")
+		}
+		quotes = append(quotes, quote)
+	}
+
+	// Get total count of matches
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM quotes`+where, args...).Scan(&total); err != nil {
+		return nil, 0, errors.NewDatabaseError("failed to get quote count")
+	}
+
+	return quotes, total, nil
+}
+
+// GetAuthors returns every author with the number of their quotes, ordered
+// by name
+func (r *QuoteRepository) GetAuthors() ([]models.AuthorCount, error) {
+	query := `SELECT author, COUNT(*) FROM quotes GROUP BY author ORDER BY author COLLATE NOCASE`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get authors")
+	}
+	defer rows.Close()
+
+	var authors []models.AuthorCount
+	for rows.Next() {
+		var author models.AuthorCount
+		if err := rows.Scan(&author.Author, &author.Count); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan author")
+		}
+		authors = append(authors, author)
+	}
+
+	return authors, nil
+}
+
+// Update saves the text, author, category, source and tags of a quote and
+// increments its version. When quote.Version is set the update only applies
+// if the stored quote still has that version, so an edit based on a stale
+// copy fails with ErrQuoteModified instead of overwriting a newer one.
+func (r *QuoteRepository) Update(quote *models.Quote) (*models.Quote, error) {
+	query := `UPDATE quotes SET text = ?, author = ?, category = ?, source = ?, tags = ?, version = version + 1 WHERE id = ?`
+	args := []interface{}{quote.Text, quote.Author, quote.Category, quote.Source, quote.Tags, quote.ID}
+	if quote.Version > 0 {
+		query += ` AND version = ?`
+		args = append(args, quote.Version)
+	}
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to update quote")
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to update quote")
+	}
+	if updated == 0 {
+		// Either the quote is gone or its version moved on
+		if _, err := r.GetByID(quote.ID); err != nil {
+			return nil, err
+		}
+		return nil, errors.ErrQuoteModified
+	}
+
+	return r.GetByID(quote.ID)
+}
+
+// Delete removes a quote
+func (r *QuoteRepository) Delete(id int) error {
+	result, err := r.db.Exec(`DELETE FROM quotes WHERE id = ?`, id)
+	if err != nil {
+		return errors.NewDatabaseError("failed to delete quote")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return errors.NewDatabaseError("failed to delete quote")
+	}
+	if deleted == 0 {
+		return errors.ErrQuoteNotFound
+	}
+
+	return nil
+}
+
+// CountByCategory returns the number of quotes in a category
+func (r *QuoteRepository) CountByCategory(category string) (int, error) {
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM quotes WHERE category = ?`, category).Scan(&count); err != nil {
+		return 0, errors.NewDatabaseError("failed to get quote count")
+	}
+
+	return count, nil
+}
@@ -3,8 +3,10 @@ package repository
 import (
 	"database/sql"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"quote-vault/errors"
 	"quote-vault/models"
 )
 
@@ -21,6 +23,10 @@ func setupTestDB(t *testing.T) *sql.DB {
 			text TEXT NOT NULL,
 			author TEXT NOT NULL,
 			category TEXT NOT NULL,
+			source TEXT NOT NULL DEFAULT '',
+			tags TEXT NOT NULL DEFAULT '',
+			likes INTEGER NOT NULL DEFAULT 0,
+			version INTEGER NOT NULL DEFAULT 1,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -76,6 +82,10 @@ func TestQuoteRepository_Create(t *testing.T) {
 				if result.Text != tt.quote.Text {
 					t.Errorf("Create() text = %v, want %v", result.Text, tt.quote.Text)
 				}
+				stored, err := repo.GetByID(result.ID)
+				if err != nil || !stored.CreatedAt.Equal(result.CreatedAt) {
+					t.Errorf("Create() created_at = %v, stored %v", result.CreatedAt, stored)
+				}
 			}
 		})
 	}
@@ -391,3 +401,314 @@ func TestQuoteRepository_GetCategories(t *testing.T) {
 		t.Errorf("GetCategories() count = %v, want 3", len(categories))
 	}
 }
+
+func TestQuoteRepository_Create_SourceTagsAndLikes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewQuoteRepository(db)
+
+	created, err := repo.Create(&models.Quote{
+		Text:     "Man is by nature a political animal.",
+		Author:   "Aristotle",
+		Category: "philosophy",
+		Source:   "Politics",
+		Tags:     models.Tags{"politics", "society"},
+		Likes:    7,
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := repo.GetByID(created.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.Source != "Politics" || got.Likes != 7 {
+		t.Errorf("GetByID() source/likes = %q/%d, want Politics/7", got.Source, got.Likes)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "politics" || got.Tags[1] != "society" {
+		t.Errorf("GetByID() tags = %v, want [politics society]", got.Tags)
+	}
+}
+
+func TestQuoteRepository_GetRecent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`INSERT INTO quotes (text, author, category, created_at) VALUES
+		('Oldest quote in the vault', 'Oscar Wilde', 'wit', '2024-01-01 09:00:00'),
+		('Middle quote in the vault', 'Mark Twain', 'wit', '2024-01-02 09:00:00'),
+		('Newest quote in the vault', 'Oscar Wilde', 'wisdom', '2024-01-03 09:00:00')`)
+	if err != nil {
+		t.Fatalf("failed to insert test data: %v", err)
+	}
+
+	repo := NewQuoteRepository(db)
+
+	tests := []struct {
+		name             string
+		category, author string
+		limit            int
+		want             []string
+	}{
+		{"all", "", "", 10, []string{"Newest", "Middle", "Oldest"}},
+		{"limited", "", "", 2, []string{"Newest", "Middle"}},
+		{"category", "wit", "", 10, []string{"Middle", "Oldest"}},
+		{"author ignores case", "", "oscar wilde", 10, []string{"Newest", "Oldest"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotes, err := repo.GetRecent(tt.category, tt.author, tt.limit)
+			if err != nil {
+				t.Fatalf("GetRecent() error = %v", err)
+			}
+			if len(quotes) != len(tt.want) {
+				t.Fatalf("GetRecent() returned %d quotes, want %d", len(quotes), len(tt.want))
+			}
+			for i, quote := range quotes {
+				if quote.Text[:len(tt.want[i])] != tt.want[i] {
+					t.Errorf("quote %d = %q, want it to start with %q", i, quote.Text, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuoteRepository_GetNth(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`INSERT INTO quotes (text, author, category, created_at) VALUES
+		('First quote in the vault', 'Author', 'test', '2024-01-01 09:00:00'),
+		('Second quote in the vault', 'Author', 'test', '2024-01-02 09:00:00'),
+		('Third quote in the vault', 'Author', 'test', '2024-01-03 09:00:00')`)
+	if err != nil {
+		t.Fatalf("failed to insert test data: %v", err)
+	}
+
+	repo := NewQuoteRepository(db)
+	endOfJan2 := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	// Only the two quotes created before the cutoff are candidates, and n
+	// wraps around them
+	for n, wantID := range map[uint64]int{0: 1, 1: 2, 2: 1, 7: 2} {
+		quote, err := repo.GetNth(n, endOfJan2, "")
+		if err != nil {
+			t.Fatalf("GetNth(%d) error = %v", n, err)
+		}
+		if quote.ID != wantID {
+			t.Errorf("GetNth(%d) ID = %d, want %d", n, quote.ID, wantID)
+		}
+	}
+
+	if _, err := repo.GetNth(0, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), ""); err != errors.ErrQuoteNotFound {
+		t.Errorf("GetNth() before any quotes error = %v, want ErrQuoteNotFound", err)
+	}
+}
+
+func TestQuoteRepository_Search(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`INSERT INTO quotes (text, author, category, source, created_at) VALUES
+		('To be, or not to be: that is the question.', 'William Shakespeare', 'literature', 'Hamlet', '2024-01-01 09:00:00'),
+		('All the world''s a stage.', 'William Shakespeare', 'literature', 'As You Like It', '2024-01-02 09:00:00'),
+		('Be yourself; everyone else is already taken.', 'Oscar Wilde', 'wit', '', '2024-01-03 09:00:00'),
+		('Discount of 100% on wisdom.', 'Anonymous', 'wit', '', '2024-01-04 09:00:00')`)
+	if err != nil {
+		t.Fatalf("failed to insert test data: %v", err)
+	}
+
+	repo := NewQuoteRepository(db)
+
+	tests := []struct {
+		name      string
+		filter    models.QuoteFilter
+		limit     int
+		offset    int
+		wantIDs   []int
+		wantTotal int
+	}{
+		{"everything newest first", models.QuoteFilter{}, 10, 0, []int{4, 3, 2, 1}, 4},
+		{"paged", models.QuoteFilter{}, 2, 2, []int{2, 1}, 4},
+		{"category", models.QuoteFilter{Category: "wit"}, 10, 0, []int{4, 3}, 2},
+		{"author ignores case", models.QuoteFilter{Author: "william shakespeare"}, 10, 0, []int{2, 1}, 2},
+		{"text ignores case", models.QuoteFilter{Query: "BE YOURSELF"}, 10, 0, []int{3}, 1},
+		{"query matches source", models.QuoteFilter{Query: "hamlet"}, 10, 0, []int{1}, 1},
+		{"query matches author", models.QuoteFilter{Query: "wilde"}, 10, 0, []int{3}, 1},
+		{"wildcards are literal", models.QuoteFilter{Query: "100%"}, 10, 0, []int{4}, 1},
+		{"combined", models.QuoteFilter{Category: "literature", Query: "stage"}, 10, 0, []int{2}, 1},
+		{"no match", models.QuoteFilter{Query: "_"}, 10, 0, nil, 0},
+		{"date range", models.QuoteFilter{From: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)}, 10, 0, []int{3, 2}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotes, total, err := repo.Search(tt.filter, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("Search() total = %d, want %d", total, tt.wantTotal)
+			}
+			if len(quotes) != len(tt.wantIDs) {
+				t.Fatalf("Search() returned %d quotes, want %d", len(quotes), len(tt.wantIDs))
+			}
+			for i, quote := range quotes {
+				if quote.ID != tt.wantIDs[i] {
+					t.Errorf("quote %d ID = %d, want %d", i, quote.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuoteRepository_GetAuthors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`INSERT INTO quotes (text, author, category) VALUES
+		('First quote by Wilde', 'Oscar Wilde', 'wit'),
+		('Only quote by Aurelius', 'marcus aurelius', 'stoicism'),
+		('Second quote by Wilde', 'Oscar Wilde', 'wit')`)
+	if err != nil {
+		t.Fatalf("failed to insert test data: %v", err)
+	}
+
+	authors, err := NewQuoteRepository(db).GetAuthors()
+	if err != nil {
+		t.Fatalf("GetAuthors() error = %v", err)
+	}
+
+	want := []models.AuthorCount{{Author: "marcus aurelius", Count: 1}, {Author: "Oscar Wilde", Count: 2}}
+	if len(authors) != len(want) {
+		t.Fatalf("GetAuthors() = %v, want %v", authors, want)
+	}
+	for i := range want {
+		if authors[i] != want[i] {
+			t.Errorf("GetAuthors()[%d] = %v, want %v", i, authors[i], want[i])
+		}
+	}
+}
+
+func TestQuoteRepository_Update(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewQuoteRepository(db)
+	created, err := repo.Create(&models.Quote{Text: "Original text of the quote", Author: "Author", Category: "test", Likes: 3})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	updated, err := repo.Update(&models.Quote{
+		ID:       created.ID,
+		Version:  1,
+		Text:     "Edited text of the quote",
+		Author:   "Editor",
+		Category: "edited",
+		Source:   "Notebook",
+		Tags:     models.Tags{"edited"},
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.Version != 2 || updated.Text != "Edited text of the quote" || updated.Source != "Notebook" {
+		t.Errorf("Update() = %+v, want the edited quote at version 2", updated)
+	}
+	if updated.Likes != 3 {
+		t.Errorf("Update() likes = %d, want them kept at 3", updated.Likes)
+	}
+
+	// An edit based on version 1 must not overwrite version 2
+	_, err = repo.Update(&models.Quote{ID: created.ID, Version: 1, Text: "Stale edit", Author: "Editor", Category: "edited"})
+	if err != errors.ErrQuoteModified {
+		t.Errorf("Update() with a stale version error = %v, want ErrQuoteModified", err)
+	}
+
+	// Without a version the update always applies
+	updated, err = repo.Update(&models.Quote{ID: created.ID, Text: "Forced edit", Author: "Editor", Category: "edited"})
+	if err != nil || updated.Version != 3 {
+		t.Errorf("Update() without a version = %+v, %v, want version 3", updated, err)
+	}
+
+	_, err = repo.Update(&models.Quote{ID: 99, Text: "Missing quote", Author: "Editor", Category: "edited"})
+	if err != errors.ErrQuoteNotFound {
+		t.Errorf("Update() of a missing quote error = %v, want ErrQuoteNotFound", err)
+	}
+}
+
+func TestQuoteRepository_Delete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewQuoteRepository(db)
+	created, err := repo.Create(&models.Quote{Text: "Soon gone", Author: "Author", Category: "fleeting"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if count, err := repo.CountByCategory("fleeting"); err != nil || count != 1 {
+		t.Errorf("CountByCategory() = %d, %v, want 1", count, err)
+	}
+
+	if err := repo.Delete(created.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.GetByID(created.ID); err != errors.ErrQuoteNotFound {
+		t.Errorf("GetByID() after Delete() error = %v, want ErrQuoteNotFound", err)
+	}
+	if count, err := repo.CountByCategory("fleeting"); err != nil || count != 0 {
+		t.Errorf("CountByCategory() after Delete() = %d, %v, want 0", count, err)
+	}
+
+	if err := repo.Delete(created.ID); err != errors.ErrQuoteNotFound {
+		t.Errorf("Delete() of a missing quote error = %v, want ErrQuoteNotFound", err)
+	}
+}
+
+func TestQuoteFilter_MatchesAgreesWithSearch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewQuoteRepository(db)
+	quotes := []*models.Quote{
+		{Text: "Know thyself", Author: "Socrates", Category: "Wisdom"},
+		{Text: "Life is what happens", Author: "John Lennon", Category: "Life", Source: "Beautiful Boy"},
+		{Text: "To live is the rarest thing", Author: "Oscar Wilde", Category: "Life"},
+		{Text: "100% effort, 50_50 odds", Author: "Anon", Category: "Sport"},
+	}
+	for _, quote := range quotes {
+		if _, err := repo.Create(quote); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	filters := []models.QuoteFilter{
+		{Category: "Life"},
+		{Category: "life"},
+		{Author: "oscar wilde"},
+		{Query: "LIFE"},
+		{Query: "boy"},
+		{Query: "0%"},
+		{Query: "0_5"},
+		{Category: "Life", Query: "live"},
+	}
+	for _, filter := range filters {
+		found, _, err := repo.Search(filter, 10, 0)
+		if err != nil {
+			t.Fatalf("Search(%+v) error = %v", filter, err)
+		}
+		want := make(map[int]bool)
+		for _, quote := range found {
+			want[quote.ID] = true
+		}
+		for _, quote := range quotes {
+			if got := filter.Matches(quote); got != want[quote.ID] {
+				t.Errorf("%+v.Matches(%q) = %v, Search() disagrees", filter, quote.Text, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+// Metadata keys holding a follower's replication state
+const (
+	replicationLeaderKey   = "replication.leader"
+	replicationPositionKey = "replication.position"
+	replicationResyncKey   = "replication.resyncing"
+)
+
+// ReplicationRepository applies a leader's changes to a follower's copy of
+// the vault and keeps the follower's position
+type ReplicationRepository struct {
+	db dbtx
+}
+
+// NewReplicationRepository creates a new replication repository
+func NewReplicationRepository(db *sql.DB) *ReplicationRepository {
+	return &ReplicationRepository{
+		db: db,
+	}
+}
+
+// State returns the saved replication state, empty before the first sync
+func (r *ReplicationRepository) State() (*models.ReplicationState, error) {
+	state := &models.ReplicationState{}
+	var err error
+	if state.Leader, _, err = getMetadata(r.db, replicationLeaderKey); err != nil {
+		return nil, errors.NewDatabaseError("failed to get replication state")
+	}
+	if state.Position, _, err = getMetadata(r.db, replicationPositionKey); err != nil {
+		return nil, errors.NewDatabaseError("failed to get replication state")
+	}
+	resyncing, _, err := getMetadata(r.db, replicationResyncKey)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get replication state")
+	}
+	state.Resyncing = resyncing == "1"
+
+	return state, nil
+}
+
+// Apply applies a page of changes and saves state as the new position, in
+// one transaction, so a page is either applied with its position or not at
+// all. Applying a change again has no effect. When keepNewer is set, an
+// upsert or a delete older than the local quote is skipped; the number
+// skipped is returned alongside the changes that were applied. A delete
+// whose version is not known always applies.
+func (r *ReplicationRepository) Apply(changes []*models.Change, state *models.ReplicationState, keepNewer bool) ([]models.ReplicatedChange, int, error) {
+	var applied []models.ReplicatedChange
+	skipped := 0
+	err := withTx(r.db, func(tx dbtx) error {
+		for _, change := range changes {
+			previous, err := quoteInTx(tx, change.ID)
+			if err != nil {
+				return err
+			}
+
+			if change.Type == models.ChangeDelete || change.Quote == nil {
+				if previous == nil {
+					continue
+				}
+				if keepNewer && change.Version > 0 && previous.Version > change.Version {
+					skipped++
+					continue
+				}
+				if _, err := tx.Exec(`DELETE FROM quotes WHERE id = ?`, change.ID); err != nil {
+					return errors.NewDatabaseError("failed to delete replicated quote")
+				}
+				applied = append(applied, models.ReplicatedChange{Previous: previous})
+				continue
+			}
+
+			quote := change.Quote
+			switch {
+			case previous == nil:
+				query := `INSERT INTO quotes (id, text, author, category, source, tags, likes, version, created_at)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+				_, err = tx.Exec(query, quote.ID, quote.Text, quote.Author, quote.Category, quote.Source, quote.Tags,
+					quote.Likes, quote.Version, quote.CreatedAt.UTC().Format(sqliteTimeFormat))
+			case sameQuote(previous, quote):
+				continue
+			case keepNewer && previous.Version > quote.Version:
+				skipped++
+				continue
+			default:
+				query := `UPDATE quotes SET text = ?, author = ?, category = ?, source = ?, tags = ?, likes = ?, version = ?,
+					created_at = ? WHERE id = ?`
+				_, err = tx.Exec(query, quote.Text, quote.Author, quote.Category, quote.Source, quote.Tags,
+					quote.Likes, quote.Version, quote.CreatedAt.UTC().Format(sqliteTimeFormat), quote.ID)
+			}
+			if err != nil {
+				return errors.NewDatabaseError("failed to save replicated quote")
+			}
+			applied = append(applied, models.ReplicatedChange{Previous: previous, Quote: quote})
+		}
+
+		return saveReplicationState(tx, state)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return applied, skipped, nil
+}
+
+// FinishResync removes every quote whose ID is not in keep, which holds the
+// quotes sent by the leader during a full resync, and saves state. The
+// removed quotes are returned.
+func (r *ReplicationRepository) FinishResync(keep map[int]bool, state *models.ReplicationState) ([]*models.Quote, error) {
+	var removed []*models.Quote
+	err := withTx(r.db, func(tx dbtx) error {
+		rows, err := tx.Query(`SELECT ` + quoteColumns + ` FROM quotes ORDER BY id`)
+		if err != nil {
+			return errors.NewDatabaseError("failed to get quotes")
+		}
+		for rows.Next() {
+			quote := &models.Quote{}
+			if err := scanQuote(rows, quote); err != nil {
+				rows.Close()
+				return errors.NewDatabaseError("failed to scan quote")
+			}
+			if !keep[quote.ID] {
+				removed = append(removed, quote)
+			}
+		}
+		rows.Close()
+
+		for _, quote := range removed {
+			if _, err := tx.Exec(`DELETE FROM quotes WHERE id = ?`, quote.ID); err != nil {
+				return errors.NewDatabaseError("failed to delete quote")
+			}
+		}
+
+		return saveReplicationState(tx, state)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// saveReplicationState stores state in the metadata table
+func saveReplicationState(db dbtx, state *models.ReplicationState) error {
+	resyncing := "0"
+	if state.Resyncing {
+		resyncing = "1"
+	}
+	for key, value := range map[string]string{
+		replicationLeaderKey:   state.Leader,
+		replicationPositionKey: state.Position,
+		replicationResyncKey:   resyncing,
+	} {
+		if err := setMetadata(db, key, value); err != nil {
+			return errors.NewDatabaseError("failed to save replication state")
+		}
+	}
+	return nil
+}
+
+// quoteInTx reads a quote inside tx, returning nil when there is none
+func quoteInTx(tx dbtx, id int) (*models.Quote, error) {
+	quote := &models.Quote{}
+	err := scanQuote(tx.QueryRow(`SELECT `+quoteColumns+` FROM quotes WHERE id = ?`, id), quote)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get quote")
+	}
+	return quote, nil
+}
+
+// sameQuote reports whether two copies of a quote hold the same version
+// and content
+func sameQuote(a, b *models.Quote) bool {
+	if a.Version != b.Version || a.Text != b.Text || a.Author != b.Author || a.Category != b.Category ||
+		a.Source != b.Source || a.Likes != b.Likes || len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for i := range a.Tags {
+		if a.Tags[i] != b.Tags[i] {
+			return false
+		}
+	}
+	return true
+}
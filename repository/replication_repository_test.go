@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func TestReplicationRepository_Apply(t *testing.T) {
+	db := setupChangeDB(t)
+	defer db.Close()
+
+	repo := NewReplicationRepository(db)
+	quotes := NewQuoteRepository(db)
+
+	state, err := repo.State()
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	if state.Leader != "" || state.Position != "" || state.Resyncing {
+		t.Errorf("State() before the first sync = %+v, want empty", state)
+	}
+
+	created := time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)
+	leaderQuote := &models.Quote{ID: 7, Text: "Replicated", Author: "Leader", Category: "remote", Tags: models.Tags{"a"}, Version: 3, CreatedAt: created}
+	changes := []*models.Change{
+		{Sequence: 1, Type: models.ChangeUpsert, ID: 7, Quote: leaderQuote},
+		{Sequence: 2, Type: models.ChangeDelete, ID: 9},
+	}
+	next := &models.ReplicationState{Leader: "https://leader.example.com", Position: "2", Resyncing: true}
+	applied, skipped, err := repo.Apply(changes, next, false)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(applied) != 1 || applied[0].Previous != nil || applied[0].Quote.ID != 7 || skipped != 0 {
+		t.Errorf("Apply() = %+v, %d, want the new quote only", applied, skipped)
+	}
+
+	got, err := quotes.GetByID(7)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.Text != "Replicated" || got.Version != 3 || !got.CreatedAt.Equal(created) || len(got.Tags) != 1 {
+		t.Errorf("replicated quote = %+v", got)
+	}
+	if state, _ := repo.State(); *state != *next {
+		t.Errorf("State() = %+v, want %+v", state, next)
+	}
+
+	// Applying the same page again changes nothing
+	applied, _, err = repo.Apply(changes, next, false)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("Apply() again = %+v, %v, want no changes", applied, err)
+	}
+
+	// A newer local edit survives an older leader version under
+	// last-writer-wins, and is overwritten otherwise
+	got.Text = "Edited locally"
+	if _, err := quotes.Update(got); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	applied, skipped, err = repo.Apply(changes[:1], next, true)
+	if err != nil || len(applied) != 0 || skipped != 1 {
+		t.Errorf("Apply(keepNewer) = %+v, %d, %v, want the change skipped", applied, skipped, err)
+	}
+	if local, _ := quotes.GetByID(7); local.Text != "Edited locally" {
+		t.Errorf("quote after Apply(keepNewer) = %q, want the local edit", local.Text)
+	}
+	applied, _, err = repo.Apply(changes[:1], next, false)
+	if err != nil || len(applied) != 1 || applied[0].Previous.Text != "Edited locally" {
+		t.Errorf("Apply() = %+v, %v, want the local edit replaced", applied, err)
+	}
+	if local, _ := quotes.GetByID(7); local.Text != "Replicated" || local.Version != 3 {
+		t.Errorf("quote after Apply() = %+v, want the leader's", local)
+	}
+
+	// A delete of an older version than a local edit is skipped the same
+	// way under last-writer-wins
+	local, _ := quotes.GetByID(7)
+	local.Text = "Edited again"
+	if _, err := quotes.Update(local); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	stale := []*models.Change{{Sequence: 3, Type: models.ChangeDelete, ID: 7, Version: 3}}
+	applied, skipped, err = repo.Apply(stale, next, true)
+	if err != nil || len(applied) != 0 || skipped != 1 {
+		t.Errorf("Apply(keepNewer) of an older delete = %+v, %d, %v, want the delete skipped", applied, skipped, err)
+	}
+	if _, err := quotes.GetByID(7); err != nil {
+		t.Errorf("GetByID() after a skipped delete error = %v", err)
+	}
+
+	tombstone := []*models.Change{{Sequence: 4, Type: models.ChangeDelete, ID: 7, Version: 4}}
+	applied, _, err = repo.Apply(tombstone, next, true)
+	if err != nil || len(applied) != 1 || applied[0].Quote != nil || applied[0].Previous.ID != 7 {
+		t.Errorf("Apply(tombstone) = %+v, %v, want the quote deleted", applied, err)
+	}
+	if _, err := quotes.GetByID(7); err == nil {
+		t.Error("GetByID() after a replicated delete found the quote")
+	}
+}
+
+func TestReplicationRepository_FinishResync(t *testing.T) {
+	db := setupChangeDB(t)
+	defer db.Close()
+
+	repo := NewReplicationRepository(db)
+	quotes := NewQuoteRepository(db)
+
+	var ids []int
+	for _, text := range []string{"Kept", "Local only", "Also kept"} {
+		quote, err := quotes.Create(&models.Quote{Text: text, Author: "Author", Category: "test"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		ids = append(ids, quote.ID)
+	}
+
+	state := &models.ReplicationState{Leader: "https://leader.example.com", Position: "12"}
+	removed, err := repo.FinishResync(map[int]bool{ids[0]: true, ids[2]: true}, state)
+	if err != nil {
+		t.Fatalf("FinishResync() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0].ID != ids[1] || removed[0].Text != "Local only" {
+		t.Errorf("FinishResync() removed %+v, want the local-only quote", removed)
+	}
+	if _, total, _ := quotes.GetAll(10, 0); total != 2 {
+		t.Errorf("quotes after FinishResync() = %d, want 2", total)
+	}
+	if saved, _ := repo.State(); *saved != *state {
+		t.Errorf("State() = %+v, want %+v", saved, state)
+	}
+}
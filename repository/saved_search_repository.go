@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+// savedSearchColumns lists the columns read by scanSavedSearch, in scan
+// order
+const savedSearchColumns = `id, name, category, author, query, webhook_url, secret, email, confirm_token,
+	unsubscribe_token, confirmed_at, created_at`
+
+// matchColumns lists the columns read by scanMatch, in scan order
+const matchColumns = `id, search_id, quote, status, attempts, last_error, next_attempt_at, notified_at, created_at`
+
+// scanSavedSearch reads a row selected with savedSearchColumns into search
+func scanSavedSearch(row rowScanner, search *models.SavedSearch) error {
+	var confirmed sql.NullTime
+	err := row.Scan(
+		&search.ID,
+		&search.Name,
+		&search.Category,
+		&search.Author,
+		&search.Query,
+		&search.WebhookURL,
+		&search.Secret,
+		&search.Email,
+		&search.ConfirmToken,
+		&search.UnsubscribeToken,
+		&confirmed,
+		&search.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	search.ConfirmedAt = timePtr(confirmed)
+	return nil
+}
+
+// scanMatch reads a row selected with matchColumns into match
+func scanMatch(row rowScanner, match *models.SearchMatch) error {
+	var quote string
+	var next, notified sql.NullTime
+	err := row.Scan(
+		&match.ID,
+		&match.SearchID,
+		&quote,
+		&match.Status,
+		&match.Attempts,
+		&match.LastError,
+		&next,
+		&notified,
+		&match.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	match.Quote = &models.Quote{}
+	if err := json.Unmarshal([]byte(quote), match.Quote); err != nil {
+		return err
+	}
+	match.NextAttemptAt = timePtr(next)
+	match.NotifiedAt = timePtr(notified)
+	return nil
+}
+
+// SavedSearchRepository handles database operations for saved searches and
+// the quotes they matched
+type SavedSearchRepository struct {
+	db *sql.DB
+}
+
+// NewSavedSearchRepository creates a new saved search repository
+func NewSavedSearchRepository(db *sql.DB) *SavedSearchRepository {
+	return &SavedSearchRepository{
+		db: db,
+	}
+}
+
+// Create saves a search
+func (r *SavedSearchRepository) Create(search *models.SavedSearch) (*models.SavedSearch, error) {
+	query := `INSERT INTO saved_searches (name, category, author, query, webhook_url, secret, email,
+		confirm_token, unsubscribe_token, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := createdNow()
+	result, err := r.db.Exec(query, search.Name, search.Category, search.Author, search.Query, search.WebhookURL,
+		search.Secret, search.Email, search.ConfirmToken, search.UnsubscribeToken, now.Format(sqliteTimeFormat))
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to create saved search")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get last insert id")
+	}
+	search.ID = int(id)
+	search.CreatedAt = now
+
+	return search, nil
+}
+
+// GetByID retrieves a saved search by its ID
+func (r *SavedSearchRepository) GetByID(id int) (*models.SavedSearch, error) {
+	return r.getBy("id", id, errors.ErrSavedSearchNotFound)
+}
+
+// GetByConfirmToken retrieves the search a confirmation link is for
+func (r *SavedSearchRepository) GetByConfirmToken(token string) (*models.SavedSearch, error) {
+	return r.getBy("confirm_token", token, errors.ErrSubscriptionNotFound)
+}
+
+// GetByUnsubscribeToken retrieves the search an unsubscribe link is for
+func (r *SavedSearchRepository) GetByUnsubscribeToken(token string) (*models.SavedSearch, error) {
+	return r.getBy("unsubscribe_token", token, errors.ErrSubscriptionNotFound)
+}
+
+// getBy retrieves the search whose column holds value, or returns notFound
+func (r *SavedSearchRepository) getBy(column string, value interface{}, notFound error) (*models.SavedSearch, error) {
+	query := `SELECT ` + savedSearchColumns + ` FROM saved_searches WHERE ` + column + ` = ?`
+
+	search := &models.SavedSearch{}
+	if err := scanSavedSearch(r.db.QueryRow(query, value), search); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFound
+		}
+		return nil, errors.NewDatabaseError("failed to get saved search")
+	}
+
+	return search, nil
+}
+
+// GetAll retrieves every saved search, oldest first
+func (r *SavedSearchRepository) GetAll() ([]*models.SavedSearch, error) {
+	return r.query(`SELECT ` + savedSearchColumns + ` FROM saved_searches ORDER BY id`)
+}
+
+// GetActive retrieves the searches new quotes are matched against: those
+// notified by webhook and those whose email address is confirmed
+func (r *SavedSearchRepository) GetActive() ([]*models.SavedSearch, error) {
+	return r.query(`SELECT ` + savedSearchColumns + ` FROM saved_searches
+		WHERE email = '' OR confirmed_at IS NOT NULL ORDER BY id`)
+}
+
+func (r *SavedSearchRepository) query(query string, args ...interface{}) ([]*models.SavedSearch, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get saved searches")
+	}
+	defer rows.Close()
+
+	var searches []*models.SavedSearch
+	for rows.Next() {
+		search := &models.SavedSearch{}
+		if err := scanSavedSearch(rows, search); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan saved search")
+		}
+		searches = append(searches, search)
+	}
+
+	return searches, nil
+}
+
+// Confirm marks the email address of a search confirmed at the given time
+func (r *SavedSearchRepository) Confirm(id int, at time.Time) error {
+	if _, err := r.db.Exec(`UPDATE saved_searches SET confirmed_at = ? WHERE id = ?`, queueTime(&at), id); err != nil {
+		return errors.NewDatabaseError("failed to confirm saved search")
+	}
+
+	return nil
+}
+
+// Delete removes a saved search together with its matches
+func (r *SavedSearchRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errors.NewDatabaseError("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM saved_searches WHERE id = ?`, id)
+	if err != nil {
+		return errors.NewDatabaseError("failed to delete saved search")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return errors.NewDatabaseError("failed to delete saved search")
+	}
+	if deleted == 0 {
+		return errors.ErrSavedSearchNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM saved_search_matches WHERE search_id = ?`, id); err != nil {
+		return errors.NewDatabaseError("failed to delete saved search matches")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.NewDatabaseError("failed to commit transaction")
+	}
+
+	return nil
+}
+
+// DeleteUnconfirmed removes email searches created before the given time
+// whose address was never confirmed, and returns how many there were.
+// Unconfirmed searches have no matches.
+func (r *SavedSearchRepository) DeleteUnconfirmed(before time.Time) (int, error) {
+	result, err := r.db.Exec(`DELETE FROM saved_searches WHERE email != '' AND confirmed_at IS NULL AND created_at < ?`,
+		before.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to delete unconfirmed saved searches")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to delete unconfirmed saved searches")
+	}
+
+	return int(deleted), nil
+}
+
+// AddMatch records that quote matched a search and queues a notification
+// about it, due at once. It reports whether the match is new; a quote
+// matches each search at most once.
+func (r *SavedSearchRepository) AddMatch(searchID int, quote *models.Quote) (bool, error) {
+	payload, err := json.Marshal(quote)
+	if err != nil {
+		return false, errors.NewInternalError("failed to encode matched quote")
+	}
+
+	now := createdNow()
+	result, err := r.db.Exec(`INSERT OR IGNORE INTO saved_search_matches
+		(search_id, quote_id, quote, status, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		searchID, quote.ID, string(payload), models.DeliveryPending, queueTime(&now), now.Format(sqliteTimeFormat))
+	if err != nil {
+		return false, errors.NewDatabaseError("failed to record saved search match")
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.NewDatabaseError("failed to record saved search match")
+	}
+
+	return added > 0, nil
+}
+
+// GetMatches retrieves the newest matches of a search
+func (r *SavedSearchRepository) GetMatches(searchID, limit int) ([]*models.SearchMatch, error) {
+	query := `SELECT ` + matchColumns + ` FROM saved_search_matches WHERE search_id = ? ORDER BY id DESC LIMIT ?`
+
+	return r.queryMatches(query, searchID, limit)
+}
+
+// GetDueMatches retrieves matches with a pending notification due at now,
+// the longest waiting first
+func (r *SavedSearchRepository) GetDueMatches(now time.Time, limit int) ([]*models.SearchMatch, error) {
+	query := `SELECT ` + matchColumns + ` FROM saved_search_matches
+		WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`
+
+	return r.queryMatches(query, models.DeliveryPending, now.UTC().Format(queueTimeFormat), limit)
+}
+
+// NextDueAt returns when the earliest pending notification is due, or nil
+// when none is pending
+func (r *SavedSearchRepository) NextDueAt() (*time.Time, error) {
+	query := `SELECT next_attempt_at FROM saved_search_matches WHERE status = ? ORDER BY next_attempt_at LIMIT 1`
+
+	var next sql.NullTime
+	if err := r.db.QueryRow(query, models.DeliveryPending).Scan(&next); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.NewDatabaseError("failed to get next saved search notification")
+	}
+
+	return timePtr(next), nil
+}
+
+// UpdateMatch saves the outcome of a notification attempt
+func (r *SavedSearchRepository) UpdateMatch(match *models.SearchMatch) error {
+	query := `UPDATE saved_search_matches SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?,
+		notified_at = ? WHERE id = ?`
+
+	_, err := r.db.Exec(query, match.Status, match.Attempts, match.LastError, queueTime(match.NextAttemptAt),
+		queueTime(match.NotifiedAt), match.ID)
+	if err != nil {
+		return errors.NewDatabaseError("failed to update saved search match")
+	}
+
+	return nil
+}
+
+// queryMatches runs a query selecting matchColumns
+func (r *SavedSearchRepository) queryMatches(query string, args ...interface{}) ([]*models.SearchMatch, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get saved search matches")
+	}
+	defer rows.Close()
+
+	var matches []*models.SearchMatch
+	for rows.Next() {
+		match := &models.SearchMatch{}
+		if err := scanMatch(rows, match); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan saved search match")
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, nil
+}
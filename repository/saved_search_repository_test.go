@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+func setupSavedSearchDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE saved_searches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			category TEXT NOT NULL DEFAULT '',
+			author TEXT NOT NULL DEFAULT '',
+			query TEXT NOT NULL DEFAULT '',
+			webhook_url TEXT NOT NULL DEFAULT '',
+			secret TEXT NOT NULL DEFAULT '',
+			email TEXT NOT NULL DEFAULT '',
+			confirm_token TEXT NOT NULL DEFAULT '',
+			unsubscribe_token TEXT NOT NULL DEFAULT '',
+			confirmed_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE saved_search_matches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			search_id INTEGER NOT NULL,
+			quote_id INTEGER NOT NULL,
+			quote TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME,
+			notified_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (search_id, quote_id)
+		)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	return db
+}
+
+func TestSavedSearchRepository_Searches(t *testing.T) {
+	db := setupSavedSearchDB(t)
+	defer db.Close()
+
+	repo := NewSavedSearchRepository(db)
+	hook, err := repo.Create(&models.SavedSearch{Name: "Stoics", Category: "Philosophy", Author: "Seneca",
+		WebhookURL: "https://example.com/hook", Secret: "whsec_test"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	email, err := repo.Create(&models.SavedSearch{Name: "Time", Query: "time", Email: "ann@example.com",
+		ConfirmToken: "confirm", UnsubscribeToken: "unsubscribe"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := repo.GetByID(hook.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.Name != "Stoics" || got.Author != "Seneca" || got.Secret != "whsec_test" || !got.Active() {
+		t.Errorf("GetByID() = %+v", got)
+	}
+
+	// Email searches only become active once confirmed
+	active, err := repo.GetActive()
+	if err != nil || len(active) != 1 || active[0].ID != hook.ID {
+		t.Fatalf("GetActive() = %v, %v; want only the webhook search", active, err)
+	}
+	if err := repo.Confirm(email.ID, time.Now()); err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	if active, _ := repo.GetActive(); len(active) != 2 {
+		t.Errorf("GetActive() after Confirm() = %d searches, want 2", len(active))
+	}
+	if got, err := repo.GetByConfirmToken("confirm"); err != nil || got.ConfirmedAt == nil {
+		t.Errorf("GetByConfirmToken() = %+v, %v", got, err)
+	}
+	if _, err := repo.GetByUnsubscribeToken("nope"); err != errors.ErrSubscriptionNotFound {
+		t.Errorf("GetByUnsubscribeToken(unknown) error = %v, want ErrSubscriptionNotFound", err)
+	}
+
+	pending, _ := repo.Create(&models.SavedSearch{Name: "Pending", Author: "Wilde", Email: "bob@example.com",
+		ConfirmToken: "c2", UnsubscribeToken: "u2"})
+	deleted, err := repo.DeleteUnconfirmed(time.Now().Add(time.Minute))
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteUnconfirmed() = %d, %v; want 1", deleted, err)
+	}
+	if _, err := repo.GetByID(pending.ID); err != errors.ErrSavedSearchNotFound {
+		t.Errorf("GetByID() of an expired search error = %v, want ErrSavedSearchNotFound", err)
+	}
+
+	if err := repo.Delete(hook.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := repo.Delete(hook.ID); err != errors.ErrSavedSearchNotFound {
+		t.Errorf("Delete() twice error = %v, want ErrSavedSearchNotFound", err)
+	}
+	if all, _ := repo.GetAll(); len(all) != 1 || all[0].ID != email.ID {
+		t.Errorf("GetAll() = %v, want the email search", all)
+	}
+}
+
+func TestSavedSearchRepository_Matches(t *testing.T) {
+	db := setupSavedSearchDB(t)
+	defer db.Close()
+
+	repo := NewSavedSearchRepository(db)
+	search, _ := repo.Create(&models.SavedSearch{Name: "Wisdom", Category: "Wisdom", WebhookURL: "https://example.com/hook"})
+	quote := &models.Quote{ID: 7, Text: "Know thyself", Author: "Socrates", Category: "Wisdom", Version: 1}
+
+	for i, want := range []bool{true, false} {
+		added, err := repo.AddMatch(search.ID, quote)
+		if err != nil || added != want {
+			t.Fatalf("AddMatch() #%d = %v, %v; want %v", i+1, added, err, want)
+		}
+	}
+
+	due, err := repo.GetDueMatches(time.Now().Add(time.Second), 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("GetDueMatches() = %v, %v; want 1 match", due, err)
+	}
+	match := due[0]
+	if match.SearchID != search.ID || match.Quote.Text != "Know thyself" || match.Status != models.DeliveryPending {
+		t.Errorf("match = %+v", match)
+	}
+	if next, err := repo.NextDueAt(); err != nil || next == nil {
+		t.Errorf("NextDueAt() = %v, %v", next, err)
+	}
+
+	now := time.Now().UTC()
+	match.Status = models.DeliverySucceeded
+	match.Attempts = 1
+	match.NextAttemptAt = nil
+	match.NotifiedAt = &now
+	if err := repo.UpdateMatch(match); err != nil {
+		t.Fatalf("UpdateMatch() error = %v", err)
+	}
+	if next, _ := repo.NextDueAt(); next != nil {
+		t.Errorf("NextDueAt() with nothing pending = %v, want nil", next)
+	}
+
+	matches, err := repo.GetMatches(search.ID, 10)
+	if err != nil || len(matches) != 1 || matches[0].NotifiedAt == nil || matches[0].Quote.ID != 7 {
+		t.Fatalf("GetMatches() = %v, %v", matches, err)
+	}
+
+	if err := repo.Delete(search.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if matches, _ := repo.GetMatches(search.ID, 10); len(matches) != 0 {
+		t.Errorf("GetMatches() after Delete() = %d matches, want 0", len(matches))
+	}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"database/sql"
+	stderrors "errors"
+
+	"quote-vault/errors"
+)
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx, so repositories can work
+// inside a transaction or outside one
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// errRollback makes withTx undo fn's work without reporting a failure
+var errRollback = stderrors.New("rollback")
+
+// Tx holds repositories that work within one transaction
+type Tx struct {
+	Quotes      *QuoteRepository
+	Replication *ReplicationRepository
+	Outbox      *OutboxRepository
+}
+
+// InTx runs fn in a transaction on the repository's database, committing
+// it when fn returns nil and rolling it back otherwise. On a repository
+// that is already part of a Tx, the work nests within that transaction.
+func (r *QuoteRepository) InTx(fn func(tx *Tx) error) error {
+	return withTx(r.db, func(db dbtx) error {
+		return fn(&Tx{
+			Quotes:      &QuoteRepository{db: db},
+			Replication: &ReplicationRepository{db: db},
+			Outbox:      &OutboxRepository{db: db},
+		})
+	})
+}
+
+// withTx runs fn in a new transaction, or in a savepoint when db is already
+// a transaction, so the nested work can be undone on its own. fn's error is
+// returned unchanged.
+func withTx(db dbtx, fn func(db dbtx) error) error {
+	if tx, ok := db.(*sql.Tx); ok {
+		if _, err := tx.Exec(`SAVEPOINT nested`); err != nil {
+			return errors.NewDatabaseError("failed to begin transaction")
+		}
+		if err := fn(tx); err != nil {
+			tx.Exec(`ROLLBACK TO nested`)
+			tx.Exec(`RELEASE nested`)
+			return err
+		}
+		if _, err := tx.Exec(`RELEASE nested`); err != nil {
+			return errors.NewDatabaseError("failed to commit transaction")
+		}
+		return nil
+	}
+
+	tx, err := db.(*sql.DB).Begin()
+	if err != nil {
+		return errors.NewDatabaseError("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.NewDatabaseError("failed to commit transaction")
+	}
+	return nil
+}
+
+// getMetadata reads a value from the metadata table, reporting false when
+// the key is not set
+func getMetadata(db dbtx, key string) (string, bool, error) {
+	var value string
+	err := db.QueryRow(`SELECT value FROM metadata WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// setMetadata stores a value in the metadata table
+func setMetadata(db dbtx, key, value string) error {
+	_, err := db.Exec(`INSERT INTO metadata (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+// queueTimeFormat stores delivery times with millisecond precision, so
+// retries a fraction of a second apart keep their order. Like
+// sqliteTimeFormat it compares correctly as text.
+const queueTimeFormat = "2006-01-02 15:04:05.000"
+
+// webhookColumns lists the columns read by scanWebhook, in scan order
+const webhookColumns = `id, url, events, secret, previous_secret, previous_secret_expires_at, created_at`
+
+// deliveryColumns lists the columns read by scanDelivery, in scan order
+const deliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, response_status, last_error,
+	next_attempt_at, last_attempt_at, delivered_at, created_at`
+
+// scanWebhook reads a row selected with webhookColumns into hook
+func scanWebhook(row rowScanner, hook *models.Webhook) error {
+	var events string
+	var previousExpires sql.NullTime
+	err := row.Scan(
+		&hook.ID,
+		&hook.URL,
+		&events,
+		&hook.Secret,
+		&hook.PreviousSecret,
+		&previousExpires,
+		&hook.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	hook.Events = nil
+	if events != "" {
+		hook.Events = strings.Split(events, ",")
+	}
+	hook.PreviousSecretExpiresAt = timePtr(previousExpires)
+	return nil
+}
+
+// scanDelivery reads a row selected with deliveryColumns into delivery
+func scanDelivery(row rowScanner, delivery *models.WebhookDelivery) error {
+	var payload string
+	var next, last, delivered sql.NullTime
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.Event,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&next,
+		&last,
+		&delivered,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	delivery.Payload = []byte(payload)
+	delivery.NextAttemptAt = timePtr(next)
+	delivery.LastAttemptAt = timePtr(last)
+	delivery.DeliveredAt = timePtr(delivered)
+	return nil
+}
+
+// timePtr returns the time held by t, or nil for NULL
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+// queueTime formats t for storage, or returns nil to store NULL
+func queueTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(queueTimeFormat)
+}
+
+// WebhookRepository handles database operations for webhooks and their
+// delivery queue
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db: db,
+	}
+}
+
+// Create adds a new webhook to the database
+func (r *WebhookRepository) Create(hook *models.Webhook) (*models.Webhook, error) {
+	query := `INSERT INTO webhooks (url, events, secret, created_at) VALUES (?, ?, ?, ?)`
+
+	now := createdNow()
+	result, err := r.db.Exec(query, hook.URL, strings.Join(hook.Events, ","), hook.Secret, now.Format(sqliteTimeFormat))
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to create webhook")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get last insert id")
+	}
+	hook.ID = int(id)
+	hook.CreatedAt = now
+
+	return hook, nil
+}
+
+// GetByID retrieves a webhook by its ID
+func (r *WebhookRepository) GetByID(id int) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ?`
+
+	hook := &models.Webhook{}
+	if err := scanWebhook(r.db.QueryRow(query, id), hook); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrWebhookNotFound
+		}
+		return nil, errors.NewDatabaseError("failed to get webhook")
+	}
+
+	return hook, nil
+}
+
+// GetAll retrieves every webhook, oldest first
+func (r *WebhookRepository) GetAll() ([]*models.Webhook, error) {
+	rows, err := r.db.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get webhooks")
+	}
+	defer rows.Close()
+
+	var hooks []*models.Webhook
+	for rows.Next() {
+		hook := &models.Webhook{}
+		if err := scanWebhook(rows, hook); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan webhook")
+		}
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+// Delete removes a webhook together with its deliveries
+func (r *WebhookRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errors.NewDatabaseError("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return errors.NewDatabaseError("failed to delete webhook")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return errors.NewDatabaseError("failed to delete webhook")
+	}
+	if deleted == 0 {
+		return errors.ErrWebhookNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return errors.NewDatabaseError("failed to delete webhook deliveries")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.NewDatabaseError("failed to commit transaction")
+	}
+
+	return nil
+}
+
+// UpdateSecrets saves the current and previous signing secrets of a webhook
+func (r *WebhookRepository) UpdateSecrets(hook *models.Webhook) error {
+	query := `UPDATE webhooks SET secret = ?, previous_secret = ?, previous_secret_expires_at = ? WHERE id = ?`
+
+	result, err := r.db.Exec(query, hook.Secret, hook.PreviousSecret, queueTime(hook.PreviousSecretExpiresAt), hook.ID)
+	if err != nil {
+		return errors.NewDatabaseError("failed to update webhook")
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return errors.NewDatabaseError("failed to update webhook")
+	}
+	if updated == 0 {
+		return errors.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// CreateDelivery queues a delivery, due at its NextAttemptAt
+func (r *WebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	now := createdNow()
+	delivery.Status = models.DeliveryPending
+	result, err := r.db.Exec(query, delivery.WebhookID, delivery.EventID, delivery.Event, string(delivery.Payload),
+		delivery.Status, queueTime(delivery.NextAttemptAt), now.Format(sqliteTimeFormat))
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to queue webhook delivery")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get last insert id")
+	}
+	delivery.ID = int(id)
+	delivery.CreatedAt = now
+
+	return delivery, nil
+}
+
+// HasDelivery reports whether a delivery of the event is queued or was
+// sent to a webhook
+func (r *WebhookRepository) HasDelivery(webhookID int, eventID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE webhook_id = ? AND event_id = ?)`,
+		webhookID, eventID).Scan(&exists)
+	if err != nil {
+		return false, errors.NewDatabaseError("failed to check webhook deliveries")
+	}
+	return exists, nil
+}
+
+// GetDelivery retrieves a delivery of a webhook by its ID
+func (r *WebhookRepository) GetDelivery(webhookID, id int) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = ? AND webhook_id = ?`
+
+	delivery := &models.WebhookDelivery{}
+	if err := scanDelivery(r.db.QueryRow(query, id, webhookID), delivery); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrDeliveryNotFound
+		}
+		return nil, errors.NewDatabaseError("failed to get webhook delivery")
+	}
+
+	return delivery, nil
+}
+
+// GetDeliveries retrieves the newest deliveries of a webhook, optionally
+// only those with the given status
+func (r *WebhookRepository) GetDeliveries(webhookID int, status string, limit int) ([]*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = ?`
+	args := []interface{}{webhookID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	return r.queryDeliveries(query, args...)
+}
+
+// GetDueDeliveries retrieves pending deliveries whose next attempt is due
+// at now, the longest waiting first
+func (r *WebhookRepository) GetDueDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`
+
+	return r.queryDeliveries(query, models.DeliveryPending, now.UTC().Format(queueTimeFormat), limit)
+}
+
+// NextDueAt returns when the earliest pending delivery is due, or nil when
+// none is pending
+func (r *WebhookRepository) NextDueAt() (*time.Time, error) {
+	// MIN() loses the column type, so select the row instead
+	query := `SELECT next_attempt_at FROM webhook_deliveries WHERE status = ? ORDER BY next_attempt_at LIMIT 1`
+
+	var next sql.NullTime
+	if err := r.db.QueryRow(query, models.DeliveryPending).Scan(&next); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.NewDatabaseError("failed to get next webhook delivery")
+	}
+
+	return timePtr(next), nil
+}
+
+// UpdateDelivery saves the outcome of a delivery attempt
+func (r *WebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, last_error = ?,
+		next_attempt_at = ?, last_attempt_at = ?, delivered_at = ? WHERE id = ?`
+
+	_, err := r.db.Exec(query, delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.LastError,
+		queueTime(delivery.NextAttemptAt), queueTime(delivery.LastAttemptAt), queueTime(delivery.DeliveredAt), delivery.ID)
+	if err != nil {
+		return errors.NewDatabaseError("failed to update webhook delivery")
+	}
+
+	return nil
+}
+
+// queryDeliveries runs a query selecting deliveryColumns
+func (r *WebhookRepository) queryDeliveries(query string, args ...interface{}) ([]*models.WebhookDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get webhook deliveries")
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		if err := scanDelivery(rows, delivery); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan webhook delivery")
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+)
+
+func setupWebhookDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			url TEXT NOT NULL,
+			events TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			previous_secret TEXT NOT NULL DEFAULT '',
+			previous_secret_expires_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			event_id TEXT NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_status INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME,
+			last_attempt_at DATETIME,
+			delivered_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	return db
+}
+
+func TestWebhookRepository_CreateAndGet(t *testing.T) {
+	db := setupWebhookDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+
+	created, err := repo.Create(&models.Webhook{
+		URL:    "https://example.com/hook",
+		Events: []string{models.EventQuoteCreated, models.EventQuoteDeleted},
+		Secret: "whsec_test",
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created.ID == 0 || created.CreatedAt.IsZero() {
+		t.Errorf("Create() = %+v, want ID and CreatedAt set", created)
+	}
+
+	hook, err := repo.GetByID(created.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if hook.URL != "https://example.com/hook" || hook.Secret != "whsec_test" {
+		t.Errorf("GetByID() = %+v", hook)
+	}
+	if len(hook.Events) != 2 || !hook.Subscribes(models.EventQuoteDeleted) || hook.Subscribes(models.EventQuoteUpdated) {
+		t.Errorf("GetByID() events = %v", hook.Events)
+	}
+	if hook.PreviousSecretExpiresAt != nil {
+		t.Errorf("GetByID() PreviousSecretExpiresAt = %v, want nil", hook.PreviousSecretExpiresAt)
+	}
+
+	expires := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	hook.PreviousSecret = hook.Secret
+	hook.PreviousSecretExpiresAt = &expires
+	hook.Secret = "whsec_rotated"
+	if err := repo.UpdateSecrets(hook); err != nil {
+		t.Fatalf("UpdateSecrets() error = %v", err)
+	}
+
+	hooks, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(hooks) != 1 {
+		t.Fatalf("GetAll() returned %d webhooks, want 1", len(hooks))
+	}
+	if hooks[0].Secret != "whsec_rotated" || hooks[0].PreviousSecret != "whsec_test" ||
+		hooks[0].PreviousSecretExpiresAt == nil || !hooks[0].PreviousSecretExpiresAt.Equal(expires) {
+		t.Errorf("GetAll() secrets = %q, %q, %v", hooks[0].Secret, hooks[0].PreviousSecret, hooks[0].PreviousSecretExpiresAt)
+	}
+
+	if _, err := repo.GetByID(999); err != errors.ErrWebhookNotFound {
+		t.Errorf("GetByID(999) error = %v, want ErrWebhookNotFound", err)
+	}
+}
+
+func TestWebhookRepository_Deliveries(t *testing.T) {
+	db := setupWebhookDB(t)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+	hook, err := repo.Create(&models.Webhook{URL: "https://example.com/hook", Events: models.EventTypes, Secret: "s"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(1500 * time.Millisecond)
+	var ids []int
+	for i, due := range []time.Time{later, now, now.Add(time.Hour)} {
+		due := due
+		delivery, err := repo.CreateDelivery(&models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			EventID:       "evt_" + string(rune('a'+i)),
+			Event:         models.EventQuoteCreated,
+			Payload:       []byte(`{"id":"evt"}`),
+			NextAttemptAt: &due,
+		})
+		if err != nil {
+			t.Fatalf("CreateDelivery() error = %v", err)
+		}
+		if delivery.Status != models.DeliveryPending {
+			t.Errorf("CreateDelivery() status = %q, want pending", delivery.Status)
+		}
+		ids = append(ids, delivery.ID)
+	}
+
+	next, err := repo.NextDueAt()
+	if err != nil {
+		t.Fatalf("NextDueAt() error = %v", err)
+	}
+	if next == nil || !next.Equal(now) {
+		t.Errorf("NextDueAt() = %v, want %v", next, now)
+	}
+
+	due, err := repo.GetDueDeliveries(later, 10)
+	if err != nil {
+		t.Fatalf("GetDueDeliveries() error = %v", err)
+	}
+	if len(due) != 2 || due[0].ID != ids[1] || due[1].ID != ids[0] {
+		t.Fatalf("GetDueDeliveries() = %v, want deliveries %d and %d", due, ids[1], ids[0])
+	}
+	if string(due[0].Payload) != `{"id":"evt"}` {
+		t.Errorf("GetDueDeliveries() payload = %s", due[0].Payload)
+	}
+
+	// Record a successful attempt; the delivery leaves the queue
+	delivered := due[0]
+	delivered.Status = models.DeliverySucceeded
+	delivered.Attempts = 1
+	delivered.ResponseStatus = 204
+	delivered.LastAttemptAt = &later
+	delivered.DeliveredAt = &later
+	delivered.NextAttemptAt = nil
+	if err := repo.UpdateDelivery(delivered); err != nil {
+		t.Fatalf("UpdateDelivery() error = %v", err)
+	}
+
+	due, err = repo.GetDueDeliveries(later, 10)
+	if err != nil {
+		t.Fatalf("GetDueDeliveries() error = %v", err)
+	}
+	if len(due) != 1 || due[0].ID != ids[0] {
+		t.Errorf("GetDueDeliveries() after delivery = %v, want only %d", due, ids[0])
+	}
+
+	got, err := repo.GetDelivery(hook.ID, delivered.ID)
+	if err != nil {
+		t.Fatalf("GetDelivery() error = %v", err)
+	}
+	if got.Status != models.DeliverySucceeded || got.ResponseStatus != 204 || got.DeliveredAt == nil || !got.DeliveredAt.Equal(later) {
+		t.Errorf("GetDelivery() = %+v", got)
+	}
+	if _, err := repo.GetDelivery(hook.ID+1, delivered.ID); err != errors.ErrDeliveryNotFound {
+		t.Errorf("GetDelivery() of another webhook error = %v, want ErrDeliveryNotFound", err)
+	}
+
+	succeeded, err := repo.GetDeliveries(hook.ID, models.DeliverySucceeded, 10)
+	if err != nil {
+		t.Fatalf("GetDeliveries() error = %v", err)
+	}
+	if len(succeeded) != 1 || succeeded[0].ID != delivered.ID {
+		t.Errorf("GetDeliveries(succeeded) = %v", succeeded)
+	}
+	all, err := repo.GetDeliveries(hook.ID, "", 2)
+	if err != nil {
+		t.Fatalf("GetDeliveries() error = %v", err)
+	}
+	if len(all) != 2 || all[0].ID != ids[2] {
+		t.Errorf("GetDeliveries() = %v, want the 2 newest", all)
+	}
+
+	if err := repo.Delete(hook.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if next, err := repo.NextDueAt(); err != nil || next != nil {
+		t.Errorf("NextDueAt() after Delete = %v, %v, want no pending deliveries", next, err)
+	}
+	if err := repo.Delete(hook.ID); err != errors.ErrWebhookNotFound {
+		t.Errorf("Delete() again error = %v, want ErrWebhookNotFound", err)
+	}
+}
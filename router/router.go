@@ -8,8 +8,31 @@ import (
 	"quote-vault/middleware"
 )
 
+// Handlers groups the HTTP handlers served by the router. Quote and Health
+// are required; optional handlers may be left nil, in which case their
+// routes are not registered.
+type Handlers struct {
+	Quote       *handlers.QuoteHandler
+	Health      *handlers.HealthHandler
+	Import      *handlers.ImportHandler
+	Export      *handlers.ExportHandler
+	Card        *handlers.CardHandler
+	Feed        *handlers.FeedHandler
+	Embed       *handlers.EmbedHandler
+	Page        *handlers.PageHandler
+	UI          *handlers.UIHandler
+	Webhook     *handlers.WebhookHandler
+	Events      *handlers.EventsHandler
+	Changes     *handlers.ChangesHandler
+	Slack       *handlers.SlackHandler
+	Discord     *handlers.DiscordHandler
+	ActivityPub *handlers.ActivityPubHandler
+	Digest      *handlers.DigestHandler
+	SavedSearch *handlers.SavedSearchHandler
+}
+
 // NewRouter creates and configures the main router
-func NewRouter(quoteHandler *handlers.QuoteHandler, healthHandler *handlers.HealthHandler) *mux.Router {
+func NewRouter(h Handlers) *mux.Router {
 	r := mux.NewRouter()
 
 	// Apply global middleware
@@ -21,31 +44,152 @@ func NewRouter(quoteHandler *handlers.QuoteHandler, healthHandler *handlers.Heal
 	r.Use(middleware.ErrorHandler)
 
 	// Health check endpoints
-	r.HandleFunc("/health", healthHandler.Health).Methods("GET")
-	r.HandleFunc("/health/ready", healthHandler.Ready).Methods("GET")
+	r.HandleFunc("/health", h.Health.Health).Methods("GET")
+	r.HandleFunc("/health/ready", h.Health.Ready).Methods("GET")
+
+	// Syndication feeds
+	if h.Feed != nil {
+		r.HandleFunc("/feeds/quotes.{format:atom|rss}", h.Feed.Quotes).Methods("GET")
+		r.HandleFunc("/feeds/daily.{format:atom|rss}", h.Feed.Daily).Methods("GET")
+		r.HandleFunc("/feeds/daily.ics", h.Feed.DailyCalendar).Methods("GET")
+		r.HandleFunc("/feeds/categories/{category}.{format:atom|rss}", h.Feed.Category).Methods("GET")
+		r.HandleFunc("/feeds/authors/{author}.{format:atom|rss}", h.Feed.Author).Methods("GET")
+	}
+
+	// Embedding
+	if h.Embed != nil {
+		r.HandleFunc("/embed/widget.js", h.Embed.Widget).Methods("GET")
+		r.HandleFunc("/oembed", h.Embed.OEmbed).Methods("GET")
+	}
+
+	// Human-facing pages
+	if h.Page != nil {
+		r.HandleFunc("/q/random", h.Page.Random).Methods("GET")
+		r.HandleFunc("/q/{id:[0-9]+}", h.Page.Quote).Methods("GET")
+		r.PathPrefix("/static/").HandlerFunc(h.Page.Static).Methods("GET")
+	}
+
+	// Web UI for browsing and curating quotes
+	if h.UI != nil {
+		r.Handle("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently)).Methods("GET")
+		r.HandleFunc("/ui/", h.UI.Browse).Methods("GET")
+		r.HandleFunc("/ui/authors", h.UI.Authors).Methods("GET")
+		r.HandleFunc("/ui/login", h.UI.Login).Methods("GET")
+		r.HandleFunc("/ui/login", h.UI.SignIn).Methods("POST")
+		r.HandleFunc("/ui/logout", h.UI.SignOut).Methods("POST")
+		r.HandleFunc("/ui/quotes/new", h.UI.NewQuote).Methods("GET")
+		r.HandleFunc("/ui/quotes", h.UI.CreateQuote).Methods("POST")
+		r.HandleFunc("/ui/quotes/{id:[0-9]+}/edit", h.UI.EditQuote).Methods("GET")
+		r.HandleFunc("/ui/quotes/{id:[0-9]+}", h.UI.UpdateQuote).Methods("POST")
+	}
+
+	// Chat integrations
+	if h.Slack != nil {
+		r.HandleFunc("/integrations/slack/command", h.Slack.Command).Methods("POST")
+	}
+	if h.Discord != nil {
+		r.HandleFunc("/integrations/discord/interactions", h.Discord.Interactions).Methods("POST")
+	}
+
+	// ActivityPub actors for the vault and each category, discovered
+	// through WebFinger
+	if h.ActivityPub != nil {
+		r.HandleFunc("/.well-known/webfinger", h.ActivityPub.WebFinger).Methods("GET")
+		r.HandleFunc("/ap/actors/{name}", h.ActivityPub.Actor).Methods("GET")
+		r.HandleFunc("/ap/actors/{name}/inbox", h.ActivityPub.Inbox).Methods("POST")
+		r.HandleFunc("/ap/actors/{name}/outbox", h.ActivityPub.Outbox).Methods("GET")
+		r.HandleFunc("/ap/actors/{name}/followers", h.ActivityPub.Followers).Methods("GET")
+		r.HandleFunc("/ap/actors/{name}/notes/{id:[0-9]+}", h.ActivityPub.Note).Methods("GET")
+	}
+
+	// Pages that links in emails lead to
+	if h.Digest != nil {
+		r.HandleFunc("/digests/confirm", h.Digest.Confirm).Methods("GET")
+		r.HandleFunc("/digests/unsubscribe", h.Digest.Unsubscribe).Methods("GET", "POST")
+	}
+
+	if h.SavedSearch != nil {
+		r.HandleFunc("/searches/confirm", h.SavedSearch.Confirm).Methods("GET")
+		r.HandleFunc("/searches/unsubscribe", h.SavedSearch.Unsubscribe).Methods("GET", "POST")
+	}
 
 	// API routes
 	api := r.PathPrefix("/api/v1").Subrouter()
 
 	// Quote routes
-	api.HandleFunc("/quotes", quoteHandler.CreateQuote).Methods("POST")
-	api.HandleFunc("/quotes", quoteHandler.GetQuotes).Methods("GET")
-	api.HandleFunc("/quotes/random", quoteHandler.GetRandomQuote).Methods("GET")
-	api.HandleFunc("/quotes/random/{category}", quoteHandler.GetRandomQuoteByCategory).Methods("GET")
-	api.HandleFunc("/quotes/{id:[0-9]+}", quoteHandler.GetQuote).Methods("GET")
-	api.HandleFunc("/quotes/{id:[0-9]+}", quoteHandler.UpdateQuote).Methods("PUT")
-	api.HandleFunc("/quotes/{id:[0-9]+}", quoteHandler.DeleteQuote).Methods("DELETE")
+	api.HandleFunc("/quotes", h.Quote.CreateQuote).Methods("POST")
+	api.HandleFunc("/quotes", h.Quote.GetQuotes).Methods("GET")
+	api.HandleFunc("/quotes/random", h.Quote.GetRandomQuote).Methods("GET")
+	api.HandleFunc("/quotes/daily", h.Quote.GetDailyQuote).Methods("GET")
+
+	// Quote card routes, registered ahead of /quotes/random/{category}
+	if h.Card != nil {
+		api.HandleFunc("/quotes/random/card.svg", h.Card.GetRandomQuoteCard).Methods("GET")
+		api.HandleFunc("/quotes/random/card.png", h.Card.GetRandomQuotePNG).Methods("GET")
+		api.HandleFunc("/quotes/{id:[0-9]+}/card.svg", h.Card.GetQuoteCard).Methods("GET")
+		api.HandleFunc("/quotes/{id:[0-9]+}/card.png", h.Card.GetQuotePNG).Methods("GET")
+	}
+
+	api.HandleFunc("/quotes/random/{category}", h.Quote.GetRandomQuoteByCategory).Methods("GET")
+	api.HandleFunc("/quotes/{id:[0-9]+}", h.Quote.GetQuote).Methods("GET")
+	api.HandleFunc("/quotes/{id:[0-9]+}", h.Quote.UpdateQuote).Methods("PUT")
+	api.HandleFunc("/quotes/{id:[0-9]+}", h.Quote.DeleteQuote).Methods("DELETE")
 
 	// Category routes
-	api.HandleFunc("/categories", quoteHandler.GetCategories).Methods("GET")
+	api.HandleFunc("/categories", h.Quote.GetCategories).Methods("GET")
+
+	// Bulk import routes
+	if h.Import != nil {
+		api.HandleFunc("/import", h.Import.Import).Methods("POST")
+	}
+
+	// Bulk export routes
+	if h.Export != nil {
+		api.HandleFunc("/export", h.Export.Export).Methods("GET")
+	}
+
+	// Event stream
+	if h.Events != nil {
+		api.HandleFunc("/events", h.Events.Stream).Methods("GET")
+	}
+
+	// Changes feed for offline sync
+	if h.Changes != nil {
+		api.HandleFunc("/changes", h.Changes.GetChanges).Methods("GET")
+	}
+
+	// Webhook routes
+	if h.Webhook != nil {
+		api.HandleFunc("/webhooks", h.Webhook.CreateWebhook).Methods("POST")
+		api.HandleFunc("/webhooks", h.Webhook.GetWebhooks).Methods("GET")
+		api.HandleFunc("/webhooks/{id:[0-9]+}", h.Webhook.GetWebhook).Methods("GET")
+		api.HandleFunc("/webhooks/{id:[0-9]+}", h.Webhook.DeleteWebhook).Methods("DELETE")
+		api.HandleFunc("/webhooks/{id:[0-9]+}/rotate-secret", h.Webhook.RotateSecret).Methods("POST")
+		api.HandleFunc("/webhooks/{id:[0-9]+}/deliveries", h.Webhook.GetDeliveries).Methods("GET")
+		api.HandleFunc("/webhooks/{id:[0-9]+}/deliveries/{delivery_id:[0-9]+}/replay", h.Webhook.ReplayDelivery).Methods("POST")
+	}
+
+	// Email digest sign-up
+	if h.Digest != nil {
+		api.HandleFunc("/digests", h.Digest.Subscribe).Methods("POST")
+	}
+
+	// Saved searches notified of new matching quotes
+	if h.SavedSearch != nil {
+		api.HandleFunc("/searches", h.SavedSearch.CreateSearch).Methods("POST")
+		api.HandleFunc("/searches", h.SavedSearch.GetSearches).Methods("GET")
+		api.HandleFunc("/searches/{id:[0-9]+}/matches", h.SavedSearch.GetMatches).Methods("GET")
+	}
 
-	// Set content type for API routes
+	// API responses default to JSON; quote endpoints negotiate other
+	// formats from the Accept header and set their own content type
 	api.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
+			w.Header().Add("Vary", "Accept")
 			next.ServeHTTP(w, r)
 		})
 	})
 
 	return r
-}
\ No newline at end of file
+}
@@ -0,0 +1,711 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"quote-vault/activitypub"
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// ActivityPub delivery defaults
+const (
+	DefaultActivityPubMaxAttempts  = 8
+	DefaultActivityPubBaseDelay    = 30 * time.Second
+	DefaultActivityPubMaxDelay     = time.Hour
+	DefaultActivityPubPollInterval = time.Minute
+	DefaultActivityPubTimeout      = 10 * time.Second
+)
+
+// VaultActor is the name of the actor publishing every quote. Each
+// category is published by an actor of its own as well.
+const VaultActor = "quotes"
+
+// ActivityPubPageSize is the number of quotes on a page of an outbox
+const ActivityPubPageSize = 20
+
+const (
+	// activityPubBatchSize is the number of due activities sent at the
+	// same time
+	activityPubBatchSize = 16
+	// remoteActorLimit caps the size of a fetched actor document
+	remoteActorLimit = 1 << 20
+)
+
+// ActivityPubOptions controls the actors' URLs and how activities are sent
+// and retried. An activity is dropped once it runs out of attempts.
+type ActivityPubOptions struct {
+	// BaseURL is the public URL of the service. It is required: actor and
+	// note IDs must stay the same however the service is reached.
+	BaseURL string
+	RetryOptions
+	// Client fetches remote actors and sends activities; by default one
+	// with a 10 second timeout that does not follow redirects
+	Client *http.Client
+}
+
+// ActivityPubService publishes the vault and each of its categories as
+// ActivityPub actors. Remote accounts follow them through their inboxes,
+// and new, changed and deleted quotes are sent to the followers from a
+// persistent queue.
+type ActivityPubService struct {
+	repo   *repository.ActivityPubRepository
+	quotes *repository.QuoteRepository
+	opts   ActivityPubOptions
+	base   string
+	host   string
+	key    *rsa.PrivateKey
+	// publicKey is the PEM form of the public half of key
+	publicKey string
+	client    *http.Client
+	worker    *deliveryWorker
+}
+
+// NewActivityPubService creates an ActivityPub service, filling unset
+// options with their defaults. The key signing the actors' requests is
+// generated on first use and kept in the database. Activities are only
+// sent while Run is running.
+func NewActivityPubService(repo *repository.ActivityPubRepository, quotes *repository.QuoteRepository, opts ActivityPubOptions) (*ActivityPubService, error) {
+	base, err := url.Parse(strings.TrimRight(opts.BaseURL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("ActivityPub needs an absolute http or https base URL, got %q", opts.BaseURL)
+	}
+	opts.RetryOptions = opts.withDefaults(RetryOptions{
+		MaxAttempts:  DefaultActivityPubMaxAttempts,
+		BaseDelay:    DefaultActivityPubBaseDelay,
+		MaxDelay:     DefaultActivityPubMaxDelay,
+		PollInterval: DefaultActivityPubPollInterval,
+	})
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout: DefaultActivityPubTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	key, err := loadActivityPubKey(repo)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := activitypub.EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ActivityPubService{
+		repo:      repo,
+		quotes:    quotes,
+		opts:      opts,
+		base:      base.String(),
+		host:      base.Host,
+		key:       key,
+		publicKey: publicKey,
+		client:    client,
+	}
+	s.worker = newDeliveryWorker("ActivityPub", opts.RetryOptions, s.deliverDue, repo.NextDueAt)
+	return s, nil
+}
+
+// loadActivityPubKey returns the stored signing key, generating and saving
+// one when there is none yet
+func loadActivityPubKey(repo *repository.ActivityPubRepository) (*rsa.PrivateKey, error) {
+	stored, ok, err := repo.PrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		key, err := activitypub.DecodePrivateKey(stored)
+		if err != nil {
+			return nil, fmt.Errorf("stored ActivityPub key is invalid: %v", err)
+		}
+		return key, nil
+	}
+
+	key, err := activitypub.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.SavePrivateKey(activitypub.EncodePrivateKey(key)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// CategoryActorName returns the name of the actor publishing a category:
+// the category with spaces replaced by underscores. Case is kept, since
+// categories differing only in case are distinct.
+func CategoryActorName(category string) string {
+	return strings.ReplaceAll(category, " ", "_")
+}
+
+// resolveActor returns the category published by the named actor, or ""
+// for the vault actor. The vault actor wins over a category of the same
+// name.
+func (s *ActivityPubService) resolveActor(name string) (string, error) {
+	if name == VaultActor {
+		return "", nil
+	}
+
+	categories, err := s.quotes.GetCategories()
+	if err != nil {
+		return "", err
+	}
+	for _, category := range categories {
+		if CategoryActorName(category) == name {
+			return category, nil
+		}
+	}
+	return "", errors.ErrActorNotFound
+}
+
+// actorURL returns the ID of the named actor
+func (s *ActivityPubService) actorURL(name string) string {
+	return s.base + "/ap/actors/" + url.PathEscape(name)
+}
+
+// noteURL returns the ID of the note the named actor publishes a quote as
+func (s *ActivityPubService) noteURL(name string, id int) string {
+	return fmt.Sprintf("%s/notes/%d", s.actorURL(name), id)
+}
+
+// profileURL returns the web page listing the quotes of an actor
+func (s *ActivityPubService) profileURL(category string) string {
+	if category == "" {
+		return s.base + "/ui/"
+	}
+	return s.base + "/ui/?category=" + url.QueryEscape(category)
+}
+
+// Actor returns the actor document of the named actor
+func (s *ActivityPubService) Actor(name string) (*activitypub.Actor, error) {
+	category, err := s.resolveActor(name)
+	if err != nil {
+		return nil, err
+	}
+
+	id := s.actorURL(name)
+	actor := &activitypub.Actor{
+		Context:           []string{activitypub.ActivityStreamsContext, activitypub.SecurityContext},
+		ID:                id,
+		Type:              "Service",
+		PreferredUsername: name,
+		Name:              "Quote Vault",
+		Summary:           "Every quote added to the vault",
+		URL:               s.profileURL(category),
+		Inbox:             id + "/inbox",
+		Outbox:            id + "/outbox",
+		Followers:         id + "/followers",
+		PublicKey: &activitypub.PublicKey{
+			ID:           id + "#main-key",
+			Owner:        id,
+			PublicKeyPem: s.publicKey,
+		},
+	}
+	if category != "" {
+		actor.Name = "Quote Vault: " + category
+		actor.Summary = "Quotes added to the " + category + " category"
+	}
+	return actor, nil
+}
+
+// WebFinger describes the actor named by an account resource such as
+// acct:quotes@example.com
+func (s *ActivityPubService) WebFinger(resource string) (*activitypub.JRD, error) {
+	user, host, err := activitypub.ParseAccount(resource)
+	if err != nil {
+		return nil, errors.NewValidationError("Invalid resource", err.Error())
+	}
+	if !strings.EqualFold(host, s.host) {
+		return nil, errors.ErrActorNotFound
+	}
+
+	category, err := s.resolveActor(user)
+	if err != nil {
+		return nil, err
+	}
+	return activitypub.NewJRD(user, s.host, s.actorURL(user), s.profileURL(category)), nil
+}
+
+// Outbox returns the outbox of the named actor: a collection of the
+// activities creating its quotes' notes, newest first. Page 0 is the
+// collection itself and pages from 1 hold ActivityPubPageSize activities
+// each.
+func (s *ActivityPubService) Outbox(name string, page int) (interface{}, error) {
+	if page < 0 {
+		return nil, errors.ErrInvalidPagination
+	}
+	category, err := s.resolveActor(name)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := models.QuoteFilter{Category: category}
+	id := s.actorURL(name) + "/outbox"
+	if page == 0 {
+		_, total, err := s.quotes.Search(filter, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		collection := &activitypub.OrderedCollection{
+			Context:    activitypub.ActivityStreamsContext,
+			ID:         id,
+			Type:       "OrderedCollection",
+			TotalItems: total,
+		}
+		if total > 0 {
+			collection.First = id + "?page=1"
+			collection.Last = fmt.Sprintf("%s?page=%d", id, (total+ActivityPubPageSize-1)/ActivityPubPageSize)
+		}
+		return collection, nil
+	}
+
+	quotes, total, err := s.quotes.Search(filter, ActivityPubPageSize, (page-1)*ActivityPubPageSize)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, len(quotes))
+	for i, quote := range quotes {
+		items[i] = s.createActivity(name, quote)
+	}
+
+	result := &activitypub.OrderedCollectionPage{
+		Context:      activitypub.ActivityStreamsContext,
+		ID:           fmt.Sprintf("%s?page=%d", id, page),
+		Type:         "OrderedCollectionPage",
+		PartOf:       id,
+		TotalItems:   total,
+		OrderedItems: items,
+	}
+	if page*ActivityPubPageSize < total {
+		result.Next = fmt.Sprintf("%s?page=%d", id, page+1)
+	}
+	if page > 1 {
+		result.Prev = fmt.Sprintf("%s?page=%d", id, page-1)
+	}
+	return result, nil
+}
+
+// Followers returns the follower collection of the named actor. Only the
+// number of followers is published, not who they are.
+func (s *ActivityPubService) Followers(name string) (*activitypub.OrderedCollection, error) {
+	if _, err := s.resolveActor(name); err != nil {
+		return nil, err
+	}
+
+	count, err := s.repo.CountFollowers(name)
+	if err != nil {
+		return nil, err
+	}
+	return &activitypub.OrderedCollection{
+		Context:    activitypub.ActivityStreamsContext,
+		ID:         s.actorURL(name) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	}, nil
+}
+
+// Note returns the note the named actor publishes a quote as
+func (s *ActivityPubService) Note(name string, id int) (*activitypub.Note, error) {
+	category, err := s.resolveActor(name)
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := s.quotes.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if category != "" && quote.Category != category {
+		return nil, errors.ErrQuoteNotFound
+	}
+
+	note := s.note(name, quote)
+	note.Context = activitypub.ActivityStreamsContext
+	return note, nil
+}
+
+// note returns the note the named actor publishes a quote as
+func (s *ActivityPubService) note(name string, quote *models.Quote) *activitypub.Note {
+	actor := s.actorURL(name)
+	return activitypub.NewNote(quote, s.noteURL(name, quote.ID), actor, actor+"/followers",
+		fmt.Sprintf("%s/q/%d", s.base, quote.ID))
+}
+
+// createActivity returns the activity creating the note of a quote, as
+// listed in the outbox and sent to followers
+func (s *ActivityPubService) createActivity(name string, quote *models.Quote) *activitypub.Activity {
+	note := s.note(name, quote)
+	return &activitypub.Activity{
+		ID:        note.ID + "/activity",
+		Type:      activitypub.TypeCreate,
+		Actor:     note.AttributedTo,
+		Object:    note,
+		Published: note.Published,
+		To:        note.To,
+		Cc:        note.Cc,
+	}
+}
+
+// HandleInbox handles an activity posted to the inbox of the named actor.
+// The request must be signed by the actor that sent the activity, whose
+// key is fetched from its actor document. Follow adds a follower and
+// answers with an Accept, Undo of a Follow removes it, and anything else
+// is accepted and ignored.
+func (s *ActivityPubService) HandleInbox(ctx context.Context, name string, r *http.Request, body []byte) error {
+	if _, err := s.resolveActor(name); err != nil {
+		return err
+	}
+
+	remote, err := s.verifySender(ctx, r, body)
+	if err != nil {
+		return err
+	}
+
+	var activity activitypub.IncomingActivity
+	if err := json.Unmarshal(body, &activity); err != nil || activity.Type == "" {
+		return errors.NewValidationError("Invalid activity", "body must be an ActivityStreams activity")
+	}
+	if activity.Actor != remote.ID {
+		return errors.NewUnauthorizedError("Invalid request signature", "activity actor does not own the signing key")
+	}
+
+	switch activity.Type {
+	case activitypub.TypeFollow:
+		return s.follow(name, remote, &activity)
+	case activitypub.TypeUndo:
+		inner, err := activity.Embedded()
+		if err != nil || inner.Type != activitypub.TypeFollow || inner.Actor != remote.ID {
+			return nil
+		}
+		_, err = s.repo.RemoveFollower(name, remote.ID)
+		return err
+	default:
+		return nil
+	}
+}
+
+// verifySender checks the HTTP signature of an inbox request against the
+// key of the actor named in it, and returns that actor
+func (s *ActivityPubService) verifySender(ctx context.Context, r *http.Request, body []byte) (*activitypub.Actor, error) {
+	sig, err := activitypub.ParseSignature(r)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid request signature", err.Error())
+	}
+
+	keyOwner, _, _ := strings.Cut(sig.KeyID, "#")
+	remote, err := s.fetchActor(ctx, keyOwner)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid request signature", "failed to fetch signing key: "+err.Error())
+	}
+	if remote.PublicKey == nil || remote.PublicKey.ID != sig.KeyID || remote.PublicKey.Owner != remote.ID {
+		return nil, errors.NewUnauthorizedError("Invalid request signature", "signing key is not published by its actor")
+	}
+	key, err := activitypub.DecodePublicKey(remote.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid request signature", err.Error())
+	}
+
+	if err := activitypub.VerifyRequest(r, sig, body, key, time.Now()); err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid request signature", err.Error())
+	}
+	return remote, nil
+}
+
+// fetchActor retrieves a remote actor document. The request is signed by
+// the vault actor, as servers requiring signed fetches expect.
+func (s *ActivityPubService) fetchActor(ctx context.Context, id string) (*activitypub.Actor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", activitypub.ContentType)
+	req.Header.Set("User-Agent", "quote-vault-activitypub")
+	if err := activitypub.SignRequest(req, s.actorURL(VaultActor)+"#main-key", s.key, nil, time.Now()); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+
+	var actor activitypub.Actor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, remoteActorLimit)).Decode(&actor); err != nil {
+		return nil, fmt.Errorf("invalid actor document: %v", err)
+	}
+	if actor.ID != id || actor.Inbox == "" {
+		return nil, fmt.Errorf("invalid actor document")
+	}
+	return &actor, nil
+}
+
+// follow adds the sender of a Follow as a follower and queues the Accept
+func (s *ActivityPubService) follow(name string, remote *activitypub.Actor, follow *activitypub.IncomingActivity) error {
+	actor := s.actorURL(name)
+	if follow.ID == "" || follow.ObjectID() != actor {
+		return errors.NewValidationError("Invalid activity", "Follow must have an ID and name this actor as its object")
+	}
+
+	err := s.repo.AddFollower(&models.Follower{
+		Actor:    name,
+		Follower: remote.ID,
+		Inbox:    remote.DeliveryInbox(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The Accept ID is derived from the Follow, so a repeated Follow is
+	// accepted once
+	sum := sha256.Sum256([]byte(follow.ID))
+	accept := &activitypub.Activity{
+		Context: activitypub.ActivityStreamsContext,
+		ID:      actor + "/activities/accept-" + hex.EncodeToString(sum[:8]),
+		Type:    activitypub.TypeAccept,
+		Actor:   actor,
+		Object: &activitypub.Activity{
+			ID:     follow.ID,
+			Type:   follow.Type,
+			Actor:  follow.Actor,
+			Object: actor,
+		},
+	}
+	return s.queue(name, accept, []string{remote.Inbox})
+}
+
+// Handle sends quote events to the followers of the vault actor and of
+// the actor of the quote's category: a Create for a new quote, an Update
+// for a changed one and a Delete for a removed one. A quote moved to
+// another category is also deleted for the followers of the actor of its
+// previous category. It implements Subscriber; activity IDs are derived
+// from the event, so handling it again queues nothing new.
+func (s *ActivityPubService) Handle(ctx context.Context, event *models.Event) error {
+	quote := event.Data.Quote
+	if quote == nil {
+		return nil
+	}
+
+	names := []string{VaultActor}
+	if name := CategoryActorName(quote.Category); name != VaultActor {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		activity := s.eventActivity(name, event)
+		if activity == nil {
+			continue
+		}
+		if err := s.publish(name, activity); err != nil {
+			return err
+		}
+	}
+
+	previous := event.Data.PreviousCategory
+	if event.Type != models.EventQuoteUpdated || previous == "" || previous == quote.Category {
+		return nil
+	}
+	if name := CategoryActorName(previous); name != VaultActor {
+		return s.publish(name, s.deleteActivity(name, event))
+	}
+	return nil
+}
+
+// publish queues an activity of the named actor for the inboxes of its
+// followers
+func (s *ActivityPubService) publish(name string, activity *activitypub.Activity) error {
+	followers, err := s.repo.GetFollowers(name)
+	if err != nil {
+		return err
+	}
+	if len(followers) == 0 {
+		return nil
+	}
+
+	inboxes := make([]string, 0, len(followers))
+	for _, follower := range followers {
+		if !contains(inboxes, follower.Inbox) {
+			inboxes = append(inboxes, follower.Inbox)
+		}
+	}
+	return s.queue(name, activity, inboxes)
+}
+
+// eventActivity returns the activity the named actor sends for a quote
+// event, or nil for events it does not send
+func (s *ActivityPubService) eventActivity(name string, event *models.Event) *activitypub.Activity {
+	switch event.Type {
+	case models.EventQuoteCreated:
+		return s.addressed(name, event, s.createActivity(name, event.Data.Quote))
+	case models.EventQuoteUpdated:
+		note := s.note(name, event.Data.Quote)
+		note.Updated = event.CreatedAt.UTC().Format(time.RFC3339)
+		return s.addressed(name, event, &activitypub.Activity{
+			Type:   activitypub.TypeUpdate,
+			Object: note,
+		})
+	case models.EventQuoteDeleted:
+		return s.deleteActivity(name, event)
+	default:
+		return nil
+	}
+}
+
+// deleteActivity returns the activity deleting the named actor's note of
+// the quote of an event
+func (s *ActivityPubService) deleteActivity(name string, event *models.Event) *activitypub.Activity {
+	return s.addressed(name, event, &activitypub.Activity{
+		Type:   activitypub.TypeDelete,
+		Object: activitypub.NewTombstone(s.noteURL(name, event.Data.Quote.ID)),
+	})
+}
+
+// addressed completes an activity the named actor sends for an event with
+// its context, actor and audience, and an ID derived from the event unless
+// it has one
+func (s *ActivityPubService) addressed(name string, event *models.Event, activity *activitypub.Activity) *activitypub.Activity {
+	actor := s.actorURL(name)
+	activity.Context = activitypub.ActivityStreamsContext
+	activity.Actor = actor
+	if activity.ID == "" {
+		activity.ID = actor + "/activities/" + event.ID
+	}
+	activity.To = []string{activitypub.Public}
+	activity.Cc = []string{actor + "/followers"}
+	return activity
+}
+
+// queue adds an activity of the named actor to the delivery queue of each
+// inbox and wakes the worker
+func (s *ActivityPubService) queue(name string, activity *activitypub.Activity, inboxes []string) error {
+	payload, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s activity %s: %v", activity.Type, activity.ID, err)
+	}
+
+	now := time.Now().UTC()
+	queued := false
+	for _, inbox := range inboxes {
+		added, err := s.repo.QueueDelivery(&models.ActivityDelivery{
+			Actor:         name,
+			Inbox:         inbox,
+			ActivityID:    activity.ID,
+			Payload:       payload,
+			NextAttemptAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		queued = queued || added
+	}
+
+	if queued {
+		s.worker.notify()
+	}
+	return nil
+}
+
+// Run sends queued activities until ctx is cancelled. Activities still
+// queued when the process stops are sent by the next Run.
+func (s *ActivityPubService) Run(ctx context.Context) {
+	s.worker.run(ctx)
+}
+
+// deliverDue sends one batch of due activities and reports whether a full
+// batch was found, in which case more may be waiting
+func (s *ActivityPubService) deliverDue(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	deliveries, err := s.repo.GetDueDeliveries(time.Now(), activityPubBatchSize)
+	if err != nil {
+		log.Printf("Failed to read ActivityPub queue: %v", err)
+		return false
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery *models.ActivityDelivery) {
+			defer wg.Done()
+			s.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(deliveries) == activityPubBatchSize
+}
+
+// deliver makes one attempt at posting an activity. A delivered activity
+// leaves the queue; a failed one is retried later, and dropped once it
+// runs out of attempts.
+func (s *ActivityPubService) deliver(ctx context.Context, delivery *models.ActivityDelivery) {
+	err := s.send(ctx, delivery)
+	if ctx.Err() != nil {
+		// Shutting down; the activity stays due for the next run
+		return
+	}
+
+	delivery.Attempts++
+	switch {
+	case err == nil:
+		err = s.repo.DeleteDelivery(delivery.ID)
+	case delivery.Attempts >= s.opts.MaxAttempts:
+		log.Printf("Dropping activity %s for %s after %d attempts: %v", delivery.ActivityID, delivery.Inbox, delivery.Attempts, err)
+		err = s.repo.DeleteDelivery(delivery.ID)
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Now().UTC().Add(s.opts.backoff(delivery.Attempts))
+		err = s.repo.RescheduleDelivery(delivery)
+	}
+	if err != nil {
+		log.Printf("Failed to record ActivityPub delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send posts an activity to its inbox, signed with the key of the actor
+// sending it. Any status outside 2xx is an error.
+func (s *ActivityPubService) send(ctx context.Context, delivery *models.ActivityDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Inbox, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", activitypub.ContentType)
+	req.Header.Set("User-Agent", "quote-vault-activitypub")
+	if err := activitypub.SignRequest(req, s.actorURL(delivery.Actor)+"#main-key", s.key, delivery.Payload, time.Now()); err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
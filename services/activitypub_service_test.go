@@ -0,0 +1,394 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"quote-vault/activitypub"
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+const testActivityPubBase = "https://quotes.example"
+
+// remoteServer stands in for another fediverse server hosting one account.
+// It serves the account's actor document and records the activities
+// posted to its inbox, checking their signatures against the vault's key.
+type remoteServer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	vaultKey *rsa.PublicKey
+
+	mu         sync.Mutex
+	activities []map[string]interface{}
+	rejected   int
+}
+
+func newRemoteServer(t *testing.T) *remoteServer {
+	key, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	remote := &remoteServer{key: key}
+	remote.Server = httptest.NewServer(remote)
+	t.Cleanup(remote.Close)
+	return remote
+}
+
+func (rs *remoteServer) actorID() string {
+	return rs.URL + "/users/ann"
+}
+
+func (rs *remoteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/users/ann":
+		pem, _ := activitypub.EncodePublicKey(&rs.key.PublicKey)
+		w.Header().Set("Content-Type", activitypub.ContentType)
+		json.NewEncoder(w).Encode(&activitypub.Actor{
+			ID:    rs.actorID(),
+			Type:  "Person",
+			Inbox: rs.actorID() + "/inbox",
+			PublicKey: &activitypub.PublicKey{
+				ID:           rs.actorID() + "#main-key",
+				Owner:        rs.actorID(),
+				PublicKeyPem: pem,
+			},
+		})
+	case r.Method == http.MethodPost && r.URL.Path == "/users/ann/inbox":
+		body, _ := io.ReadAll(r.Body)
+		sig, err := activitypub.ParseSignature(r)
+		if err == nil {
+			err = activitypub.VerifyRequest(r, sig, body, rs.vaultKey, time.Now())
+		}
+
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		if err != nil {
+			rs.rejected++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var activity map[string]interface{}
+		json.Unmarshal(body, &activity)
+		rs.activities = append(rs.activities, activity)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// waitForActivities waits until the inbox has received n activities and
+// returns them
+func (rs *remoteServer) waitForActivities(t *testing.T, n int) []map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rs.mu.Lock()
+		got := append([]map[string]interface{}(nil), rs.activities...)
+		rejected := rs.rejected
+		rs.mu.Unlock()
+		if rejected > 0 {
+			t.Fatalf("remote inbox rejected %d badly signed deliveries", rejected)
+		}
+		if len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("remote inbox received %d activities, want %d", len(got), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// post sends an activity from the remote account to an inbox of the
+// service, signed with the given key
+func (rs *remoteServer) post(service *ActivityPubService, name string, activity interface{}, key *rsa.PrivateKey) error {
+	body, _ := json.Marshal(activity)
+	req := httptest.NewRequest(http.MethodPost, testActivityPubBase+"/ap/actors/"+name+"/inbox", bytes.NewReader(body))
+	if err := activitypub.SignRequest(req, rs.actorID()+"#main-key", key, body, time.Now()); err != nil {
+		return err
+	}
+	return service.HandleInbox(context.Background(), name, req, body)
+}
+
+// startActivityPubService runs an ActivityPub service with short retry
+// delays until the test ends
+func startActivityPubService(t *testing.T, repo *repository.ActivityPubRepository, quotes *repository.QuoteRepository) *ActivityPubService {
+	service, err := NewActivityPubService(repo, quotes, ActivityPubOptions{
+		BaseURL: testActivityPubBase,
+		RetryOptions: RetryOptions{
+			BaseDelay:    10 * time.Millisecond,
+			MaxDelay:     40 * time.Millisecond,
+			PollInterval: 50 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewActivityPubService() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return service
+}
+
+func TestActivityPubService_FollowAndDeliver(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	quoteRepo := repository.NewQuoteRepository(db)
+	repo := repository.NewActivityPubRepository(db)
+	service := startActivityPubService(t, repo, quoteRepo)
+	remote := newRemoteServer(t)
+	actor, err := service.Actor(VaultActor)
+	if err != nil {
+		t.Fatalf("Actor() error = %v", err)
+	}
+	remote.vaultKey, _ = activitypub.DecodePublicKey(actor.PublicKey.PublicKeyPem)
+
+	follow := &activitypub.Activity{
+		ID:     remote.actorID() + "/follows/1",
+		Type:   activitypub.TypeFollow,
+		Actor:  remote.actorID(),
+		Object: actor.ID,
+	}
+	if err := remote.post(service, "quotes", follow, remote.key); err != nil {
+		t.Fatalf("HandleInbox(Follow) error = %v", err)
+	}
+
+	activities := remote.waitForActivities(t, 1)
+	accept := activities[0]
+	object, _ := accept["object"].(map[string]interface{})
+	if accept["type"] != "Accept" || accept["actor"] != actor.ID || object["id"] != follow.ID {
+		t.Errorf("inbox received %v, want an Accept of the Follow", accept)
+	}
+	if followers, err := service.Followers("quotes"); err != nil || followers.TotalItems != 1 {
+		t.Errorf("Followers() = %+v, %v, want 1 follower", followers, err)
+	}
+
+	quote, err := quoteRepo.Create(&models.Quote{Text: "Know thyself", Author: "Socrates", Category: "Wisdom"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	created := models.NewEvent(models.EventQuoteCreated, models.EventData{Quote: quote})
+	for i := 0; i < 2; i++ {
+		// Handling an event twice delivers it once
+		if err := service.Handle(context.Background(), created); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+	}
+
+	activities = remote.waitForActivities(t, 2)
+	create := activities[1]
+	note, _ := create["object"].(map[string]interface{})
+	if create["type"] != "Create" || note["type"] != "Note" || note["attributedTo"] != actor.ID {
+		t.Fatalf("inbox received %v, want a Create of a Note", create)
+	}
+	if content, _ := note["content"].(string); !strings.Contains(content, "Know thyself") || note["url"] != testActivityPubBase+"/q/1" {
+		t.Errorf("note = %v", note)
+	}
+
+	deleted := models.NewEvent(models.EventQuoteDeleted, models.EventData{Quote: quote})
+	if err := service.Handle(context.Background(), deleted); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	activities = remote.waitForActivities(t, 3)
+	if tombstone, _ := activities[2]["object"].(map[string]interface{}); activities[2]["type"] != "Delete" || tombstone["id"] != note["id"] {
+		t.Errorf("inbox received %v, want a Delete of the note", activities[2])
+	}
+
+	undo := &activitypub.Activity{
+		ID:     remote.actorID() + "/follows/1/undo",
+		Type:   activitypub.TypeUndo,
+		Actor:  remote.actorID(),
+		Object: follow,
+	}
+	if err := remote.post(service, "quotes", undo, remote.key); err != nil {
+		t.Fatalf("HandleInbox(Undo) error = %v", err)
+	}
+	if followers, err := service.Followers("quotes"); err != nil || followers.TotalItems != 0 {
+		t.Errorf("Followers() after Undo = %+v, %v, want none", followers, err)
+	}
+
+	// Without followers nothing more is sent
+	if err := service.Handle(context.Background(), models.NewEvent(models.EventQuoteUpdated, models.EventData{Quote: quote})); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if due, err := repo.GetDueDeliveries(time.Now().Add(time.Hour), 10); err != nil || len(due) != 0 {
+		t.Errorf("queued deliveries after Undo = %v, %v", due, err)
+	}
+}
+
+func TestActivityPubService_DeletesMovedQuotes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	quoteRepo := repository.NewQuoteRepository(db)
+	quote, err := quoteRepo.Create(&models.Quote{Text: "Know thyself", Author: "Socrates", Category: "Wisdom"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	service := startActivityPubService(t, repository.NewActivityPubRepository(db), quoteRepo)
+	remote := newRemoteServer(t)
+	actor, err := service.Actor("Wisdom")
+	if err != nil {
+		t.Fatalf("Actor() error = %v", err)
+	}
+	remote.vaultKey, _ = activitypub.DecodePublicKey(actor.PublicKey.PublicKeyPem)
+
+	follow := &activitypub.Activity{
+		ID:     remote.actorID() + "/follows/1",
+		Type:   activitypub.TypeFollow,
+		Actor:  remote.actorID(),
+		Object: actor.ID,
+	}
+	if err := remote.post(service, "Wisdom", follow, remote.key); err != nil {
+		t.Fatalf("HandleInbox(Follow) error = %v", err)
+	}
+	remote.waitForActivities(t, 1)
+
+	quote.Category = "Humor"
+	if _, err := quoteRepo.Update(quote); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	moved := models.NewEvent(models.EventQuoteUpdated, models.EventData{Quote: quote, PreviousCategory: "Wisdom"})
+	if err := service.Handle(context.Background(), moved); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	activities := remote.waitForActivities(t, 2)
+	tombstone, _ := activities[1]["object"].(map[string]interface{})
+	if activities[1]["type"] != "Delete" || activities[1]["actor"] != actor.ID || tombstone["id"] != actor.ID+"/notes/1" {
+		t.Errorf("inbox received %v, want a Delete of the Wisdom note", activities[1])
+	}
+}
+
+func TestActivityPubService_RejectsUnverifiedActivities(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	quoteRepo := repository.NewQuoteRepository(db)
+	service := startActivityPubService(t, repository.NewActivityPubRepository(db), quoteRepo)
+	remote := newRemoteServer(t)
+
+	follow := &activitypub.Activity{
+		ID:     remote.actorID() + "/follows/1",
+		Type:   activitypub.TypeFollow,
+		Actor:  remote.actorID(),
+		Object: testActivityPubBase + "/ap/actors/quotes",
+	}
+
+	other, _ := activitypub.GenerateKey()
+	err := remote.post(service, "quotes", follow, other)
+	if appErr, ok := err.(*errors.AppError); !ok || appErr.Code != http.StatusUnauthorized {
+		t.Errorf("HandleInbox() signed with another key = %v, want 401", err)
+	}
+
+	forged := *follow
+	forged.Actor = "https://elsewhere.example/users/mallory"
+	err = remote.post(service, "quotes", &forged, remote.key)
+	if appErr, ok := err.(*errors.AppError); !ok || appErr.Code != http.StatusUnauthorized {
+		t.Errorf("HandleInbox() for another actor = %v, want 401", err)
+	}
+
+	if err := remote.post(service, "nobody", follow, remote.key); err != errors.ErrActorNotFound {
+		t.Errorf("HandleInbox() for an unknown actor = %v, want ErrActorNotFound", err)
+	}
+	if followers, _ := service.Followers("quotes"); followers.TotalItems != 0 {
+		t.Errorf("unverified Follow added %d followers", followers.TotalItems)
+	}
+}
+
+func TestActivityPubService_Actors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	quoteRepo := repository.NewQuoteRepository(db)
+	for i := 0; i < ActivityPubPageSize+1; i++ {
+		category := "Self Help"
+		if i%2 == 0 {
+			category = "Wisdom"
+		}
+		if _, err := quoteRepo.Create(&models.Quote{Text: "Quote " + string(rune('A'+i)), Author: "Anon", Category: category}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	service := startActivityPubService(t, repository.NewActivityPubRepository(db), quoteRepo)
+
+	jrd, err := service.WebFinger("acct:Self_Help@quotes.example")
+	if err != nil {
+		t.Fatalf("WebFinger() error = %v", err)
+	}
+	if jrd.Subject != "acct:Self_Help@quotes.example" || jrd.Links[0].Href != testActivityPubBase+"/ap/actors/Self_Help" {
+		t.Errorf("WebFinger() = %+v", jrd)
+	}
+	if _, err := service.WebFinger("acct:quotes@elsewhere.example"); err != errors.ErrActorNotFound {
+		t.Errorf("WebFinger() of another host = %v, want ErrActorNotFound", err)
+	}
+	if _, err := service.WebFinger("acct:poetry@quotes.example"); err != errors.ErrActorNotFound {
+		t.Errorf("WebFinger() of an empty category = %v, want ErrActorNotFound", err)
+	}
+
+	actor, err := service.Actor("Self_Help")
+	if err != nil {
+		t.Fatalf("Actor() error = %v", err)
+	}
+	if actor.PreferredUsername != "Self_Help" || actor.Name != "Quote Vault: Self Help" || actor.PublicKey.Owner != actor.ID {
+		t.Errorf("Actor() = %+v", actor)
+	}
+
+	outbox, err := service.Outbox(VaultActor, 0)
+	if err != nil {
+		t.Fatalf("Outbox() error = %v", err)
+	}
+	collection := outbox.(*activitypub.OrderedCollection)
+	if collection.TotalItems != ActivityPubPageSize+1 || collection.Last != collection.ID+"?page=2" {
+		t.Errorf("Outbox() = %+v", collection)
+	}
+	page, err := service.Outbox(VaultActor, 2)
+	if err != nil {
+		t.Fatalf("Outbox(page 2) error = %v", err)
+	}
+	if last := page.(*activitypub.OrderedCollectionPage); len(last.OrderedItems) != 1 || last.Next != "" || last.Prev == "" {
+		t.Errorf("Outbox(page 2) = %+v", last)
+	}
+
+	// Categories differing only in case have actors of their own
+	if _, err := quoteRepo.Create(&models.Quote{Text: "Lower case wisdom", Author: "Anon", Category: "wisdom"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for name, want := range map[string]int{"Wisdom": 11, "wisdom": 1} {
+		page, err = service.Outbox(name, 1)
+		if err != nil {
+			t.Fatalf("Outbox(%s) error = %v", name, err)
+		}
+		if items := page.(*activitypub.OrderedCollectionPage).OrderedItems; len(items) != want {
+			t.Errorf("Outbox(%s) has %d items, want %d", name, len(items), want)
+		}
+	}
+
+	if _, err := service.Note("Wisdom", 1); err != nil {
+		t.Errorf("Note() of a quote in the category error = %v", err)
+	}
+	if _, err := service.Note("Wisdom", 2); err != errors.ErrQuoteNotFound {
+		t.Errorf("Note() of a quote in another category = %v, want ErrQuoteNotFound", err)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"sync"
+
+	"quote-vault/models"
+	"quote-vault/render"
+)
+
+// DefaultCardCacheSize is the number of rendered PNG cards kept in memory
+const DefaultCardCacheSize = 256
+
+// CardService renders PNG quote cards, keeping the most recently used ones
+// in memory. Cards are cached by quote ID and version, so an edited quote
+// is rendered afresh.
+type CardService struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recently used at the front
+	entries  map[string]*list.Element
+}
+
+type cardEntry struct {
+	key  string
+	data []byte
+}
+
+func NewCardService(capacity int) *CardService {
+	if capacity < 1 {
+		capacity = DefaultCardCacheSize
+	}
+	return &CardService{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// CardKey identifies a rendered card. It doubles as the ETag of the image.
+func CardKey(quote *models.Quote, opts render.PNGOptions) string {
+	return fmt.Sprintf("%d.%d:%s", quote.ID, quote.Version, opts.Key())
+}
+
+// PNG returns the quote rendered as a PNG card
+func (s *CardService) PNG(quote *models.Quote, opts render.PNGOptions) ([]byte, error) {
+	key := CardKey(quote, opts)
+	if data, ok := s.get(key); ok {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	if err := render.PNGCard(&buf, quote, opts); err != nil {
+		return nil, err
+	}
+	s.put(key, buf.Bytes())
+	return buf.Bytes(), nil
+}
+
+func (s *CardService) get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(elem)
+	return elem.Value.(*cardEntry).data, true
+}
+
+func (s *CardService) put(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.order.MoveToFront(elem)
+		return
+	}
+	s.entries[key] = s.order.PushFront(&cardEntry{key: key, data: data})
+
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*cardEntry).key)
+	}
+}
+
+// Len returns the number of cached cards
+func (s *CardService) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
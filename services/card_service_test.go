@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"quote-vault/models"
+	"quote-vault/render"
+)
+
+func TestCardService_PNG(t *testing.T) {
+	service := NewCardService(2)
+	quote := &models.Quote{ID: 1, Version: 1, Text: "Simplicity is the ultimate sophistication.", Author: "Leonardo da Vinci"}
+	opts := render.DefaultPNGOptions(render.ThemeLight)
+
+	first, err := service.PNG(quote, opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(first)); err != nil {
+		t.Fatalf("PNG() did not return a valid image: %v", err)
+	}
+
+	// A cached card is returned without rendering again
+	quote.Text = "Changed without bumping the version"
+	second, _ := service.PNG(quote, opts)
+	if !bytes.Equal(first, second) {
+		t.Error("expected the cached card for an unchanged version")
+	}
+
+	// A new version renders a new card
+	quote.Version = 2
+	third, _ := service.PNG(quote, opts)
+	if bytes.Equal(first, third) {
+		t.Error("expected a new card for a new version")
+	}
+
+	// The cache holds at most its capacity, evicting the least recently used
+	service.PNG(&models.Quote{ID: 2, Version: 1, Text: "Another quote for the cache", Author: "Someone"}, opts)
+	if service.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", service.Len())
+	}
+	if _, ok := service.get(CardKey(&models.Quote{ID: 1, Version: 1}, opts)); ok {
+		t.Error("expected the oldest card to be evicted")
+	}
+}
+
+func TestCardKey(t *testing.T) {
+	quote := &models.Quote{ID: 3, Version: 4}
+	light := render.DefaultPNGOptions(render.ThemeLight)
+	dark := render.DefaultPNGOptions(render.ThemeDark)
+
+	if CardKey(quote, light) == CardKey(quote, dark) {
+		t.Error("cards with different options should have different keys")
+	}
+	if CardKey(quote, light) != CardKey(&models.Quote{ID: 3, Version: 4}, light) {
+		t.Error("cards with the same quote version and options should share a key")
+	}
+}
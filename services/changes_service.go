@@ -0,0 +1,116 @@
+package services
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// DefaultTombstoneRetention is how long deleted quotes stay in the changes
+// feed. Clients that have not synced for longer must resync in full.
+const DefaultTombstoneRetention = 30 * 24 * time.Hour
+
+// tombstonePruneInterval limits how often expired tombstones are removed
+const tombstonePruneInterval = time.Hour
+
+// ChangesService serves the changes feed that clients use to keep a copy
+// of the vault in sync
+type ChangesService struct {
+	repo      *repository.ChangeRepository
+	retention time.Duration
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+// NewChangesService creates a changes service that keeps tombstones for
+// the given time, or DefaultTombstoneRetention when it is not positive
+func NewChangesService(repo *repository.ChangeRepository, retention time.Duration) *ChangesService {
+	if retention <= 0 {
+		retention = DefaultTombstoneRetention
+	}
+	return &ChangesService{
+		repo:      repo,
+		retention: retention,
+	}
+}
+
+// GetChanges returns up to limit changes after the position described by
+// since, a token from an earlier ChangeSet. An empty token starts from the
+// beginning. Tokens older than the oldest pruned tombstone, or ahead of
+// the log (as after the database was restored), start from the beginning
+// too and ask the client for a full resync.
+func (s *ChangesService) GetChanges(since string, limit int) (*models.ChangeSet, error) {
+	s.prune()
+
+	var seq int64
+	if since != "" {
+		var err error
+		seq, err = strconv.ParseInt(since, 10, 64)
+		if err != nil || seq < 0 {
+			return nil, errors.NewValidationError("Invalid since token", "use the next token of an earlier response")
+		}
+	}
+
+	latest, err := s.repo.Latest()
+	if err != nil {
+		return nil, err
+	}
+	prunedThrough, err := s.repo.PrunedThrough()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &models.ChangeSet{}
+	if since != "" && (seq < prunedThrough || seq > latest) {
+		set.FullResync = true
+		seq = 0
+	}
+
+	// Ask for one more change than needed to tell whether more are waiting
+	changes, err := s.repo.Since(seq, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		set.HasMore = true
+	}
+	if changes == nil {
+		changes = []*models.Change{}
+	}
+	set.Changes = changes
+
+	// With nothing new, the client is up to date at the end of the log
+	if len(changes) > 0 {
+		seq = changes[len(changes)-1].Sequence
+	} else {
+		seq = latest
+	}
+	set.Next = strconv.FormatInt(seq, 10)
+
+	return set, nil
+}
+
+// prune removes expired tombstones, at most once per tombstonePruneInterval
+func (s *ChangesService) prune() {
+	now := time.Now()
+	s.mu.Lock()
+	due := now.Sub(s.prunedAt) >= tombstonePruneInterval
+	if due {
+		s.prunedAt = now
+	}
+	s.mu.Unlock()
+
+	if !due {
+		return
+	}
+	if err := s.repo.PruneTombstones(now.Add(-s.retention)); err != nil {
+		log.Printf("Failed to prune change log tombstones: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+func TestChangesService_GetChanges(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	quoteService := NewQuoteService(repository.NewQuoteRepository(db))
+	changeRepo := repository.NewChangeRepository(db)
+	service := NewChangesService(changeRepo, 0)
+
+	empty, err := service.GetChanges("", 10)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	if len(empty.Changes) != 0 || empty.Next != "0" || empty.HasMore || empty.FullResync {
+		t.Errorf("GetChanges() of an empty vault = %+v", empty)
+	}
+
+	var ids []int
+	for _, text := range []string{"One", "Two", "Three"} {
+		quote, err := quoteService.CreateQuote(&models.Quote{Text: text, Author: "Author", Category: "test"})
+		if err != nil {
+			t.Fatalf("CreateQuote() error = %v", err)
+		}
+		ids = append(ids, quote.ID)
+	}
+
+	page, err := service.GetChanges("", 2)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	if len(page.Changes) != 2 || !page.HasMore || page.FullResync {
+		t.Fatalf("GetChanges(limit 2) = %+v, want 2 changes and more", page)
+	}
+	page, err = service.GetChanges(page.Next, 2)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	if len(page.Changes) != 1 || page.Changes[0].ID != ids[2] || page.HasMore {
+		t.Fatalf("GetChanges(next) = %+v, want the last quote only", page)
+	}
+
+	synced := page.Next
+	if _, err := quoteService.DeleteQuote(ids[0]); err != nil {
+		t.Fatalf("DeleteQuote() error = %v", err)
+	}
+	page, err = service.GetChanges(synced, 10)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	if len(page.Changes) != 1 || page.Changes[0].Type != models.ChangeDelete || page.Changes[0].ID != ids[0] {
+		t.Fatalf("GetChanges() after delete = %+v, want a tombstone for %d", page, ids[0])
+	}
+
+	// Nothing new keeps the client where it is
+	idle, err := service.GetChanges(page.Next, 10)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	if len(idle.Changes) != 0 || idle.Next != page.Next {
+		t.Errorf("GetChanges() with nothing new = %+v, want next %s", idle, page.Next)
+	}
+
+	// Once the tombstone is pruned, a client that missed it must resync
+	if err := changeRepo.PruneTombstones(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PruneTombstones() error = %v", err)
+	}
+	for _, token := range []string{synced, "999"} {
+		resync, err := service.GetChanges(token, 10)
+		if err != nil {
+			t.Fatalf("GetChanges(%s) error = %v", token, err)
+		}
+		if !resync.FullResync || len(resync.Changes) != 2 {
+			t.Errorf("GetChanges(%s) = %+v, want a full resync of 2 quotes", token, resync)
+		}
+	}
+	if current, err := service.GetChanges(page.Next, 10); err != nil || current.FullResync {
+		t.Errorf("GetChanges(%s) = %+v, %v, want no resync", page.Next, current, err)
+	}
+
+	for _, token := range []string{"abc", "-1"} {
+		_, err := service.GetChanges(token, 10)
+		if appErr, ok := err.(*errors.AppError); !ok || appErr.Type != errors.TypeValidation {
+			t.Errorf("GetChanges(%q) error = %v, want a validation error", token, err)
+		}
+	}
+}
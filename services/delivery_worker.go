@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RetryOptions controls how a persistent delivery queue is worked through
+// and how failed deliveries are retried. Services fill unset options with
+// defaults of their own.
+type RetryOptions struct {
+	// MaxAttempts is the number of attempts before a delivery is given up
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry. Each later retry
+	// waits twice as long as the one before, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// PollInterval is the longest the worker sleeps between checks of the
+	// queue; queueing a delivery wakes it immediately
+	PollInterval time.Duration
+}
+
+// withDefaults returns the options with each unset one taken from defaults
+func (o RetryOptions) withDefaults(defaults RetryOptions) RetryOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = defaults.MaxAttempts
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = defaults.BaseDelay
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = defaults.MaxDelay
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = defaults.PollInterval
+	}
+	return o
+}
+
+// backoff returns the wait after the given number of failed attempts
+func (o RetryOptions) backoff(attempts int) time.Duration {
+	return exponentialBackoff(attempts, o.BaseDelay, o.MaxDelay)
+}
+
+// exponentialBackoff returns base doubled for each failed attempt after the
+// first, up to max
+func exponentialBackoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// deliveryWorker runs the delivery queue of a service: it sends what is
+// due, batch after batch, then sleeps until the next delivery is due, the
+// poll interval passes or notify is called. What a delivery is and how its
+// outcome is recorded is up to the service.
+type deliveryWorker struct {
+	// name describes the queue in log messages
+	name string
+	opts RetryOptions
+	// deliverDue sends one batch of due deliveries and reports whether a
+	// full batch was found, in which case more may be waiting
+	deliverDue func(ctx context.Context) bool
+	// nextDueAt returns when the earliest queued delivery is due, or nil
+	// when none is
+	nextDueAt func() (*time.Time, error)
+	// afterPass, when set, runs after each pass over the due deliveries
+	afterPass func()
+	wake      chan struct{}
+}
+
+func newDeliveryWorker(name string, opts RetryOptions, deliverDue func(context.Context) bool, nextDueAt func() (*time.Time, error)) *deliveryWorker {
+	return &deliveryWorker{
+		name:       name,
+		opts:       opts,
+		deliverDue: deliverDue,
+		nextDueAt:  nextDueAt,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// notify wakes the worker without waiting for it
+func (w *deliveryWorker) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run works through the queue until ctx is cancelled
+func (w *deliveryWorker) run(ctx context.Context) {
+	for {
+		for w.deliverDue(ctx) {
+		}
+		if w.afterPass != nil {
+			w.afterPass()
+		}
+
+		wait := w.opts.PollInterval
+		if next, err := w.nextDueAt(); err != nil {
+			log.Printf("Failed to check %s queue: %v", w.name, err)
+		} else if next != nil && time.Until(*next) < wait {
+			wait = time.Until(*next)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-w.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryOptions_Backoff(t *testing.T) {
+	opts := RetryOptions{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := opts.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestRetryOptions_WithDefaults(t *testing.T) {
+	opts := RetryOptions{MaxAttempts: 3}.withDefaults(defaultWebhookRetry)
+	if opts.MaxAttempts != 3 || opts.BaseDelay != DefaultWebhookBaseDelay || opts.PollInterval != DefaultWebhookPollInterval {
+		t.Errorf("withDefaults() = %+v", opts)
+	}
+}
+
+func TestDeliveryWorker_Run(t *testing.T) {
+	var passes, batches int32
+	worker := newDeliveryWorker("test", RetryOptions{PollInterval: time.Hour},
+		func(ctx context.Context) bool {
+			// The first pass finds two full batches and a partial one
+			return atomic.AddInt32(&batches, 1) < 3
+		},
+		func() (*time.Time, error) { return nil, nil })
+	worker.afterPass = func() { atomic.AddInt32(&passes, 1) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.run(ctx)
+		close(done)
+	}()
+
+	waitFor := func(want int32) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt32(&passes) < want {
+			if time.Now().After(deadline) {
+				t.Fatalf("worker made %d passes, want %d", atomic.LoadInt32(&passes), want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitFor(1)
+	if n := atomic.LoadInt32(&batches); n != 3 {
+		t.Errorf("first pass read %d batches, want 3", n)
+	}
+
+	// notify wakes the worker long before the poll interval
+	worker.notify()
+	worker.notify()
+	waitFor(2)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("run did not return after cancel")
+	}
+}
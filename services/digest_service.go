@@ -0,0 +1,503 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/mail"
+	"quote-vault/models"
+	"quote-vault/repository"
+	"quote-vault/web"
+)
+
+// Digest defaults
+const (
+	DefaultDigestMaxQuotes       = 10
+	DefaultDigestConfirmationTTL = 48 * time.Hour
+	DefaultDigestRetryDelay      = 15 * time.Minute
+	DefaultDigestPollInterval    = time.Minute
+)
+
+const (
+	// digestBatchSize is the number of due digests read at a time
+	digestBatchSize = 50
+	// maxDigestCategories caps the categories of one subscription
+	maxDigestCategories = 20
+)
+
+// Email templates, parsed once. The HTML and plain-text bodies of each
+// email share the data they are executed with.
+var (
+	emailTemplateFuncs = map[string]interface{}{"join": strings.Join}
+	emailTextTemplates = texttemplate.Must(texttemplate.New("email").Funcs(emailTemplateFuncs).
+				ParseFS(web.Templates, "templates/email/*.txt"))
+	emailHTMLTemplates = htmltemplate.Must(htmltemplate.New("email").Funcs(emailTemplateFuncs).
+				ParseFS(web.Templates, "templates/email/*.html"))
+)
+
+// DigestOptions controls who digests come from, when they are sent and
+// how long confirmation links last
+type DigestOptions struct {
+	// BaseURL is the public URL of the service, used for the links in
+	// emails. It is required.
+	BaseURL string
+	// From is the sender address, such as "Quote Vault <quotes@example.com>".
+	// It is required.
+	From string
+	// SendHour is the hour, UTC, at which digests go out. Daily digests
+	// are sent every day and weekly ones on Mondays.
+	SendHour int
+	// MaxQuotes is the number of quotes listed in a digest; any more are
+	// linked to
+	MaxQuotes int
+	// ConfirmationTTL is how long a confirmation link works. Unconfirmed
+	// subscriptions are removed after it.
+	ConfirmationTTL time.Duration
+	// RetryDelay is the wait before trying a digest again when sending it
+	// failed
+	RetryDelay time.Duration
+	// PollInterval is the time between checks for due digests
+	PollInterval time.Duration
+}
+
+// DigestService signs email addresses up for daily or weekly digests of
+// new quotes, with double opt-in, and sends the digests on schedule
+type DigestService struct {
+	repo   *repository.DigestRepository
+	quotes *repository.QuoteRepository
+	sender mail.Sender
+	opts   DigestOptions
+}
+
+// NewDigestService creates a digest service, filling unset options with
+// their defaults. Digests are only sent while Run is running.
+func NewDigestService(repo *repository.DigestRepository, quotes *repository.QuoteRepository, sender mail.Sender, opts DigestOptions) (*DigestService, error) {
+	base, err := url.Parse(strings.TrimRight(opts.BaseURL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("email digests need an absolute http or https base URL, got %q", opts.BaseURL)
+	}
+	opts.BaseURL = base.String()
+	if _, err := mail.ParseAddress(opts.From); err != nil {
+		return nil, fmt.Errorf("invalid digest sender address %q: %v", opts.From, err)
+	}
+	if opts.SendHour < 0 || opts.SendHour > 23 {
+		return nil, fmt.Errorf("digest send hour must be between 0 and 23, got %d", opts.SendHour)
+	}
+	if opts.MaxQuotes <= 0 {
+		opts.MaxQuotes = DefaultDigestMaxQuotes
+	}
+	if opts.ConfirmationTTL <= 0 {
+		opts.ConfirmationTTL = DefaultDigestConfirmationTTL
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultDigestRetryDelay
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultDigestPollInterval
+	}
+
+	return &DigestService{
+		repo:   repo,
+		quotes: quotes,
+		sender: sender,
+		opts:   opts,
+	}, nil
+}
+
+// Subscribe signs an address up for a digest and emails it a confirmation
+// link; nothing else is sent until the link is followed. Subscribing an
+// unconfirmed address again replaces its settings and sends a new link.
+// An address that is already confirmed is left as it is, and gets no
+// email, so the response does not tell whether an address is subscribed.
+func (s *DigestService) Subscribe(ctx context.Context, req *models.DigestSubscriptionRequest) error {
+	sub, err := s.validate(req)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.repo.GetByEmail(sub.Email)
+	switch {
+	case err == nil && existing.Confirmed():
+		return nil
+	case err == nil:
+		existing.Frequency = sub.Frequency
+		existing.Categories = sub.Categories
+		if existing.ConfirmToken, err = newEmailToken(); err != nil {
+			return err
+		}
+		if err := s.repo.UpdatePending(existing); err != nil {
+			return err
+		}
+		sub = existing
+	case err == errors.ErrSubscriptionNotFound:
+		if sub.ConfirmToken, err = newEmailToken(); err != nil {
+			return err
+		}
+		if sub.UnsubscribeToken, err = newEmailToken(); err != nil {
+			return err
+		}
+		if sub, err = s.repo.Create(sub); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	msg, err := s.confirmationEmail(sub)
+	if err != nil {
+		return err
+	}
+	if err := s.sender.Send(ctx, msg); err != nil {
+		log.Printf("Failed to send digest confirmation to subscription %d: %v", sub.ID, err)
+		return errors.ErrMailUnavailable
+	}
+	return nil
+}
+
+// validate checks a subscription request and returns the subscription it
+// asks for
+func (s *DigestService) validate(req *models.DigestSubscriptionRequest) (*models.DigestSubscription, error) {
+	email, err := validateEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	frequency := strings.ToLower(strings.TrimSpace(req.Frequency))
+	if frequency == "" {
+		frequency = models.DigestDaily
+	}
+	if frequency != models.DigestDaily && frequency != models.DigestWeekly {
+		return nil, errors.NewValidationError("Invalid digest frequency",
+			fmt.Sprintf("frequency must be %s or %s", models.DigestDaily, models.DigestWeekly))
+	}
+
+	var categories []string
+	for _, category := range req.Categories {
+		category = strings.TrimSpace(category)
+		if category == "" || strings.Contains(category, ",") {
+			return nil, errors.NewValidationError("Invalid digest categories", "categories must be non-empty names")
+		}
+		if !contains(categories, category) {
+			categories = append(categories, category)
+		}
+	}
+	if len(categories) > maxDigestCategories {
+		return nil, errors.NewValidationError("Invalid digest categories",
+			fmt.Sprintf("a digest can follow at most %d categories", maxDigestCategories))
+	}
+
+	return &models.DigestSubscription{Email: email, Frequency: frequency, Categories: categories}, nil
+}
+
+// validateEmail checks that email is a plain address, without a display
+// name, and returns it trimmed
+func validateEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address != email || len(email) > 254 {
+		return "", errors.NewValidationError("Invalid email address", "email must be a plain address such as ann@example.com")
+	}
+	return email, nil
+}
+
+// Confirm confirms the subscription of a confirmation link and schedules
+// its first digest. Following the link again is harmless; links expire
+// after ConfirmationTTL.
+func (s *DigestService) Confirm(token string) (*models.DigestSubscription, error) {
+	if token == "" {
+		return nil, errors.ErrSubscriptionNotFound
+	}
+	sub, err := s.repo.GetByConfirmToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Confirmed() {
+		return sub, nil
+	}
+
+	now := time.Now().UTC()
+	if now.Sub(sub.CreatedAt) > s.opts.ConfirmationTTL {
+		return nil, errors.ErrSubscriptionNotFound
+	}
+	next := nextDigestAt(sub.Frequency, now, s.opts.SendHour)
+	sub.ConfirmedAt = &now
+	sub.NextDigestAt = &next
+	if err := s.repo.Confirm(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// GetSubscription returns the subscription of an unsubscribe link
+func (s *DigestService) GetSubscription(unsubscribeToken string) (*models.DigestSubscription, error) {
+	if unsubscribeToken == "" {
+		return nil, errors.ErrSubscriptionNotFound
+	}
+	return s.repo.GetByUnsubscribeToken(unsubscribeToken)
+}
+
+// Unsubscribe removes the subscription of an unsubscribe link
+func (s *DigestService) Unsubscribe(unsubscribeToken string) (*models.DigestSubscription, error) {
+	sub, err := s.GetSubscription(unsubscribeToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(sub.ID); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Run sends digests as they fall due, and clears out expired unconfirmed
+// subscriptions, until ctx is cancelled
+func (s *DigestService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		s.sendDue(ctx, now)
+		if _, err := s.repo.DeleteUnconfirmed(now.Add(-s.opts.ConfirmationTTL)); err != nil {
+			log.Printf("Failed to remove unconfirmed digest subscriptions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue sends every digest due at now and returns how many were sent
+func (s *DigestService) sendDue(ctx context.Context, now time.Time) int {
+	sent := 0
+	for ctx.Err() == nil {
+		due, err := s.repo.GetDue(now, digestBatchSize)
+		if err != nil {
+			log.Printf("Failed to read due digests: %v", err)
+			return sent
+		}
+		for _, sub := range due {
+			if s.sendDigest(ctx, sub, now) {
+				sent++
+			}
+		}
+		if len(due) < digestBatchSize {
+			break
+		}
+	}
+	return sent
+}
+
+// sendDigest sends one subscription its digest of the quotes added since
+// the previous one, and schedules the next. When there are no new quotes
+// nothing is sent and the next digest covers the longer period. A failed
+// send is retried after RetryDelay.
+func (s *DigestService) sendDigest(ctx context.Context, sub *models.DigestSubscription, now time.Time) bool {
+	// Quotes are stored with second precision, so a digest covers whole
+	// seconds and the next one starts where it ended
+	until := now.UTC().Truncate(time.Second)
+	since := *sub.ConfirmedAt
+	if sub.LastSentAt != nil {
+		since = *sub.LastSentAt
+	}
+	next := nextDigestAt(sub.Frequency, now, s.opts.SendHour)
+
+	quotes, total, err := s.newQuotes(sub.Categories, since, until)
+	if err != nil {
+		log.Printf("Failed to collect digest for subscription %d: %v", sub.ID, err)
+		s.reschedule(sub, now.Add(s.opts.RetryDelay))
+		return false
+	}
+	if total == 0 {
+		s.reschedule(sub, next)
+		return false
+	}
+
+	msg, err := s.digestEmail(sub, quotes, total)
+	if err == nil {
+		err = s.sender.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Printf("Failed to send digest to subscription %d: %v", sub.ID, err)
+		s.reschedule(sub, now.Add(s.opts.RetryDelay))
+		return false
+	}
+
+	if err := s.repo.MarkSent(sub.ID, until, next); err != nil {
+		log.Printf("Failed to record digest for subscription %d: %v", sub.ID, err)
+	}
+	return true
+}
+
+func (s *DigestService) reschedule(sub *models.DigestSubscription, next time.Time) {
+	if err := s.repo.Reschedule(sub.ID, next); err != nil {
+		log.Printf("Failed to reschedule digest for subscription %d: %v", sub.ID, err)
+	}
+}
+
+// newQuotes returns the newest quotes added in [since, until) to any of
+// the categories, or to any category when none are given, up to
+// MaxQuotes, along with the total number added
+func (s *DigestService) newQuotes(categories []string, since, until time.Time) ([]*models.Quote, int, error) {
+	if len(categories) == 0 {
+		categories = []string{""}
+	}
+
+	var quotes []*models.Quote
+	total := 0
+	for _, category := range categories {
+		found, count, err := s.quotes.Search(models.QuoteFilter{Category: category, From: since, To: until}, s.opts.MaxQuotes, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		quotes = append(quotes, found...)
+		total += count
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		if !quotes[i].CreatedAt.Equal(quotes[j].CreatedAt) {
+			return quotes[i].CreatedAt.After(quotes[j].CreatedAt)
+		}
+		return quotes[i].ID > quotes[j].ID
+	})
+	if len(quotes) > s.opts.MaxQuotes {
+		quotes = quotes[:s.opts.MaxQuotes]
+	}
+	return quotes, total, nil
+}
+
+// nextDigestAt returns when the next digest of the given frequency is due
+// after t: the next sendHour:00 UTC, on a Monday for weekly digests
+func nextDigestAt(frequency string, t time.Time, sendHour int) time.Time {
+	t = t.UTC()
+	next := time.Date(t.Year(), t.Month(), t.Day(), sendHour, 0, 0, 0, time.UTC)
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	if frequency == models.DigestWeekly {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
+
+// digestQuote is a quote as listed in a digest email
+type digestQuote struct {
+	*models.Quote
+	URL string
+}
+
+// confirmationEmail builds the email asking an address to confirm its
+// subscription
+func (s *DigestService) confirmationEmail(sub *models.DigestSubscription) (*mail.Message, error) {
+	data := map[string]interface{}{
+		"Base":       s.opts.BaseURL,
+		"Email":      sub.Email,
+		"Frequency":  sub.Frequency,
+		"Categories": sub.Categories,
+		"ConfirmURL": s.opts.BaseURL + "/digests/confirm?token=" + url.QueryEscape(sub.ConfirmToken),
+		"ExpiresIn":  formatHours(s.opts.ConfirmationTTL),
+	}
+	return s.email(sub, "Confirm your Quote Vault digest", "confirm", data, nil)
+}
+
+// digestEmail builds a digest listing quotes, out of total new ones
+func (s *DigestService) digestEmail(sub *models.DigestSubscription, quotes []*models.Quote, total int) (*mail.Message, error) {
+	listed := make([]digestQuote, len(quotes))
+	for i, quote := range quotes {
+		listed[i] = digestQuote{Quote: quote, URL: fmt.Sprintf("%s/q/%d", s.opts.BaseURL, quote.ID)}
+	}
+
+	period := "today"
+	if sub.Frequency == models.DigestWeekly {
+		period = "this week"
+	}
+	heading := fmt.Sprintf("%d new quotes %s", total, period)
+	if total == 1 {
+		heading = "1 new quote " + period
+	}
+
+	browse := s.opts.BaseURL + "/ui/"
+	if len(sub.Categories) == 1 {
+		browse += "?category=" + url.QueryEscape(sub.Categories[0])
+	}
+	unsubscribe := s.opts.BaseURL + "/digests/unsubscribe?token=" + url.QueryEscape(sub.UnsubscribeToken)
+
+	data := map[string]interface{}{
+		"Base":           s.opts.BaseURL,
+		"Heading":        heading,
+		"Frequency":      sub.Frequency,
+		"Categories":     sub.Categories,
+		"Quotes":         listed,
+		"More":           total - len(listed),
+		"BrowseURL":      browse,
+		"UnsubscribeURL": unsubscribe,
+	}
+	// List-Unsubscribe-Post lets mail clients unsubscribe with one click
+	// (RFC 8058)
+	header := map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribe + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	return s.email(sub, "Quote Vault: "+heading, "digest", data, header)
+}
+
+// email renders the named email template pair into a message to sub
+func (s *DigestService) email(sub *models.DigestSubscription, subject, name string, data interface{}, header map[string]string) (*mail.Message, error) {
+	return renderEmail(name, data, &mail.Message{
+		From:    s.opts.From,
+		To:      sub.Email,
+		Subject: subject,
+		Header:  header,
+	})
+}
+
+// renderEmail fills in the bodies of msg from the named pair of email
+// templates
+func renderEmail(name string, data interface{}, msg *mail.Message) (*mail.Message, error) {
+	var text, html bytes.Buffer
+	if err := emailTextTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s email: %v", name, err)
+	}
+	if err := emailHTMLTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s email: %v", name, err)
+	}
+	msg.Text = text.String()
+	msg.HTML = html.String()
+	return msg, nil
+}
+
+// formatHours describes a duration in whole hours, or days when even
+func formatHours(d time.Duration) string {
+	hours := int(d.Hours())
+	if hours%24 == 0 && hours > 24 {
+		return fmt.Sprintf("%d days", hours/24)
+	}
+	if hours == 1 {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d hours", hours)
+}
+
+// newEmailToken returns a random token for a confirmation or unsubscribe
+// link in an email
+func newEmailToken() (string, error) {
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return "", errors.NewInternalError("failed to generate subscription token")
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/mail"
+	"quote-vault/mail/mailtest"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+const testDigestBase = "https://quotes.example"
+
+// linkToken matches the token of a link to the service in an email
+var linkToken = regexp.MustCompile(`https://quotes\.example/(?:digests|searches)/(confirm|unsubscribe)\?token=([A-Za-z0-9_%-]+)`)
+
+// setupDigestService returns a digest service sending to an in-process
+// SMTP server
+func setupDigestService(t *testing.T) (*DigestService, *repository.QuoteRepository, *mailtest.Server) {
+	db := setupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+	server := mailtest.NewServer()
+	t.Cleanup(server.Close)
+
+	quoteRepo := repository.NewQuoteRepository(db)
+	sender := mail.NewSMTPSender(mail.SMTPConfig{Host: server.Host, Port: server.Port})
+	service, err := NewDigestService(repository.NewDigestRepository(db), quoteRepo, sender, DigestOptions{
+		BaseURL:   testDigestBase,
+		From:      "Quote Vault <quotes@example.com>",
+		SendHour:  8,
+		MaxQuotes: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewDigestService() error = %v", err)
+	}
+	return service, quoteRepo, server
+}
+
+// link returns the token of the kind of link in a received email
+func link(t *testing.T, msg *mailtest.Received, kind string) string {
+	t.Helper()
+	parsed, err := msg.Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for _, match := range linkToken.FindAllStringSubmatch(parsed.Text, -1) {
+		if match[1] == kind {
+			token, _ := url.QueryUnescape(match[2])
+			return token
+		}
+	}
+	t.Fatalf("email has no %s link:\n%s", kind, parsed.Text)
+	return ""
+}
+
+func TestDigestService_SubscribeAndSend(t *testing.T) {
+	service, quoteRepo, server := setupDigestService(t)
+	ctx := context.Background()
+
+	req := &models.DigestSubscriptionRequest{Email: "ann@example.com", Frequency: "daily", Categories: []string{"Wisdom", "Humor", "Wisdom"}}
+	if err := service.Subscribe(ctx, req); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	received, err := server.Wait(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmation, _ := received[0].Parse()
+	if received[0].To[0] != "ann@example.com" || !strings.Contains(confirmation.Text, "Wisdom, Humor") {
+		t.Errorf("confirmation email:\n%s", confirmation.Text)
+	}
+
+	// Nothing is sent before the address is confirmed
+	if sent := service.sendDue(ctx, time.Now().Add(48*time.Hour)); sent != 0 {
+		t.Errorf("sendDue() before confirming sent %d digests", sent)
+	}
+
+	sub, err := service.Confirm(link(t, received[0], "confirm"))
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	if !sub.Confirmed() || sub.NextDigestAt.Hour() != 8 {
+		t.Errorf("Confirm() = %+v", sub)
+	}
+
+	// Subscribing a confirmed address again changes nothing and sends no
+	// email
+	if err := service.Subscribe(ctx, &models.DigestSubscriptionRequest{Email: "ANN@example.com", Frequency: "weekly"}); err != nil {
+		t.Fatalf("Subscribe() again error = %v", err)
+	}
+
+	// With no new quotes no digest is sent
+	due := sub.NextDigestAt.Add(time.Minute)
+	if sent := service.sendDue(ctx, due); sent != 0 {
+		t.Errorf("sendDue() without new quotes sent %d digests", sent)
+	}
+
+	for _, quote := range []*models.Quote{
+		{Text: "Know thyself", Author: "Socrates", Category: "Wisdom"},
+		{Text: "I am not young enough to know everything", Author: "Oscar Wilde", Category: "Humor"},
+		{Text: "Well begun is half done", Author: "Aristotle", Category: "Wisdom"},
+		{Text: "Be yourself; everyone else is already taken", Author: "Oscar Wilde", Category: "Life"},
+	} {
+		if _, err := quoteRepo.Create(quote); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	due = due.Add(24 * time.Hour)
+	if sent := service.sendDue(ctx, due); sent != 1 {
+		t.Fatalf("sendDue() sent %d digests, want 1", sent)
+	}
+	if n := len(server.Messages()); n != 2 {
+		t.Fatalf("server received %d emails, want the confirmation and one digest", n)
+	}
+	digest, _ := server.Messages()[1].Parse()
+	if digest.Subject != "Quote Vault: 3 new quotes today" {
+		t.Errorf("digest subject = %q", digest.Subject)
+	}
+	if !strings.Contains(digest.Text, "Well begun") || strings.Contains(digest.Text, "Be yourself") || !strings.Contains(digest.Text, "And 1 more") {
+		t.Errorf("digest should list the newest two of three quotes in its categories:\n%s", digest.Text)
+	}
+	if !strings.Contains(digest.HTML, "Know thyself") && !strings.Contains(digest.HTML, "not young enough") {
+		t.Errorf("digest HTML:\n%s", digest.HTML)
+	}
+	if h := digest.Header.Get("List-Unsubscribe-Post"); h != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", h)
+	}
+
+	// The next digest only covers quotes added since
+	if sent := service.sendDue(ctx, due.Add(24*time.Hour)); sent != 0 {
+		t.Errorf("sendDue() with nothing new sent %d digests", sent)
+	}
+
+	unsubscribe := link(t, server.Messages()[1], "unsubscribe")
+	if _, err := service.Unsubscribe(unsubscribe); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if _, err := service.GetSubscription(unsubscribe); err != errors.ErrSubscriptionNotFound {
+		t.Errorf("GetSubscription() after Unsubscribe() error = %v, want ErrSubscriptionNotFound", err)
+	}
+}
+
+func TestDigestService_SendFailure(t *testing.T) {
+	service, quoteRepo, server := setupDigestService(t)
+	ctx := context.Background()
+
+	if err := service.Subscribe(ctx, &models.DigestSubscriptionRequest{Email: "ann@example.com"}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	received, _ := server.Wait(1, time.Second)
+	sub, err := service.Confirm(link(t, received[0], "confirm"))
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	if _, err := quoteRepo.Create(&models.Quote{Text: "Know thyself", Author: "Socrates", Category: "Wisdom"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	server.Reject(true)
+	due := sub.NextDigestAt.Add(time.Minute)
+	if sent := service.sendDue(ctx, due); sent != 0 {
+		t.Fatalf("sendDue() to a rejecting server sent %d digests", sent)
+	}
+	// The digest is retried after RetryDelay, not the next day
+	server.Reject(false)
+	if sent := service.sendDue(ctx, due.Add(time.Minute)); sent != 0 {
+		t.Errorf("sendDue() before the retry delay sent %d digests", sent)
+	}
+	if sent := service.sendDue(ctx, due.Add(DefaultDigestRetryDelay+time.Minute)); sent != 1 {
+		t.Errorf("sendDue() after the retry delay sent %d digests, want 1", sent)
+	}
+
+	// A confirmation that cannot be sent is reported
+	server.Reject(true)
+	err = service.Subscribe(ctx, &models.DigestSubscriptionRequest{Email: "bob@example.com"})
+	if err != errors.ErrMailUnavailable {
+		t.Errorf("Subscribe() with a rejecting server error = %v, want ErrMailUnavailable", err)
+	}
+}
+
+func TestDigestService_Validation(t *testing.T) {
+	service, _, server := setupDigestService(t)
+
+	invalid := []*models.DigestSubscriptionRequest{
+		{Email: "not an address"},
+		{Email: "Ann <ann@example.com>"},
+		{Email: "ann@example.com", Frequency: "hourly"},
+		{Email: "ann@example.com", Categories: []string{" "}},
+	}
+	for _, req := range invalid {
+		err := service.Subscribe(context.Background(), req)
+		if appErr, ok := err.(*errors.AppError); !ok || appErr.Type != errors.TypeValidation {
+			t.Errorf("Subscribe(%+v) error = %v, want a validation error", req, err)
+		}
+	}
+	if n := len(server.Messages()); n != 0 {
+		t.Errorf("server received %d emails for invalid requests", n)
+	}
+
+	if _, err := service.Confirm("unknown"); err != errors.ErrSubscriptionNotFound {
+		t.Errorf("Confirm(unknown) error = %v, want ErrSubscriptionNotFound", err)
+	}
+	if _, err := NewDigestService(nil, nil, nil, DigestOptions{From: "quotes@example.com"}); err == nil {
+		t.Error("NewDigestService() without a base URL should fail")
+	}
+}
+
+func TestNextDigestAt(t *testing.T) {
+	// 2024-03-06 is a Wednesday
+	tests := []struct {
+		frequency string
+		after     time.Time
+		want      time.Time
+	}{
+		{models.DigestDaily, time.Date(2024, 3, 6, 7, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 8, 0, 0, 0, time.UTC)},
+		{models.DigestDaily, time.Date(2024, 3, 6, 8, 0, 0, 0, time.UTC), time.Date(2024, 3, 7, 8, 0, 0, 0, time.UTC)},
+		{models.DigestWeekly, time.Date(2024, 3, 6, 7, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)},
+		{models.DigestWeekly, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 18, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := nextDigestAt(tt.frequency, tt.after, 8); !got.Equal(tt.want) {
+			t.Errorf("nextDigestAt(%s, %v) = %v, want %v", tt.frequency, tt.after, got, tt.want)
+		}
+	}
+}
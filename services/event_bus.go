@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// Defaults for EventBusOptions
+const (
+	DefaultEventBusMaxAttempts  = 10
+	DefaultEventBusBaseDelay    = 100 * time.Millisecond
+	DefaultEventBusMaxDelay     = 30 * time.Second
+	DefaultEventBusPollInterval = 5 * time.Second
+)
+
+const (
+	// outboxBatch is the number of events read from the outbox at a time
+	outboxBatch = 100
+	// outboxPruneInterval is how often events every subscriber has handled
+	// are removed from the outbox
+	outboxPruneInterval = time.Minute
+)
+
+// EventBusOptions configures an EventBus. Zero values select defaults.
+type EventBusOptions struct {
+	// MaxAttempts is the number of times an event is handed to a failing
+	// subscriber before it is skipped
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for each
+	// further one up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// PollInterval is the longest a dispatcher sleeps between checks of
+	// the outbox; Notify wakes it immediately
+	PollInterval time.Duration
+}
+
+// EventBus hands the events recorded in the outbox to subscribers in this
+// process. Each subscriber gets every event in order, at least once: its
+// place in the outbox is saved only after it handled an event, so events
+// in flight at a crash are handled again after the restart.
+type EventBus struct {
+	repo          *repository.OutboxRepository
+	opts          EventBusOptions
+	subscriptions []*subscription
+}
+
+type subscription struct {
+	name       string
+	subscriber Subscriber
+	wake       chan struct{}
+	// mu keeps one dispatch running at a time
+	mu sync.Mutex
+	// cursor is the sequence number of the last event handled
+	cursor atomic.Int64
+}
+
+// NewEventBus creates an event bus, filling unset options with their
+// defaults. Events are only dispatched while Run is running or when Flush
+// is called.
+func NewEventBus(repo *repository.OutboxRepository, opts EventBusOptions) *EventBus {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultEventBusMaxAttempts
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = DefaultEventBusBaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultEventBusMaxDelay
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultEventBusPollInterval
+	}
+
+	return &EventBus{
+		repo: repo,
+		opts: opts,
+	}
+}
+
+// Subscribe registers a subscriber. Its place in the outbox is kept under
+// name across restarts; a name seen for the first time starts with the
+// events recorded after this call. Subscribe must be called before Run.
+func (b *EventBus) Subscribe(name string, subscriber Subscriber) error {
+	cursor, ok, err := b.repo.Cursor(name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if cursor, err = b.repo.Latest(); err != nil {
+			return err
+		}
+		if err := b.repo.SaveCursor(name, cursor); err != nil {
+			return err
+		}
+	}
+
+	sub := &subscription{
+		name:       name,
+		subscriber: subscriber,
+		wake:       make(chan struct{}, 1),
+	}
+	sub.cursor.Store(cursor)
+	b.subscriptions = append(b.subscriptions, sub)
+	return nil
+}
+
+// Notify wakes the dispatchers once new events are committed
+func (b *EventBus) Notify() {
+	for _, sub := range b.subscriptions {
+		select {
+		case sub.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run dispatches events until ctx is done, each subscriber on its own
+// goroutine so a failing one does not hold up the others. Events not yet
+// handled stay in the outbox for the next start.
+func (b *EventBus) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, sub := range b.subscriptions {
+		wg.Add(1)
+		go func(sub *subscription) {
+			defer wg.Done()
+			b.run(ctx, sub)
+		}(sub)
+	}
+
+	ticker := time.NewTicker(outboxPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			b.prune()
+		}
+	}
+}
+
+// Flush hands every pending event to every subscriber, returning once they
+// are handled or at the first error reading the outbox
+func (b *EventBus) Flush(ctx context.Context) error {
+	for _, sub := range b.subscriptions {
+		if err := b.dispatch(ctx, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run dispatches the events of one subscriber until ctx is done
+func (b *EventBus) run(ctx context.Context, sub *subscription) {
+	for {
+		if err := b.dispatch(ctx, sub); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to dispatch events to %s: %v", sub.name, err)
+		}
+
+		timer := time.NewTimer(b.opts.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-sub.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// dispatch hands the events after the subscriber's cursor to it, in order
+func (b *EventBus) dispatch(ctx context.Context, sub *subscription) error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	for {
+		events, err := b.repo.Since(sub.cursor.Load(), outboxBatch)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		for _, entry := range events {
+			if err := b.deliver(ctx, sub, entry.Event); err != nil {
+				return err
+			}
+			if err := b.repo.SaveCursor(sub.name, entry.Sequence); err != nil {
+				return err
+			}
+			sub.cursor.Store(entry.Sequence)
+		}
+	}
+}
+
+// deliver hands an event to a subscriber, retrying failures with backoff.
+// An event still failing after MaxAttempts is logged and skipped, so one
+// bad event cannot stop the subscriber for good. Only a done ctx is
+// returned as an error.
+func (b *EventBus) deliver(ctx context.Context, sub *subscription, event *models.Event) error {
+	for attempt := 1; ; attempt++ {
+		err := sub.subscriber.Handle(ctx, event)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= b.opts.MaxAttempts {
+			log.Printf("Skipping %s event %s for %s after %d attempts: %v", event.Type, event.ID, sub.name, attempt, err)
+			return nil
+		}
+		log.Printf("Failed to handle %s event %s in %s (attempt %d): %v", event.Type, event.ID, sub.name, attempt, err)
+
+		timer := time.NewTimer(exponentialBackoff(attempt, b.opts.BaseDelay, b.opts.MaxDelay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// prune removes the events every subscriber has handled
+func (b *EventBus) prune() {
+	if len(b.subscriptions) == 0 {
+		return
+	}
+
+	handled := b.subscriptions[0].cursor.Load()
+	for _, sub := range b.subscriptions[1:] {
+		if cursor := sub.cursor.Load(); cursor < handled {
+			handled = cursor
+		}
+	}
+	if _, err := b.repo.DeleteThrough(handled); err != nil {
+		log.Printf("Failed to prune outbox: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// flakySubscriber fails the first failures attempts at each event
+type flakySubscriber struct {
+	recordingSubscriber
+	failures int
+	attempts map[string]int
+}
+
+func (f *flakySubscriber) Handle(ctx context.Context, event *models.Event) error {
+	if f.attempts == nil {
+		f.attempts = make(map[string]int)
+	}
+	f.attempts[event.ID]++
+	if f.attempts[event.ID] <= f.failures {
+		return fmt.Errorf("attempt %d failed", f.attempts[event.ID])
+	}
+	return f.recordingSubscriber.Handle(ctx, event)
+}
+
+// appendEvents records category events named after the given categories
+func appendEvents(t *testing.T, outbox *repository.OutboxRepository, categories ...string) {
+	t.Helper()
+	for _, category := range categories {
+		event := models.NewEvent(models.EventCategoryChanged, models.EventData{Category: category, Action: models.CategoryAdded})
+		if err := outbox.Append(event); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+}
+
+// categories lists the categories of the recorded events
+func (r *recordingSubscriber) categories() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, event := range r.events {
+		out = append(out, event.Data.Category)
+	}
+	return out
+}
+
+func TestEventBus_DeliversInOrderWithRetries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	outbox := repository.NewOutboxRepository(db)
+	// Events recorded before a subscriber first registers are not its
+	appendEvents(t, outbox, "before")
+
+	steady := &recordingSubscriber{}
+	flaky := &flakySubscriber{failures: 2}
+	bus := newTestBus(t, db, steady, flaky)
+
+	appendEvents(t, outbox, "a", "b", "c")
+	flushBus(t, bus)
+
+	for name, got := range map[string][]string{"steady": steady.categories(), "flaky": flaky.categories()} {
+		if fmt.Sprint(got) != "[a b c]" {
+			t.Errorf("%s subscriber got %v, want [a b c]", name, got)
+		}
+	}
+	for id, attempts := range flaky.attempts {
+		if attempts != 3 {
+			t.Errorf("event %s took %d attempts, want 3", id, attempts)
+		}
+	}
+
+	// An event still failing after the last attempt is skipped
+	bus = NewEventBus(outbox, EventBusOptions{MaxAttempts: 2, BaseDelay: time.Millisecond})
+	broken := &flakySubscriber{failures: 5}
+	if err := bus.Subscribe("broken", broken); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	appendEvents(t, outbox, "lost")
+	flushBus(t, bus)
+	if cursor, _, err := outbox.Cursor("broken"); err != nil || cursor != 5 {
+		t.Errorf("Cursor() after skipping = %d, %v, want 5", cursor, err)
+	}
+	if len(broken.events) != 0 {
+		t.Errorf("broken subscriber handled %v", broken.categories())
+	}
+}
+
+func TestEventBus_ResumesAfterRestart(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	outbox := repository.NewOutboxRepository(db)
+	first := &recordingSubscriber{}
+	bus := newTestBus(t, db, first)
+	appendEvents(t, outbox, "a", "b")
+	flushBus(t, bus)
+
+	// Events recorded while the process is down are handed to the same
+	// subscriber once it registers again, and handled ones are not
+	appendEvents(t, outbox, "c")
+	second := &recordingSubscriber{}
+	bus = newTestBus(t, db, second)
+	flushBus(t, bus)
+	if got := second.categories(); fmt.Sprint(got) != "[c]" {
+		t.Errorf("after restart got %v, want [c]", got)
+	}
+
+	// Events every subscriber has handled are removed
+	bus.prune()
+	if events, err := outbox.Since(0, 10); err != nil || len(events) != 0 {
+		t.Errorf("outbox after pruning = %d events, %v, want none", len(events), err)
+	}
+}
+
+func TestEventBus_Run(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	recorder := &recordingSubscriber{}
+	// The poll interval is long, so only Notify can wake the dispatcher
+	bus := NewEventBus(repository.NewOutboxRepository(db), EventBusOptions{PollInterval: time.Hour})
+	if err := bus.Subscribe("recorder", recorder); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	quotes := NewQuoteService(repository.NewQuoteRepository(db))
+	quotes.SetEventBus(bus)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bus.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	if _, err := quotes.CreateQuote(&models.Quote{Text: "Dispatched straight away", Author: "Author", Category: "news"}); err != nil {
+		t.Fatalf("CreateQuote() error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(recorder.summary()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("events = %v, want quote.created and category.changed", recorder.summary())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// DefaultEventRetention is how long events stay in the log
+const DefaultEventRetention = 7 * 24 * time.Hour
+
+// eventPruneInterval limits how often expired events are removed
+const eventPruneInterval = time.Hour
+
+// EventLog keeps the events it is handed in a numbered log, so streams can resume
+// where they left off, and wakes subscribers when new events arrive
+type EventLog struct {
+	repo      *repository.EventRepository
+	retention time.Duration
+
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	closed      bool
+	prunedAt    time.Time
+}
+
+// NewEventLog creates an event log that keeps events for the given time,
+// or DefaultEventRetention when it is not positive
+func NewEventLog(repo *repository.EventRepository, retention time.Duration) *EventLog {
+	if retention <= 0 {
+		retention = DefaultEventRetention
+	}
+	return &EventLog{
+		repo:        repo,
+		retention:   retention,
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Handle appends event to the log and wakes the subscribers. It
+// implements Subscriber; an event that is already logged is not added
+// again.
+func (l *EventLog) Handle(ctx context.Context, event *models.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event %s: %v", event.Type, event.ID, err)
+	}
+
+	_, err = l.repo.Append(&models.LoggedEvent{
+		ID:        event.ID,
+		Type:      event.Type,
+		Category:  event.Category(),
+		Payload:   payload,
+		CreatedAt: event.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	l.prune()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel that receives a value whenever events have
+// been logged since the last receive, and a function that ends the
+// subscription. The channel is closed when the log is closed.
+func (l *EventLog) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	l.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subscribers[ch]; ok {
+			delete(l.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Since returns up to limit logged events following sequence number seq,
+// in order
+func (l *EventLog) Since(seq int64, limit int) ([]*models.LoggedEvent, error) {
+	return l.repo.Since(seq, limit)
+}
+
+// Bounds returns the sequence numbers of the oldest and newest events
+// still in the log, both 0 when it is empty
+func (l *EventLog) Bounds() (oldest, newest int64, err error) {
+	return l.repo.Bounds()
+}
+
+// Close ends every subscription, so streams finish before shutdown
+func (l *EventLog) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	for ch := range l.subscribers {
+		delete(l.subscribers, ch)
+		close(ch)
+	}
+}
+
+// prune removes expired events, at most once per eventPruneInterval
+func (l *EventLog) prune() {
+	now := time.Now()
+	l.mu.Lock()
+	due := now.Sub(l.prunedAt) >= eventPruneInterval
+	if due {
+		l.prunedAt = now
+	}
+	l.mu.Unlock()
+
+	if !due {
+		return
+	}
+	if _, err := l.repo.DeleteBefore(now.Add(-l.retention)); err != nil {
+		log.Printf("Failed to prune event log: %v", err)
+	}
+}
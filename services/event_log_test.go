@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+func TestEventLog(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	eventLog := NewEventLog(repository.NewEventRepository(db), 0)
+	recorder := &recordingSubscriber{}
+	bus := newTestBus(t, db, eventLog, recorder)
+	quotes := NewQuoteService(repository.NewQuoteRepository(db))
+	quotes.SetEventBus(bus)
+
+	notify, unsubscribe := eventLog.Subscribe()
+	defer unsubscribe()
+
+	quote, err := quotes.CreateQuote(&models.Quote{Text: "Logged for later readers", Author: "Scribe", Category: "history"})
+	if err != nil {
+		t.Fatalf("CreateQuote() error = %v", err)
+	}
+	flushBus(t, bus)
+	// Events handed over again are not logged twice
+	for _, event := range recorder.events {
+		if err := eventLog.Handle(context.Background(), event); err != nil {
+			t.Fatalf("Handle() of a logged event error = %v", err)
+		}
+	}
+
+	select {
+	case <-notify:
+	default:
+		t.Fatal("subscriber was not notified of the new events")
+	}
+
+	events, err := eventLog.Since(0, 10)
+	if err != nil {
+		t.Fatalf("Since() error = %v", err)
+	}
+	if len(events) != 2 || len(recorder.events) != 2 {
+		t.Fatalf("logged %d events and recorded %d, want 2 each", len(events), len(recorder.events))
+	}
+	if events[0].Type != models.EventQuoteCreated || events[0].Category != "history" || events[0].ID != recorder.events[0].ID {
+		t.Errorf("first logged event = %+v", events[0])
+	}
+	if events[1].Type != models.EventCategoryChanged || events[1].Category != "history" {
+		t.Errorf("second logged event = %+v", events[1])
+	}
+
+	var payload models.Event
+	if err := json.Unmarshal(events[0].Payload, &payload); err != nil || payload.Data.Quote == nil || payload.Data.Quote.ID != quote.ID {
+		t.Errorf("logged payload = %s, %v", events[0].Payload, err)
+	}
+
+	if oldest, newest, err := eventLog.Bounds(); err != nil || oldest != 1 || newest != 2 {
+		t.Errorf("Bounds() = %d, %d, %v, want 1, 2", oldest, newest, err)
+	}
+
+	eventLog.Close()
+	if _, ok := <-notify; ok {
+		t.Error("Close() left the subscription open")
+	}
+	late, _ := eventLog.Subscribe()
+	if _, ok := <-late; ok {
+		t.Error("Subscribe() after Close() returned an open channel")
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// Subscriber handles the events dispatched by an EventBus. The same event
+// may be handed over more than once, so handling it again must be
+// harmless. A returned error has the event handed over again later.
+type Subscriber interface {
+	Handle(ctx context.Context, event *models.Event) error
+}
+
+// SubscriberFunc adapts a function to a Subscriber
+type SubscriberFunc func(ctx context.Context, event *models.Event) error
+
+// Handle implements Subscriber
+func (f SubscriberFunc) Handle(ctx context.Context, event *models.Event) error {
+	return f(ctx, event)
+}
+
+// eventSource records the events describing the changes a service makes
+// in the outbox, in the transaction that makes them. It is embedded by the
+// services that change quotes.
+type eventSource struct {
+	bus *EventBus
+}
+
+// SetEventBus registers the bus dispatching the events of changes made
+// through the service. It must be called before the service is used.
+// Without one, no events are recorded.
+func (s *eventSource) SetEventBus(bus *EventBus) {
+	s.bus = bus
+}
+
+// write runs fn in a transaction and records the events it returns in the
+// outbox within the same transaction, then wakes the bus. An error from fn
+// rolls everything back and is returned unchanged.
+func (s *eventSource) write(quoteRepo *repository.QuoteRepository, fn func(tx *repository.Tx) ([]*models.Event, error)) error {
+	err := quoteRepo.InTx(func(tx *repository.Tx) error {
+		events, err := fn(tx)
+		if err != nil || s.bus == nil {
+			return err
+		}
+		return tx.Outbox.Append(events...)
+	})
+	if err == nil && s.bus != nil {
+		s.bus.Notify()
+	}
+	return err
+}
+
+// categoryChange returns events with category.changed appended when a
+// category has just gained its first quote, if added is set, or lost its
+// last one otherwise
+func categoryChange(quotes *repository.QuoteRepository, events []*models.Event, category string, added bool) ([]*models.Event, error) {
+	count, err := quotes.CountByCategory(category)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case added && count == 1:
+		events = append(events, models.NewEvent(models.EventCategoryChanged, models.EventData{Category: category, Action: models.CategoryAdded}))
+	case !added && count == 0:
+		events = append(events, models.NewEvent(models.EventCategoryChanged, models.EventData{Category: category, Action: models.CategoryRemoved}))
+	}
+	return events, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"io"
+	"time"
+
+	"quote-vault/exporters"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// ExportOptions selects the quotes to export
+type ExportOptions struct {
+	Filter models.QuoteFilter
+	// Title names the book for formats with a title page, such as EPUB and
+	// PDF; when empty a default title is used
+	Title string
+	// Name is the base name of the download, given to the files inside
+	// archive formats
+	Name string
+}
+
+type ExportService struct {
+	quoteRepo *repository.QuoteRepository
+}
+
+func NewExportService(quoteRepo *repository.QuoteRepository) *ExportService {
+	return &ExportService{
+		quoteRepo: quoteRepo,
+	}
+}
+
+// Export streams every quote matching the filter to w in the given format.
+// Quotes are written as they are read, so the full result set is never
+// held in memory.
+func (s *ExportService) Export(w io.Writer, format exporters.Format, opts ExportOptions) error {
+	writer := format.NewWriter(w)
+	if book, ok := writer.(exporters.MetadataWriter); ok {
+		book.SetMetadata(exporters.Metadata{
+			Title:       opts.Title,
+			Description: describeFilter(opts.Filter),
+			Date:        time.Now().UTC(),
+			Name:        opts.Name,
+		})
+	}
+
+	err := s.quoteRepo.Each(opts.Filter, func(quote *models.Quote) error {
+		return writer.WriteQuote(quote)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// describeFilter sums up a selection of quotes for a title page, such as
+// "Quotes by Oscar Wilde in wit from 2024"
+func describeFilter(filter models.QuoteFilter) string {
+	const day = "2 January 2006"
+
+	description := "Quotes"
+	if filter.Author != "" {
+		description += " by " + filter.Author
+	}
+	if filter.Category != "" {
+		description += " in " + filter.Category
+	}
+	if filter.Query != "" {
+		description += " mentioning “" + filter.Query + "”"
+	}
+
+	from, to := filter.From.UTC(), filter.To.UTC()
+	switch {
+	case !filter.From.IsZero() && from.Equal(time.Date(from.Year(), 1, 1, 0, 0, 0, 0, time.UTC)) && to.Equal(from.AddDate(1, 0, 0)):
+		description += " from " + from.Format("2006")
+	case !filter.From.IsZero() && !filter.To.IsZero():
+		description += " from " + from.Format(day) + " to " + to.AddDate(0, 0, -1).Format(day)
+	case !filter.From.IsZero():
+		description += " since " + from.Format(day)
+	case !filter.To.IsZero():
+		description += " before " + to.Format(day)
+	}
+	return description
+}
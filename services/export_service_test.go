@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func TestDescribeFilter(t *testing.T) {
+	year := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter models.QuoteFilter
+		want   string
+	}{
+		{"everything", models.QuoteFilter{}, "Quotes"},
+		{"author and category", models.QuoteFilter{Author: "Oscar Wilde", Category: "wit"}, "Quotes by Oscar Wilde in wit"},
+		{"query", models.QuoteFilter{Query: "love"}, "Quotes mentioning “love”"},
+		{"whole year", models.QuoteFilter{From: year, To: year.AddDate(1, 0, 0)}, "Quotes from 2024"},
+		{"date range", models.QuoteFilter{From: year, To: year.AddDate(0, 3, 0)}, "Quotes from 1 January 2024 to 31 March 2024"},
+		{"since", models.QuoteFilter{From: year}, "Quotes since 1 January 2024"},
+		{"before", models.QuoteFilter{To: year}, "Quotes before 1 January 2024"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeFilter(tt.filter); got != tt.want {
+				t.Errorf("describeFilter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"quote-vault/errors"
+	"quote-vault/importers"
+	"quote-vault/models"
+	"quote-vault/repository"
+	"quote-vault/validators"
+)
+
+// importBatchSize is the number of rows written per transaction
+const importBatchSize = 500
+
+// importPreviewRows is the number of rows listed in an import preview
+const importPreviewRows = 20
+
+// ImportOptions controls a bulk import
+type ImportOptions struct {
+	Format string
+	// Source names the service a CSV file was exported from, if any
+	Source string
+	DryRun bool
+	// Preview implies DryRun and reports the applied column mapping and
+	// the mapped values of the first rows
+	Preview bool
+	// DefaultCategory is used for rows that do not name a category; when
+	// empty they fall back to "general" as with CreateQuote
+	DefaultCategory string
+}
+
+type ImportService struct {
+	eventSource
+	replicaGuard
+	quoteRepo *repository.QuoteRepository
+	validator *validators.QuoteValidator
+}
+
+func NewImportService(quoteRepo *repository.QuoteRepository) *ImportService {
+	return &ImportService{
+		quoteRepo: quoteRepo,
+		validator: validators.NewQuoteValidator(),
+	}
+}
+
+// Import parses the payload, validates every row and writes the valid,
+// non-duplicate rows in batched transactions. Each batch commits on its own,
+// so a failure part way through leaves earlier batches in place; the report
+// is then returned with the error, with the rows that were not written
+// marked as not imported. In dry-run mode every batch is rolled back and
+// the report describes what would have happened.
+func (s *ImportService) Import(parser importers.Parser, r io.Reader, opts ImportOptions) (*models.ImportReport, error) {
+	if opts.Preview {
+		opts.DryRun = true
+	}
+	if !opts.DryRun {
+		if err := s.checkCreate(); err != nil {
+			return nil, err
+		}
+	}
+
+	records, err := parser.Parse(r)
+	if err != nil {
+		return nil, errors.NewValidationError("Invalid import file", err.Error())
+	}
+
+	report := &models.ImportReport{
+		Format:  opts.Format,
+		Source:  opts.Source,
+		DryRun:  opts.DryRun,
+		Preview: opts.Preview,
+		Total:   len(records),
+		Rows:    make([]models.ImportRowResult, len(records)),
+	}
+	if reporter, ok := parser.(importers.MappingReporter); ok && opts.Preview {
+		report.Mapping = reporter.Mapping()
+	}
+
+	// Categories that existed before the import, extended as batches
+	// commit, so each new one is announced once
+	var categories map[string]bool
+	if s.bus != nil && !opts.DryRun {
+		existing, err := s.quoteRepo.GetCategories()
+		if err != nil {
+			return nil, err
+		}
+		categories = make(map[string]bool, len(existing))
+		for _, category := range existing {
+			categories[category] = true
+		}
+	}
+
+	seen := make(map[string]int)
+	var batch []*models.Quote
+	var batchRows []int
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		skipped, err := s.createBatch(batch, opts.DryRun, categories)
+		if err != nil {
+			return err
+		}
+		for i, quote := range batch {
+			result := &report.Rows[batchRows[i]]
+			if skipped[i] {
+				result.Status = models.ImportStatusSkippedDuplicate
+				result.Reason = "quote already exists"
+				report.Skipped++
+				continue
+			}
+			result.Status = models.ImportStatusCreated
+			if !opts.DryRun {
+				result.QuoteID = quote.ID
+			}
+			report.Created++
+		}
+		batch = batch[:0]
+		batchRows = batchRows[:0]
+		return nil
+	}
+
+	// abort marks the rows of the failed batch and those after it
+	abort := func(err error) (*models.ImportReport, error) {
+		for i := range report.Rows {
+			result := &report.Rows[i]
+			if result.Status == "" {
+				result.Row = records[i].Row
+				result.Status = models.ImportStatusNotImported
+				report.NotImported++
+			}
+		}
+		return report, err
+	}
+
+	for i, record := range records {
+		result := &report.Rows[i]
+		result.Row = record.Row
+
+		if record.Err != nil {
+			result.Status = models.ImportStatusInvalid
+			result.Reason = record.Err.Error()
+			report.Invalid++
+			continue
+		}
+
+		quote := &models.Quote{
+			Text:     strings.TrimSpace(record.Text),
+			Author:   strings.TrimSpace(record.Author),
+			Category: strings.TrimSpace(record.Category),
+			Source:   strings.TrimSpace(record.Source),
+			Tags:     models.NormalizeTags(record.Tags),
+			Likes:    record.Likes,
+		}
+		if quote.Category == "" {
+			quote.Category = strings.TrimSpace(opts.DefaultCategory)
+		}
+		if quote.Category == "" {
+			quote.Category = "general"
+		}
+
+		if opts.Preview {
+			result.Quote = quote
+		}
+
+		err := s.validator.ValidateQuote(quote.Text, quote.Author, quote.Category)
+		if err == nil {
+			err = s.validator.ValidateSource(quote.Source)
+		}
+		if err == nil {
+			err = s.validator.ValidateTags(quote.Tags)
+		}
+		if err != nil {
+			result.Status = models.ImportStatusInvalid
+			result.Reason = err.Error()
+			report.Invalid++
+			continue
+		}
+
+		key := strings.ToLower(quote.Author) + "\x00" + strings.ToLower(quote.Text)
+		if firstRow, ok := seen[key]; ok {
+			result.Status = models.ImportStatusSkippedDuplicate
+			result.Reason = fmt.Sprintf("duplicate of row %d", firstRow)
+			report.Skipped++
+			continue
+		}
+		seen[key] = record.Row
+
+		batch = append(batch, quote)
+		batchRows = append(batchRows, i)
+		if len(batch) >= importBatchSize {
+			if err := flush(); err != nil {
+				return abort(err)
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return abort(err)
+	}
+
+	if opts.Preview && len(report.Rows) > importPreviewRows {
+		report.Rows = report.Rows[:importPreviewRows]
+	}
+
+	return report, nil
+}
+
+// createBatch writes a batch along with the events for its new quotes.
+// categories holds the categories already announced and gains those the
+// batch adds once it commits.
+func (s *ImportService) createBatch(batch []*models.Quote, dryRun bool, categories map[string]bool) ([]bool, error) {
+	if dryRun {
+		return s.quoteRepo.CreateBatch(batch, true)
+	}
+
+	var skipped []bool
+	var added []string
+	err := s.write(s.quoteRepo, func(tx *repository.Tx) ([]*models.Event, error) {
+		var err error
+		if skipped, err = tx.Quotes.CreateBatch(batch, false); err != nil {
+			return nil, err
+		}
+
+		var events []*models.Event
+		added = added[:0]
+		for i, quote := range batch {
+			if skipped[i] {
+				continue
+			}
+			events = append(events, models.NewEvent(models.EventQuoteCreated, models.EventData{Quote: quote}))
+			if categories != nil && !categories[quote.Category] && !contains(added, quote.Category) {
+				added = append(added, quote.Category)
+				events = append(events, models.NewEvent(models.EventCategoryChanged, models.EventData{Category: quote.Category, Action: models.CategoryAdded}))
+			}
+		}
+		return events, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, category := range added {
+		categories[category] = true
+	}
+	return skipped, nil
+}
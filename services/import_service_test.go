@@ -0,0 +1,237 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"quote-vault/importers"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+func TestImportService_Import(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewQuoteRepository(db)
+	service := NewImportService(repo)
+
+	if _, err := repo.Create(&models.Quote{
+		Text:     "Existing quote already in the vault",
+		Author:   "Someone",
+		Category: "test",
+	}); err != nil {
+		t.Fatalf("failed to create test quote: %v", err)
+	}
+
+	input := "text,author,category\n" +
+		"The first imported quote text,Author One,wisdom\n" +
+		"existing quote already in the vault,someone,test\n" +
+		"Too short,Author Two,wisdom\n" +
+		"The first imported quote text,Author One,wisdom\n" +
+		"A quote without a category,Author Three,\n"
+
+	report, err := service.Import(importers.NewCSVParser(importers.ColumnMapping{}), strings.NewReader(input), ImportOptions{Format: importers.FormatCSV})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if report.Total != 5 || report.Created != 2 || report.Skipped != 2 || report.Invalid != 1 {
+		t.Errorf("Import() totals = %d/%d/%d/%d, want 5/2/2/1", report.Total, report.Created, report.Skipped, report.Invalid)
+	}
+
+	wantStatuses := []string{
+		models.ImportStatusCreated,
+		models.ImportStatusSkippedDuplicate,
+		models.ImportStatusInvalid,
+		models.ImportStatusSkippedDuplicate,
+		models.ImportStatusCreated,
+	}
+	for i, want := range wantStatuses {
+		if report.Rows[i].Status != want {
+			t.Errorf("Import() row %d status = %v, want %v", i+1, report.Rows[i].Status, want)
+		}
+	}
+	if report.Rows[0].QuoteID == 0 {
+		t.Error("Import() did not report created quote ID")
+	}
+
+	quotes, total, err := repo.GetByCategory("general", 10, 0)
+	if err != nil {
+		t.Fatalf("GetByCategory() error = %v", err)
+	}
+	if total != 1 || quotes[0].Author != "Author Three" {
+		t.Errorf("Import() did not default category to general")
+	}
+}
+
+func TestImportService_Import_DryRun(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewQuoteRepository(db)
+	service := NewImportService(repo)
+
+	input := `{"text":"A dry run quote that is long enough","author":"Tester"}` + "\n"
+
+	report, err := service.Import(importers.NewJSONLParser(), strings.NewReader(input), ImportOptions{Format: importers.FormatJSONL, DryRun: true})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if !report.DryRun || report.Created != 1 {
+		t.Errorf("Import() dry run report = %+v", report)
+	}
+	if report.Rows[0].QuoteID != 0 {
+		t.Error("Import() dry run reported a quote ID")
+	}
+
+	_, total, err := repo.GetAll(10, 0)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if total != 0 {
+		t.Errorf("Import() dry run persisted %d quotes, want 0", total)
+	}
+}
+
+func TestImportService_Import_InvalidFile(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewImportService(repository.NewQuoteRepository(db))
+
+	_, err := service.Import(importers.NewCSVParser(importers.ColumnMapping{}), strings.NewReader("foo,bar\n1,2\n"), ImportOptions{Format: importers.FormatCSV})
+	if err == nil {
+		t.Error("Import() error = nil, want error for missing columns")
+	}
+}
+
+func TestImportService_Import_PartialFailure(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// The second batch fails on its last row after the first has committed
+	if _, err := db.Exec(`CREATE TRIGGER fail_import BEFORE INSERT ON quotes WHEN NEW.author = 'Breaker'
+		BEGIN SELECT RAISE(ABORT, 'disk full'); END`); err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+
+	repo := repository.NewQuoteRepository(db)
+	service := NewImportService(repo)
+
+	var input strings.Builder
+	input.WriteString("text,author,category\n")
+	for i := 1; i <= importBatchSize+2; i++ {
+		author := "Author"
+		if i == importBatchSize+2 {
+			author = "Breaker"
+		}
+		fmt.Fprintf(&input, "Imported quote number %d,%s,wisdom\n", i, author)
+	}
+	input.WriteString("Too short,Author,wisdom\n")
+
+	report, err := service.Import(importers.NewCSVParser(importers.ColumnMapping{}), strings.NewReader(input.String()), ImportOptions{Format: importers.FormatCSV})
+	if err == nil {
+		t.Fatal("Import() error = nil, want the failure of the second batch")
+	}
+	if report == nil {
+		t.Fatal("Import() report = nil, want the rows imported before the failure")
+	}
+	if report.Created != importBatchSize || report.NotImported != 2 || report.Invalid != 1 {
+		t.Errorf("Import() created %d, not imported %d, invalid %d, want %d, 2, 1",
+			report.Created, report.NotImported, report.Invalid, importBatchSize)
+	}
+	if report.Rows[0].Status != models.ImportStatusCreated || report.Rows[0].QuoteID == 0 {
+		t.Errorf("Import() first row = %+v, want it created", report.Rows[0])
+	}
+	for _, result := range report.Rows[importBatchSize : importBatchSize+2] {
+		if result.Status != models.ImportStatusNotImported || result.Row == 0 {
+			t.Errorf("Import() row %d = %+v, want it not imported", result.Row, result)
+		}
+	}
+
+	if _, total, err := repo.GetByCategory("wisdom", 1, 0); err != nil || total != importBatchSize {
+		t.Errorf("stored %d quotes, %v, want the %d of the first batch", total, err, importBatchSize)
+	}
+}
+
+func TestImportService_Import_Preview(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewQuoteRepository(db)
+	service := NewImportService(repo)
+
+	var input strings.Builder
+	input.WriteString("Quote,Author,Book,Tags,Likes\n")
+	for i := 0; i < importPreviewRows+5; i++ {
+		fmt.Fprintf(&input, "\"Preview quote number %d\",Author,The Book,wisdom,%d\n", i, i)
+	}
+
+	mapping, _ := importers.SourceMapping(importers.SourceGoodreads)
+	report, err := service.Import(importers.NewCSVParser(mapping), strings.NewReader(input.String()), ImportOptions{
+		Format:  importers.FormatCSV,
+		Source:  importers.SourceGoodreads,
+		Preview: true,
+	})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if !report.DryRun || report.Created != importPreviewRows+5 || len(report.Rows) != importPreviewRows {
+		t.Errorf("Import() preview created/rows = %d/%d, dry run %v", report.Created, len(report.Rows), report.DryRun)
+	}
+	if report.Mapping["source"] != "Book" {
+		t.Errorf("Import() preview mapping = %v", report.Mapping)
+	}
+
+	quote := report.Rows[1].Quote
+	if quote == nil || quote.Source != "The Book" || quote.Category != "wisdom" || quote.Likes != 1 {
+		t.Errorf("Import() preview quote = %+v", quote)
+	}
+
+	_, total, _ := repo.GetAll(10, 0)
+	if total != 0 {
+		t.Errorf("Import() preview persisted %d quotes, want 0", total)
+	}
+}
+
+func TestImportService_Import_PublishesEvents(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := repository.NewQuoteRepository(db)
+	service := NewImportService(repo)
+	recorder := &recordingSubscriber{}
+	bus := newTestBus(t, db, recorder)
+	service.SetEventBus(bus)
+
+	if _, err := repo.Create(&models.Quote{Text: "Existing quote already in the vault", Author: "Someone", Category: "wisdom"}); err != nil {
+		t.Fatalf("failed to create test quote: %v", err)
+	}
+
+	input := "text,author,category\n" +
+		"The first imported quote text,Author One,wisdom\n" +
+		"The second imported quote text,Author Two,travel\n" +
+		"The third imported quote text,Author Three,travel\n"
+	parser := importers.NewCSVParser(importers.ColumnMapping{})
+
+	if _, err := service.Import(parser, strings.NewReader(input), ImportOptions{Format: importers.FormatCSV, DryRun: true}); err != nil {
+		t.Fatalf("Import() dry run error = %v", err)
+	}
+	flushBus(t, bus)
+	if len(recorder.events) != 0 {
+		t.Errorf("dry run recorded %v, want no events", recorder.summary())
+	}
+
+	if _, err := service.Import(parser, strings.NewReader(input), ImportOptions{Format: importers.FormatCSV}); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	flushBus(t, bus)
+	want := []string{"quote.created", "quote.created", "category.changed:travel:added", "quote.created"}
+	if got := recorder.summary(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
@@ -1,12 +1,17 @@
 package services
 
 import (
+	"hash/fnv"
+	"time"
+
 	"quote-vault/errors"
 	"quote-vault/models"
 	"quote-vault/repository"
 )
 
 type QuoteService struct {
+	eventSource
+	replicaGuard
 	quoteRepo *repository.QuoteRepository
 }
 
@@ -17,6 +22,10 @@ func NewQuoteService(quoteRepo *repository.QuoteRepository) *QuoteService {
 }
 
 func (s *QuoteService) CreateQuote(quote *models.Quote) (*models.Quote, error) {
+	if err := s.checkCreate(); err != nil {
+		return nil, err
+	}
+
 	if quote.Text == "" {
 		return nil, errors.ErrEmptyQuoteText
 	}
@@ -29,7 +38,104 @@ func (s *QuoteService) CreateQuote(quote *models.Quote) (*models.Quote, error) {
 		quote.Category = "general"
 	}
 
-	return s.quoteRepo.Create(quote)
+	var created *models.Quote
+	err := s.write(s.quoteRepo, func(tx *repository.Tx) ([]*models.Event, error) {
+		var err error
+		if created, err = tx.Quotes.Create(quote); err != nil {
+			return nil, err
+		}
+		events := []*models.Event{models.NewEvent(models.EventQuoteCreated, models.EventData{Quote: created})}
+		return categoryChange(tx.Quotes, events, created.Category, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// UpdateQuote saves changes to an existing quote and returns it with its
+// new version. A non-zero Version must match the stored one, otherwise
+// ErrQuoteModified is returned.
+func (s *QuoteService) UpdateQuote(quote *models.Quote) (*models.Quote, error) {
+	if quote.ID <= 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	if err := s.checkEdit(); err != nil {
+		return nil, err
+	}
+
+	if quote.Text == "" {
+		return nil, errors.ErrEmptyQuoteText
+	}
+
+	if quote.Author == "" {
+		return nil, errors.ErrEmptyAuthor
+	}
+
+	if quote.Category == "" {
+		quote.Category = "general"
+	}
+
+	var updated *models.Quote
+	err := s.write(s.quoteRepo, func(tx *repository.Tx) ([]*models.Event, error) {
+		previous, err := tx.Quotes.GetByID(quote.ID)
+		if err != nil {
+			return nil, err
+		}
+		if updated, err = tx.Quotes.Update(quote); err != nil {
+			return nil, err
+		}
+
+		data := models.EventData{Quote: updated}
+		if updated.Category != previous.Category {
+			data.PreviousCategory = previous.Category
+		}
+		events := []*models.Event{models.NewEvent(models.EventQuoteUpdated, data)}
+		if updated.Category == previous.Category {
+			return events, nil
+		}
+		if events, err = categoryChange(tx.Quotes, events, previous.Category, false); err != nil {
+			return nil, err
+		}
+		return categoryChange(tx.Quotes, events, updated.Category, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteQuote removes a quote and returns it as it was before deletion
+func (s *QuoteService) DeleteQuote(id int) (*models.Quote, error) {
+	if id <= 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	if err := s.checkEdit(); err != nil {
+		return nil, err
+	}
+
+	var quote *models.Quote
+	err := s.write(s.quoteRepo, func(tx *repository.Tx) ([]*models.Event, error) {
+		var err error
+		if quote, err = tx.Quotes.GetByID(id); err != nil {
+			return nil, err
+		}
+		if err := tx.Quotes.Delete(id); err != nil {
+			return nil, err
+		}
+
+		events := []*models.Event{models.NewEvent(models.EventQuoteDeleted, models.EventData{Quote: quote})}
+		return categoryChange(tx.Quotes, events, quote.Category, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return quote, nil
 }
 
 func (s *QuoteService) GetQuotes(limit, offset int, category string) ([]*models.Quote, int, error) {
@@ -56,4 +162,49 @@ func (s *QuoteService) GetRandomQuote(category string) (*models.Quote, error) {
 
 func (s *QuoteService) GetCategories() ([]string, error) {
 	return s.quoteRepo.GetCategories()
-}
\ No newline at end of file
+}
+
+// SearchQuotes returns a page of the quotes matching filter, newest first,
+// and the total number of matches
+func (s *QuoteService) SearchQuotes(filter models.QuoteFilter, limit, offset int) ([]*models.Quote, int, error) {
+	if limit <= 0 || offset < 0 {
+		return nil, 0, errors.ErrInvalidPagination
+	}
+	return s.quoteRepo.Search(filter, limit, offset)
+}
+
+// GetAuthors returns every author with the number of their quotes
+func (s *QuoteService) GetAuthors() ([]models.AuthorCount, error) {
+	return s.quoteRepo.GetAuthors()
+}
+
+// GetRecentQuotes returns the newest quotes, optionally restricted to a
+// category and an author
+func (s *QuoteService) GetRecentQuotes(category, author string, limit int) ([]*models.Quote, error) {
+	return s.quoteRepo.GetRecent(category, author, limit)
+}
+
+// LastChanged returns when any quote was last created, edited or deleted,
+// or the zero time when none has been
+func (s *QuoteService) LastChanged() (time.Time, error) {
+	return s.quoteRepo.LastChanged()
+}
+
+// GetDailyQuote returns the quote of the day for the UTC date of day,
+// optionally from a single category. The same date always gives the same
+// quote, chosen among the quotes that existed by the end of that day.
+func (s *QuoteService) GetDailyQuote(day time.Time, category string) (*models.Quote, error) {
+	start := StartOfDay(day)
+
+	h := fnv.New64a()
+	h.Write([]byte(start.Format("2006-01-02")))
+	h.Write([]byte(category))
+
+	return s.quoteRepo.GetNth(h.Sum64(), start.AddDate(0, 0, 1), category)
+}
+
+// StartOfDay returns midnight UTC at the start of the UTC date of t
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -1,8 +1,12 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"quote-vault/models"
@@ -21,16 +25,194 @@ func setupTestDB(t *testing.T) *sql.DB {
 			text TEXT NOT NULL,
 			author TEXT NOT NULL,
 			category TEXT NOT NULL,
+			source TEXT NOT NULL DEFAULT '',
+			tags TEXT NOT NULL DEFAULT '',
+			likes INTEGER NOT NULL DEFAULT 0,
+			version INTEGER NOT NULL DEFAULT 1,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
+		);
+		CREATE TABLE webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			url TEXT NOT NULL,
+			events TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			previous_secret TEXT NOT NULL DEFAULT '',
+			previous_secret_expires_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			event_id TEXT NOT NULL,
+			event TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_status INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME,
+			last_attempt_at DATETIME,
+			delivered_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE events (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			category TEXT NOT NULL DEFAULT '',
+			payload TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE quote_changes (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			quote_id INTEGER NOT NULL UNIQUE,
+			deleted INTEGER NOT NULL DEFAULT 0,
+			version INTEGER NOT NULL DEFAULT 0,
+			changed_at DATETIME
+		);
+		CREATE TRIGGER quotes_log_insert AFTER INSERT ON quotes BEGIN
+			INSERT OR REPLACE INTO quote_changes (quote_id, deleted, changed_at)
+			VALUES (NEW.id, 0, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+		END;
+		CREATE TRIGGER quotes_log_update AFTER UPDATE ON quotes BEGIN
+			INSERT OR REPLACE INTO quote_changes (quote_id, deleted, changed_at)
+			VALUES (NEW.id, 0, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+		END;
+		CREATE TRIGGER quotes_log_delete AFTER DELETE ON quotes BEGIN
+			INSERT OR REPLACE INTO quote_changes (quote_id, deleted, version, changed_at)
+			VALUES (OLD.id, 1, OLD.version, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+		END;
+		CREATE TABLE metadata (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		CREATE TABLE outbox (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			created_at DATETIME
+		);
+		CREATE TABLE ap_followers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor TEXT NOT NULL,
+			follower TEXT NOT NULL,
+			inbox TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (actor, follower)
+		);
+		CREATE TABLE ap_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor TEXT NOT NULL,
+			inbox TEXT NOT NULL,
+			activity_id TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (inbox, activity_id)
+		);
+		CREATE TABLE digest_subscriptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			email TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			frequency TEXT NOT NULL,
+			categories TEXT NOT NULL DEFAULT '',
+			confirm_token TEXT NOT NULL UNIQUE,
+			unsubscribe_token TEXT NOT NULL UNIQUE,
+			confirmed_at DATETIME,
+			last_sent_at DATETIME,
+			next_digest_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE saved_searches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			category TEXT NOT NULL DEFAULT '',
+			author TEXT NOT NULL DEFAULT '',
+			query TEXT NOT NULL DEFAULT '',
+			webhook_url TEXT NOT NULL DEFAULT '',
+			secret TEXT NOT NULL DEFAULT '',
+			email TEXT NOT NULL DEFAULT '',
+			confirm_token TEXT NOT NULL DEFAULT '',
+			unsubscribe_token TEXT NOT NULL DEFAULT '',
+			confirmed_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE saved_search_matches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			search_id INTEGER NOT NULL,
+			quote_id INTEGER NOT NULL,
+			quote TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME,
+			notified_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (search_id, quote_id)
+		);
+		CREATE UNIQUE INDEX idx_events_event_id ON events (event_id)
 	`)
 	if err != nil {
 		t.Fatalf("failed to create table: %v", err)
 	}
+	// Every connection to ":memory:" is a separate database, and the
+	// webhook worker queries from its own goroutines
+	db.SetMaxOpenConns(1)
 
 	return db
 }
 
+// recordingSubscriber keeps the events it is handed
+type recordingSubscriber struct {
+	mu     sync.Mutex
+	events []*models.Event
+}
+
+func (r *recordingSubscriber) Handle(ctx context.Context, event *models.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// summary lists the events as "type" or "type:category:action"
+func (r *recordingSubscriber) summary() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, event := range r.events {
+		if event.Type == models.EventCategoryChanged {
+			out = append(out, event.Type+":"+event.Data.Category+":"+event.Data.Action)
+			continue
+		}
+		out = append(out, event.Type)
+	}
+	return out
+}
+
+// newTestBus returns an event bus on db with the subscribers registered
+// as "sub0", "sub1" and so on, retrying failures without a noticeable wait
+func newTestBus(t *testing.T, db *sql.DB, subscribers ...Subscriber) *EventBus {
+	t.Helper()
+	bus := NewEventBus(repository.NewOutboxRepository(db), EventBusOptions{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	for i, subscriber := range subscribers {
+		if err := bus.Subscribe(fmt.Sprintf("sub%d", i), subscriber); err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+	}
+	return bus
+}
+
+// flushBus hands the pending events to the subscribers of bus
+func flushBus(t *testing.T, bus *EventBus) {
+	t.Helper()
+	if err := bus.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+}
+
 func TestQuoteService_CreateQuote(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -338,3 +520,158 @@ func TestQuoteService_GetCategories(t *testing.T) {
 		t.Errorf("GetCategories() count = %v, want 3", len(categories))
 	}
 }
+
+func TestQuoteService_GetDailyQuote(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for i := 1; i <= 20; i++ {
+		_, err := db.Exec(`INSERT INTO quotes (text, author, category, created_at) VALUES (?, 'Author', 'test', '2024-01-01 09:00:00')`, fmt.Sprintf("Daily quote number %d", i))
+		if err != nil {
+			t.Fatalf("failed to insert test data: %v", err)
+		}
+	}
+
+	service := NewQuoteService(repository.NewQuoteRepository(db))
+
+	morning := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+	first, err := service.GetDailyQuote(morning, "")
+	if err != nil {
+		t.Fatalf("GetDailyQuote() error = %v", err)
+	}
+	again, _ := service.GetDailyQuote(morning.Add(15*time.Hour), "")
+	if again.ID != first.ID {
+		t.Errorf("GetDailyQuote() changed within a day: %d then %d", first.ID, again.ID)
+	}
+
+	// Across a few weeks the pick should move around the vault
+	seen := map[int]bool{}
+	for day := 0; day < 21; day++ {
+		quote, _ := service.GetDailyQuote(morning.AddDate(0, 0, day), "")
+		seen[quote.ID] = true
+	}
+	if len(seen) < 5 {
+		t.Errorf("GetDailyQuote() picked only %d distinct quotes in 21 days", len(seen))
+	}
+
+	if _, err := service.GetDailyQuote(time.Date(2023, 12, 31, 12, 0, 0, 0, time.UTC), ""); err == nil {
+		t.Error("GetDailyQuote() before any quotes existed should fail")
+	}
+}
+
+func TestQuoteService_UpdateQuote(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewQuoteService(repository.NewQuoteRepository(db))
+	created, err := service.CreateQuote(&models.Quote{Text: "A quote worth keeping", Author: "Author", Category: "test"})
+	if err != nil {
+		t.Fatalf("CreateQuote() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		quote   *models.Quote
+		wantErr bool
+	}{
+		{"invalid ID", &models.Quote{Text: "Some text", Author: "Author"}, true},
+		{"empty text", &models.Quote{ID: created.ID, Author: "Author"}, true},
+		{"empty author", &models.Quote{ID: created.ID, Text: "Some text"}, true},
+		{"valid", &models.Quote{ID: created.ID, Version: created.Version, Text: "A quote worth editing", Author: "Author"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.UpdateQuote(tt.quote)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateQuote() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (result.Category != "general" || result.Version != created.Version+1) {
+				t.Errorf("UpdateQuote() = %+v, want category general at version %d", result, created.Version+1)
+			}
+		})
+	}
+}
+
+func TestQuoteService_PublishesEvents(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewQuoteService(repository.NewQuoteRepository(db))
+	recorder := &recordingSubscriber{}
+	bus := newTestBus(t, db, recorder)
+	service.SetEventBus(bus)
+
+	first, err := service.CreateQuote(&models.Quote{Text: "The first quote about art", Author: "Author", Category: "art"})
+	if err != nil {
+		t.Fatalf("CreateQuote() error = %v", err)
+	}
+	if _, err := service.CreateQuote(&models.Quote{Text: "The second quote about art", Author: "Author", Category: "art"}); err != nil {
+		t.Fatalf("CreateQuote() error = %v", err)
+	}
+	first.Category = "craft"
+	if _, err := service.UpdateQuote(first); err != nil {
+		t.Fatalf("UpdateQuote() error = %v", err)
+	}
+	if _, err := service.DeleteQuote(first.ID); err != nil {
+		t.Fatalf("DeleteQuote() error = %v", err)
+	}
+	if _, err := service.DeleteQuote(first.ID); err == nil {
+		t.Error("DeleteQuote() of a deleted quote succeeded")
+	}
+	flushBus(t, bus)
+
+	want := []string{
+		"quote.created", "category.changed:art:added",
+		"quote.created",
+		"quote.updated", "category.changed:craft:added",
+		"quote.deleted", "category.changed:craft:removed",
+	}
+	if got := recorder.summary(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	created := recorder.events[0]
+	if created.ID == "" || created.CreatedAt.IsZero() || created.Data.Quote == nil || created.Data.Quote.Author != "Author" {
+		t.Errorf("quote.created event = %+v", created)
+	}
+	if updated := recorder.events[3]; updated.Data.Quote.Category != "craft" || updated.Data.PreviousCategory != "art" {
+		t.Errorf("quote.updated event data = %+v, want a move from art to craft", updated.Data)
+	}
+	if deleted := recorder.events[5]; deleted.Data.Quote.ID != first.ID || deleted.Data.Quote.Category != "craft" {
+		t.Errorf("quote.deleted event quote = %+v, want the deleted quote", deleted.Data.Quote)
+	}
+}
+
+func TestQuoteService_FailedWriteRecordsNoEvents(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewQuoteService(repository.NewQuoteRepository(db))
+	recorder := &recordingSubscriber{}
+	bus := newTestBus(t, db, recorder)
+	service.SetEventBus(bus)
+
+	created, err := service.CreateQuote(&models.Quote{Text: "A quote that is edited twice", Author: "Author", Category: "art"})
+	if err != nil {
+		t.Fatalf("CreateQuote() error = %v", err)
+	}
+	stale := *created
+	created.Category = "craft"
+	if _, err := service.UpdateQuote(created); err != nil {
+		t.Fatalf("UpdateQuote() error = %v", err)
+	}
+	stale.Category = "music"
+	if _, err := service.UpdateQuote(&stale); err == nil {
+		t.Fatal("UpdateQuote() of a stale version succeeded")
+	}
+	flushBus(t, bus)
+
+	want := []string{
+		"quote.created", "category.changed:art:added",
+		"quote.updated", "category.changed:art:removed", "category.changed:craft:added",
+	}
+	if got := recorder.summary(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// Defaults for ReplicatorOptions
+const (
+	DefaultReplicationInterval = 5 * time.Second
+	DefaultReplicationMaxLag   = time.Minute
+	DefaultReplicationTimeout  = 10 * time.Second
+)
+
+// replicationBatch is the number of changes requested from the leader at
+// a time
+const replicationBatch = 500
+
+// ReplicatorOptions configures a Replicator. Zero values select defaults.
+type ReplicatorOptions struct {
+	// Leader is the base URL of the instance to follow
+	Leader string
+	// Conflicts is models.ConflictReject (the default) or
+	// models.ConflictLastWriterWins
+	Conflicts string
+	// Interval is the wait between checks for new changes once caught up
+	Interval time.Duration
+	// MaxLag is how long the follower may go without catching up before
+	// it reports itself as lagging
+	MaxLag time.Duration
+	// Client fetches the changes; by default one with a 10 second timeout
+	Client *http.Client
+}
+
+// Replicator makes this instance a follower of another. It pulls the
+// leader's changes feed and applies it to the local database, keeping its
+// position there, so it resumes where it stopped after a restart.
+type Replicator struct {
+	eventSource
+	repo      *repository.ReplicationRepository
+	quoteRepo *repository.QuoteRepository
+	opts      ReplicatorOptions
+	client    *http.Client
+
+	// syncMu allows one sync at a time; seen is only used under it
+	syncMu sync.Mutex
+	// seen holds the quotes sent by the leader during a full resync, nil
+	// when none is under way in this process
+	seen map[int]bool
+
+	mu         sync.Mutex
+	position   string
+	resyncing  bool
+	caughtUpAt time.Time
+	lastSyncAt time.Time
+	lastError  string
+	localWins  int64
+}
+
+// NewReplicator creates a replicator following opts.Leader, filling unset
+// options with their defaults. Changes are only pulled while Run is
+// running or when Sync is called.
+func NewReplicator(repo *repository.ReplicationRepository, quoteRepo *repository.QuoteRepository, opts ReplicatorOptions) (*Replicator, error) {
+	leader, err := url.Parse(opts.Leader)
+	if err != nil || (leader.Scheme != "http" && leader.Scheme != "https") || leader.Host == "" {
+		return nil, fmt.Errorf("invalid leader URL %q: it must be an absolute http or https URL", opts.Leader)
+	}
+	opts.Leader = strings.TrimRight(opts.Leader, "/")
+
+	switch opts.Conflicts {
+	case "":
+		opts.Conflicts = models.ConflictReject
+	case models.ConflictReject, models.ConflictLastWriterWins:
+	default:
+		return nil, fmt.Errorf("invalid conflict handling %q: use %s or %s",
+			opts.Conflicts, models.ConflictReject, models.ConflictLastWriterWins)
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultReplicationInterval
+	}
+	if opts.MaxLag <= 0 {
+		opts.MaxLag = DefaultReplicationMaxLag
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultReplicationTimeout}
+	}
+
+	return &Replicator{
+		repo:      repo,
+		quoteRepo: quoteRepo,
+		opts:      opts,
+		client:    client,
+	}, nil
+}
+
+// Conflicts returns how local writes are handled
+func (r *Replicator) Conflicts() string {
+	return r.opts.Conflicts
+}
+
+// Run syncs with the leader every Interval until ctx is done. Failures are
+// logged and retried at the next interval.
+func (r *Replicator) Run(ctx context.Context) {
+	for {
+		if err := r.Sync(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to replicate from %s: %v", r.opts.Leader, err)
+		}
+
+		timer := time.NewTimer(r.opts.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Sync applies the leader's changes until it has none left. The first
+// sync, a change of leader or a full_resync answer copy the whole vault,
+// and quotes the leader no longer has are removed at the end.
+func (r *Replicator) Sync(ctx context.Context) error {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+
+	err := r.sync(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.lastError = err.Error()
+		return err
+	}
+	r.lastError = ""
+	return nil
+}
+
+func (r *Replicator) sync(ctx context.Context) error {
+	state, err := r.repo.State()
+	if err != nil {
+		return err
+	}
+	if state.Leader != r.opts.Leader || state.Position == "" {
+		state = &models.ReplicationState{Leader: r.opts.Leader, Resyncing: true}
+	}
+	if state.Resyncing && r.seen == nil {
+		// The quotes seen before a restart are not known, so start over
+		state.Position = ""
+		r.seen = make(map[int]bool)
+	}
+
+	for {
+		fetchedAt := time.Now()
+		set, err := r.fetch(ctx, state.Position)
+		if err != nil {
+			return err
+		}
+
+		next := *state
+		next.Position = set.Next
+		if set.FullResync {
+			next.Resyncing = true
+			r.seen = make(map[int]bool)
+		}
+		if next.Resyncing {
+			for _, change := range set.Changes {
+				if change.Type == models.ChangeDelete {
+					delete(r.seen, change.ID)
+				} else {
+					r.seen[change.ID] = true
+				}
+			}
+		}
+
+		finish := next.Resyncing && !set.HasMore
+		var skipped int
+		err = r.write(r.quoteRepo, func(tx *repository.Tx) ([]*models.Event, error) {
+			before, err := r.categories(tx.Quotes)
+			if err != nil {
+				return nil, err
+			}
+			var applied []models.ReplicatedChange
+			applied, skipped, err = tx.Replication.Apply(set.Changes, &next, r.opts.Conflicts == models.ConflictLastWriterWins)
+			if err != nil {
+				return nil, err
+			}
+			if finish {
+				next.Resyncing = false
+				removed, err := tx.Replication.FinishResync(r.seen, &next)
+				if err != nil {
+					return nil, err
+				}
+				for _, quote := range removed {
+					applied = append(applied, models.ReplicatedChange{Previous: quote})
+				}
+			}
+			after, err := r.categories(tx.Quotes)
+			if err != nil {
+				return nil, err
+			}
+			return appliedEvents(applied, before, after), nil
+		})
+		if err != nil {
+			return err
+		}
+		if finish {
+			r.seen = nil
+		}
+		state = &next
+
+		r.mu.Lock()
+		r.position = state.Position
+		r.resyncing = state.Resyncing
+		r.lastSyncAt = time.Now()
+		r.localWins += int64(skipped)
+		if !set.HasMore {
+			r.caughtUpAt = fetchedAt
+		}
+		r.mu.Unlock()
+
+		if !set.HasMore {
+			return nil
+		}
+	}
+}
+
+// fetch requests the page of changes following position from the leader
+func (r *Replicator) fetch(ctx context.Context, position string) (*models.ChangeSet, error) {
+	target := fmt.Sprintf("%s/api/v1/changes?limit=%d", r.opts.Leader, replicationBatch)
+	if position != "" {
+		target += "&since=" + url.QueryEscape(position)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("leader answered %s", resp.Status)
+	}
+
+	var body struct {
+		Data *models.ChangeSet `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid changes from leader: %v", err)
+	}
+	if body.Data == nil || body.Data.Next == "" {
+		return nil, fmt.Errorf("invalid changes from leader: no next token")
+	}
+	return body.Data, nil
+}
+
+// Status reports the follower's position and how far it is behind
+func (r *Replicator) Status() *models.ReplicationStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := &models.ReplicationStatus{
+		Leader:    r.opts.Leader,
+		Conflicts: r.opts.Conflicts,
+		State:     models.ReplicationSyncing,
+		Position:  r.position,
+		LastError: r.lastError,
+		LocalWins: r.localWins,
+	}
+	if !r.lastSyncAt.IsZero() {
+		lastSync := r.lastSyncAt.UTC()
+		status.LastSyncAt = &lastSync
+	}
+	if !r.caughtUpAt.IsZero() {
+		lag := time.Since(r.caughtUpAt)
+		seconds := lag.Seconds()
+		status.LagSeconds = &seconds
+		switch {
+		case r.resyncing:
+		case lag > r.opts.MaxLag:
+			status.State = models.ReplicationLagging
+		default:
+			status.State = models.ReplicationCaughtUp
+		}
+	}
+
+	return status
+}
+
+// categories returns the set of local categories when events are recorded
+func (r *Replicator) categories(quotes *repository.QuoteRepository) (map[string]bool, error) {
+	if r.bus == nil {
+		return nil, nil
+	}
+	categories, err := quotes.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		set[category] = true
+	}
+	return set, nil
+}
+
+// appliedEvents returns the events for replicated changes, so streams and
+// webhooks of the follower see them as they would local ones. before and
+// after hold the categories that existed around the changes.
+func appliedEvents(applied []models.ReplicatedChange, before, after map[string]bool) []*models.Event {
+	var events []*models.Event
+	for _, change := range applied {
+		switch {
+		case change.Previous == nil:
+			events = append(events, models.NewEvent(models.EventQuoteCreated, models.EventData{Quote: change.Quote}))
+		case change.Quote == nil:
+			events = append(events, models.NewEvent(models.EventQuoteDeleted, models.EventData{Quote: change.Previous}))
+		default:
+			data := models.EventData{Quote: change.Quote}
+			if change.Previous.Category != change.Quote.Category {
+				data.PreviousCategory = change.Previous.Category
+			}
+			events = append(events, models.NewEvent(models.EventQuoteUpdated, data))
+		}
+	}
+
+	events = append(events, categoryDiff(after, before, models.CategoryAdded)...)
+	return append(events, categoryDiff(before, after, models.CategoryRemoved)...)
+}
+
+// categoryDiff returns category.changed events with action for each
+// category in from that is missing from to, in name order
+func categoryDiff(from, to map[string]bool, action string) []*models.Event {
+	var categories []string
+	for category := range from {
+		if !to[category] {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	events := make([]*models.Event, 0, len(categories))
+	for _, category := range categories {
+		events = append(events, models.NewEvent(models.EventCategoryChanged, models.EventData{Category: category, Action: action}))
+	}
+	return events
+}
+
+// replicaGuard refuses local writes on a follower that conflict with its
+// replication. It is embedded by the services that change quotes.
+type replicaGuard struct {
+	conflicts string
+}
+
+// SetReplica marks the service as part of a follower handling local writes
+// as conflicts says. It must be called before the service is used.
+func (g *replicaGuard) SetReplica(conflicts string) {
+	g.conflicts = conflicts
+}
+
+// checkCreate refuses new quotes on a follower, since their IDs would clash
+// with those assigned by the leader
+func (g *replicaGuard) checkCreate() error {
+	if g.conflicts != "" {
+		return errors.ErrReplicaWrite
+	}
+	return nil
+}
+
+// checkEdit refuses edits and deletions on a follower that rejects local
+// writes
+func (g *replicaGuard) checkEdit() error {
+	if g.conflicts == models.ConflictReject {
+		return errors.ErrReplicaWrite
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"quote-vault/errors"
+	"quote-vault/importers"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// testLeader is a leader instance serving its changes feed
+type testLeader struct {
+	quotes  *QuoteService
+	changes *repository.ChangeRepository
+	server  *httptest.Server
+}
+
+func newTestLeader(t *testing.T) *testLeader {
+	db := setupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	leader := &testLeader{
+		quotes:  NewQuoteService(repository.NewQuoteRepository(db)),
+		changes: repository.NewChangeRepository(db),
+	}
+	changesService := NewChangesService(leader.changes, 0)
+	leader.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		set, err := changesService.GetChanges(r.URL.Query().Get("since"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": set})
+	}))
+	t.Cleanup(leader.server.Close)
+
+	return leader
+}
+
+func (l *testLeader) create(t *testing.T, text, category string) *models.Quote {
+	quote, err := l.quotes.CreateQuote(&models.Quote{Text: text, Author: "Leader", Category: category})
+	if err != nil {
+		t.Fatalf("CreateQuote() error = %v", err)
+	}
+	return quote
+}
+
+// quoteTexts lists the texts of every quote in repo, sorted
+func quoteTexts(t *testing.T, repo *repository.QuoteRepository) []string {
+	quotes, _, err := repo.GetAll(100, 0)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	var texts []string
+	for _, quote := range quotes {
+		texts = append(texts, quote.Text)
+	}
+	sort.Strings(texts)
+	return texts
+}
+
+func TestReplicator_Sync(t *testing.T) {
+	leader := newTestLeader(t)
+	db := setupTestDB(t)
+	defer db.Close()
+
+	followerQuotes := repository.NewQuoteRepository(db)
+	// A quote that only the follower has is removed by the first sync
+	if _, err := followerQuotes.Create(&models.Quote{Text: "Local", Author: "Follower", Category: "local"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	replicator, err := NewReplicator(repository.NewReplicationRepository(db), followerQuotes, ReplicatorOptions{Leader: leader.server.URL + "/"})
+	if err != nil {
+		t.Fatalf("NewReplicator() error = %v", err)
+	}
+	recorder := &recordingSubscriber{}
+	bus := newTestBus(t, db, recorder)
+	replicator.SetEventBus(bus)
+
+	if status := replicator.Status(); status.State != models.ReplicationSyncing || status.LagSeconds != nil {
+		t.Errorf("Status() before syncing = %+v", status)
+	}
+
+	first := leader.create(t, "First", "wisdom")
+	second := leader.create(t, "Second", "wisdom")
+	if err := replicator.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if got := quoteTexts(t, followerQuotes); len(got) != 2 || got[0] != "First" || got[1] != "Second" {
+		t.Errorf("follower quotes = %v, want the leader's", got)
+	}
+	if quote, err := followerQuotes.GetByID(first.ID); err != nil || !quote.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("follower quote %d = %+v, %v, want the leader's ID and created_at", first.ID, quote, err)
+	}
+	status := replicator.Status()
+	if status.State != models.ReplicationCaughtUp || status.LagSeconds == nil || status.Conflicts != models.ConflictReject {
+		t.Errorf("Status() after syncing = %+v", status)
+	}
+	if status.Leader != leader.server.URL {
+		t.Errorf("Status() leader = %q, want %q", status.Leader, leader.server.URL)
+	}
+
+	// Later syncs apply only what changed
+	flushBus(t, bus)
+	recorder.events = nil
+	edit := *second
+	edit.Text = "Second, edited"
+	edit.Category = "humor"
+	if _, err := leader.quotes.UpdateQuote(&edit); err != nil {
+		t.Fatalf("UpdateQuote() error = %v", err)
+	}
+	if _, err := leader.quotes.DeleteQuote(first.ID); err != nil {
+		t.Fatalf("DeleteQuote() error = %v", err)
+	}
+	if err := replicator.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if got := quoteTexts(t, followerQuotes); len(got) != 1 || got[0] != "Second, edited" {
+		t.Errorf("follower quotes = %v, want the edited quote only", got)
+	}
+	flushBus(t, bus)
+	want := []string{"quote.updated", "quote.deleted", "category.changed:humor:added", "category.changed:wisdom:removed"}
+	if got := recorder.summary(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if moved := recorder.events[0]; moved.Data.PreviousCategory != "wisdom" {
+		t.Errorf("quote.updated previous category = %q, want wisdom", moved.Data.PreviousCategory)
+	}
+
+	// A follower that has everything changes nothing
+	recorder.events = nil
+	err = replicator.Sync(context.Background())
+	flushBus(t, bus)
+	if err != nil || len(recorder.events) != 0 {
+		t.Errorf("Sync() when caught up = %v with events %v, want none", err, recorder.summary())
+	}
+
+	// When the leader no longer has the follower's position, a full
+	// resync removes the quote whose tombstone was missed
+	if _, err := db.Exec(`INSERT INTO quotes (text, author, category) VALUES ('Missed', 'Leader', 'humor')`); err != nil {
+		t.Fatalf("failed to insert quote: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO metadata (key, value) VALUES ('replication.position', '999')
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`); err != nil {
+		t.Fatalf("failed to move position: %v", err)
+	}
+	if err := replicator.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if got := quoteTexts(t, followerQuotes); len(got) != 1 || got[0] != "Second, edited" {
+		t.Errorf("follower quotes after a full resync = %v, want the leader's", got)
+	}
+}
+
+func TestReplicator_Conflicts(t *testing.T) {
+	leader := newTestLeader(t)
+	quote := leader.create(t, "Shared", "wisdom")
+
+	for _, conflicts := range []string{models.ConflictReject, models.ConflictLastWriterWins} {
+		t.Run(conflicts, func(t *testing.T) {
+			db := setupTestDB(t)
+			defer db.Close()
+
+			quoteRepo := repository.NewQuoteRepository(db)
+			replicator, err := NewReplicator(repository.NewReplicationRepository(db), quoteRepo, ReplicatorOptions{
+				Leader:    leader.server.URL,
+				Conflicts: conflicts,
+			})
+			if err != nil {
+				t.Fatalf("NewReplicator() error = %v", err)
+			}
+			if err := replicator.Sync(context.Background()); err != nil {
+				t.Fatalf("Sync() error = %v", err)
+			}
+
+			service := NewQuoteService(quoteRepo)
+			service.SetReplica(replicator.Conflicts())
+			importService := NewImportService(quoteRepo)
+			importService.SetReplica(replicator.Conflicts())
+
+			if _, err := service.CreateQuote(&models.Quote{Text: "New", Author: "Follower"}); err != errors.ErrReplicaWrite {
+				t.Errorf("CreateQuote() error = %v, want ErrReplicaWrite", err)
+			}
+			if _, err := importService.Import(importers.NewJSONLParser(), strings.NewReader(`{"text":"New","author":"Follower"}`), ImportOptions{}); err != errors.ErrReplicaWrite {
+				t.Errorf("Import() error = %v, want ErrReplicaWrite", err)
+			}
+
+			edit := *quote
+			edit.Text = "Shared, edited locally"
+			_, err = service.UpdateQuote(&edit)
+			if conflicts == models.ConflictReject {
+				if err != errors.ErrReplicaWrite {
+					t.Errorf("UpdateQuote() error = %v, want ErrReplicaWrite", err)
+				}
+				if _, err := service.DeleteQuote(quote.ID); err != errors.ErrReplicaWrite {
+					t.Errorf("DeleteQuote() error = %v, want ErrReplicaWrite", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateQuote() error = %v", err)
+			}
+
+			// The local edit is newer than the leader's quote until the
+			// leader edits it too, when the leader wins the tie
+			if _, err := db.Exec(`UPDATE metadata SET value = '0' WHERE key = 'replication.position'`); err != nil {
+				t.Fatalf("failed to rewind position: %v", err)
+			}
+			if err := replicator.Sync(context.Background()); err != nil {
+				t.Fatalf("Sync() error = %v", err)
+			}
+			if got, _ := quoteRepo.GetByID(quote.ID); got.Text != "Shared, edited locally" {
+				t.Errorf("quote after sync = %q, want the local edit", got.Text)
+			}
+			if status := replicator.Status(); status.LocalWins != 1 {
+				t.Errorf("Status() local wins = %d, want 1", status.LocalWins)
+			}
+
+			leaderEdit := *quote
+			leaderEdit.Text = "Shared, edited on the leader"
+			if _, err := leader.quotes.UpdateQuote(&leaderEdit); err != nil {
+				t.Fatalf("UpdateQuote() on the leader error = %v", err)
+			}
+			if err := replicator.Sync(context.Background()); err != nil {
+				t.Fatalf("Sync() error = %v", err)
+			}
+			if got, _ := quoteRepo.GetByID(quote.ID); got.Text != "Shared, edited on the leader" {
+				t.Errorf("quote after sync = %q, want the leader's edit", got.Text)
+			}
+		})
+	}
+}
+
+func TestNewReplicator_InvalidOptions(t *testing.T) {
+	for _, opts := range []ReplicatorOptions{
+		{Leader: ""},
+		{Leader: "ftp://leader.example.com"},
+		{Leader: "https://leader.example.com", Conflicts: "merge"},
+	} {
+		if _, err := NewReplicator(nil, nil, opts); err == nil {
+			t.Errorf("NewReplicator(%+v) succeeded, want an error", opts)
+		}
+	}
+}
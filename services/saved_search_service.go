@@ -0,0 +1,501 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"quote-vault/errors"
+	"quote-vault/mail"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// SearchMatchedEvent is the event type of saved search notifications
+const SearchMatchedEvent = "search.matched"
+
+// Limits on the fields of a saved search, in characters
+const (
+	maxSearchName  = 100
+	maxSearchField = 200
+)
+
+// searchBatchSize is the number of due notifications read at a time
+const searchBatchSize = 16
+
+// SavedSearchOptions controls how saved searches are notified.
+// Notifications are retried like webhook deliveries, with the same
+// defaults, and marked failed once they run out of attempts.
+type SavedSearchOptions struct {
+	// BaseURL is the public URL of the service, used for the links in
+	// emails. It is required when a mail sender is given.
+	BaseURL string
+	// From is the sender address of emails
+	From string
+	// ConfirmationTTL is how long the confirmation link of an email
+	// search works. Unconfirmed searches are removed after it.
+	ConfirmationTTL time.Duration
+	RetryOptions
+	// Client posts webhook notifications; by default the same kind of
+	// client webhooks use
+	Client *http.Client
+}
+
+// SavedSearchService keeps saved searches, matches every new quote
+// against them and notifies each search of its matches, by webhook or by
+// email, from a persistent queue
+type SavedSearchService struct {
+	repo   *repository.SavedSearchRepository
+	sender mail.Sender
+	opts   SavedSearchOptions
+	client *http.Client
+	worker *deliveryWorker
+}
+
+// NewSavedSearchService creates a saved search service, filling unset
+// options with their defaults. sender may be nil, in which case searches
+// can only be notified by webhook. Notifications are only sent while Run
+// is running.
+func NewSavedSearchService(repo *repository.SavedSearchRepository, sender mail.Sender, opts SavedSearchOptions) (*SavedSearchService, error) {
+	if sender != nil {
+		base, err := url.Parse(strings.TrimRight(opts.BaseURL, "/"))
+		if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+			return nil, fmt.Errorf("saved search emails need an absolute http or https base URL, got %q", opts.BaseURL)
+		}
+		opts.BaseURL = base.String()
+		if _, err := mail.ParseAddress(opts.From); err != nil {
+			return nil, fmt.Errorf("invalid saved search sender address %q: %v", opts.From, err)
+		}
+	}
+	if opts.ConfirmationTTL <= 0 {
+		opts.ConfirmationTTL = DefaultDigestConfirmationTTL
+	}
+	opts.RetryOptions = opts.withDefaults(defaultWebhookRetry)
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout: DefaultWebhookTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	s := &SavedSearchService{
+		repo:   repo,
+		sender: sender,
+		opts:   opts,
+		client: client,
+	}
+	s.worker = newDeliveryWorker("saved search", opts.RetryOptions, s.notifyDue, repo.NextDueAt)
+	// Expired unconfirmed searches are cleared out as the queue is worked
+	s.worker.afterPass = func() {
+		if _, err := repo.DeleteUnconfirmed(time.Now().Add(-opts.ConfirmationTTL)); err != nil {
+			log.Printf("Failed to remove unconfirmed saved searches: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// CreateSearch saves a search. Every search gets an unsubscribe token, the
+// only way to read its matches or remove it. A webhook search also gets a
+// signing secret and is matched from now on; an email search is sent a
+// confirmation link and matched once it is followed.
+func (s *SavedSearchService) CreateSearch(ctx context.Context, req *models.SavedSearchRequest) (*models.SavedSearch, error) {
+	search, err := s.validate(req)
+	if err != nil {
+		return nil, err
+	}
+	if search.UnsubscribeToken, err = newEmailToken(); err != nil {
+		return nil, err
+	}
+
+	if search.WebhookURL != "" {
+		if search.Secret, err = newWebhookSecret(); err != nil {
+			return nil, err
+		}
+		return s.repo.Create(search)
+	}
+
+	if search.ConfirmToken, err = newEmailToken(); err != nil {
+		return nil, err
+	}
+	if search, err = s.repo.Create(search); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.confirmationEmail(search)
+	if err == nil {
+		err = s.sender.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Printf("Failed to send confirmation of saved search %d: %v", search.ID, err)
+		if err := s.repo.Delete(search.ID); err != nil {
+			log.Printf("Failed to remove unconfirmable saved search %d: %v", search.ID, err)
+		}
+		return nil, errors.ErrMailUnavailable
+	}
+	return search, nil
+}
+
+// validate checks a saved search request and returns the search it asks
+// for
+func (s *SavedSearchService) validate(req *models.SavedSearchRequest) (*models.SavedSearch, error) {
+	search := &models.SavedSearch{
+		Name:     strings.TrimSpace(req.Name),
+		Category: strings.TrimSpace(req.Category),
+		Author:   strings.TrimSpace(req.Author),
+		Query:    strings.TrimSpace(req.Query),
+	}
+
+	if search.Name == "" || utf8.RuneCountInString(search.Name) > maxSearchName {
+		return nil, errors.NewValidationError("Invalid search name",
+			fmt.Sprintf("name is required and must be at most %d characters", maxSearchName))
+	}
+	if search.Category == "" && search.Author == "" && search.Query == "" {
+		return nil, errors.NewValidationError("Empty search", "at least one of category, author and query is required")
+	}
+	for _, field := range []string{search.Category, search.Author, search.Query} {
+		if utf8.RuneCountInString(field) > maxSearchField {
+			return nil, errors.NewValidationError("Invalid search",
+				fmt.Sprintf("category, author and query must be at most %d characters", maxSearchField))
+		}
+	}
+
+	webhookURL, email := strings.TrimSpace(req.WebhookURL), strings.TrimSpace(req.Email)
+	switch {
+	case (webhookURL == "") == (email == ""):
+		return nil, errors.NewValidationError("Invalid search notification", "exactly one of webhook_url and email is required")
+	case webhookURL != "":
+		target, err := url.Parse(webhookURL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return nil, errors.NewValidationError("Invalid webhook URL", "webhook_url must be an absolute http or https URL")
+		}
+		search.WebhookURL = target.String()
+	case s.sender == nil:
+		return nil, errors.NewValidationError("Email notifications unavailable",
+			"this server has no mail server configured; use webhook_url instead")
+	default:
+		address, err := validateEmail(email)
+		if err != nil {
+			return nil, err
+		}
+		search.Email = address
+	}
+
+	return search, nil
+}
+
+// GetSearches returns every saved search
+func (s *SavedSearchService) GetSearches() ([]*models.SavedSearch, error) {
+	return s.repo.GetAll()
+}
+
+// GetMatches returns the newest quotes that matched a search, with the
+// outcome of notifying it about each. token must be the unsubscribe token
+// of the search; any other token is answered as if the search did not
+// exist.
+func (s *SavedSearchService) GetMatches(id int, token string, limit int) ([]*models.SearchMatch, error) {
+	if id <= 0 {
+		return nil, errors.ErrInvalidID
+	}
+	search, err := s.GetByUnsubscribeToken(token)
+	if err == errors.ErrSubscriptionNotFound || (err == nil && search.ID != id) {
+		return nil, errors.ErrSavedSearchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetMatches(id, limit)
+}
+
+// Confirm confirms the email address of the search of a confirmation
+// link, after which new quotes are matched against it. Following the
+// link again is harmless; links expire after ConfirmationTTL.
+func (s *SavedSearchService) Confirm(token string) (*models.SavedSearch, error) {
+	if token == "" {
+		return nil, errors.ErrSubscriptionNotFound
+	}
+	search, err := s.repo.GetByConfirmToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if search.ConfirmedAt != nil {
+		return search, nil
+	}
+
+	now := time.Now().UTC()
+	if now.Sub(search.CreatedAt) > s.opts.ConfirmationTTL {
+		return nil, errors.ErrSubscriptionNotFound
+	}
+	if err := s.repo.Confirm(search.ID, now); err != nil {
+		return nil, err
+	}
+	search.ConfirmedAt = &now
+	return search, nil
+}
+
+// GetByUnsubscribeToken returns the search of an unsubscribe link
+func (s *SavedSearchService) GetByUnsubscribeToken(token string) (*models.SavedSearch, error) {
+	if token == "" {
+		return nil, errors.ErrSubscriptionNotFound
+	}
+	return s.repo.GetByUnsubscribeToken(token)
+}
+
+// Unsubscribe removes the search of an unsubscribe link
+func (s *SavedSearchService) Unsubscribe(token string) (*models.SavedSearch, error) {
+	search, err := s.GetByUnsubscribeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(search.ID); err != nil {
+		return nil, err
+	}
+	return search, nil
+}
+
+// Handle matches the quote of a quote.created event against the active
+// saved searches and queues a notification for each match. It implements
+// Subscriber; a quote matches a search only once, so handling an event
+// again queues nothing new. Edits that make an existing quote match are
+// not reported.
+func (s *SavedSearchService) Handle(ctx context.Context, event *models.Event) error {
+	if event.Type != models.EventQuoteCreated || event.Data.Quote == nil {
+		return nil
+	}
+
+	searches, err := s.repo.GetActive()
+	if err != nil {
+		return err
+	}
+
+	queued := false
+	for _, search := range searches {
+		if !search.Filter().Matches(event.Data.Quote) {
+			continue
+		}
+		added, err := s.repo.AddMatch(search.ID, event.Data.Quote)
+		if err != nil {
+			return err
+		}
+		queued = queued || added
+	}
+
+	if queued {
+		s.worker.notify()
+	}
+	return nil
+}
+
+// Run sends queued notifications, and clears out expired unconfirmed
+// searches, until ctx is cancelled
+func (s *SavedSearchService) Run(ctx context.Context) {
+	s.worker.run(ctx)
+}
+
+// notifyDue sends one batch of due notifications and reports whether a
+// full batch was found, in which case more may be waiting
+func (s *SavedSearchService) notifyDue(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	matches, err := s.repo.GetDueMatches(time.Now(), searchBatchSize)
+	if err != nil {
+		log.Printf("Failed to read saved search notifications: %v", err)
+		return false
+	}
+
+	searches := make(map[int]*models.SavedSearch)
+	for _, match := range matches {
+		search, ok := searches[match.SearchID]
+		if !ok {
+			search, err = s.repo.GetByID(match.SearchID)
+			if err != nil {
+				// Deleted since the batch was read, along with its matches
+				log.Printf("Skipping saved search match %d: %v", match.ID, err)
+				continue
+			}
+			searches[match.SearchID] = search
+		}
+		s.deliver(ctx, search, match)
+	}
+
+	return len(matches) == searchBatchSize
+}
+
+// deliver makes one attempt at notifying a search of a match and records
+// the outcome, scheduling a retry or marking it failed when it fails
+func (s *SavedSearchService) deliver(ctx context.Context, search *models.SavedSearch, match *models.SearchMatch) {
+	var err error
+	if search.WebhookURL != "" {
+		err = s.post(ctx, search, match)
+	} else {
+		err = s.email(ctx, search, match)
+	}
+	if ctx.Err() != nil {
+		// Shutting down; the notification stays due for the next run
+		return
+	}
+
+	now := time.Now().UTC()
+	match.Attempts++
+	match.NextAttemptAt = nil
+	switch {
+	case err == nil:
+		match.Status = models.DeliverySucceeded
+		match.NotifiedAt = &now
+		match.LastError = ""
+	case match.Attempts >= s.opts.MaxAttempts:
+		match.Status = models.DeliveryFailed
+		match.LastError = err.Error()
+	default:
+		next := now.Add(s.opts.backoff(match.Attempts))
+		match.NextAttemptAt = &next
+		match.LastError = err.Error()
+	}
+
+	if err := s.repo.UpdateMatch(match); err != nil {
+		log.Printf("Failed to record saved search match %d: %v", match.ID, err)
+	}
+}
+
+// searchMatched is the body of a webhook notification, shaped like the
+// events webhooks receive
+type searchMatched struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	CreatedAt time.Time         `json:"created_at"`
+	Data      searchMatchedData `json:"data"`
+}
+
+type searchMatchedData struct {
+	Search *models.SavedSearch `json:"search"`
+	Quote  *models.Quote       `json:"quote"`
+}
+
+// post sends a match to the webhook of a search, signed like webhook
+// deliveries. Any status outside 2xx is an error.
+func (s *SavedSearchService) post(ctx context.Context, search *models.SavedSearch, match *models.SearchMatch) error {
+	public := *search
+	public.Secret, public.Email = "", ""
+	payload, err := json.Marshal(&searchMatched{
+		ID:        fmt.Sprintf("match_%d", match.ID),
+		Type:      SearchMatchedEvent,
+		CreatedAt: match.CreatedAt,
+		Data:      searchMatchedData{Search: &public, Quote: match.Quote},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, search.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "quote-vault-webhooks")
+	req.Header.Set(WebhookEventHeader, SearchMatchedEvent)
+	req.Header.Set(WebhookDeliveryHeader, fmt.Sprintf("match_%d", match.ID))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(search.Secret, payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+// email sends a match to the address of a search
+func (s *SavedSearchService) email(ctx context.Context, search *models.SavedSearch, match *models.SearchMatch) error {
+	if s.sender == nil {
+		return fmt.Errorf("no mail server is configured")
+	}
+	unsubscribe := s.opts.BaseURL + "/searches/unsubscribe?token=" + url.QueryEscape(search.UnsubscribeToken)
+	data := map[string]interface{}{
+		"Base":           s.opts.BaseURL,
+		"Name":           search.Name,
+		"Description":    describeSearch(search),
+		"Quote":          match.Quote,
+		"QuoteURL":       fmt.Sprintf("%s/q/%d", s.opts.BaseURL, match.Quote.ID),
+		"SearchURL":      searchURL(s.opts.BaseURL, search),
+		"UnsubscribeURL": unsubscribe,
+	}
+	msg, err := renderEmail("search_match", data, &mail.Message{
+		From:    s.opts.From,
+		To:      search.Email,
+		Subject: "New quote matching " + search.Name,
+		Header: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return s.sender.Send(ctx, msg)
+}
+
+// confirmationEmail builds the email asking the address of a search to
+// confirm it
+func (s *SavedSearchService) confirmationEmail(search *models.SavedSearch) (*mail.Message, error) {
+	data := map[string]interface{}{
+		"Name":        search.Name,
+		"Description": describeSearch(search),
+		"Email":       search.Email,
+		"ConfirmURL":  s.opts.BaseURL + "/searches/confirm?token=" + url.QueryEscape(search.ConfirmToken),
+		"ExpiresIn":   formatHours(s.opts.ConfirmationTTL),
+	}
+	return renderEmail("search_confirm", data, &mail.Message{
+		From:    s.opts.From,
+		To:      search.Email,
+		Subject: "Confirm your saved search on Quote Vault",
+	})
+}
+
+// describeSearch lists the criteria of a search, such as
+// `category "Wisdom", text "time"`
+func describeSearch(search *models.SavedSearch) string {
+	var parts []string
+	if search.Category != "" {
+		parts = append(parts, fmt.Sprintf("category %q", search.Category))
+	}
+	if search.Author != "" {
+		parts = append(parts, fmt.Sprintf("author %q", search.Author))
+	}
+	if search.Query != "" {
+		parts = append(parts, fmt.Sprintf("text %q", search.Query))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// searchURL returns the web UI page listing the quotes a search matches
+func searchURL(base string, search *models.SavedSearch) string {
+	query := url.Values{}
+	if search.Query != "" {
+		query.Set("q", search.Query)
+	}
+	if search.Category != "" {
+		query.Set("category", search.Category)
+	}
+	if search.Author != "" {
+		query.Set("author", search.Author)
+	}
+	return base + "/ui/?" + query.Encode()
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/mail"
+	"quote-vault/mail/mailtest"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// setupSavedSearchService returns a saved search service with short retry
+// delays, emailing through an in-process SMTP server
+func setupSavedSearchService(t *testing.T) (*SavedSearchService, *mailtest.Server) {
+	db := setupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+	server := mailtest.NewServer()
+	t.Cleanup(server.Close)
+
+	sender := mail.NewSMTPSender(mail.SMTPConfig{Host: server.Host, Port: server.Port})
+	service, err := NewSavedSearchService(repository.NewSavedSearchRepository(db), sender, SavedSearchOptions{
+		BaseURL: testDigestBase,
+		From:    "Quote Vault <quotes@example.com>",
+		RetryOptions: RetryOptions{
+			MaxAttempts: 2,
+			BaseDelay:   10 * time.Millisecond,
+			MaxDelay:    10 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewSavedSearchService() error = %v", err)
+	}
+	return service, server
+}
+
+// quoteCreated hands the service the event of a new quote, as the event
+// bus would
+func quoteCreated(t *testing.T, service *SavedSearchService, quote *models.Quote) {
+	t.Helper()
+	if err := service.Handle(context.Background(), models.NewEvent(models.EventQuoteCreated, models.EventData{Quote: quote})); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+}
+
+func TestSavedSearchService_Webhook(t *testing.T) {
+	service, _ := setupSavedSearchService(t)
+	receiver := newWebhookReceiver(http.StatusInternalServerError)
+	target := httptest.NewServer(receiver)
+	defer target.Close()
+
+	search, err := service.CreateSearch(context.Background(), &models.SavedSearchRequest{
+		Name: "Wilde on life", Author: "oscar wilde", Query: "LIFE", WebhookURL: target.URL,
+	})
+	if err != nil {
+		t.Fatalf("CreateSearch() error = %v", err)
+	}
+	if !strings.HasPrefix(search.Secret, webhookSecretPrefix) {
+		t.Errorf("Secret = %q, want a webhook secret", search.Secret)
+	}
+
+	match := &models.Quote{ID: 1, Text: "Life is far too important a thing ever to talk seriously about", Author: "Oscar Wilde", Category: "Humor"}
+	quoteCreated(t, service, match)
+	quoteCreated(t, service, &models.Quote{ID: 2, Text: "Life is short", Author: "Seneca", Category: "Philosophy"})
+	quoteCreated(t, service, &models.Quote{ID: 3, Text: "Be yourself", Author: "Oscar Wilde", Category: "Life"})
+	// Handling an event again matches nothing new
+	quoteCreated(t, service, match)
+
+	// The first attempt fails and is retried
+	service.notifyDue(context.Background())
+	time.Sleep(20 * time.Millisecond)
+	service.notifyDue(context.Background())
+
+	requests := receiver.all()
+	if len(requests) != 2 {
+		t.Fatalf("receiver got %d requests, want 2", len(requests))
+	}
+	sent := requests[1]
+	if !verifySignature(search.Secret, sent.body, sent.header.Get(WebhookSignatureHeader)) {
+		t.Error("notification signature does not verify")
+	}
+	if sent.header.Get(WebhookEventHeader) != SearchMatchedEvent {
+		t.Errorf("%s = %q", WebhookEventHeader, sent.header.Get(WebhookEventHeader))
+	}
+	var payload struct {
+		Type string `json:"type"`
+		Data struct {
+			Search map[string]interface{} `json:"search"`
+			Quote  models.Quote           `json:"quote"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(sent.body, &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if payload.Type != SearchMatchedEvent || payload.Data.Quote.ID != 1 || payload.Data.Search["name"] != "Wilde on life" {
+		t.Errorf("payload = %s", sent.body)
+	}
+	if _, ok := payload.Data.Search["secret"]; ok {
+		t.Error("payload includes the signing secret")
+	}
+
+	// Matches are only listed with the token of the search
+	if _, err := service.GetMatches(search.ID, "wrong", 10); err != errors.ErrSavedSearchNotFound {
+		t.Errorf("GetMatches() with another token error = %v, want ErrSavedSearchNotFound", err)
+	}
+	matches, err := service.GetMatches(search.ID, search.UnsubscribeToken, 10)
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("GetMatches() = %v, %v; want 1 match", matches, err)
+	}
+	if matches[0].Status != models.DeliverySucceeded || matches[0].Attempts != 2 || matches[0].NotifiedAt == nil {
+		t.Errorf("match = %+v", matches[0])
+	}
+}
+
+func TestSavedSearchService_Email(t *testing.T) {
+	service, server := setupSavedSearchService(t)
+	ctx := context.Background()
+
+	search, err := service.CreateSearch(ctx, &models.SavedSearchRequest{Name: "Wisdom", Category: "Wisdom", Email: "ann@example.com"})
+	if err != nil {
+		t.Fatalf("CreateSearch() error = %v", err)
+	}
+	if search.Secret != "" || search.ConfirmedAt != nil {
+		t.Errorf("CreateSearch() = %+v", search)
+	}
+	received, err := server.Wait(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Quotes added before the address is confirmed are not matched
+	quoteCreated(t, service, &models.Quote{ID: 1, Text: "Know thyself", Author: "Socrates", Category: "Wisdom"})
+	if matches, _ := service.GetMatches(search.ID, search.UnsubscribeToken, 10); len(matches) != 0 {
+		t.Errorf("unconfirmed search has %d matches", len(matches))
+	}
+
+	if _, err := service.Confirm(link(t, received[0], "confirm")); err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	quoteCreated(t, service, &models.Quote{ID: 2, Text: "Well begun is half done", Author: "Aristotle", Category: "Wisdom"})
+	service.notifyDue(ctx)
+
+	received, err = server.Wait(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, _ := received[1].Parse()
+	if email.Subject != "New quote matching Wisdom" || !strings.Contains(email.Text, "Well begun is half done") {
+		t.Errorf("match email %q:\n%s", email.Subject, email.Text)
+	}
+	if !strings.Contains(email.Text, testDigestBase+"/ui/?category=Wisdom") {
+		t.Errorf("match email has no link to the search:\n%s", email.Text)
+	}
+
+	if _, err := service.Unsubscribe(link(t, received[1], "unsubscribe")); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if _, err := service.GetMatches(search.ID, search.UnsubscribeToken, 10); err != errors.ErrSavedSearchNotFound {
+		t.Errorf("GetMatches() after Unsubscribe() error = %v, want ErrSavedSearchNotFound", err)
+	}
+}
+
+func TestSavedSearchService_Validation(t *testing.T) {
+	service, server := setupSavedSearchService(t)
+
+	invalid := []*models.SavedSearchRequest{
+		{Category: "Wisdom", WebhookURL: "https://example.com/hook"},
+		{Name: "Everything", WebhookURL: "https://example.com/hook"},
+		{Name: "Wisdom", Category: "Wisdom"},
+		{Name: "Wisdom", Category: "Wisdom", WebhookURL: "https://example.com/hook", Email: "ann@example.com"},
+		{Name: "Wisdom", Category: "Wisdom", WebhookURL: "ftp://example.com/hook"},
+		{Name: "Wisdom", Category: "Wisdom", Email: "Ann <ann@example.com>"},
+	}
+	for _, req := range invalid {
+		_, err := service.CreateSearch(context.Background(), req)
+		if appErr, ok := err.(*errors.AppError); !ok || appErr.Type != errors.TypeValidation {
+			t.Errorf("CreateSearch(%+v) error = %v, want a validation error", req, err)
+		}
+	}
+	if n := len(server.Messages()); n != 0 {
+		t.Errorf("server received %d emails for invalid requests", n)
+	}
+
+	// Without a mail server only webhooks can be used
+	webhookOnly, err := NewSavedSearchService(service.repo, nil, SavedSearchOptions{})
+	if err != nil {
+		t.Fatalf("NewSavedSearchService() without a sender error = %v", err)
+	}
+	_, err = webhookOnly.CreateSearch(context.Background(), &models.SavedSearchRequest{Name: "Wisdom", Category: "Wisdom", Email: "ann@example.com"})
+	if appErr, ok := err.(*errors.AppError); !ok || appErr.Type != errors.TypeValidation {
+		t.Errorf("CreateSearch() by email without a sender error = %v, want a validation error", err)
+	}
+
+	// A confirmation that cannot be sent leaves no search behind
+	server.Reject(true)
+	_, err = service.CreateSearch(context.Background(), &models.SavedSearchRequest{Name: "Wisdom", Category: "Wisdom", Email: "ann@example.com"})
+	if err != errors.ErrMailUnavailable {
+		t.Errorf("CreateSearch() with a rejecting server error = %v, want ErrMailUnavailable", err)
+	}
+	if searches, _ := service.GetSearches(); len(searches) != 0 {
+		t.Errorf("GetSearches() = %d searches, want 0", len(searches))
+	}
+}
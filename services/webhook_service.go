@@ -0,0 +1,419 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// Webhook delivery defaults
+const (
+	DefaultWebhookMaxAttempts  = 8
+	DefaultWebhookBaseDelay    = 30 * time.Second
+	DefaultWebhookMaxDelay     = time.Hour
+	DefaultWebhookPollInterval = time.Minute
+	DefaultWebhookTimeout      = 10 * time.Second
+)
+
+// WebhookSecretGracePeriod is how long a rotated secret keeps signing
+// deliveries next to its replacement
+const WebhookSecretGracePeriod = 24 * time.Hour
+
+// Headers sent with every delivery
+const (
+	WebhookEventHeader     = "X-Quote-Vault-Event"
+	WebhookDeliveryHeader  = "X-Quote-Vault-Delivery"
+	WebhookSignatureHeader = "X-Quote-Vault-Signature"
+)
+
+// webhookBatchSize is the number of due deliveries sent at the same time
+const webhookBatchSize = 16
+
+// defaultWebhookRetry holds the defaults of unset WebhookOptions
+var defaultWebhookRetry = RetryOptions{
+	MaxAttempts:  DefaultWebhookMaxAttempts,
+	BaseDelay:    DefaultWebhookBaseDelay,
+	MaxDelay:     DefaultWebhookMaxDelay,
+	PollInterval: DefaultWebhookPollInterval,
+}
+
+// webhookSecretPrefix marks webhook signing secrets
+const webhookSecretPrefix = "whsec_"
+
+// WebhookOptions controls how deliveries are sent and retried. A delivery
+// fails once it runs out of attempts.
+type WebhookOptions struct {
+	RetryOptions
+	// Client sends the deliveries; by default one with a 10 second timeout
+	// that does not follow redirects
+	Client *http.Client
+}
+
+// WebhookService registers webhooks and delivers events to them from a
+// persistent queue
+type WebhookService struct {
+	repo   *repository.WebhookRepository
+	opts   WebhookOptions
+	client *http.Client
+	worker *deliveryWorker
+}
+
+// NewWebhookService creates a webhook service, filling unset options with
+// their defaults. Deliveries are only sent while Run is running.
+func NewWebhookService(repo *repository.WebhookRepository, opts WebhookOptions) *WebhookService {
+	opts.RetryOptions = opts.withDefaults(defaultWebhookRetry)
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout: DefaultWebhookTimeout,
+			// A redirect is treated as a failed delivery rather than
+			// resending the payload elsewhere
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	s := &WebhookService{
+		repo:   repo,
+		opts:   opts,
+		client: client,
+	}
+	s.worker = newDeliveryWorker("webhook", opts.RetryOptions, s.deliverDue, repo.NextDueAt)
+	return s
+}
+
+// CreateWebhook registers a webhook with a new signing secret. It receives
+// every event type when req.Events is empty.
+func (s *WebhookService) CreateWebhook(req *models.WebhookRequest) (*models.Webhook, error) {
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, errors.NewValidationError("Invalid webhook URL", "url must be an absolute http or https URL")
+	}
+
+	var events []string
+	for _, event := range req.Events {
+		event = strings.TrimSpace(event)
+		if !models.IsEventType(event) {
+			return nil, errors.NewValidationError("Invalid webhook events",
+				fmt.Sprintf("unknown event %q, expected one of %s", event, strings.Join(models.EventTypes, ", ")))
+		}
+		if !contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		events = append(events, models.EventTypes...)
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.Create(&models.Webhook{
+		URL:    target.String(),
+		Events: events,
+		Secret: secret,
+	})
+}
+
+// GetWebhooks returns every registered webhook
+func (s *WebhookService) GetWebhooks() ([]*models.Webhook, error) {
+	return s.repo.GetAll()
+}
+
+// GetWebhook returns a webhook by its ID
+func (s *WebhookService) GetWebhook(id int) (*models.Webhook, error) {
+	if id <= 0 {
+		return nil, errors.ErrInvalidID
+	}
+	return s.repo.GetByID(id)
+}
+
+// DeleteWebhook removes a webhook and drops its queued deliveries
+func (s *WebhookService) DeleteWebhook(id int) error {
+	if id <= 0 {
+		return errors.ErrInvalidID
+	}
+	return s.repo.Delete(id)
+}
+
+// RotateSecret replaces the signing secret of a webhook. Deliveries carry
+// signatures from both secrets for WebhookSecretGracePeriod, so receivers
+// can switch to the new one without rejecting any.
+func (s *WebhookService) RotateSecret(id int) (*models.Webhook, error) {
+	hook, err := s.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().UTC().Add(WebhookSecretGracePeriod)
+	hook.PreviousSecret = hook.Secret
+	hook.PreviousSecretExpiresAt = &expires
+	hook.Secret = secret
+	if err := s.repo.UpdateSecrets(hook); err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+// GetDeliveries returns the newest deliveries of a webhook, optionally
+// only those with the given status
+func (s *WebhookService) GetDeliveries(webhookID int, status string, limit int) ([]*models.WebhookDelivery, error) {
+	switch status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed:
+	default:
+		return nil, errors.NewValidationError("Invalid delivery status",
+			fmt.Sprintf("status must be %s, %s or %s", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed))
+	}
+	if limit <= 0 {
+		return nil, errors.ErrInvalidPagination
+	}
+
+	if _, err := s.GetWebhook(webhookID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDeliveries(webhookID, status, limit)
+}
+
+// ReplayDelivery queues the payload of a finished delivery again as a new
+// delivery, which is sent straight away and retried like any other. The
+// event ID is kept, so receivers can recognise the repeat.
+func (s *WebhookService) ReplayDelivery(webhookID, deliveryID int) (*models.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(webhookID); err != nil {
+		return nil, err
+	}
+
+	original, err := s.repo.GetDelivery(webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Status == models.DeliveryPending {
+		return nil, errors.ErrDeliveryPending
+	}
+
+	now := time.Now().UTC()
+	replay, err := s.repo.CreateDelivery(&models.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventID:       original.EventID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		NextAttemptAt: &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.worker.notify()
+	return replay, nil
+}
+
+// Handle queues a delivery of event for every webhook subscribed to its
+// type. It implements Subscriber; webhooks that already have a delivery of
+// the event are left alone, so handling it again queues nothing new.
+func (s *WebhookService) Handle(ctx context.Context, event *models.Event) error {
+	hooks, err := s.repo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event %s: %v", event.Type, event.ID, err)
+	}
+
+	now := time.Now().UTC()
+	queued := false
+	for _, hook := range hooks {
+		if !hook.Subscribes(event.Type) {
+			continue
+		}
+		exists, err := s.repo.HasDelivery(hook.ID, event.ID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		_, err = s.repo.CreateDelivery(&models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			EventID:       event.ID,
+			Event:         event.Type,
+			Payload:       payload,
+			NextAttemptAt: &now,
+		})
+		if err != nil {
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		s.worker.notify()
+	}
+	return nil
+}
+
+// Run sends queued deliveries until ctx is cancelled. Deliveries left
+// pending when the process stops, including any interrupted by the
+// cancellation, are sent by the next Run.
+func (s *WebhookService) Run(ctx context.Context) {
+	s.worker.run(ctx)
+}
+
+// deliverDue sends one batch of due deliveries and reports whether a full
+// batch was found, in which case more may be waiting
+func (s *WebhookService) deliverDue(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	deliveries, err := s.repo.GetDueDeliveries(time.Now(), webhookBatchSize)
+	if err != nil {
+		log.Printf("Failed to read webhook queue: %v", err)
+		return false
+	}
+
+	hooks := make(map[int]*models.Webhook)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		hook, ok := hooks[delivery.WebhookID]
+		if !ok {
+			hook, err = s.repo.GetByID(delivery.WebhookID)
+			if err != nil {
+				// Deleted since the batch was read, along with its queue
+				log.Printf("Skipping webhook delivery %d: %v", delivery.ID, err)
+				continue
+			}
+			hooks[delivery.WebhookID] = hook
+		}
+
+		wg.Add(1)
+		go func(hook *models.Webhook, delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			s.deliver(ctx, hook, delivery)
+		}(hook, delivery)
+	}
+	wg.Wait()
+
+	return len(deliveries) == webhookBatchSize
+}
+
+// deliver makes one attempt at a delivery and records the outcome,
+// scheduling a retry or marking it failed when the attempt fails
+func (s *WebhookService) deliver(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) {
+	now := time.Now().UTC()
+	status, err := s.send(ctx, hook, delivery, now)
+	if ctx.Err() != nil {
+		// Shutting down; the delivery stays due for the next run
+		return
+	}
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	delivery.NextAttemptAt = nil
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= s.opts.MaxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		next := now.Add(s.opts.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.LastError = err.Error()
+	}
+
+	if err := s.repo.UpdateDelivery(delivery); err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send posts the payload of a delivery to its webhook and returns the
+// response status. Any status outside 2xx is an error.
+func (s *WebhookService) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	signatures := []string{SignWebhookPayload(hook.Secret, delivery.Payload)}
+	if hook.PreviousSecret != "" && hook.PreviousSecretExpiresAt != nil && now.Before(*hook.PreviousSecretExpiresAt) {
+		signatures = append(signatures, SignWebhookPayload(hook.PreviousSecret, delivery.Payload))
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "quote-vault-webhooks")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, fmt.Sprint(delivery.ID))
+	req.Header.Set(WebhookSignatureHeader, strings.Join(signatures, ","))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload returns the signature of a delivery payload as sent
+// in the X-Quote-Vault-Signature header: "sha256=" followed by the hex
+// HMAC-SHA256 of the payload keyed with the secret
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookSecret returns a random signing secret
+func newWebhookSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.NewInternalError("failed to generate webhook secret")
+	}
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"quote-vault/errors"
+	"quote-vault/models"
+	"quote-vault/repository"
+)
+
+// webhookReceiver records the requests posted to it and answers with the
+// next queued status, or 204 once the queue is empty
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(statuses ...int) *webhookReceiver {
+	return &webhookReceiver{statuses: statuses}
+}
+
+func (rec *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rec.mu.Lock()
+	rec.requests = append(rec.requests, receivedWebhook{header: r.Header.Clone(), body: body})
+	status := http.StatusNoContent
+	if len(rec.statuses) > 0 {
+		status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+	}
+	rec.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func (rec *webhookReceiver) all() []receivedWebhook {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]receivedWebhook(nil), rec.requests...)
+}
+
+// startWebhookService runs a webhook service with short retry delays until
+// the test ends
+func startWebhookService(t *testing.T, repo *repository.WebhookRepository, maxAttempts int) *WebhookService {
+	service := NewWebhookService(repo, WebhookOptions{RetryOptions: RetryOptions{
+		MaxAttempts:  maxAttempts,
+		BaseDelay:    10 * time.Millisecond,
+		MaxDelay:     40 * time.Millisecond,
+		PollInterval: 50 * time.Millisecond,
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return service
+}
+
+// waitForDelivery polls the deliveries of a webhook until the newest one
+// has the given status
+func waitForDelivery(t *testing.T, service *WebhookService, webhookID int, status string) *models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := service.GetDeliveries(webhookID, "", 10)
+		if err != nil {
+			t.Fatalf("GetDeliveries() error = %v", err)
+		}
+		if len(deliveries) > 0 && deliveries[0].Status == status {
+			return deliveries[0]
+		}
+		if time.Now().After(deadline) {
+			var statuses []string
+			for _, delivery := range deliveries {
+				statuses = append(statuses, delivery.Status)
+			}
+			t.Fatalf("deliveries of webhook %d have statuses %v, want the newest %s", webhookID, statuses, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// verifySignature checks a signature header value against the payload
+func verifySignature(secret string, payload []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal([]byte(signature), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+}
+
+// handleEvent hands an event to the webhook service as the event bus would
+func handleEvent(t *testing.T, service *WebhookService, event *models.Event) {
+	t.Helper()
+	if err := service.Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+}
+
+func TestWebhookService_CreateWebhook(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewWebhookService(repository.NewWebhookRepository(db), WebhookOptions{})
+
+	tests := []struct {
+		name       string
+		req        models.WebhookRequest
+		wantEvents []string
+		wantErr    bool
+	}{
+		{"all events by default", models.WebhookRequest{URL: "https://example.com/hook"}, models.EventTypes, false},
+		{"duplicates dropped", models.WebhookRequest{URL: "http://localhost:9000/", Events: []string{"quote.deleted", " quote.deleted"}}, []string{"quote.deleted"}, false},
+		{"unknown event", models.WebhookRequest{URL: "https://example.com/hook", Events: []string{"quote.liked"}}, nil, true},
+		{"relative URL", models.WebhookRequest{URL: "/hook"}, nil, true},
+		{"unsupported scheme", models.WebhookRequest{URL: "ftp://example.com/hook"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, err := service.CreateWebhook(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if strings.Join(hook.Events, ",") != strings.Join(tt.wantEvents, ",") {
+				t.Errorf("CreateWebhook() events = %v, want %v", hook.Events, tt.wantEvents)
+			}
+			if !strings.HasPrefix(hook.Secret, webhookSecretPrefix) || len(hook.Secret) < 40 {
+				t.Errorf("CreateWebhook() secret = %q", hook.Secret)
+			}
+		})
+	}
+}
+
+func TestWebhookService_DeliversSignedEvents(t *testing.T) {
+	db := setupTestDB(t)
+	// Closed after the worker stops
+	t.Cleanup(func() { db.Close() })
+
+	receiver := newWebhookReceiver()
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhooks := startWebhookService(t, repository.NewWebhookRepository(db), 3)
+	hook, err := webhooks.CreateWebhook(&models.WebhookRequest{URL: server.URL, Events: []string{models.EventQuoteCreated}})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	// Subscribed to other events only, so it gets no delivery
+	other, err := webhooks.CreateWebhook(&models.WebhookRequest{URL: server.URL, Events: []string{models.EventQuoteDeleted}})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	bus := newTestBus(t, db, webhooks)
+	quotes := NewQuoteService(repository.NewQuoteRepository(db))
+	quotes.SetEventBus(bus)
+	created, err := quotes.CreateQuote(&models.Quote{Text: "Delivered to every subscriber", Author: "Author", Category: "test"})
+	if err != nil {
+		t.Fatalf("CreateQuote() error = %v", err)
+	}
+	flushBus(t, bus)
+
+	delivery := waitForDelivery(t, webhooks, hook.ID, models.DeliverySucceeded)
+	if delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusNoContent || delivery.DeliveredAt == nil {
+		t.Errorf("delivery = %+v, want one successful attempt", delivery)
+	}
+
+	requests := receiver.all()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if !verifySignature(hook.Secret, req.body, req.header.Get(WebhookSignatureHeader)) {
+		t.Errorf("signature %q does not match the payload", req.header.Get(WebhookSignatureHeader))
+	}
+	if req.header.Get(WebhookEventHeader) != models.EventQuoteCreated || req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", req.header)
+	}
+
+	var event models.Event
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if event.Type != models.EventQuoteCreated || event.ID != delivery.EventID || event.Data.Quote == nil || event.Data.Quote.ID != created.ID {
+		t.Errorf("payload = %s", req.body)
+	}
+
+	// An event handed over again is not delivered twice
+	if err := webhooks.Handle(context.Background(), &event); err != nil {
+		t.Fatalf("Handle() of a delivered event error = %v", err)
+	}
+	if deliveries, err := webhooks.GetDeliveries(hook.ID, "", 10); err != nil || len(deliveries) != 1 {
+		t.Errorf("GetDeliveries() after handling the event again = %v, %v, want 1", deliveries, err)
+	}
+
+	if deliveries, err := webhooks.GetDeliveries(other.ID, "", 10); err != nil || len(deliveries) != 0 {
+		t.Errorf("GetDeliveries() of the unsubscribed webhook = %v, %v, want none", deliveries, err)
+	}
+}
+
+func TestWebhookService_RetriesAndReplay(t *testing.T) {
+	db := setupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	// Fails twice, so the third and last attempt succeeds
+	receiver := newWebhookReceiver(http.StatusInternalServerError, http.StatusBadGateway)
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhooks := startWebhookService(t, repository.NewWebhookRepository(db), 3)
+	hook, err := webhooks.CreateWebhook(&models.WebhookRequest{URL: server.URL})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	handleEvent(t, webhooks, models.NewEvent(models.EventCategoryChanged, models.EventData{Category: "test", Action: models.CategoryAdded}))
+	delivery := waitForDelivery(t, webhooks, hook.ID, models.DeliverySucceeded)
+	if delivery.Attempts != 3 {
+		t.Errorf("delivery attempts = %d, want 3", delivery.Attempts)
+	}
+
+	// Out of attempts, the delivery fails for good
+	receiver.mu.Lock()
+	receiver.statuses = []int{500, 500, 500}
+	receiver.mu.Unlock()
+	handleEvent(t, webhooks, models.NewEvent(models.EventCategoryChanged, models.EventData{Category: "test", Action: models.CategoryRemoved}))
+	failed := waitForDelivery(t, webhooks, hook.ID, models.DeliveryFailed)
+	if failed.Attempts != 3 || failed.ResponseStatus != 500 || !strings.Contains(failed.LastError, "500") || failed.NextAttemptAt != nil {
+		t.Errorf("failed delivery = %+v", failed)
+	}
+
+	replay, err := webhooks.ReplayDelivery(hook.ID, failed.ID)
+	if err != nil {
+		t.Fatalf("ReplayDelivery() error = %v", err)
+	}
+	if replay.ID == failed.ID || replay.EventID != failed.EventID {
+		t.Errorf("ReplayDelivery() = %+v, want a new delivery of event %s", replay, failed.EventID)
+	}
+	replayed := waitForDelivery(t, webhooks, hook.ID, models.DeliverySucceeded)
+	if replayed.ID != replay.ID || string(replayed.Payload) != string(failed.Payload) {
+		t.Errorf("replayed delivery = %+v", replayed)
+	}
+
+	if _, err := webhooks.ReplayDelivery(hook.ID, 999); err != errors.ErrDeliveryNotFound {
+		t.Errorf("ReplayDelivery() of a missing delivery error = %v, want ErrDeliveryNotFound", err)
+	}
+
+	if failures, err := webhooks.GetDeliveries(hook.ID, models.DeliveryFailed, 10); err != nil || len(failures) != 1 {
+		t.Errorf("GetDeliveries(failed) = %v, %v, want 1", failures, err)
+	}
+	if _, err := webhooks.GetDeliveries(hook.ID, "lost", 10); err == nil {
+		t.Error("GetDeliveries() with an unknown status succeeded")
+	}
+}
+
+func TestWebhookService_RotateSecret(t *testing.T) {
+	db := setupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	receiver := newWebhookReceiver()
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhooks := startWebhookService(t, repository.NewWebhookRepository(db), 3)
+	hook, err := webhooks.CreateWebhook(&models.WebhookRequest{URL: server.URL})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	rotated, err := webhooks.RotateSecret(hook.ID)
+	if err != nil {
+		t.Fatalf("RotateSecret() error = %v", err)
+	}
+	if rotated.Secret == hook.Secret || rotated.PreviousSecretExpiresAt == nil {
+		t.Fatalf("RotateSecret() = %+v, want a new secret with the old one expiring", rotated)
+	}
+
+	handleEvent(t, webhooks, models.NewEvent(models.EventQuoteDeleted, models.EventData{Quote: &models.Quote{ID: 1}}))
+	waitForDelivery(t, webhooks, hook.ID, models.DeliverySucceeded)
+
+	req := receiver.all()[0]
+	signatures := strings.Split(req.header.Get(WebhookSignatureHeader), ",")
+	if len(signatures) != 2 {
+		t.Fatalf("signature header = %q, want the new and old signatures", req.header.Get(WebhookSignatureHeader))
+	}
+	if !verifySignature(rotated.Secret, req.body, signatures[0]) || !verifySignature(hook.Secret, req.body, signatures[1]) {
+		t.Errorf("signatures %v do not match the new and old secrets", signatures)
+	}
+
+	if _, err := webhooks.RotateSecret(999); err != errors.ErrWebhookNotFound {
+		t.Errorf("RotateSecret() of a missing webhook error = %v, want ErrWebhookNotFound", err)
+	}
+}
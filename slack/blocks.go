@@ -0,0 +1,134 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+
+	"quote-vault/models"
+)
+
+// Response types of a slash-command reply
+const (
+	// InChannel replies are shown to everyone in the channel
+	InChannel = "in_channel"
+	// Ephemeral replies are shown only to the user who typed the command
+	Ephemeral = "ephemeral"
+)
+
+// Message is a slash-command reply. Text is the plain fallback shown in
+// notifications; Blocks is the Block Kit layout.
+type Message struct {
+	ResponseType string  `json:"response_type"`
+	Text         string  `json:"text"`
+	Blocks       []Block `json:"blocks,omitempty"`
+}
+
+// Block is a Block Kit layout block. Only section, context and divider
+// blocks are used.
+type Block struct {
+	Type     string `json:"type"`
+	Text     *Text  `json:"text,omitempty"`
+	Elements []Text `json:"elements,omitempty"`
+}
+
+// Text is a Block Kit text object
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func mrkdwn(text string) *Text {
+	return &Text{Type: "mrkdwn", Text: text}
+}
+
+// TextMessage is a reply of plain text, seen only by the user
+func TextMessage(text string) *Message {
+	return &Message{
+		ResponseType: Ephemeral,
+		Text:         text,
+		Blocks:       []Block{{Type: "section", Text: mrkdwn(text)}},
+	}
+}
+
+// QuoteMessage shows a quote to the channel. link is the quote's page.
+func QuoteMessage(quote *models.Quote, link string) *Message {
+	return &Message{
+		ResponseType: InChannel,
+		Text:         fallback(quote),
+		Blocks:       quoteBlocks(quote, link),
+	}
+}
+
+// AddedMessage confirms to the user that a quote was added
+func AddedMessage(quote *models.Quote, link string) *Message {
+	blocks := []Block{{Type: "section", Text: mrkdwn(fmt.Sprintf("Added quote <%s|#%d>", link, quote.ID))}}
+	return &Message{
+		ResponseType: Ephemeral,
+		Text:         fmt.Sprintf("Added quote #%d: %s", quote.ID, fallback(quote)),
+		Blocks:       append(blocks, quoteBlocks(quote, link)...),
+	}
+}
+
+// SearchMessage lists search results to the user. total is the number of
+// matches, of which quotes are the first; link returns a quote's page.
+func SearchMessage(terms string, quotes []*models.Quote, total int, link func(*models.Quote) string) *Message {
+	if total == 0 {
+		return TextMessage(fmt.Sprintf("No quotes match _%s_", Escape(terms)))
+	}
+
+	summary := fmt.Sprintf("%d quotes match _%s_", total, Escape(terms))
+	switch {
+	case total == 1:
+		summary = fmt.Sprintf("1 quote matches _%s_", Escape(terms))
+	case total > len(quotes):
+		summary += fmt.Sprintf(", showing the first %d", len(quotes))
+	}
+
+	blocks := []Block{{Type: "section", Text: mrkdwn(summary)}}
+	for _, quote := range quotes {
+		blocks = append(blocks, Block{Type: "divider"})
+		blocks = append(blocks, quoteBlocks(quote, link(quote))...)
+	}
+	return &Message{
+		ResponseType: Ephemeral,
+		Text:         strings.ReplaceAll(summary, "_", ""),
+		Blocks:       blocks,
+	}
+}
+
+// HelpMessage explains the command. command is its name, such as /quote.
+func HelpMessage(command string) *Message {
+	lines := []string{
+		fmt.Sprintf("`%s` posts a random quote", command),
+		fmt.Sprintf("`%s wisdom` posts a random quote from a category", command),
+		fmt.Sprintf("`%s search courage` finds quotes", command),
+		fmt.Sprintf("`%s add \"The quote\" -- Author` adds a quote", command),
+	}
+	return TextMessage(strings.Join(lines, "\n"))
+}
+
+// quoteBlocks shows a quote as a block quote with its attribution, and its
+// category and a link underneath
+func quoteBlocks(quote *models.Quote, link string) []Block {
+	lines := strings.Split(Escape(quote.Text), "\n")
+	text := "> " + strings.Join(lines, "\n> ") + "\n— *" + Escape(quote.Author) + "*"
+	if quote.Source != "" {
+		text += ", _" + Escape(quote.Source) + "_"
+	}
+
+	return []Block{
+		{Type: "section", Text: mrkdwn(text)},
+		{Type: "context", Elements: []Text{*mrkdwn(fmt.Sprintf("%s · <%s|#%d>", Escape(quote.Category), link, quote.ID))}},
+	}
+}
+
+// fallback is the plain text form of a quote
+func fallback(quote *models.Quote) string {
+	return fmt.Sprintf("“%s” — %s", quote.Text, quote.Author)
+}
+
+// Escape escapes the characters Slack's mrkdwn treats as control
+// characters
+func Escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
@@ -0,0 +1,95 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of /quote command
+const (
+	CommandRandom = "random"
+	CommandSearch = "search"
+	CommandAdd    = "add"
+	CommandHelp   = "help"
+)
+
+// Command is the parsed text of a /quote command
+type Command struct {
+	Kind string
+	// Category limits a random quote; empty for any category
+	Category string
+	// Terms is what to search for
+	Terms string
+	// Text and Author are the quote to add
+	Text   string
+	Author string
+}
+
+// addSeparator separates the text of a quote to add from its author
+const addSeparator = "--"
+
+// ParseCommand parses the text typed after the command name:
+//
+//	(empty)                  a random quote
+//	<category>               a random quote from the category
+//	search <terms>           quotes matching the terms
+//	add "<text>" -- <author> a new quote
+//	help                     usage
+func ParseCommand(text string) (*Command, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return &Command{Kind: CommandRandom}, nil
+	}
+
+	word, rest := text, ""
+	if i := strings.IndexFunc(text, isSpace); i >= 0 {
+		word, rest = text[:i], strings.TrimSpace(text[i:])
+	}
+
+	switch strings.ToLower(word) {
+	case CommandHelp:
+		return &Command{Kind: CommandHelp}, nil
+	case CommandSearch:
+		if rest == "" {
+			return nil, fmt.Errorf("tell me what to search for, as in `search courage`")
+		}
+		return &Command{Kind: CommandSearch, Terms: rest}, nil
+	case CommandAdd:
+		return parseAdd(rest)
+	}
+	return &Command{Kind: CommandRandom, Category: text}, nil
+}
+
+// parseAdd parses `"<text>" -- <author>`. The quotation marks are
+// optional, and the curly ones Slack clients often substitute are accepted.
+func parseAdd(rest string) (*Command, error) {
+	usage := fmt.Errorf("add a quote as `add \"The quote\" -- Author`")
+
+	i := strings.LastIndex(rest, addSeparator)
+	if i < 0 {
+		return nil, usage
+	}
+	text := strings.TrimSpace(rest[:i])
+	author := strings.TrimSpace(rest[i+len(addSeparator):])
+	text = strings.TrimSpace(trimQuotes(text))
+	if text == "" || author == "" {
+		return nil, usage
+	}
+
+	return &Command{Kind: CommandAdd, Text: text, Author: author}, nil
+}
+
+// trimQuotes removes one pair of straight or curly quotation marks
+// around s
+func trimQuotes(s string) string {
+	for _, pair := range [][2]string{{`"`, `"`}, {"“", "”"}, {"'", "'"}, {"‘", "’"}} {
+		if len(s) >= len(pair[0])+len(pair[1]) && strings.HasPrefix(s, pair[0]) && strings.HasSuffix(s, pair[1]) {
+			return s[len(pair[0]) : len(s)-len(pair[1])]
+		}
+	}
+	return s
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n'
+}
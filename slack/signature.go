@@ -0,0 +1,70 @@
+// Package slack implements the parts of Slack's slash-command protocol the
+// service answers: signed requests, the text of /quote commands and Block
+// Kit replies.
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers carrying the signature of a request from Slack
+const (
+	SignatureHeader = "X-Slack-Signature"
+	TimestampHeader = "X-Slack-Request-Timestamp"
+)
+
+// MaxClockSkew is how far a request's timestamp may be from the current
+// time. Older requests are refused, so a captured request cannot be
+// replayed later.
+const MaxClockSkew = 5 * time.Minute
+
+// Reasons Verify refuses a request
+var (
+	ErrMissingSignature = errors.New("missing request signature")
+	ErrStaleRequest     = errors.New("request timestamp is outside the allowed window")
+	ErrBadSignature     = errors.New("request signature does not match")
+)
+
+// Sign returns the signature Slack sends for body at timestamp, in Unix
+// seconds, signed with the app's signing secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%d:", timestamp)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest sets the signature headers of a request as Slack would at
+// now, so requests can be made to the endpoint without Slack
+func SignRequest(req *http.Request, secret string, body []byte, now time.Time) {
+	timestamp := now.Unix()
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+}
+
+// Verify checks that body was signed with secret and that its timestamp
+// is within MaxClockSkew of now
+func Verify(secret string, header http.Header, body []byte, now time.Time) error {
+	signature := header.Get(SignatureHeader)
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if signature == "" || err != nil {
+		return ErrMissingSignature
+	}
+
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return ErrStaleRequest
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package slack
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"quote-vault/models"
+)
+
+func TestVerify(t *testing.T) {
+	// The example request from Slack's documentation on verifying requests
+	secret := "8f742231b10e8888abcd99yyyzzz85a5"
+	body := []byte("token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c")
+	timestamp := time.Unix(1531420618, 0)
+	header := http.Header{}
+	header.Set(TimestampHeader, "1531420618")
+	header.Set(SignatureHeader, "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503")
+
+	if err := Verify(secret, header, body, timestamp.Add(time.Minute)); err != nil {
+		t.Errorf("Verify() of Slack's example = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		body   []byte
+		now    time.Time
+		header func(http.Header)
+		want   error
+	}{
+		{"wrong secret", "other", body, timestamp, nil, ErrBadSignature},
+		{"altered body", secret, append([]byte("x"), body...), timestamp, nil, ErrBadSignature},
+		{"replayed later", secret, body, timestamp.Add(MaxClockSkew + time.Second), nil, ErrStaleRequest},
+		{"from the future", secret, body, timestamp.Add(-MaxClockSkew - time.Second), nil, ErrStaleRequest},
+		{"no signature", secret, body, timestamp, func(h http.Header) { h.Del(SignatureHeader) }, ErrMissingSignature},
+		{"bad timestamp", secret, body, timestamp, func(h http.Header) { h.Set(TimestampHeader, "yesterday") }, ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := header.Clone()
+			if tt.header != nil {
+				tt.header(h)
+			}
+			if err := Verify(tt.secret, h, tt.body, tt.now); err != tt.want {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Signed requests made locally verify like Slack's
+	req, _ := http.NewRequest(http.MethodPost, "/integrations/slack/command", nil)
+	now := time.Now()
+	SignRequest(req, secret, body, now)
+	if err := Verify(secret, req.Header, body, now); err != nil {
+		t.Errorf("Verify() of a request from SignRequest = %v", err)
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Command
+		wantErr bool
+	}{
+		{text: "", want: Command{Kind: CommandRandom}},
+		{text: "  wisdom ", want: Command{Kind: CommandRandom, Category: "wisdom"}},
+		{text: "help", want: Command{Kind: CommandHelp}},
+		{text: "search  stay hungry ", want: Command{Kind: CommandSearch, Terms: "stay hungry"}},
+		{text: "Search courage", want: Command{Kind: CommandSearch, Terms: "courage"}},
+		{text: "search", wantErr: true},
+		{text: `add "Be yourself -- everyone else is taken." -- Oscar Wilde`, want: Command{Kind: CommandAdd, Text: "Be yourself -- everyone else is taken.", Author: "Oscar Wilde"}},
+		{text: "add “Curly quotes” -- Someone", want: Command{Kind: CommandAdd, Text: "Curly quotes", Author: "Someone"}},
+		{text: "add Unquoted text --Anon", want: Command{Kind: CommandAdd, Text: "Unquoted text", Author: "Anon"}},
+		{text: `add "No author"`, wantErr: true},
+		{text: `add "" -- Nobody`, wantErr: true},
+		{text: `add "Nobody said" --`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseCommand(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("ParseCommand() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteMessage(t *testing.T) {
+	quote := &models.Quote{ID: 3, Text: "Less <is> more\n& then some", Author: "Mies", Category: "design", Source: "Interview"}
+	message := QuoteMessage(quote, "https://quotes.example.com/q/3")
+
+	if message.ResponseType != InChannel || message.Text != "“Less <is> more\n& then some” — Mies" {
+		t.Errorf("QuoteMessage() = %+v", message)
+	}
+	if len(message.Blocks) != 2 {
+		t.Fatalf("QuoteMessage() has %d blocks, want 2", len(message.Blocks))
+	}
+	if got := message.Blocks[0].Text.Text; got != "> Less &lt;is&gt; more\n> &amp; then some\n— *Mies*, _Interview_" {
+		t.Errorf("quote section = %q", got)
+	}
+	if got := message.Blocks[1].Elements[0].Text; got != "design · <https://quotes.example.com/q/3|#3>" {
+		t.Errorf("context = %q", got)
+	}
+
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(encoded), `"response_type":"in_channel"`) || !strings.Contains(string(encoded), `"type":"context","elements":[{"type":"mrkdwn"`) {
+		t.Errorf("encoded message = %s", encoded)
+	}
+}
+
+func TestSearchMessage(t *testing.T) {
+	link := func(quote *models.Quote) string { return "/q/1" }
+	quotes := []*models.Quote{{ID: 1, Text: "One", Author: "A", Category: "c"}}
+
+	if message := SearchMessage("nothing", nil, 0, link); message.ResponseType != Ephemeral || message.Text != "No quotes match _nothing_" {
+		t.Errorf("SearchMessage() without matches = %+v", message)
+	}
+	if message := SearchMessage("one", quotes, 1, link); message.Text != "1 quote matches one" || len(message.Blocks) != 4 {
+		t.Errorf("SearchMessage() with one match = %+v", message)
+	}
+	if message := SearchMessage("one", quotes, 7, link); message.Text != "7 quotes match one, showing the first 1" {
+		t.Errorf("SearchMessage() with more matches = %q", message.Text)
+	}
+}
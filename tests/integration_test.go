@@ -1,20 +1,54 @@
 package tests
 
 import (
+	"archive/zip"
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"quote-vault/activitypub"
 	"quote-vault/database"
+	"quote-vault/discord"
 	"quote-vault/handlers"
+	"quote-vault/mail"
+	"quote-vault/mail/mailtest"
+	"quote-vault/models"
 	"quote-vault/repository"
 	"quote-vault/router"
 	"quote-vault/services"
+	"quote-vault/slack"
 )
 
+// testCuratorPassword signs in to the web UI of the test server
+const testCuratorPassword = "let me curate"
+
+// testHeartbeat is the keep-alive interval of test event streams
+const testHeartbeat = 100 * time.Millisecond
+
+// testSlackSecret is the signing secret of the test server's Slack app
+const testSlackSecret = "slack signing secret"
+
+// testDiscordKey is the key pair of the test server's Discord application
+var testDiscordKey = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{7}, ed25519.SeedSize))
+
 func setupTestServer(t *testing.T) (*httptest.Server, *database.SQLiteDB) {
 	db, err := database.NewSQLiteDB(":memory:")
 	if err != nil {
@@ -23,12 +57,67 @@ func setupTestServer(t *testing.T) (*httptest.Server, *database.SQLiteDB) {
 
 	repo := repository.NewQuoteRepository(db.DB())
 	service := services.NewQuoteService(repo)
+	importService := services.NewImportService(repo)
+	webhookService := services.NewWebhookService(repository.NewWebhookRepository(db.DB()), services.WebhookOptions{
+		RetryOptions: services.RetryOptions{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond},
+	})
+	eventLog := services.NewEventLog(repository.NewEventRepository(db.DB()), 0)
+	eventBus := services.NewEventBus(repository.NewOutboxRepository(db.DB()), services.EventBusOptions{
+		BaseDelay:    10 * time.Millisecond,
+		PollInterval: 50 * time.Millisecond,
+	})
+	for name, subscriber := range map[string]services.Subscriber{"event_log": eventLog, "webhooks": webhookService} {
+		if err := eventBus.Subscribe(name, subscriber); err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+	}
+	service.SetEventBus(eventBus)
+	importService.SetEventBus(eventBus)
 	quoteHandler := handlers.NewQuoteHandler(service)
 	healthHandler := handlers.NewHealthHandler(db)
-
-	r := router.NewRouter(quoteHandler, healthHandler)
+	importHandler := handlers.NewImportHandler(importService)
+	exportHandler := handlers.NewExportHandler(services.NewExportService(repo))
+	cardHandler := handlers.NewCardHandler(service, services.NewCardService(services.DefaultCardCacheSize))
+	feedHandler := handlers.NewFeedHandler(service, "")
+	embedHandler := handlers.NewEmbedHandler(service, "")
+	pageHandler := handlers.NewPageHandler(service, "")
+	uiHandler := handlers.NewUIHandler(service, handlers.UIOptions{PageSize: 2, CuratorPassword: testCuratorPassword})
+
+	r := router.NewRouter(router.Handlers{
+		Quote:   quoteHandler,
+		Health:  healthHandler,
+		Import:  importHandler,
+		Export:  exportHandler,
+		Card:    cardHandler,
+		Feed:    feedHandler,
+		Embed:   embedHandler,
+		Page:    pageHandler,
+		UI:      uiHandler,
+		Webhook: handlers.NewWebhookHandler(webhookService, testCuratorPassword),
+		Events:  handlers.NewEventsHandler(eventLog, testHeartbeat),
+		Changes: handlers.NewChangesHandler(services.NewChangesService(repository.NewChangeRepository(db.DB()), 0)),
+		Slack:   handlers.NewSlackHandler(service, testSlackSecret, ""),
+		Discord: handlers.NewDiscordHandler(service, testDiscordKey.Public().(ed25519.PublicKey), ""),
+	})
 	server := httptest.NewServer(r)
 
+	ctx, stopWorker := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		webhookService.Run(ctx)
+		close(workerDone)
+	}()
+	busDone := make(chan struct{})
+	go func() {
+		eventBus.Run(ctx)
+		close(busDone)
+	}()
+	t.Cleanup(func() {
+		stopWorker()
+		<-workerDone
+		<-busDone
+	})
+
 	return server, db
 }
 
@@ -371,3 +460,2084 @@ func TestIntegration_ResponseHeaders(t *testing.T) {
 		t.Error("missing X-Request-ID header")
 	}
 }
+
+func TestIntegration_ImportQuotes(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	csvBody := "quote,by\n" +
+		"Imagination is more important than knowledge.,Albert Einstein\n" +
+		"Short,Nobody\n"
+
+	resp, err := http.Post(
+		server.URL+"/api/v1/import?text_column=quote&author_column=by",
+		"text/csv",
+		bytes.NewReader([]byte(csvBody)),
+	)
+	if err != nil {
+		t.Fatalf("failed to import quotes: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /api/v1/import status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	var importResponse map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&importResponse)
+
+	data := importResponse["data"].(map[string]interface{})
+	if int(data["created"].(float64)) != 1 || int(data["invalid"].(float64)) != 1 {
+		t.Errorf("POST /api/v1/import created/invalid = %v/%v, want 1/1", data["created"], data["invalid"])
+	}
+
+	// Unknown formats are rejected
+	resp, err = http.Post(server.URL+"/api/v1/import", "application/xml", bytes.NewReader([]byte("<quotes/>")))
+	if err != nil {
+		t.Fatalf("failed to import quotes: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /api/v1/import unsupported format status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestIntegration_ExportQuotes(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	quotesData := []map[string]string{
+		{"text": "Motivation quote 1", "author": "Author 1", "category": "motivation"},
+		{"text": "Humor quote", "author": "Author 2", "category": "humor"},
+	}
+	for _, q := range quotesData {
+		body, _ := json.Marshal(q)
+		resp, err := http.Post(server.URL+"/api/v1/quotes", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("failed to create quote: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(server.URL + "/api/v1/export?format=md&category=motivation")
+	if err != nil {
+		t.Fatalf("failed to export quotes: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /api/v1/export status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+		t.Errorf("GET /api/v1/export Content-Type = %v, want text/markdown", ct)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, `filename="quotes-motivation-`) {
+		t.Errorf("GET /api/v1/export Content-Disposition = %v", cd)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "## motivation") || strings.Contains(string(body), "humor") {
+		t.Errorf("GET /api/v1/export body = %q, want only the motivation category", body)
+	}
+
+	// The fortune text and its index come from one read, in one archive
+	resp, err = http.Get(server.URL + "/api/v1/export?format=fortune-zip&category=motivation")
+	if err != nil {
+		t.Fatalf("failed to export quotes: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Errorf("GET /api/v1/export?format=fortune-zip status = %v, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("fortune export is not a zip: %v", err)
+	}
+	base := "quotes-motivation-" + time.Now().UTC().Format("20060102")
+	if len(archive.File) != 2 || archive.File[0].Name != base || archive.File[1].Name != base+".dat" {
+		t.Errorf("fortune archive files = %v, want %s and its .dat", archive.File, base)
+	}
+
+	_, err = db.DB().Exec(`UPDATE quotes SET created_at = '2023-06-01 12:00:00' WHERE category = 'humor'`)
+	if err != nil {
+		t.Fatalf("failed to backdate quote: %v", err)
+	}
+	for format, contentType := range map[string]string{"epub": "application/epub+zip", "pdf": "application/pdf"} {
+		resp, err := http.Get(server.URL + "/api/v1/export?format=" + format + "&year=2023&title=Anthology")
+		if err != nil {
+			t.Fatalf("failed to export quotes: %v", err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != contentType {
+			t.Errorf("GET /api/v1/export?format=%s status = %v, Content-Type = %q", format, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, "."+format+`"`) {
+			t.Errorf("GET /api/v1/export?format=%s Content-Disposition = %v", format, cd)
+		}
+		if format == "epub" {
+			book, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("EPUB export is not a zip: %v", err)
+			}
+			var chapters []string
+			for _, f := range book.File {
+				if strings.HasPrefix(f.Name, "OEBPS/chapter-") {
+					chapters = append(chapters, f.Name)
+				}
+			}
+			if len(chapters) != 1 {
+				t.Errorf("EPUB export of 2023 has chapters %v, want only humor", chapters)
+			}
+		}
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/export?format=pdf&year=last")
+	if err != nil {
+		t.Fatalf("failed to export quotes: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET /api/v1/export invalid year status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/export?format=docx")
+	if err != nil {
+		t.Fatalf("failed to export quotes: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET /api/v1/export unsupported format status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestIntegration_ImportFortuneFile(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "fortunes/wisdom")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	part.Write([]byte("The only true wisdom is in knowing you know nothing.\n\t\t-- Socrates\n%\n"))
+	writer.Close()
+
+	resp, err := http.Post(server.URL+"/api/v1/import?format=fortune", writer.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("failed to import fortune file: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /api/v1/import?format=fortune status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/quotes?category=wisdom")
+	if err != nil {
+		t.Fatalf("failed to list quotes: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var listResponse map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&listResponse)
+
+	quotes := listResponse["data"].(map[string]interface{})["quotes"].([]interface{})
+	if len(quotes) != 1 || quotes[0].(map[string]interface{})["author"] != "Socrates" {
+		t.Errorf("GET /api/v1/quotes?category=wisdom = %v, want the imported fortune", quotes)
+	}
+}
+
+func TestIntegration_ContentNegotiation(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	body, _ := json.Marshal(map[string]string{
+		"text":     "The only way to do great work is to love what you do.",
+		"author":   "Steve Jobs",
+		"category": "motivation",
+	})
+	resp, err := http.Post(server.URL+"/api/v1/quotes", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create quote: %v", err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		accept      string
+		agent       string
+		contentType string
+		contains    string
+	}{
+		{name: "default json", path: "/api/v1/quotes/random", contentType: "application/json", contains: `"author":"Steve Jobs"`},
+		{name: "plain text", path: "/api/v1/quotes/random", accept: "text/plain", contentType: "text/plain", contains: "— Steve Jobs"},
+		{name: "html", path: "/api/v1/quotes/1", accept: "text/html", contentType: "text/html", contains: `<span class="quote-author">Steve Jobs</span>`},
+		{name: "xml list", path: "/api/v1/quotes", accept: "application/xml", contentType: "application/xml", contains: `<quotes total="1"`},
+		{name: "format override", path: "/api/v1/quotes/random?format=md", accept: "text/html", contentType: "text/markdown", contains: "> — **Steve Jobs**"},
+		{name: "curl", path: "/api/v1/quotes/random", accept: "*/*", agent: "curl/8.4.0", contentType: "text/plain", contains: "╭"},
+		{name: "curl asking for json", path: "/api/v1/quotes/random", accept: "application/json", agent: "curl/8.4.0", contentType: "application/json", contains: `"author":"Steve Jobs"`},
+		{name: "cowsay", path: "/api/v1/quotes/random?term=1&frame=cow&color=false&width=30", contentType: "text/plain", contains: "(oo)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if tt.agent != "" {
+				req.Header.Set("User-Agent", tt.agent)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to make request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s status = %v, want %v", tt.path, resp.StatusCode, http.StatusOK)
+			}
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("GET %s Content-Type = %v, want %v", tt.path, ct, tt.contentType)
+			}
+			data, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(data), tt.contains) {
+				t.Errorf("GET %s body = %q, want it to contain %q", tt.path, data, tt.contains)
+			}
+		})
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/quotes/random?format=pdf")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET /api/v1/quotes/random?format=pdf status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestIntegration_QuoteCards(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	body, _ := json.Marshal(map[string]string{
+		"text":     "The only way to do great work is to love what you do.",
+		"author":   "Steve Jobs",
+		"category": "motivation",
+	})
+	resp, err := http.Post(server.URL+"/api/v1/quotes", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create quote: %v", err)
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		status     int
+		noCache    bool
+		wantInBody string
+	}{
+		{name: "by id", path: "/api/v1/quotes/1/card.svg?theme=dark", status: http.StatusOK, wantInBody: "#0d1117"},
+		{name: "random", path: "/api/v1/quotes/random/card.svg?category=motivation", status: http.StatusOK, noCache: true, wantInBody: "Steve Jobs"},
+		{name: "missing quote", path: "/api/v1/quotes/99/card.svg", status: http.StatusNotFound},
+		{name: "unknown theme", path: "/api/v1/quotes/1/card.svg?theme=neon", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatalf("failed to make request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("GET %s status = %v, want %v", tt.path, resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/svg+xml") {
+				t.Errorf("GET %s Content-Type = %v, want image/svg+xml", tt.path, ct)
+			}
+			cacheControl := resp.Header.Get("Cache-Control")
+			if tt.noCache && (!strings.Contains(cacheControl, "no-cache") || resp.Header.Get("ETag") != "") {
+				t.Errorf("GET %s Cache-Control = %q, ETag = %q, want an uncached response", tt.path, cacheControl, resp.Header.Get("ETag"))
+			}
+			data, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(data), tt.wantInBody) {
+				t.Errorf("GET %s body = %q, want it to contain %q", tt.path, data, tt.wantInBody)
+			}
+		})
+	}
+}
+
+func TestIntegration_PNGQuoteCards(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	body, _ := json.Marshal(map[string]string{
+		"text":     "The only way to do great work is to love what you do.",
+		"author":   "Steve Jobs",
+		"category": "motivation",
+	})
+	resp, err := http.Post(server.URL+"/api/v1/quotes", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create quote: %v", err)
+	}
+	resp.Body.Close()
+
+	path := server.URL + "/api/v1/quotes/1/card.png?width=1200&height=630&bg=ff9a8b,8e44ad&color=fff"
+	resp, err = http.Get(path)
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET card.png status = %v, want %v: %s", resp.StatusCode, http.StatusOK, data)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %v, want image/png", ct)
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width != 1200 || config.Height != 630 {
+		t.Errorf("DecodeConfig() = %+v, %v, want a 1200x630 PNG", config, err)
+	}
+
+	// The ETag follows the quote version, so a revalidation is answered
+	// without a body
+	etag := resp.Header.Get("ETag")
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("conditional GET status = %v, want %v", resp.StatusCode, http.StatusNotModified)
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/quotes/random/card.png")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		t.Errorf("random card.png status = %v, Cache-Control = %q", resp.StatusCode, resp.Header.Get("Cache-Control"))
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/quotes/1/card.png?bg=notacolor")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid bg status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestIntegration_Feeds(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	quotes := []map[string]string{
+		{"text": "The only way to do great work is to love what you do.", "author": "Steve Jobs", "category": "motivation"},
+		{"text": "Be yourself; everyone else is already taken.", "author": "Oscar Wilde", "category": "wisdom"},
+	}
+	for _, quote := range quotes {
+		body, _ := json.Marshal(quote)
+		resp, err := http.Post(server.URL+"/api/v1/quotes", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("failed to create quote: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    []string
+		excludes    string
+	}{
+		{"/feeds/quotes.atom", "application/atom+xml", []string{"<feed", "Steve Jobs", "Oscar Wilde", ":quotes/1</id>"}, ""},
+		{"/feeds/quotes.rss", "application/rss+xml", []string{"<rss", `<guid isPermaLink="false">`, "Oscar Wilde"}, ""},
+		{"/feeds/categories/wisdom.atom", "application/atom+xml", []string{"Oscar Wilde"}, "Steve Jobs"},
+		{"/feeds/authors/steve%20jobs.rss", "application/rss+xml", []string{"Steve Jobs"}, "Oscar Wilde"},
+		{"/feeds/daily.atom", "application/atom+xml", []string{"quote of the day", ":daily/"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatalf("failed to make request: %v", err)
+			}
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s status = %v, want %v", tt.path, resp.StatusCode, http.StatusOK)
+			}
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("GET %s Content-Type = %v, want %v", tt.path, ct, tt.contentType)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("GET %s body does not contain %q", tt.path, want)
+				}
+			}
+			if tt.excludes != "" && strings.Contains(string(data), tt.excludes) {
+				t.Errorf("GET %s body contains %q", tt.path, tt.excludes)
+			}
+
+			// Feed readers poll with the validators from the last fetch
+			for header, value := range map[string]string{
+				"If-None-Match":     resp.Header.Get("ETag"),
+				"If-Modified-Since": resp.Header.Get("Last-Modified"),
+			} {
+				req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+				req.Header.Set(header, value)
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("failed to make request: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusNotModified {
+					t.Errorf("GET %s with %s status = %v, want %v", tt.path, header, resp.StatusCode, http.StatusNotModified)
+				}
+			}
+		})
+	}
+
+	resp, err := http.Get(server.URL + "/api/v1/quotes/daily")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /api/v1/quotes/daily status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestIntegration_DailyCalendar(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	body, _ := json.Marshal(map[string]string{
+		"text":     "Be yourself; everyone else is already taken.",
+		"author":   "Oscar Wilde",
+		"category": "wisdom",
+	})
+	resp, err := http.Post(server.URL+"/api/v1/quotes", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create quote: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/feeds/daily.ics?tz=America/New_York&category=wisdom")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /feeds/daily.ics status = %v, want %v: %s", resp.StatusCode, http.StatusOK, data)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("Content-Type = %v, want text/calendar", ct)
+	}
+
+	out := strings.ReplaceAll(string(data), "\r\n ", "")
+	// The quote was created today, so it is the quote of today and of
+	// every upcoming day, but not of the days before it existed
+	if n := strings.Count(out, "BEGIN:VEVENT"); n < 29 || n > 31 {
+		t.Errorf("calendar has %d events, want about 30", n)
+	}
+	for _, want := range []string{"X-WR-TIMEZONE:America/New_York", `Be yourself\; everyone else`, "CATEGORIES:wisdom", "UID:daily-wisdom-"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("calendar does not contain %q", want)
+		}
+	}
+
+	resp, err = http.Get(server.URL + "/feeds/daily.ics?tz=Mars/Olympus_Mons")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown tz status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestIntegration_Embed(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	body, _ := json.Marshal(map[string]string{
+		"text":     "Be yourself; everyone else is already taken.",
+		"author":   "Oscar Wilde",
+		"category": "wisdom",
+	})
+	resp, err := http.Post(server.URL+"/api/v1/quotes", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create quote: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/embed/widget.js")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	script, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/javascript") {
+		t.Errorf("GET /embed/widget.js status = %v, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(script), "quote-vault") {
+		t.Error("widget script looks empty")
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"api url", "url=" + server.URL + "/api/v1/quotes/1&maxwidth=400&theme=dark", http.StatusOK},
+		{"permalink", "url=" + server.URL + "/q/1", http.StatusOK},
+		{"missing quote", "url=" + server.URL + "/q/99", http.StatusNotFound},
+		{"other site", "url=https://example.com/quotes/1", http.StatusNotFound},
+		{"not a quote", "url=" + server.URL + "/feeds/quotes.atom", http.StatusNotFound},
+		{"xml", "url=" + server.URL + "/q/1&format=xml", http.StatusNotImplemented},
+		{"no url", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + "/oembed?" + tt.query)
+			if err != nil {
+				t.Fatalf("failed to make request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("GET /oembed?%s status = %v, want %v", tt.query, resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var embed map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&embed); err != nil {
+				t.Fatalf("failed to decode oEmbed response: %v", err)
+			}
+			if embed["version"] != "1.0" || embed["type"] != "rich" || embed["author_name"] != "Oscar Wilde" {
+				t.Errorf("oEmbed response = %v", embed)
+			}
+			html, _ := embed["html"].(string)
+			if !strings.Contains(html, `class="quote-vault" data-id="1"`) || !strings.Contains(html, "/embed/widget.js") {
+				t.Errorf("oEmbed html = %q", html)
+			}
+			if tt.name == "api url" && (embed["width"] != float64(400) || !strings.Contains(html, `data-theme="dark"`)) {
+				t.Errorf("oEmbed width = %v, html = %q, want maxwidth and theme applied", embed["width"], html)
+			}
+		})
+	}
+}
+
+func TestIntegration_QuotePages(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"text":     "Be yourself; everyone else is already taken. <script>alert(1)</script>",
+		"author":   "Oscar Wilde",
+		"category": "wisdom",
+		"source":   "Attributed",
+	})
+	resp, err := http.Post(server.URL+"/api/v1/quotes", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create quote: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/q/1")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("GET /q/1 status = %v, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	page := string(data)
+	for _, want := range []string{
+		`<link rel="canonical" href="` + server.URL + `/q/1">`,
+		`<meta property="og:url" content="` + server.URL + `/q/1">`,
+		`<meta property="og:image" content="` + server.URL + `/api/v1/quotes/1/card.png?width=1200&amp;height=630">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`type="application/json+oembed"`,
+		`<span class="author">Oscar Wilde</span>, <cite>Attributed</cite>`,
+		`&lt;script&gt;alert(1)&lt;/script&gt;`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("GET /q/1 page does not contain %q", want)
+		}
+	}
+	if strings.Contains(page, "<script>alert(1)") {
+		t.Error("quote text is not escaped")
+	}
+
+	resp, err = http.Get(server.URL + "/static/style.css")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/css") {
+		t.Errorf("GET /static/style.css status = %v, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Get(server.URL + "/q/99")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("GET /q/99 status = %v, Content-Type = %q, want an HTML 404", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = client.Get(server.URL + "/q/random")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != server.URL+"/q/1" {
+		t.Errorf("GET /q/random status = %v, Location = %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestIntegration_WebUI(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	get := func(path string) (*http.Response, string) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(data)
+	}
+	post := func(path string, form url.Values) (*http.Response, string) {
+		resp, err := client.PostForm(server.URL+path, form)
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", path, err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(data)
+	}
+	csrfToken := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+	resp, page := get("/ui/")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(page, "No quotes found") {
+		t.Fatalf("GET /ui/ status = %v, want an empty listing", resp.StatusCode)
+	}
+	if !strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		t.Errorf("GET /ui/ Cache-Control = %q, want no-store", resp.Header.Get("Cache-Control"))
+	}
+
+	// Editing needs a signed-in curator
+	resp, _ = get("/ui/quotes/new")
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != server.URL+"/ui/login?next=%2Fui%2Fquotes%2Fnew" {
+		t.Fatalf("GET /ui/quotes/new signed out status = %v, Location = %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	resp, _ = post("/ui/login", url.Values{"password": {"wrong"}})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("POST /ui/login with a wrong password status = %v, want 401", resp.StatusCode)
+	}
+	resp, _ = post("/ui/login", url.Values{"password": {testCuratorPassword}, "next": {"/ui/quotes/new"}})
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != server.URL+"/ui/quotes/new" {
+		t.Fatalf("POST /ui/login status = %v, Location = %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, page = get("/ui/quotes/new")
+	match := csrfToken.FindStringSubmatch(page)
+	if resp.StatusCode != http.StatusOK || match == nil {
+		t.Fatalf("GET /ui/quotes/new status = %v, want a form with a CSRF token", resp.StatusCode)
+	}
+	token := match[1]
+
+	quote := url.Values{
+		"text":     {"Be yourself;\r\neveryone else is already taken."},
+		"author":   {"Oscar Wilde"},
+		"category": {"wit"},
+		"tags":     {"Identity, self"},
+	}
+	resp, _ = post("/ui/quotes", quote)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("POST /ui/quotes without a CSRF token status = %v, want 403", resp.StatusCode)
+	}
+
+	quote.Set("csrf_token", token)
+	quote.Set("text", "Short")
+	resp, page = post("/ui/quotes", quote)
+	if resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(page, "at least 10 characters") {
+		t.Errorf("POST /ui/quotes with short text status = %v, want 422 with the problem", resp.StatusCode)
+	}
+
+	quote.Set("text", "Be yourself;\r\neveryone else is already taken.")
+	resp, _ = post("/ui/quotes", quote)
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != server.URL+"/q/1" {
+		t.Fatalf("POST /ui/quotes status = %v, Location = %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	for _, text := range []string{"Another quote for paging tests", "A third quote for paging tests"} {
+		quote.Set("text", text)
+		post("/ui/quotes", quote)
+	}
+
+	created := getQuote(t, server, 1)
+	if created.Text != "Be yourself;\neveryone else is already taken." || len(created.Tags) != 2 || created.Tags[0] != "identity" {
+		t.Errorf("created quote = %+v", created)
+	}
+
+	resp, page = get("/ui/quotes/1/edit")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(page, `name="version" value="1"`) {
+		t.Fatalf("GET /ui/quotes/1/edit status = %v, want a form at version 1", resp.StatusCode)
+	}
+	edit := url.Values{
+		"csrf_token": {token},
+		"version":    {"1"},
+		"text":       {"Be yourself; everyone else is already taken."},
+		"author":     {"Oscar Wilde"},
+		"category":   {"wisdom"},
+		"source":     {"Attributed"},
+	}
+	resp, _ = post("/ui/quotes/1", edit)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("POST /ui/quotes/1 status = %v, want 303", resp.StatusCode)
+	}
+	if updated := getQuote(t, server, 1); updated.Version != 2 || updated.Category != "wisdom" || updated.Source != "Attributed" {
+		t.Errorf("updated quote = %+v, want it at version 2", updated)
+	}
+
+	// A second edit based on version 1 is stopped with a warning
+	edit.Set("text", "A stale edit of the quote")
+	resp, page = post("/ui/quotes/1", edit)
+	if resp.StatusCode != http.StatusConflict || !strings.Contains(page, `name="version" value="2"`) {
+		t.Errorf("POST /ui/quotes/1 with a stale version status = %v, want 409 with version 2", resp.StatusCode)
+	}
+	if getQuote(t, server, 1).Text != "Be yourself; everyone else is already taken." {
+		t.Error("stale edit overwrote the quote")
+	}
+
+	resp, page = get("/ui/?q=YOURSELF")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(page, "Be yourself") || !strings.Contains(page, `href="`+server.URL+`/ui/quotes/1/edit"`) {
+		t.Errorf("GET /ui/?q=YOURSELF status = %v, want the quote with an edit link", resp.StatusCode)
+	}
+	if strings.Contains(page, "paging tests") {
+		t.Error("GET /ui/?q=YOURSELF lists quotes that do not match")
+	}
+
+	_, page = get("/ui/?author=oscar+wilde")
+	if !strings.Contains(page, "Page 1 of 2") || !strings.Contains(page, `rel="next" href="`+server.URL+`/ui/?author=oscar&#43;wilde&amp;page=2"`) {
+		t.Errorf("GET /ui/?author=oscar+wilde page = %s, want the first of two pages", page)
+	}
+
+	_, page = get("/ui/authors")
+	if !strings.Contains(page, `<a href="`+server.URL+`/ui/?author=Oscar%20Wilde">Oscar Wilde</a> <span class="count">3</span>`) {
+		t.Errorf("GET /ui/authors page = %s, want Oscar Wilde with 3 quotes", page)
+	}
+
+	resp, _ = post("/ui/logout", url.Values{"csrf_token": {token}})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("POST /ui/logout status = %v, want 303", resp.StatusCode)
+	}
+	resp, _ = get("/ui/quotes/1/edit")
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("GET /ui/quotes/1/edit after signing out status = %v, want a redirect to sign in", resp.StatusCode)
+	}
+}
+
+// getQuote fetches a quote through the JSON API
+func getQuote(t *testing.T, server *httptest.Server, id int) *models.Quote {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/quotes/%d", server.URL, id))
+	if err != nil {
+		t.Fatalf("failed to get quote %d: %v", id, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data models.Quote `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode quote %d: %v", id, err)
+	}
+	return &body.Data
+}
+
+// apiRequest sends a JSON request and returns the response with its body
+func apiRequest(t *testing.T, method, target string, payload interface{}) (*http.Response, []byte) {
+	var body io.Reader
+	if payload != nil {
+		data, _ := json.Marshal(payload)
+		body = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, target, body)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, data
+}
+
+// curatorRequest is apiRequest with the curator password as a bearer token
+func curatorRequest(t *testing.T, method, target string, payload interface{}) (*http.Response, []byte) {
+	var body io.Reader
+	if payload != nil {
+		data, _ := json.Marshal(payload)
+		body = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, target, body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testCuratorPassword)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, data
+}
+
+func TestIntegration_UpdateAndDeleteQuote(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	api := server.URL + "/api/v1/quotes"
+	resp, _ := apiRequest(t, "POST", api, map[string]string{"text": "A quote to be revised", "author": "Drafter", "category": "drafts"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /api/v1/quotes status = %v", resp.StatusCode)
+	}
+
+	update := map[string]interface{}{"text": "A quote after revision", "author": "Drafter", "category": "final", "version": 1}
+	resp, body := apiRequest(t, "PUT", api+"/1", update)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /api/v1/quotes/1 status = %v: %s", resp.StatusCode, body)
+	}
+	if quote := getQuote(t, server, 1); quote.Text != "A quote after revision" || quote.Version != 2 {
+		t.Errorf("quote after PUT = %+v", quote)
+	}
+
+	// The same edit again is based on a stale version
+	resp, _ = apiRequest(t, "PUT", api+"/1", update)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("PUT with a stale version status = %v, want %v", resp.StatusCode, http.StatusConflict)
+	}
+	resp, _ = apiRequest(t, "PUT", api+"/1", map[string]string{"text": "", "author": "Drafter"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT without text status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp, _ = apiRequest(t, "DELETE", api+"/1", nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE /api/v1/quotes/1 status = %v, want %v", resp.StatusCode, http.StatusNoContent)
+	}
+	resp, _ = apiRequest(t, "GET", api+"/1", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after DELETE status = %v, want %v", resp.StatusCode, http.StatusNotFound)
+	}
+	resp, _ = apiRequest(t, "DELETE", api+"/1", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("second DELETE status = %v, want %v", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestIntegration_Webhooks(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	// Closed once the webhook worker has stopped
+	t.Cleanup(func() { db.Close() })
+
+	received := make(chan *http.Request, 20)
+	var failing atomic.Bool
+	failing.Store(true)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		received <- r
+		if failing.Load() && r.Header.Get("X-Quote-Vault-Event") == "quote.deleted" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	webhooks := server.URL + "/api/v1/webhooks"
+	for _, route := range []struct{ method, path string }{
+		{"POST", ""}, {"GET", ""}, {"GET", "/1"}, {"DELETE", "/1"}, {"POST", "/1/rotate-secret"},
+		{"GET", "/1/deliveries"}, {"POST", "/1/deliveries/1/replay"},
+	} {
+		if resp, _ := apiRequest(t, route.method, webhooks+route.path, nil); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s /api/v1/webhooks%s without the curator password status = %v, want %v",
+				route.method, route.path, resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
+
+	resp, _ := curatorRequest(t, "POST", webhooks, map[string]interface{}{"url": "not a url"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /api/v1/webhooks with an invalid URL status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp, body := curatorRequest(t, "POST", webhooks, map[string]interface{}{
+		"url":    receiver.URL,
+		"events": []string{"quote.created", "quote.updated", "quote.deleted"},
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /api/v1/webhooks status = %v: %s", resp.StatusCode, body)
+	}
+	var created struct {
+		Data models.Webhook `json:"data"`
+	}
+	json.Unmarshal(body, &created)
+	hook := created.Data
+	if hook.ID == 0 || !strings.HasPrefix(hook.Secret, "whsec_") {
+		t.Fatalf("POST /api/v1/webhooks = %s, want the webhook with its secret", body)
+	}
+
+	receiverURL := receiver.URL + "/hooks?token=abc"
+	resp, body = curatorRequest(t, "POST", webhooks, map[string]interface{}{"url": receiverURL, "events": []string{"category.changed"}})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /api/v1/webhooks status = %v: %s", resp.StatusCode, body)
+	}
+	_, body = curatorRequest(t, "GET", webhooks, nil)
+	if strings.Contains(string(body), hook.Secret) || strings.Contains(string(body), receiverURL) || !strings.Contains(string(body), receiver.URL) {
+		t.Errorf("GET /api/v1/webhooks = %s, want the webhooks by origin and without their secrets", body)
+	}
+	_, body = curatorRequest(t, "GET", fmt.Sprintf("%s/%d", webhooks, hook.ID+1), nil)
+	if strings.Contains(string(body), "whsec_") || !strings.Contains(string(body), receiverURL) {
+		t.Errorf("GET /api/v1/webhooks/%d = %s, want the full URL without the secret", hook.ID+1, body)
+	}
+	curatorRequest(t, "DELETE", fmt.Sprintf("%s/%d", webhooks, hook.ID+1), nil)
+
+	quotes := server.URL + "/api/v1/quotes"
+	apiRequest(t, "POST", quotes, map[string]string{"text": "Announced to every webhook", "author": "Herald", "category": "news"})
+	apiRequest(t, "PUT", quotes+"/1", map[string]string{"text": "Announced to every webhook, again", "author": "Herald", "category": "news"})
+	apiRequest(t, "DELETE", quotes+"/1", nil)
+
+	var events []string
+	for len(events) < 4 {
+		select {
+		case r := <-received:
+			payload, _ := io.ReadAll(r.Body)
+			if r.Header.Get("X-Quote-Vault-Signature") != services.SignWebhookPayload(hook.Secret, payload) {
+				t.Errorf("delivery of %s has signature %q", r.Header.Get("X-Quote-Vault-Event"), r.Header.Get("X-Quote-Vault-Signature"))
+			}
+			events = append(events, r.Header.Get("X-Quote-Vault-Event"))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received events %v, want created, updated and a retried deletion", events)
+		}
+	}
+	sort.Strings(events)
+	if want := "quote.created quote.deleted quote.deleted quote.updated"; strings.Join(events, " ") != want {
+		t.Errorf("received events %v, want %s", events, want)
+	}
+
+	// The failing delivery is retried until it runs out of attempts
+	var failed []models.WebhookDelivery
+	deadline := time.Now().Add(5 * time.Second)
+	for len(failed) == 0 && time.Now().Before(deadline) {
+		_, body = curatorRequest(t, "GET", fmt.Sprintf("%s/%d/deliveries?status=failed", webhooks, hook.ID), nil)
+		var list struct {
+			Data []models.WebhookDelivery `json:"data"`
+		}
+		json.Unmarshal(body, &list)
+		failed = list.Data
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(failed) != 1 || failed[0].Event != "quote.deleted" || failed[0].Attempts != services.DefaultWebhookMaxAttempts {
+		t.Fatalf("failed deliveries = %+v, want the deletion after %d attempts", failed, services.DefaultWebhookMaxAttempts)
+	}
+
+	// Rotating the secret returns the new one; deliveries carry both
+	// signatures for now
+	resp, body = curatorRequest(t, "POST", fmt.Sprintf("%s/%d/rotate-secret", webhooks, hook.ID), nil)
+	var rotated struct {
+		Data models.Webhook `json:"data"`
+	}
+	json.Unmarshal(body, &rotated)
+	if resp.StatusCode != http.StatusOK || rotated.Data.Secret == "" || rotated.Data.Secret == hook.Secret {
+		t.Fatalf("POST rotate-secret = %v: %s", resp.StatusCode, body)
+	}
+
+	failing.Store(false)
+	for len(received) > 0 {
+		<-received
+	}
+	resp, body = curatorRequest(t, "POST", fmt.Sprintf("%s/%d/deliveries/%d/replay", webhooks, hook.ID, failed[0].ID), nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST replay status = %v: %s", resp.StatusCode, body)
+	}
+	select {
+	case r := <-received:
+		payload, _ := io.ReadAll(r.Body)
+		want := services.SignWebhookPayload(rotated.Data.Secret, payload) + "," + services.SignWebhookPayload(hook.Secret, payload)
+		if r.Header.Get("X-Quote-Vault-Signature") != want {
+			t.Errorf("replayed delivery signature = %q, want %q", r.Header.Get("X-Quote-Vault-Signature"), want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("replayed delivery was not received")
+	}
+
+	resp, _ = curatorRequest(t, "DELETE", fmt.Sprintf("%s/%d", webhooks, hook.ID), nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE webhook status = %v, want %v", resp.StatusCode, http.StatusNoContent)
+	}
+	resp, _ = curatorRequest(t, "GET", fmt.Sprintf("%s/%d/deliveries", webhooks, hook.ID), nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET deliveries of a deleted webhook status = %v, want %v", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+// sseEvent is one event or comment read from an event stream
+type sseEvent struct {
+	id, event, data, comment string
+}
+
+// openEventStream connects to an event stream and reads its events in the
+// background until the response body is closed
+func openEventStream(t *testing.T, target, lastEventID string) (*http.Response, <-chan sseEvent) {
+	req, _ := http.NewRequest("GET", target, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", target, err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		resp.Body.Close()
+		t.Fatalf("GET %s = %v %s, want an event stream", target, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent, 100)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var event sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if event != (sseEvent{}) {
+					events <- event
+				}
+				event = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				event.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				event.id = line[len("id: "):]
+			case strings.HasPrefix(line, "event: "):
+				event.event = line[len("event: "):]
+			case strings.HasPrefix(line, "data: "):
+				event.data = line[len("data: "):]
+			}
+		}
+	}()
+	return resp, events
+}
+
+// nextEvent returns the next event from a stream, skipping comments
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("event stream ended")
+			}
+			if event.comment == "" {
+				return event
+			}
+		case <-timeout:
+			t.Fatal("no event within 5 seconds")
+		}
+	}
+}
+
+func TestIntegration_EventStream(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	quotes := server.URL + "/api/v1/quotes"
+	apiRequest(t, "POST", quotes, map[string]string{"text": "Said before anyone listened", "author": "Early", "category": "news"})
+
+	// Without Last-Event-ID only new events are sent
+	resp, events := openEventStream(t, server.URL+"/api/v1/events?category=news", "")
+	defer resp.Body.Close()
+
+	apiRequest(t, "POST", quotes, map[string]string{"text": "A quote about something else", "author": "Other", "category": "other"})
+	apiRequest(t, "PUT", quotes+"/1", map[string]string{"text": "Said before anyone listened, revised", "author": "Early", "category": "news"})
+	apiRequest(t, "DELETE", quotes+"/1", nil)
+
+	updated := nextEvent(t, events)
+	if updated.event != "quote.updated" || !strings.Contains(updated.data, "revised") {
+		t.Errorf("first event = %+v, want the update of the news quote", updated)
+	}
+	var payload models.Event
+	if err := json.Unmarshal([]byte(updated.data), &payload); err != nil || payload.Type != "quote.updated" || payload.Data.Quote.ID != 1 {
+		t.Errorf("event data = %s, %v", updated.data, err)
+	}
+	if event := nextEvent(t, events); event.event != "quote.deleted" {
+		t.Errorf("second event = %+v, want quote.deleted", event)
+	}
+	removed := nextEvent(t, events)
+	if removed.event != "category.changed" || !strings.Contains(removed.data, `"removed"`) {
+		t.Errorf("third event = %+v, want the news category removed", removed)
+	}
+
+	// Idle streams get heartbeats
+	timeout := time.After(5 * time.Second)
+	for heartbeat := false; !heartbeat; {
+		select {
+		case event := <-events:
+			heartbeat = event.comment == "heartbeat"
+		case <-timeout:
+			t.Fatal("no heartbeat on an idle stream")
+		}
+	}
+	resp.Body.Close()
+
+	// Resuming after the update replays what followed in every category
+	resp, events = openEventStream(t, server.URL+"/api/v1/events", updated.id)
+	defer resp.Body.Close()
+	for _, want := range []string{"quote.deleted", "category.changed"} {
+		if event := nextEvent(t, events); event.event != want {
+			t.Errorf("resumed event = %+v, want %s", event, want)
+		}
+	}
+	apiRequest(t, "POST", quotes, map[string]string{"text": "Arrived while the stream was open", "author": "Late", "category": "news"})
+	if event := nextEvent(t, events); event.event != "quote.created" || !strings.Contains(event.data, "Arrived while") {
+		t.Errorf("live event after resuming = %+v", event)
+	}
+	resp.Body.Close()
+
+	// A position the log does not have asks the client to start over
+	resp, events = openEventStream(t, server.URL+"/api/v1/events", "9999")
+	defer resp.Body.Close()
+	if event := nextEvent(t, events); event.event != "reset" {
+		t.Errorf("event for an unknown position = %+v, want reset", event)
+	}
+	resp.Body.Close()
+
+	bad, _ := apiRequest(t, "GET", server.URL+"/api/v1/events?last_event_id=abc", nil)
+	if bad.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET /api/v1/events with an invalid position status = %v, want %v", bad.StatusCode, http.StatusBadRequest)
+	}
+}
+
+// getChanges fetches a page of the changes feed
+func getChanges(t *testing.T, server *httptest.Server, query string) models.ChangeSet {
+	resp, body := apiRequest(t, "GET", server.URL+"/api/v1/changes"+query, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /api/v1/changes%s status = %v: %s", query, resp.StatusCode, body)
+	}
+	var page struct {
+		Data models.ChangeSet `json:"data"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatalf("GET /api/v1/changes%s returned invalid JSON: %v", query, err)
+	}
+	return page.Data
+}
+
+func TestIntegration_ChangesFeed(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	api := server.URL + "/api/v1/quotes"
+	for _, text := range []string{"Kept as written", "Revised later", "Removed later"} {
+		resp, body := apiRequest(t, "POST", api, map[string]string{"text": text, "author": "Syncer", "category": "sync"})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("POST /api/v1/quotes status = %v: %s", resp.StatusCode, body)
+		}
+	}
+
+	// A new client pages through everything
+	first := getChanges(t, server, "?limit=2")
+	if len(first.Changes) != 2 || !first.HasMore || first.FullResync {
+		t.Fatalf("first page = %+v, want 2 changes and more", first)
+	}
+	second := getChanges(t, server, "?limit=2&since="+first.Next)
+	if len(second.Changes) != 1 || second.HasMore || second.Changes[0].Quote == nil || second.Changes[0].Quote.Text != "Removed later" {
+		t.Fatalf("second page = %+v, want the last quote", second)
+	}
+
+	apiRequest(t, "PUT", api+"/2", map[string]string{"text": "Revised", "author": "Syncer", "category": "sync"})
+	apiRequest(t, "DELETE", api+"/3", nil)
+
+	changes := getChanges(t, server, "?since="+second.Next)
+	if len(changes.Changes) != 2 {
+		t.Fatalf("changes after edits = %+v, want 2", changes)
+	}
+	upsert, tombstone := changes.Changes[0], changes.Changes[1]
+	if upsert.Type != models.ChangeUpsert || upsert.ID != 2 || upsert.Quote == nil || upsert.Quote.Text != "Revised" || upsert.Quote.Version != 2 {
+		t.Errorf("first change = %+v, want the revised quote", upsert)
+	}
+	if tombstone.Type != models.ChangeDelete || tombstone.ID != 3 || tombstone.Quote != nil {
+		t.Errorf("second change = %+v, want a tombstone for quote 3", tombstone)
+	}
+	if tombstone.Sequence <= upsert.Sequence || changes.Next == second.Next {
+		t.Errorf("changes = %+v, want increasing sequences and a new token", changes)
+	}
+
+	if idle := getChanges(t, server, "?since="+changes.Next); len(idle.Changes) != 0 || idle.Next != changes.Next {
+		t.Errorf("changes when up to date = %+v, want none and the same token", idle)
+	}
+
+	// A token the server does not know asks for a full resync
+	resync := getChanges(t, server, "?since=1000")
+	if !resync.FullResync || len(resync.Changes) != 3 {
+		t.Errorf("changes for an unknown token = %+v, want a full resync", resync)
+	}
+
+	resp, _ := apiRequest(t, "GET", server.URL+"/api/v1/changes?since=yesterday", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET /api/v1/changes with an invalid token status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+// setupFollowerServer starts an instance replicating from leaderURL. It
+// syncs only when the test calls Sync on the returned replicator.
+func setupFollowerServer(t *testing.T, leaderURL, conflicts string) (*httptest.Server, *services.Replicator) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo := repository.NewQuoteRepository(db.DB())
+	replicator, err := services.NewReplicator(repository.NewReplicationRepository(db.DB()), repo, services.ReplicatorOptions{
+		Leader:    leaderURL,
+		Conflicts: conflicts,
+	})
+	if err != nil {
+		t.Fatalf("NewReplicator() error = %v", err)
+	}
+	service := services.NewQuoteService(repo)
+	service.SetReplica(replicator.Conflicts())
+	healthHandler := handlers.NewHealthHandler(db)
+	healthHandler.SetReplicator(replicator)
+
+	server := httptest.NewServer(router.NewRouter(router.Handlers{
+		Quote:   handlers.NewQuoteHandler(service),
+		Health:  healthHandler,
+		Changes: handlers.NewChangesHandler(services.NewChangesService(repository.NewChangeRepository(db.DB()), 0)),
+	}))
+	t.Cleanup(server.Close)
+
+	return server, replicator
+}
+
+func TestIntegration_Replication(t *testing.T) {
+	leader, db := setupTestServer(t)
+	defer leader.Close()
+	defer db.Close()
+
+	follower, replicator := setupFollowerServer(t, leader.URL, models.ConflictReject)
+
+	api := leader.URL + "/api/v1/quotes"
+	for _, text := range []string{"Written on the leader", "Edited on the leader"} {
+		resp, body := apiRequest(t, "POST", api, map[string]string{"text": text, "author": "Leader", "category": "replicated"})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("POST /api/v1/quotes status = %v: %s", resp.StatusCode, body)
+		}
+	}
+	apiRequest(t, "PUT", api+"/2", map[string]string{"text": "Edited on the leader, twice", "author": "Leader", "category": "replicated"})
+
+	if err := replicator.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if quote := getQuote(t, follower, 2); quote.Text != "Edited on the leader, twice" || quote.Version != 2 {
+		t.Errorf("follower quote 2 = %+v, want the leader's", quote)
+	}
+
+	resp, err := http.Get(follower.URL + "/health")
+	if err != nil {
+		t.Fatalf("GET /health failed: %v", err)
+	}
+	var health struct {
+		Status      string                    `json:"status"`
+		Replication *models.ReplicationStatus `json:"replication"`
+	}
+	json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if health.Status != "ok" || health.Replication == nil || health.Replication.State != models.ReplicationCaughtUp ||
+		health.Replication.LagSeconds == nil || health.Replication.Leader != leader.URL {
+		t.Errorf("follower health = %+v, want caught up with %s", health, leader.URL)
+	}
+
+	// Deletions reach the follower as tombstones
+	apiRequest(t, "DELETE", api+"/1", nil)
+	if err := replicator.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	resp, _ = apiRequest(t, "GET", follower.URL+"/api/v1/quotes/1", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET deleted quote on the follower status = %v, want %v", resp.StatusCode, http.StatusNotFound)
+	}
+
+	// The follower refuses local writes and serves its own changes feed
+	resp, body := apiRequest(t, "POST", follower.URL+"/api/v1/quotes", map[string]string{"text": "Local", "author": "Follower"})
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("POST on the follower status = %v, want %v: %s", resp.StatusCode, http.StatusConflict, body)
+	}
+	resp, _ = apiRequest(t, "PUT", follower.URL+"/api/v1/quotes/2", map[string]string{"text": "Local", "author": "Follower"})
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("PUT on the follower status = %v, want %v", resp.StatusCode, http.StatusConflict)
+	}
+	changes := getChanges(t, follower, "")
+	if len(changes.Changes) != 2 || changes.Changes[0].ID != 2 || changes.Changes[1].Type != models.ChangeDelete {
+		t.Errorf("follower changes = %+v, want quote 2 and the tombstone of quote 1", changes)
+	}
+
+	// A leader that cannot be reached leaves the follower's data in place
+	leader.Close()
+	if err := replicator.Sync(context.Background()); err == nil {
+		t.Error("Sync() with the leader down succeeded, want an error")
+	}
+	if status := replicator.Status(); status.LastError == "" {
+		t.Errorf("Status() = %+v, want the last error", status)
+	}
+	if quote := getQuote(t, follower, 2); quote.Version != 2 {
+		t.Errorf("follower quote 2 after a failed sync = %+v", quote)
+	}
+}
+
+// slackCommand sends a /quote command signed at signedAt and decodes the
+// Block Kit reply
+func slackCommand(t *testing.T, server *httptest.Server, text string, signedAt time.Time) (*http.Response, *slack.Message) {
+	t.Helper()
+	body := []byte(url.Values{"command": {"/quote"}, "text": {text}, "user_name": {"tester"}}.Encode())
+	req, _ := http.NewRequest("POST", server.URL+"/integrations/slack/command", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	slack.SignRequest(req, testSlackSecret, body, signedAt)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /integrations/slack/command failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	var message slack.Message
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		t.Fatalf("Slack reply is not JSON: %v", err)
+	}
+	return resp, &message
+}
+
+func TestIntegration_SlackCommand(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	now := time.Now()
+	if _, message := slackCommand(t, server, "", now); message == nil || message.Text != "There are no quotes yet" {
+		t.Errorf("/quote in an empty vault = %+v", message)
+	}
+
+	_, message := slackCommand(t, server, `add "Courage is grace under pressure" -- Ernest Hemingway`, now)
+	if message == nil || message.ResponseType != slack.Ephemeral || !strings.HasPrefix(message.Text, "Added quote #1") {
+		t.Fatalf("/quote add = %+v", message)
+	}
+	if quote := getQuote(t, server, 1); quote.Author != "Ernest Hemingway" || quote.Category != "general" {
+		t.Errorf("added quote = %+v", quote)
+	}
+	apiRequest(t, "POST", server.URL+"/api/v1/quotes", map[string]string{"text": "Know thyself", "author": "Socrates", "category": "wisdom"})
+
+	_, message = slackCommand(t, server, "wisdom", now)
+	if message == nil || message.ResponseType != slack.InChannel || message.Text != "“Know thyself” — Socrates" {
+		t.Errorf("/quote wisdom = %+v", message)
+	}
+	if _, message = slackCommand(t, server, "poetry", now); message == nil || message.Text != "There are no quotes in _poetry_ yet" {
+		t.Errorf("/quote of an empty category = %+v", message)
+	}
+
+	_, message = slackCommand(t, server, "search courage", now)
+	if message == nil || message.Text != "1 quote matches courage" || len(message.Blocks) != 4 {
+		t.Fatalf("/quote search = %+v", message)
+	}
+	if link := message.Blocks[3].Elements[0].Text; !strings.Contains(link, server.URL+"/q/1|#1") {
+		t.Errorf("search result link = %q", link)
+	}
+
+	if _, message = slackCommand(t, server, `add "No author given"`, now); message == nil || !strings.Contains(message.Text, "add \"The quote\" -- Author") {
+		t.Errorf("/quote add without an author = %+v", message)
+	}
+	if _, message = slackCommand(t, server, "help", now); message == nil || !strings.Contains(message.Text, "/quote search") {
+		t.Errorf("/quote help = %+v", message)
+	}
+
+	// Requests signed too long ago, or not by Slack, are refused
+	if resp, _ := slackCommand(t, server, "", now.Add(-10*time.Minute)); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("replayed request status = %d, want 401", resp.StatusCode)
+	}
+	resp, err := http.Post(server.URL+"/integrations/slack/command", "application/x-www-form-urlencoded", strings.NewReader("text=wisdom"))
+	if err != nil {
+		t.Fatalf("POST /integrations/slack/command failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unsigned request status = %d, want 401", resp.StatusCode)
+	}
+}
+
+// discordInteraction sends a signed interaction and decodes the response
+func discordInteraction(t *testing.T, server *httptest.Server, interaction string) (int, map[string]interface{}) {
+	t.Helper()
+	body := []byte(interaction)
+	req, _ := http.NewRequest("POST", server.URL+"/integrations/discord/interactions", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	discord.SignRequest(req, testDiscordKey, body, time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /integrations/discord/interactions failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&response)
+	return resp.StatusCode, response
+}
+
+func TestIntegration_DiscordInteractions(t *testing.T) {
+	server, db := setupTestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	if status, response := discordInteraction(t, server, `{"id":"1","type":1}`); status != http.StatusOK || response["type"] != float64(1) {
+		t.Errorf("PING = %d %v, want PONG", status, response)
+	}
+
+	// Discord refuses endpoints that accept unsigned requests
+	resp, err := http.Post(server.URL+"/integrations/discord/interactions", "application/json", strings.NewReader(`{"type":1}`))
+	if err != nil {
+		t.Fatalf("POST /integrations/discord/interactions failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unsigned PING status = %d, want 401", resp.StatusCode)
+	}
+
+	for _, q := range []map[string]string{
+		{"text": "Know thyself", "author": "Socrates", "category": "wisdom"},
+		{"text": "Wit is educated insolence", "author": "Aristotle", "category": "wit"},
+		{"text": "Art is long, life is short", "author": "Hippocrates", "category": "art"},
+	} {
+		apiRequest(t, "POST", server.URL+"/api/v1/quotes", q)
+	}
+
+	_, response := discordInteraction(t, server, `{"id":"2","type":2,"data":{"name":"quote","options":[{"name":"category","type":3,"value":"wisdom"}]}}`)
+	data, _ := response["data"].(map[string]interface{})
+	embeds, _ := data["embeds"].([]interface{})
+	if response["type"] != float64(4) || len(embeds) != 1 {
+		t.Fatalf("/quote category:wisdom = %v", response)
+	}
+	embed := embeds[0].(map[string]interface{})
+	if embed["description"] != "“Know thyself”" || embed["url"] != server.URL+"/q/1" || embed["author"].(map[string]interface{})["name"] != "Socrates" {
+		t.Errorf("quote embed = %v", embed)
+	}
+
+	if _, response = discordInteraction(t, server, `{"id":"3","type":2,"data":{"name":"quote"}}`); response["type"] != float64(4) {
+		t.Errorf("/quote = %v, want a quote", response)
+	}
+
+	_, response = discordInteraction(t, server, `{"id":"4","type":2,"data":{"name":"quote","options":[{"name":"category","type":3,"value":"poetry"}]}}`)
+	data, _ = response["data"].(map[string]interface{})
+	if data["content"] != "There are no quotes in *poetry* yet" || data["flags"] != float64(discord.FlagEphemeral) {
+		t.Errorf("/quote of an empty category = %v", response)
+	}
+
+	// User input is shown as typed and cannot ping anyone
+	_, response = discordInteraction(t, server, `{"id":"6","type":2,"data":{"name":"quote","options":[{"name":"category","type":3,"value":"**@everyone**"}]}}`)
+	data, _ = response["data"].(map[string]interface{})
+	if data["content"] != "There are no quotes in *\\*\\*@\u200beveryone\\*\\** yet" || fmt.Sprint(data["allowed_mentions"]) != "map[parse:[]]" {
+		t.Errorf("/quote of a category with markdown = %v", response)
+	}
+
+	_, response = discordInteraction(t, server, `{"id":"5","type":4,"data":{"name":"quote","options":[{"name":"category","type":3,"value":"w","focused":true}]}}`)
+	data, _ = response["data"].(map[string]interface{})
+	if response["type"] != float64(8) || fmt.Sprint(data["choices"]) != "[map[name:wisdom value:wisdom] map[name:wit value:wit]]" {
+		t.Errorf("category autocomplete = %v", response)
+	}
+
+	_, response = discordInteraction(t, server, `{"id":"6","type":2,"data":{"name":"weather"}}`)
+	if data, _ = response["data"].(map[string]interface{}); data["content"] != "Unknown command" {
+		t.Errorf("unknown command = %v", response)
+	}
+}
+
+// setupActivityPubServer starts a server publishing its quotes over
+// ActivityPub. Its base URL is only known once it listens, so it is set up
+// apart from setupTestServer.
+func setupActivityPubServer(t *testing.T) (*httptest.Server, *database.SQLiteDB) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(nil)
+	base := "http://" + server.Listener.Addr().String()
+
+	repo := repository.NewQuoteRepository(db.DB())
+	service := services.NewQuoteService(repo)
+	activityPubService, err := services.NewActivityPubService(repository.NewActivityPubRepository(db.DB()), repo, services.ActivityPubOptions{
+		BaseURL:      base,
+		RetryOptions: services.RetryOptions{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewActivityPubService() error = %v", err)
+	}
+	eventBus := services.NewEventBus(repository.NewOutboxRepository(db.DB()), services.EventBusOptions{
+		BaseDelay:    10 * time.Millisecond,
+		PollInterval: 50 * time.Millisecond,
+	})
+	if err := eventBus.Subscribe("activitypub", activityPubService); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	service.SetEventBus(eventBus)
+
+	server.Config.Handler = router.NewRouter(router.Handlers{
+		Quote:       handlers.NewQuoteHandler(service),
+		Health:      handlers.NewHealthHandler(db),
+		ActivityPub: handlers.NewActivityPubHandler(activityPubService),
+	})
+	server.Start()
+
+	ctx, stopWorker := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		activityPubService.Run(ctx)
+		close(workerDone)
+	}()
+	busDone := make(chan struct{})
+	go func() {
+		eventBus.Run(ctx)
+		close(busDone)
+	}()
+	t.Cleanup(func() {
+		stopWorker()
+		<-workerDone
+		<-busDone
+	})
+
+	return server, db
+}
+
+// fediverseAccount stands in for an account on another server: it serves
+// its actor document and keeps the activities posted to its inbox once
+// their signatures check out against the sender's published key
+type fediverseAccount struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	inbox  chan map[string]interface{}
+}
+
+func newFediverseAccount(t *testing.T) *fediverseAccount {
+	key, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	account := &fediverseAccount{key: key, inbox: make(chan map[string]interface{}, 10)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/ann", func(w http.ResponseWriter, r *http.Request) {
+		pem, _ := activitypub.EncodePublicKey(&key.PublicKey)
+		w.Header().Set("Content-Type", activitypub.ContentType)
+		json.NewEncoder(w).Encode(&activitypub.Actor{
+			ID:        account.id(),
+			Type:      "Person",
+			Inbox:     account.id() + "/inbox",
+			PublicKey: &activitypub.PublicKey{ID: account.id() + "#main-key", Owner: account.id(), PublicKeyPem: pem},
+		})
+	})
+	mux.HandleFunc("/users/ann/inbox", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifyActivitySignature(r, body); err != nil {
+			t.Errorf("inbox received a badly signed activity: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var activity map[string]interface{}
+		json.Unmarshal(body, &activity)
+		account.inbox <- activity
+		w.WriteHeader(http.StatusAccepted)
+	})
+	account.server = httptest.NewServer(mux)
+	t.Cleanup(account.server.Close)
+
+	return account
+}
+
+func (a *fediverseAccount) id() string {
+	return a.server.URL + "/users/ann"
+}
+
+// send posts an activity to an inbox, signed unless sign is false
+func (a *fediverseAccount) send(t *testing.T, inbox string, activity interface{}, sign bool) int {
+	body, _ := json.Marshal(activity)
+	req, _ := http.NewRequest(http.MethodPost, inbox, bytes.NewReader(body))
+	req.Header.Set("Content-Type", activitypub.ContentType)
+	if sign {
+		activitypub.SignRequest(req, a.id()+"#main-key", a.key, body, time.Now())
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", inbox, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// receive waits for the next activity delivered to the inbox
+func (a *fediverseAccount) receive(t *testing.T) map[string]interface{} {
+	t.Helper()
+	select {
+	case activity := <-a.inbox:
+		return activity
+	case <-time.After(5 * time.Second):
+		t.Fatal("no activity was delivered to the inbox")
+		return nil
+	}
+}
+
+// verifyActivitySignature checks a delivery against the key published in
+// its sender's actor document
+func verifyActivitySignature(r *http.Request, body []byte) error {
+	sig, err := activitypub.ParseSignature(r)
+	if err != nil {
+		return err
+	}
+	var actor activitypub.Actor
+	if err := getActivityJSON(strings.TrimSuffix(sig.KeyID, "#main-key"), &actor); err != nil {
+		return err
+	}
+	key, err := activitypub.DecodePublicKey(actor.PublicKey.PublicKeyPem)
+	if err != nil {
+		return err
+	}
+	return activitypub.VerifyRequest(r, sig, body, key, time.Now())
+}
+
+// getActivityJSON fetches an ActivityPub document
+func getActivityJSON(target string, v interface{}) error {
+	req, _ := http.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Accept", activitypub.ContentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s status = %d", target, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != activitypub.ContentType && ct != activitypub.WebFingerContentType {
+		return fmt.Errorf("GET %s Content-Type = %q", target, ct)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func TestIntegration_ActivityPub(t *testing.T) {
+	server, db := setupActivityPubServer(t)
+	defer server.Close()
+	defer db.Close()
+	ann := newFediverseAccount(t)
+
+	// Discover the vault actor the way a remote server would
+	host := strings.TrimPrefix(server.URL, "http://")
+	var jrd activitypub.JRD
+	if err := getActivityJSON(server.URL+"/.well-known/webfinger?resource=acct:quotes@"+host, &jrd); err != nil {
+		t.Fatalf("WebFinger lookup failed: %v", err)
+	}
+	if jrd.Subject != "acct:quotes@"+host || jrd.Links[0].Rel != "self" {
+		t.Fatalf("WebFinger = %+v", jrd)
+	}
+	var actor activitypub.Actor
+	if err := getActivityJSON(jrd.Links[0].Href, &actor); err != nil {
+		t.Fatalf("actor lookup failed: %v", err)
+	}
+	if actor.Inbox != server.URL+"/ap/actors/quotes/inbox" || actor.PublicKey == nil {
+		t.Fatalf("actor = %+v", actor)
+	}
+
+	// Unsigned activities are refused
+	follow := map[string]string{"id": ann.id() + "/follows/1", "type": "Follow", "actor": ann.id(), "object": actor.ID}
+	if status := ann.send(t, actor.Inbox, follow, false); status != http.StatusUnauthorized {
+		t.Errorf("unsigned Follow status = %d, want 401", status)
+	}
+
+	if status := ann.send(t, actor.Inbox, follow, true); status != http.StatusAccepted {
+		t.Fatalf("Follow status = %d, want 202", status)
+	}
+	if accept := ann.receive(t); accept["type"] != "Accept" || accept["actor"] != actor.ID {
+		t.Errorf("after Follow the inbox received %v, want an Accept", accept)
+	}
+
+	resp, _ := apiRequest(t, "POST", server.URL+"/api/v1/quotes", map[string]string{"text": "Know thyself", "author": "Socrates", "category": "wisdom"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /api/v1/quotes status = %d", resp.StatusCode)
+	}
+	create := ann.receive(t)
+	note, _ := create["object"].(map[string]interface{})
+	if create["type"] != "Create" || note["id"] != actor.ID+"/notes/1" {
+		t.Fatalf("after a new quote the inbox received %v, want a Create of its note", create)
+	}
+
+	var fetched activitypub.Note
+	if err := getActivityJSON(actor.ID+"/notes/1", &fetched); err != nil || fetched.Content != note["content"] {
+		t.Errorf("note = %+v, %v", fetched, err)
+	}
+	var page activitypub.OrderedCollectionPage
+	if err := getActivityJSON(actor.Outbox+"?page=1", &page); err != nil || page.TotalItems != 1 || len(page.OrderedItems) != 1 {
+		t.Errorf("outbox page = %+v, %v", page, err)
+	}
+
+	// Browsers following a link to the actor land on its web page
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	req, _ := http.NewRequest(http.MethodGet, actor.ID, nil)
+	req.Header.Set("Accept", "text/html")
+	if resp, err := noRedirect.Do(req); err != nil || resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != server.URL+"/ui/" {
+		t.Errorf("actor page redirect = %v, %v", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+
+	undo := map[string]interface{}{"id": ann.id() + "/follows/1/undo", "type": "Undo", "actor": ann.id(), "object": follow}
+	if status := ann.send(t, actor.Inbox, undo, true); status != http.StatusAccepted {
+		t.Fatalf("Undo status = %d, want 202", status)
+	}
+	var followers activitypub.OrderedCollection
+	if err := getActivityJSON(actor.Followers, &followers); err != nil || followers.TotalItems != 0 {
+		t.Errorf("followers after Undo = %+v, %v", followers, err)
+	}
+
+	if err := getActivityJSON(server.URL+"/.well-known/webfinger?resource=acct:poetry@"+host, &jrd); err == nil {
+		t.Error("WebFinger of an unknown actor succeeded")
+	}
+}
+
+// setupDigestServer starts a server offering email digests, sent to an
+// in-process SMTP server
+func setupDigestServer(t *testing.T) (*httptest.Server, *database.SQLiteDB, *mailtest.Server) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	smtpServer := mailtest.NewServer()
+	t.Cleanup(smtpServer.Close)
+
+	server := httptest.NewUnstartedServer(nil)
+	base := "http://" + server.Listener.Addr().String()
+
+	repo := repository.NewQuoteRepository(db.DB())
+	sender := mail.NewSMTPSender(mail.SMTPConfig{Host: smtpServer.Host, Port: smtpServer.Port})
+	digestService, err := services.NewDigestService(repository.NewDigestRepository(db.DB()), repo, sender, services.DigestOptions{
+		BaseURL: base,
+		From:    "Quote Vault <quotes@example.com>",
+	})
+	if err != nil {
+		t.Fatalf("NewDigestService() error = %v", err)
+	}
+
+	server.Config.Handler = router.NewRouter(router.Handlers{
+		Quote:  handlers.NewQuoteHandler(services.NewQuoteService(repo)),
+		Health: handlers.NewHealthHandler(db),
+		Digest: handlers.NewDigestHandler(digestService, base),
+	})
+	server.Start()
+
+	return server, db, smtpServer
+}
+
+// getPage fetches a page and returns its status and body
+func getPage(t *testing.T, method, target string) (int, string) {
+	req, _ := http.NewRequest(method, target, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("%s %s Content-Type = %q, want HTML", method, target, ct)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestIntegration_EmailDigests(t *testing.T) {
+	server, db, smtpServer := setupDigestServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	resp, _ := apiRequest(t, http.MethodPost, server.URL+"/api/v1/digests", map[string]interface{}{
+		"email": "not an address",
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("subscribing an invalid address status = %d, want 400", resp.StatusCode)
+	}
+
+	resp, _ = apiRequest(t, http.MethodPost, server.URL+"/api/v1/digests", map[string]interface{}{
+		"email":      "ann@example.com",
+		"frequency":  "weekly",
+		"categories": []string{"Wisdom"},
+	})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("subscribe status = %d, want 202", resp.StatusCode)
+	}
+
+	received, err := smtpServer.Wait(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, err := received[0].Parse()
+	if err != nil {
+		t.Fatalf("failed to parse confirmation email: %v", err)
+	}
+	confirmURL := regexp.MustCompile(regexp.QuoteMeta(server.URL) + `/digests/confirm\?token=\S+`).FindString(email.Text)
+	if confirmURL == "" {
+		t.Fatalf("confirmation email has no confirm link:\n%s", email.Text)
+	}
+	if !strings.Contains(email.HTML, "Confirm subscription") {
+		t.Errorf("confirmation email HTML:\n%s", email.HTML)
+	}
+
+	if status, _ := getPage(t, http.MethodGet, server.URL+"/digests/confirm?token=wrong"); status != http.StatusNotFound {
+		t.Errorf("confirming with a wrong token status = %d, want 404", status)
+	}
+	status, body := getPage(t, http.MethodGet, confirmURL)
+	if status != http.StatusOK || !strings.Contains(body, "Subscription confirmed") || !strings.Contains(body, "weekly") {
+		t.Errorf("confirm page status = %d:\n%s", status, body)
+	}
+
+	// Unsubscribe links come in digests; take the token from the database
+	var token string
+	if err := db.DB().QueryRow(`SELECT unsubscribe_token FROM digest_subscriptions`).Scan(&token); err != nil {
+		t.Fatalf("failed to read unsubscribe token: %v", err)
+	}
+	unsubscribeURL := server.URL + "/digests/unsubscribe?token=" + url.QueryEscape(token)
+
+	// Fetching the link only asks for confirmation
+	status, body = getPage(t, http.MethodGet, unsubscribeURL)
+	if status != http.StatusOK || !strings.Contains(body, `<form method="post"`) {
+		t.Errorf("unsubscribe page status = %d:\n%s", status, body)
+	}
+	status, body = getPage(t, http.MethodPost, unsubscribeURL)
+	if status != http.StatusOK || !strings.Contains(body, "Unsubscribed") {
+		t.Errorf("unsubscribe status = %d:\n%s", status, body)
+	}
+	if status, _ := getPage(t, http.MethodPost, unsubscribeURL); status != http.StatusNotFound {
+		t.Errorf("unsubscribing twice status = %d, want 404", status)
+	}
+}
+
+// setupSavedSearchServer starts a server whose saved searches are matched
+// against quotes as they are created through the API
+func setupSavedSearchServer(t *testing.T) (*httptest.Server, *database.SQLiteDB) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+
+	repo := repository.NewQuoteRepository(db.DB())
+	service := services.NewQuoteService(repo)
+	searchService, err := services.NewSavedSearchService(repository.NewSavedSearchRepository(db.DB()), nil, services.SavedSearchOptions{
+		RetryOptions: services.RetryOptions{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewSavedSearchService() error = %v", err)
+	}
+	eventBus := services.NewEventBus(repository.NewOutboxRepository(db.DB()), services.EventBusOptions{
+		BaseDelay:    10 * time.Millisecond,
+		PollInterval: 50 * time.Millisecond,
+	})
+	if err := eventBus.Subscribe("saved_searches", searchService); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	service.SetEventBus(eventBus)
+
+	server := httptest.NewServer(router.NewRouter(router.Handlers{
+		Quote:       handlers.NewQuoteHandler(service),
+		Health:      handlers.NewHealthHandler(db),
+		SavedSearch: handlers.NewSavedSearchHandler(searchService, ""),
+	}))
+
+	ctx, stopWorker := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		searchService.Run(ctx)
+		close(workerDone)
+	}()
+	busDone := make(chan struct{})
+	go func() {
+		eventBus.Run(ctx)
+		close(busDone)
+	}()
+	t.Cleanup(func() {
+		stopWorker()
+		<-workerDone
+		<-busDone
+	})
+
+	return server, db
+}
+
+func TestIntegration_SavedSearches(t *testing.T) {
+	server, db := setupSavedSearchServer(t)
+	defer server.Close()
+	defer db.Close()
+
+	notifications := make(chan []byte, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		notifications <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	resp, _ := apiRequest(t, http.MethodPost, server.URL+"/api/v1/searches", map[string]interface{}{
+		"name": "Stoic time", "category": "Philosophy", "email": "ann@example.com",
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("saving an email search without a mail server status = %d, want 400", resp.StatusCode)
+	}
+
+	resp, data := apiRequest(t, http.MethodPost, server.URL+"/api/v1/searches", map[string]interface{}{
+		"name": "Stoic time", "category": "Philosophy", "query": "time", "webhook_url": receiver.URL,
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("save search status = %d: %s", resp.StatusCode, data)
+	}
+	var created struct {
+		Data struct {
+			models.SavedSearch
+			UnsubscribeURL string `json:"unsubscribe_url"`
+		} `json:"data"`
+	}
+	json.Unmarshal(data, &created)
+	if created.Data.Secret == "" || created.Data.UnsubscribeURL == "" {
+		t.Fatalf("creating a webhook search should return its secret and unsubscribe link: %s", data)
+	}
+	// Without BASE_URL the link is built from the request
+	if !strings.HasPrefix(created.Data.UnsubscribeURL, server.URL+"/searches/unsubscribe?token=") {
+		t.Errorf("unsubscribe_url = %q, want an absolute link on %s", created.Data.UnsubscribeURL, server.URL)
+	}
+	unsubscribe, _ := url.Parse(created.Data.UnsubscribeURL)
+	token := unsubscribe.Query().Get("token")
+	matchesURL := fmt.Sprintf("%s/api/v1/searches/%d/matches", server.URL, created.Data.ID)
+
+	for _, quote := range []map[string]string{
+		{"text": "It is not that we have a short time to live, but that we waste a lot of it.", "author": "Seneca", "category": "Philosophy"},
+		{"text": "Time flies like an arrow.", "author": "Groucho Marx", "category": "Humor"},
+	} {
+		if resp, data := apiRequest(t, http.MethodPost, server.URL+"/api/v1/quotes", quote); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create quote status = %d: %s", resp.StatusCode, data)
+		}
+	}
+
+	select {
+	case body := <-notifications:
+		if !strings.Contains(string(body), `"search.matched"`) || !strings.Contains(string(body), "Seneca") {
+			t.Errorf("notification = %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification was posted to the webhook")
+	}
+
+	// The history lists the match once its notification went out
+	var matches struct {
+		Data []models.SearchMatch `json:"data"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, data = apiRequest(t, http.MethodGet, matchesURL+"?token="+url.QueryEscape(token), nil)
+		json.Unmarshal(data, &matches)
+		if len(matches.Data) == 1 && matches.Data[0].Status == models.DeliverySucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("matches = %s, want one notified match", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if matches.Data[0].Quote.Author != "Seneca" {
+		t.Errorf("matched quote = %+v", matches.Data[0].Quote)
+	}
+
+	_, data = apiRequest(t, http.MethodGet, server.URL+"/api/v1/searches", nil)
+	if strings.Contains(string(data), created.Data.Secret) || strings.Contains(string(data), token) || strings.Contains(string(data), receiver.URL) {
+		t.Error("listing saved searches exposes the webhook URL, the signing secret or the unsubscribe token")
+	}
+
+	// Without its token a search can be neither read nor removed
+	if resp, _ := apiRequest(t, http.MethodGet, matchesURL, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("matches without a token status = %d, want 404", resp.StatusCode)
+	}
+	if resp, _ := apiRequest(t, http.MethodDelete, fmt.Sprintf("%s/api/v1/searches/%d", server.URL, created.Data.ID), nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("delete by ID status = %d, want 404", resp.StatusCode)
+	}
+
+	if status, page := getPage(t, http.MethodGet, created.Data.UnsubscribeURL); status != http.StatusOK || !strings.Contains(page, receiver.URL) ||
+		!strings.Contains(page, `action="`+server.URL+"/searches/unsubscribe?token=") {
+		t.Errorf("unsubscribe page status = %d, want 200 naming the webhook and posting to an absolute link:\n%s", status, page)
+	}
+	if status, _ := getPage(t, http.MethodPost, created.Data.UnsubscribeURL); status != http.StatusOK {
+		t.Errorf("unsubscribe status = %d, want 200", status)
+	}
+	if resp, _ := apiRequest(t, http.MethodGet, matchesURL+"?token="+url.QueryEscape(token), nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("matches of a removed search status = %d, want 404", resp.StatusCode)
+	}
+}
@@ -58,6 +58,25 @@ func ErrorResponse(w http.ResponseWriter, status int, message string) {
 	}
 }
 
+// ErrorResponseWithData writes an error response that also carries data,
+// such as what an operation that failed part way had already done
+func ErrorResponseWithData(w http.ResponseWriter, status int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	response := Response{
+		Success:   false,
+		Data:      data,
+		Error:     message,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Status:    status,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+}
+
 func WriteJSONResponse(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
@@ -66,4 +66,25 @@ func (v *QuoteValidator) validateCategory(category string) error {
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// ValidateSource checks the optional source title of a quote
+func (v *QuoteValidator) ValidateSource(source string) error {
+	if utf8.RuneCountInString(strings.TrimSpace(source)) > 200 {
+		return errors.New("source cannot exceed 200 characters")
+	}
+	return nil
+}
+
+// ValidateTags checks the optional tags of a quote
+func (v *QuoteValidator) ValidateTags(tags []string) error {
+	if len(tags) > 20 {
+		return errors.New("a quote cannot have more than 20 tags")
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > 50 {
+			return errors.New("tags cannot exceed 50 characters")
+		}
+	}
+	return nil
+}
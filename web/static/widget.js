@@ -0,0 +1,137 @@
+/*
+ * Quote Vault embeddable widget.
+ *
+ * Add an element with the quote-vault class where the quote should appear
+ * and load this script once per page:
+ *
+ *   <div class="quote-vault" data-category="wisdom" data-theme="dark" data-refresh="60"></div>
+ *   <script async src="https://quotes.example.com/embed/widget.js"></script>
+ *
+ * Options, as data attributes on the element:
+ *   data-id       show this quote instead of a random one
+ *   data-category pick random quotes from one category
+ *   data-theme    light (default), dark or minimal
+ *   data-refresh  seconds between new random quotes, at least 10; off by default
+ *
+ * Quotes are rendered inside a shadow root so the host page's styles do not
+ * leak in. Any content already in the element, such as the blockquote from
+ * an oEmbed response, is shown until the quote has loaded.
+ */
+(function () {
+  "use strict";
+
+  var script = document.currentScript;
+  if (!script) {
+    var scripts = document.querySelectorAll("script[src*='/embed/widget.js']");
+    script = scripts[scripts.length - 1];
+  }
+  var origin = new URL(script.src, location.href).origin;
+
+  var MIN_REFRESH = 10;
+
+  var THEMES = {
+    light: { background: "#ffffff", border: "1px solid #d0d7de", text: "#1f2328", author: "#57606a", accent: "#0969da" },
+    dark: { background: "#0d1117", border: "1px solid #30363d", text: "#e6edf3", author: "#8b949e", accent: "#58a6ff" },
+    minimal: { background: "transparent", border: "none", text: "inherit", author: "inherit", accent: "transparent" }
+  };
+
+  function styles(theme) {
+    return (
+      ":host { display: block; }" +
+      "figure { margin: 0; padding: 1.25em 1.5em; border-radius: 8px;" +
+      " background: " + theme.background + "; border: " + theme.border + "; color: " + theme.text + ";" +
+      " font-family: Georgia, 'Times New Roman', serif; }" +
+      "blockquote { margin: 0; font-size: 1.25em; line-height: 1.4;" +
+      " border-left: 3px solid " + theme.accent + "; padding-left: 0.75em; }" +
+      "figcaption { margin-top: 0.75em; text-align: right; font-style: italic; color: " + theme.author + "; }" +
+      "a { color: inherit; text-decoration: none; }" +
+      "a:hover { text-decoration: underline; }" +
+      ".error { font-family: sans-serif; font-size: 0.9em; opacity: 0.7; }"
+    );
+  }
+
+  function quoteURL(options) {
+    if (options.id) {
+      return origin + "/api/v1/quotes/" + encodeURIComponent(options.id);
+    }
+    var url = origin + "/api/v1/quotes/random";
+    if (options.category) {
+      url += "?category=" + encodeURIComponent(options.category);
+    }
+    return url;
+  }
+
+  function render(root, theme, quote) {
+    var figure = document.createElement("figure");
+    var blockquote = document.createElement("blockquote");
+    blockquote.textContent = "“" + quote.text + "”";
+    figure.appendChild(blockquote);
+
+    var caption = document.createElement("figcaption");
+    var link = document.createElement("a");
+    link.href = origin + "/q/" + quote.id;
+    link.target = "_blank";
+    link.rel = "noopener";
+    link.textContent = "— " + quote.author + (quote.source ? ", " + quote.source : "");
+    caption.appendChild(link);
+    figure.appendChild(caption);
+
+    var style = document.createElement("style");
+    style.textContent = styles(theme);
+    root.replaceChildren(style, figure);
+  }
+
+  function load(element) {
+    if (element.dataset.quoteVaultLoaded) {
+      return;
+    }
+    element.dataset.quoteVaultLoaded = "true";
+
+    var options = {
+      id: element.dataset.id,
+      category: element.dataset.category,
+      refresh: parseInt(element.dataset.refresh, 10)
+    };
+    var theme = THEMES[element.dataset.theme] || THEMES.light;
+    var root = null;
+
+    function show() {
+      // Random quotes are cached by the API for a few minutes; always ask
+      // for a fresh one
+      fetch(quoteURL(options), { headers: { Accept: "application/json" }, cache: "no-store" })
+        .then(function (response) {
+          if (!response.ok) {
+            throw new Error("HTTP " + response.status);
+          }
+          return response.json();
+        })
+        .then(function (body) {
+          root = root || element.attachShadow({ mode: "open" });
+          render(root, theme, body.data);
+        })
+        .catch(function (err) {
+          if (window.console) {
+            console.warn("quote-vault: could not load quote:", err);
+          }
+        });
+    }
+
+    show();
+    if (!options.id && options.refresh > 0) {
+      setInterval(show, Math.max(options.refresh, MIN_REFRESH) * 1000);
+    }
+  }
+
+  function loadAll() {
+    var elements = document.querySelectorAll(".quote-vault");
+    for (var i = 0; i < elements.length; i++) {
+      load(elements[i]);
+    }
+  }
+
+  if (document.readyState === "loading") {
+    document.addEventListener("DOMContentLoaded", loadAll);
+  } else {
+    loadAll();
+  }
+})();
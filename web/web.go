@@ -0,0 +1,17 @@
+// Package web holds the static files and page templates served to
+// browsers and the templates of emails, embedded in the binary so the
+// service ships as a single file.
+package web
+
+import "embed"
+
+// Static holds the files under static/, served as-is
+//
+//go:embed static
+var Static embed.FS
+
+// Templates holds the html/template page templates under templates/, and
+// the HTML and plain-text email templates under templates/email/
+//
+//go:embed templates
+var Templates embed.FS